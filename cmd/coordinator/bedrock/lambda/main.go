@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
-	"os"
+	"time"
+
 	"pantryagent"
-	"pantryagent/coordinator/bedrock"
+	"pantryagent/lambdaevent"
+	"pantryagent/mcp"
+	"pantryagent/planner"
 	"pantryagent/tools"
 	"pantryagent/tools/storage"
 
@@ -16,162 +20,121 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/joeshaw/envdecode"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
-type Params struct {
-	Task string `json:"task"`
+// S3Config locates the pantry and recipe artifacts in S3.
+type S3Config struct {
+	Bucket     string `env:"ARTIFACTS_S3_BUCKET,required"`
+	PantryKey  string `env:"ARTIFACTS_PANTRY_S3_KEY,required"`
+	RecipesKey string `env:"ARTIFACTS_RECIPES_S3_KEY,required"`
+	// CacheTTL is how long warm invocations reuse the artifacts before revalidating them with S3.
+	CacheTTL time.Duration `env:"ARTIFACTS_CACHE_TTL,default=1m"`
+}
+
+// EventConfig configures the event source adapters, see lambdaevent.
+type EventConfig struct {
+	// ScheduleTask plans EventBridge events naming no task, e.g. a weekly schedule.
+	ScheduleTask string `env:"LAMBDA_SCHEDULE_TASK"`
 }
 
-type Results struct {
-	Output any `json:"output"`
+// handler holds everything that survives warm invocations: the Bedrock planner backend over the tool
+// registry and cached S3 state, and the OTel providers.
+type handler struct {
+	backend        planner.Backend
+	tracerProvider *sdktrace.TracerProvider
+	meterProvider  *sdkmetric.MeterProvider
 }
 
 func main() {
-	fn := func(ctx context.Context, params Params) (Results, error) {
-		var modelConfig pantryagent.ModelConfig
-		if err := envdecode.Decode(&modelConfig); err != nil {
-			log.Fatalf("Failed to decode: %s", err)
-		}
-
-		var agentConfig pantryagent.AgentConfig
-		if err := envdecode.Decode(&agentConfig); err != nil {
-			log.Fatalf("Failed to decode: %s", err)
-		}
-
-		// S3 config from env
-		s3Bucket := os.Getenv("ARTIFACTS_S3_BUCKET")
-		pantryKey := os.Getenv("ARTIFACTS_PANTRY_S3_KEY")
-		recipesKey := os.Getenv("ARTIFACTS_RECIPES_S3_KEY")
-		if s3Bucket == "" || pantryKey == "" || recipesKey == "" {
-			return Results{}, fmt.Errorf("missing S3 config: ARTIFACTS_S3_BUCKET, ARTIFACTS_PANTRY_S3_KEY, ARTIFACTS_RECIPES_S3_KEY must be set")
-		}
-
-		awsCfg, err := config.LoadDefaultConfig(ctx)
-		if err != nil {
-			return Results{}, fmt.Errorf("failed to load AWS config: %w", err)
-		}
-		s3Client := s3.NewFromConfig(awsCfg)
-
-		ps := storage.NewS3PantryState(s3Client, s3Bucket, pantryKey)
-		rs := storage.NewS3RecipeState(s3Client, s3Bucket, recipesKey)
-		registry, err := tools.NewRegistry(ps, rs)
-		if err != nil {
-			slog.Error("SETUP: Failed to create tool registry", "error", err)
-			return Results{}, err
-		}
-		slog.Info("SETUP: S3 pantry and recipe state initialized")
-
-		// Use helpers to decode pantry and recipe data
-		pantryData, err := loadPantryData(ps)
-		if err != nil {
-			slog.Error("SETUP: Failed to load pantry data from S3", "error", err)
-			return Results{}, err
-		}
-		slog.Info("SETUP: Pantry data loaded from S3",
-			"ingredients_count", func() int {
-				if ingredients, ok := pantryData["ingredients"].([]any); ok {
-					return len(ingredients)
-				}
-				return 0
-			}())
-
-		recipeData, err := loadRecipeData(rs)
-		if err != nil {
-			slog.Error("SETUP: Failed to load recipe data from S3", "error", err)
-			return Results{}, err
-		}
-		slog.Info("SETUP: Recipe data loaded from S3", "recipes_count", len(recipeData))
-
-		coordinationLogger := pantryagent.NewStdoutCoordinationLogger()
-
-		brc, err := newBedrockRuntimeClient(ctx)
-		if err != nil {
-			slog.Error("SETUP: Failed to create Bedrock client", "error", err)
-			return Results{}, err
-		}
-
-		opts := bedrock.LLMOptions{
-			ModelID:   modelConfig.ModelID,
-			MaxTokens: modelConfig.MaxTokens,
-			TopP:      modelConfig.TopP,
-		}
-
-		llm := bedrock.NewLLMClient(brc, opts)
-
-		tracerProvider, meterProvider, otelShutdown, err := pantryagent.InitOtel(ctx)
-		if err != nil {
-			slog.Error("SETUP: Failed to initialize OpenTelemetry", "error", err)
-			return Results{}, err
-		}
-		_ = meterProvider // TODO: Use meterProvider as needed
-		defer func() {
-			if err := otelShutdown(ctx); err != nil {
-				slog.Error("SETUP: Failed to shutdown OpenTelemetry", "error", err)
-			}
-		}()
-
-		output, err := bedrock.NewCoordinator(
-			llm,
-			registry,
-			pantryData,
-			recipeData,
-			agentConfig.MaxIterations,
-			coordinationLogger,
-			tracerProvider).Run(ctx, params.Task)
-		if err != nil {
-			slog.Error("RESULT: Error handling task", "error", err)
-			return Results{}, err
-		}
-
-		return Results{Output: output}, nil
+	// Cold start: build the clients once; Lambda reuses them for every invocation of this instance
+	h, err := newHandler(context.Background())
+	if err != nil {
+		log.Fatalf("SETUP: %s", err)
 	}
-
-	lambda.Start(fn)
+	var eventConfig EventConfig
+	if err := envdecode.Decode(&eventConfig); err != nil {
+		log.Fatalf("SETUP: Failed to decode: %s", err)
+	}
+	// Direct invocations, HTTP requests, SQS batches and EventBridge events are all planned by h.plan
+	lambda.Start(lambdaevent.NewHandler(h.plan, lambdaevent.Options{
+		DefaultTask:  planner.DefaultTask,
+		ScheduleTask: eventConfig.ScheduleTask,
+	}))
 }
 
-func newBedrockRuntimeClient(ctx context.Context) (*bedrockruntime.Client, error) {
+func newHandler(ctx context.Context) (*handler, error) {
+	var modelConfig pantryagent.ModelConfig
+	if err := envdecode.Decode(&modelConfig); err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+	var agentConfig pantryagent.AgentConfig
+	if err := envdecode.Decode(&agentConfig); err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+	var s3Config S3Config
+	if err := envdecode.Decode(&s3Config); err != nil {
+		return nil, fmt.Errorf("missing S3 config: %w", err)
+	}
+
 	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRetryMaxAttempts(5))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	s3Client := s3.NewFromConfig(awsCfg)
+
+	pantry := storage.NewCachedPantryState(storage.NewS3PantryState(s3Client, s3Config.Bucket, s3Config.PantryKey), s3Config.CacheTTL)
+	recipes := storage.NewCachedState(storage.NewS3RecipeState(s3Client, s3Config.Bucket, s3Config.RecipesKey), s3Config.CacheTTL)
+	registry, err := tools.NewRegistry(pantry, recipes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tool registry: %w", err)
+	}
+	slog.Info("SETUP: S3 pantry and recipe state initialized", "cache_ttl", s3Config.CacheTTL)
+
+	// MCP connections stay open for the lifetime of the instance
+	if _, err := mcp.AddServers(ctx, registry, agentConfig.MCPServers); err != nil {
+		return nil, fmt.Errorf("failed to add MCP server tools: %w", err)
 	}
-	return bedrockruntime.NewFromConfig(awsCfg), nil
-}
 
-func loadPantryData(ps storage.PantryState) (map[string]any, error) {
-	pantryTool := tools.NewPantryGet(ps)
-	result, err := pantryTool.Run(context.Background(), map[string]any{"current_day": 0})
+	promptRegistry, promptVars, err := agentConfig.Prompts()
 	if err != nil {
-		return nil, fmt.Errorf("failed to load pantry: %w", err)
+		return nil, fmt.Errorf("failed to load prompts: %w", err)
 	}
 
-	// Extract the inner pantry data from the tool result
-	if pantryData, ok := result["pantry"].(map[string]any); ok {
-		return pantryData, nil
+	// The providers are flushed after each invocation rather than shut down, since a frozen instance
+	// may be thawed for the next one
+	h := &handler{}
+	if h.tracerProvider, h.meterProvider, _, err = pantryagent.InitOtel(ctx); err != nil {
+		return nil, fmt.Errorf("failed to initialize OpenTelemetry: %w", err)
 	}
 
-	return nil, fmt.Errorf("invalid pantry structure: missing 'pantry' key in result")
+	h.backend = planner.NewBedrock(planner.Deps{
+		Registry:       registry,
+		Model:          modelConfig,
+		Agent:          agentConfig,
+		Prompts:        promptRegistry,
+		PromptVars:     promptVars,
+		TracerProvider: h.tracerProvider,
+	}, bedrockruntime.NewFromConfig(awsCfg))
+	return h, nil
 }
 
-func loadRecipeData(rs storage.RecipeState) ([]any, error) {
-	recipeTool := tools.NewRecipeGet(rs)
-	result, err := recipeTool.Run(context.Background(), map[string]any{"meal_types": []string{"dinner"}})
+// plan runs the Bedrock planner backend on task.
+func (h *handler) plan(ctx context.Context, task string) (string, error) {
+	defer h.flush(ctx)
+
+	output, err := h.backend.Plan(ctx, planner.Run{Task: task, Logger: pantryagent.NewStdoutCoordinationLogger()})
 	if err != nil {
-		return nil, fmt.Errorf("failed to load recipes: %w", err)
-	}
-	recipesRaw, ok := result["recipes"]
-	if !ok {
-		return nil, fmt.Errorf("no 'recipes' key in result")
+		slog.Error("RESULT: Error handling task", "error", err)
+		return "", err
 	}
-	// Handle both []any and []map[string]any types
-	if recipes, ok := recipesRaw.([]any); ok {
-		return recipes, nil
-	} else if recipesMap, ok := recipesRaw.([]map[string]any); ok {
-		// Convert []map[string]any to []any
-		recipes := make([]any, len(recipesMap))
-		for i, r := range recipesMap {
-			recipes[i] = r
-		}
-		return recipes, nil
+	return output, nil
+}
+
+// flush exports the invocation's telemetry before Lambda freezes the instance.
+func (h *handler) flush(ctx context.Context) {
+	if err := errors.Join(h.tracerProvider.ForceFlush(ctx), h.meterProvider.ForceFlush(ctx)); err != nil {
+		slog.Error("SETUP: Failed to flush OpenTelemetry", "error", err)
 	}
-	return nil, fmt.Errorf("invalid recipes data format: expected []any or []map[string]any, got %T", recipesRaw)
 }
@@ -0,0 +1,101 @@
+// Package cli is the pantry command line. Besides cmd/pantry, the commands that predate it (cmd/server,
+// cmd/migrate and cmd/sqlite-import) run one of its subcommands, so existing builds and deployments keep
+// working.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// command is a subcommand of pantry.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, s *settings, args []string) error
+}
+
+var commands = []command{
+	{"plan", "plan meals on a backend", runPlan},
+	{"pantry", "list the pantry, or record added and consumed ingredients", runPantry},
+	{"recipes", "list, show or validate the recipe catalog", runRecipes},
+	{"logs", "show a coordination log", runLogs},
+	{"history", "list the latest plans kept in the SQLite storage", runHistory},
+	{"eval", "run evaluation cases on a backend and check their plans", runEval},
+	{"serve", "serve the HTTP API", runServe},
+	{"migrate", "upgrade the stored pantry and recipes to the current format", runMigrate},
+	{"import", "import the pantry and recipe files into the SQLite database", runImport},
+}
+
+// errUsage reports a command line mistake; the usage has been printed.
+var errUsage = errors.New("usage")
+
+// Main runs the command of args, e.g. "plan -backend ollama", until it ends or the process is interrupted,
+// and exits with 2 on usage errors and 1 on failures.
+func Main(args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, args)
+	switch {
+	case err == nil:
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "pantry: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		usage()
+		return errUsage
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			s, err := newSettings()
+			if err != nil {
+				return err
+			}
+			return c.run(ctx, s, args[1:])
+		}
+	}
+	if args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+		fmt.Fprintf(os.Stderr, "pantry: unknown command %q\n", args[0])
+	}
+	usage()
+	return errUsage
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: pantry <command> [flags] [args]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun pantry <command> -h for the flags of a command.\n")
+}
+
+// dispatch runs the subcommand of parent named by the first of args, e.g. "list".
+func dispatch(ctx context.Context, s *settings, parent string, subs []command, args []string) error {
+	if len(args) > 0 {
+		for _, c := range subs {
+			if c.name == args[0] {
+				return c.run(ctx, s, args[1:])
+			}
+		}
+		if args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+			fmt.Fprintf(os.Stderr, "pantry %s: unknown subcommand %q\n", parent, args[0])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Usage: pantry %s <subcommand> [flags] [args]\n\nSubcommands:\n", parent)
+	for _, c := range subs {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
+	}
+	return errUsage
+}
@@ -0,0 +1,290 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/joeshaw/envdecode"
+
+	"pantryagent"
+	"pantryagent/mcp"
+	"pantryagent/planner"
+	"pantryagent/tools"
+	"pantryagent/tools/storage"
+	"pantryagent/tools/storage/sqlite"
+)
+
+// settings is the environment configuration, overridden by the flags of the command.
+type settings struct {
+	model  pantryagent.ModelConfig
+	agent  pantryagent.AgentConfig
+	server pantryagent.ServerConfig
+	slack  pantryagent.SlackConfig
+	// modelID overrides MODEL_ID; the model configuration is only decoded by commands running a model.
+	modelID string
+	output  string
+	verbose bool
+}
+
+func newSettings() (*settings, error) {
+	s := &settings{modelID: os.Getenv("MODEL_ID")}
+	if err := envdecode.Decode(&s.agent); err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+	if err := envdecode.Decode(&s.server); err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+	// Slack is optional, so none of its variables may be set
+	if err := envdecode.Decode(&s.slack); err != nil && !errors.Is(err, envdecode.ErrNoTargetFieldsAreSet) {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+	return s, nil
+}
+
+// flags returns the flag set of a command with the flags every command has: storage and output.
+func (s *settings) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("pantry "+name, flag.ContinueOnError)
+	fs.StringVar(&s.server.Storage, "storage", s.server.Storage, "storage of the pantry and recipes: file or sqlite (SERVER_STORAGE)")
+	fs.StringVar(&s.agent.ArtifactsPantryPath, "pantry", s.agent.ArtifactsPantryPath, "pantry file of the file storage (ARTIFACTS_PANTRY_PATH)")
+	fs.StringVar(&s.agent.ArtifactsRecipesPath, "recipes", s.agent.ArtifactsRecipesPath, "recipes file of the file storage (ARTIFACTS_RECIPES_PATH)")
+	fs.StringVar(&s.agent.SQLitePath, "db", s.agent.SQLitePath, "database of the sqlite storage (SQLITE_PATH)")
+	fs.StringVar(&s.output, "o", "text", "output format: text or json")
+	fs.BoolVar(&s.verbose, "v", false, "log progress to stderr")
+	return fs
+}
+
+// modelFlags adds the flags of commands running a model.
+func (s *settings) modelFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.modelID, "model", s.modelID, "model id (MODEL_ID)")
+	fs.IntVar(&s.agent.MaxIterations, "max-iterations", s.agent.MaxIterations, "maximum coordinator iterations (MAX_ITERATIONS)")
+	fs.StringVar(&s.agent.BaseOllamaEndpoint, "ollama", s.agent.BaseOllamaEndpoint, "Ollama endpoint (BASE_OLLAMA_ENDPOINT)")
+}
+
+// parse parses the flags of a command, which may follow its arguments, e.g. "consume rice 100 g -o json".
+// Unless -v is given, only warnings and errors are logged.
+func (s *settings) parse(fs *flag.FlagSet, args []string) error {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return err
+			}
+			return errUsage // the flag set has printed the error and usage
+		}
+		rest := fs.Args()
+		parsed := args[:len(args)-len(rest)]
+		if len(rest) == 0 || len(parsed) > 0 && parsed[len(parsed)-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	fs.Parse(append([]string{"--"}, positional...)) // nolint: errcheck
+	if s.output != "text" && s.output != "json" {
+		return fmt.Errorf("unknown output %q (want text or json)", s.output)
+	}
+	if !s.verbose {
+		slog.SetLogLoggerLevel(slog.LevelWarn)
+	}
+	return nil
+}
+
+// loadModel decodes the model configuration for the given backends, with -model as MODEL_ID. Only the
+// mock backend runs without a model, so its model id defaults to "mock".
+func (s *settings) loadModel(backends ...string) error {
+	if s.modelID == "" && !slices.ContainsFunc(backends, func(b string) bool { return b != planner.Mock }) {
+		s.modelID = planner.Mock
+	}
+	if s.modelID == "" {
+		return errors.New("no model: set MODEL_ID or -model")
+	}
+	if err := os.Setenv("MODEL_ID", s.modelID); err != nil {
+		return err
+	}
+	if err := envdecode.Decode(&s.model); err != nil {
+		return fmt.Errorf("failed to decode: %w", err)
+	}
+	return nil
+}
+
+// openStorage returns the pantry and recipe states of the configured storage and a function closing
+// them.
+func (s *settings) openStorage(ctx context.Context) (storage.VersionedPantryState, storage.RecipeState, func() error, error) {
+	st, err := s.openStores(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return st.pantry, st.recipes, st.close, nil
+}
+
+// stores are the states of the configured storage.
+type stores struct {
+	pantry  storage.VersionedPantryState
+	recipes storage.RecipeState
+	// history is nil for storages keeping no plan history, i.e. the files.
+	history storage.PlanHistory
+	close   func() error
+}
+
+func (s *settings) openStores(ctx context.Context) (*stores, error) {
+	switch s.server.Storage {
+	case "file":
+		return &stores{
+			pantry:  storage.NewFilePantryState(s.agent.ArtifactsPantryPath),
+			recipes: storage.NewFileRecipeState(s.agent.ArtifactsRecipesPath),
+			close:   func() error { return nil },
+		}, nil
+	case "sqlite":
+		store, err := sqlite.Open(ctx, s.agent.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+		return &stores{pantry: store.Pantry(), recipes: store.Recipes(), history: store, close: store.Close}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q (want file or sqlite)", s.server.Storage)
+	}
+}
+
+// backendModel returns the model the backend named plans with.
+func (s *settings) backendModel(backend string) string {
+	if backend == planner.Mock {
+		return planner.Mock
+	}
+	return s.model.ModelID
+}
+
+// planning is what commands running plans use: the storage, and the dependencies of the backends.
+type planning struct {
+	pantry  storage.VersionedPantryState
+	recipes storage.RecipeState
+	history storage.PlanHistory // nil without one
+	deps    planner.Deps
+	closers []func() error
+}
+
+// openPlanning opens the storage, and builds the tool registry, with the tools of the MCP servers, and
+// the prompts. The model configuration must have been loaded.
+func (s *settings) openPlanning(ctx context.Context) (*planning, error) {
+	st, err := s.openStores(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	p := &planning{pantry: st.pantry, recipes: st.recipes, history: st.history, closers: []func() error{st.close}}
+
+	registry, err := tools.NewRegistry(st.pantry, st.recipes)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to create tool registry: %w", err), p.Close())
+	}
+	closeMCP, err := mcp.AddServers(ctx, registry, s.agent.MCPServers)
+	p.closers = append(p.closers, closeMCP)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to add MCP server tools: %w", err), p.Close())
+	}
+	promptRegistry, promptVars, err := s.agent.Prompts()
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to load prompts: %w", err), p.Close())
+	}
+
+	p.deps = planner.Deps{
+		Registry:   registry,
+		Model:      s.model,
+		Agent:      s.agent,
+		Prompts:    promptRegistry,
+		PromptVars: promptVars,
+	}
+	return p, nil
+}
+
+// Close closes the MCP servers and the storage.
+func (p *planning) Close() error {
+	var errs []error
+	for _, c := range slices.Backward(p.closers) {
+		errs = append(errs, c())
+	}
+	return errors.Join(errs...)
+}
+
+// savePlan keeps plan in the storage's plan history, if it has one. A plan the history failed to keep is
+// only logged.
+func (p *planning) savePlan(ctx context.Context, task, modelID string, plan pantryagent.MealPlan) {
+	if p.history == nil {
+		return
+	}
+	b, err := json.Marshal(plan)
+	if err == nil {
+		_, err = p.history.SavePlan(ctx, task, modelID, b)
+	}
+	if err != nil {
+		slog.Error("PANTRY: Failed to keep plan in the history", "error", err)
+	}
+}
+
+// load returns the recipe catalog and the pantry of the storage.
+func (p *planning) load(ctx context.Context) ([]tools.Recipe, *tools.Pantry, error) {
+	b, err := p.recipes.Load(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read recipes: %w", err)
+	}
+	recipes, err := tools.ParseRecipes(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := p.pantry.Load(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load pantry: %w", err)
+	}
+	pantry, err := tools.ParsePantry(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return recipes, pantry, nil
+}
+
+func newBackend(ctx context.Context, name string, deps planner.Deps) (planner.Backend, error) {
+	switch name {
+	case planner.Mock:
+		return planner.NewMock(deps), nil
+	case planner.Ollama:
+		return planner.NewOllama(deps), nil
+	case planner.Bedrock:
+		brc, err := newBedrockRuntimeClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("bedrock: %w", err)
+		}
+		return planner.NewBedrock(deps, brc), nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q (want mock, ollama or bedrock)", name)
+	}
+}
+
+func newBedrockRuntimeClient(ctx context.Context) (*bedrockruntime.Client, error) {
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRetryMaxAttempts(5))
+	if err != nil {
+		return nil, err
+	}
+	return bedrockruntime.NewFromConfig(awsCfg), nil
+}
+
+// print writes v to stdout as indented JSON with -o json, and as text written by text otherwise. Text is
+// written through a tabwriter, so tab-separated columns are aligned.
+func (s *settings) print(v any, text func(w io.Writer)) error {
+	if s.output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	text(tw)
+	return tw.Flush()
+}
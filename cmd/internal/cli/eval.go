@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"pantryagent/eval"
+	"pantryagent/planner"
+)
+
+// evalReport is the JSON output of eval.
+type evalReport struct {
+	Passed  int           `json:"passed"`
+	Total   int           `json:"total"`
+	Results []eval.Result `json:"results"`
+}
+
+// runEval runs evaluation cases on a backend, and fails if any case fails.
+func runEval(ctx context.Context, s *settings, args []string) error {
+	fs := s.flags("eval")
+	backendName := fs.String("backend", planner.Mock, "coordinator backend: mock, ollama or bedrock")
+	casesPath := fs.String("cases", "", "JSON file of evaluation cases (default: the built-in cases)")
+	s.modelFlags(fs)
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+
+	cases := eval.DefaultCases()
+	if *casesPath != "" {
+		var err error
+		if cases, err = eval.LoadCases(*casesPath); err != nil {
+			return err
+		}
+	}
+	if err := s.loadModel(*backendName); err != nil {
+		return err
+	}
+
+	p, err := s.openPlanning(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := p.Close(); err != nil {
+			slog.Error("SETUP: Failed to close", "error", err)
+		}
+	}()
+
+	checker, err := newChecker(ctx, p)
+	if err != nil {
+		return err
+	}
+	backend, err := newBackend(ctx, *backendName, p.deps)
+	if err != nil {
+		return err
+	}
+
+	report := evalReport{Total: len(cases), Results: eval.Run(ctx, backend, checker, cases)}
+	for _, res := range report.Results {
+		if res.Passed {
+			report.Passed++
+		}
+	}
+	if err := s.print(report, func(w io.Writer) {
+		fmt.Fprintln(w, "CASE\tBACKEND\tRESULT\tITERATIONS\tDURATION")
+		for _, res := range report.Results {
+			result := "pass"
+			if !res.Passed {
+				result = "FAIL"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", res.Case, res.Backend, result, res.Iterations, res.Duration.Round(time.Millisecond))
+		}
+		for _, res := range report.Results {
+			if res.Error != "" {
+				fmt.Fprintf(w, "\n%s: error: %s\n", res.Case, res.Error)
+			}
+			if len(res.Problems) > 0 {
+				fmt.Fprintf(w, "\n%s:\n  %s\n", res.Case, strings.Join(res.Problems, "\n  "))
+			}
+		}
+		fmt.Fprintf(w, "\n%d/%d passed\n", report.Passed, report.Total)
+	}); err != nil {
+		return err
+	}
+	if report.Passed < report.Total {
+		return fmt.Errorf("%d of %d cases failed", report.Total-report.Passed, report.Total)
+	}
+	return nil
+}
+
+// newChecker returns a checker for the catalog and pantry of the storage.
+func newChecker(ctx context.Context, p *planning) (*eval.Checker, error) {
+	recipes, pantry, err := p.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return eval.NewChecker(recipes, pantry)
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"pantryagent"
+)
+
+func runHistory(ctx context.Context, s *settings, args []string) error {
+	fs := s.flags("history")
+	limit := fs.Int("limit", 10, "number of plans to list")
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	if *limit <= 0 {
+		return fmt.Errorf("invalid -limit %d", *limit)
+	}
+	st, err := s.openStores(ctx)
+	if err != nil {
+		return err
+	}
+	defer st.close() // nolint: errcheck
+	if st.history == nil {
+		return fmt.Errorf("the %s storage keeps no plan history (want -storage sqlite)", s.server.Storage)
+	}
+
+	plans, err := st.history.RecentPlans(ctx, *limit)
+	if err != nil {
+		return err
+	}
+	return s.print(map[string]any{"plans": plans}, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tCREATED\tMODEL\tDAYS\tTASK")
+		for _, p := range plans {
+			var plan pantryagent.MealPlan
+			days := "-"
+			if err := json.Unmarshal(p.Plan, &plan); err == nil {
+				days = fmt.Sprint(len(plan.DaysPlanned))
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", p.ID, p.CreatedAt.Local().Format(time.DateTime), p.ModelID, days, p.Task)
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"pantryagent/tools/storage/sqlite"
+)
+
+// runImport imports the pantry and recipe files into the SQLite database, replacing its pantry and
+// recipes, events included. The plan history is kept.
+func runImport(ctx context.Context, s *settings, args []string) error {
+	fs := s.flags("import")
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	store, err := sqlite.Open(ctx, s.agent.SQLitePath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer store.Close() // nolint: errcheck
+
+	if err := store.ImportFiles(ctx, s.agent.ArtifactsPantryPath, s.agent.ArtifactsRecipesPath); err != nil {
+		return err
+	}
+	imported := map[string]string{
+		"database": s.agent.SQLitePath,
+		"pantry":   s.agent.ArtifactsPantryPath,
+		"recipes":  s.agent.ArtifactsRecipesPath,
+	}
+	return s.print(imported, func(w io.Writer) {
+		fmt.Fprintf(w, "Imported %s and %s into %s\n", imported["pantry"], imported["recipes"], imported["database"])
+	})
+}
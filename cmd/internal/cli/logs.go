@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pantryagent"
+)
+
+func runLogs(ctx context.Context, s *settings, args []string) error {
+	return dispatch(ctx, s, "logs", []command{
+		{"show", "show a coordination log, the latest by default: show [file]", runLogsShow},
+	}, args)
+}
+
+// coordinationSession is a log written by pantryagent.FileCoordinationLogger.
+type coordinationSession struct {
+	Session struct {
+		Iterations []pantryagent.IterationLog `json:"iterations"`
+	} `json:"coordination_session"`
+}
+
+func runLogsShow(_ context.Context, s *settings, args []string) error {
+	fs := s.flags("logs show")
+	dir := fs.String("dir", "logs", "directory of the coordination logs")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pantry logs show [flags] [file]\n\n")
+		fs.PrintDefaults()
+	}
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+
+	var path string
+	switch fs.NArg() {
+	case 0:
+		var err error
+		if path, err = latestLog(*dir); err != nil {
+			return err
+		}
+	case 1:
+		path = fs.Arg(0)
+	default:
+		fs.Usage()
+		return errUsage
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if s.output == "json" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	var log coordinationSession
+	if err := json.Unmarshal(data, &log); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return s.print(nil, func(w io.Writer) {
+		fmt.Fprintf(w, "%s: %d iterations\n", path, len(log.Session.Iterations))
+		for _, it := range log.Session.Iterations {
+			fmt.Fprintf(w, "\n# Iteration %d", it.Iteration)
+			if it.Model != "" {
+				fmt.Fprintf(w, " (%s)", it.Model)
+			}
+			fmt.Fprintln(w)
+			if output := logOutput(it.LLMOutput); output != "" {
+				fmt.Fprintln(w, output)
+			}
+			for _, call := range it.ToolCalls {
+				input, _ := json.Marshal(call.Input)
+				fmt.Fprintf(w, "-> %s %s", call.Name, input)
+				if call.Error != "" {
+					fmt.Fprintf(w, ": error: %s", call.Error)
+				}
+				fmt.Fprintln(w)
+			}
+			if it.Error != "" {
+				fmt.Fprintf(w, "error: %s\n", it.Error)
+			}
+		}
+	})
+}
+
+// latestLog returns the most recently written .json file of dir.
+func latestLog(dir string) (string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 0 {
+		return "", errors.New("no coordination logs in " + dir)
+	}
+	var (
+		latest  string
+		modTime int64
+	)
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return "", err
+		}
+		if t := info.ModTime().UnixNano(); latest == "" || t > modTime || (t == modTime && p > latest) {
+			latest, modTime = p, t
+		}
+	}
+	return latest, nil
+}
+
+// logOutput returns the text of a logged model output, which coordinators log as a string or as their
+// response structure.
+func logOutput(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
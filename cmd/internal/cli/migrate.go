@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/joeshaw/envdecode"
+
+	"pantryagent/tools/storage"
+	"pantryagent/tools/storage/format"
+)
+
+// s3Config migrates the artifacts in S3 instead of the local files when Bucket is set.
+type s3Config struct {
+	Bucket     string `env:"ARTIFACTS_S3_BUCKET"`
+	PantryKey  string `env:"ARTIFACTS_PANTRY_S3_KEY,default=pantry.json"`
+	RecipesKey string `env:"ARTIFACTS_RECIPES_S3_KEY,default=recipes.json"`
+}
+
+// artifact is a stored document to migrate. The versioned pantry states store any JSON document, so they
+// serve for the recipes too.
+type artifact struct {
+	kind     format.Kind
+	location string
+	state    storage.VersionedPantryState
+}
+
+// migration is the JSON output of migrate for a document.
+type migration struct {
+	Kind     format.Kind `json:"kind"`
+	Location string      `json:"location"`
+	From     int         `json:"from"`
+	To       int         `json:"to"`
+	// Status is "up to date", "would migrate" (with -dry-run), "migrated" or "failed".
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// runMigrate upgrades the pantry and recipe artifacts to the current format version in place (see
+// tools/storage/format). Writes are conditional: a document changed during the migration is reported and
+// left alone, so the command can simply be run again.
+func runMigrate(ctx context.Context, s *settings, args []string) error {
+	var s3c s3Config
+	if err := envdecode.Decode(&s3c); err != nil {
+		return fmt.Errorf("failed to decode: %w", err)
+	}
+	fs := s.flags("migrate")
+	dryRun := fs.Bool("dry-run", false, "report the documents to migrate without writing them")
+	fs.StringVar(&s3c.Bucket, "s3-bucket", s3c.Bucket, "migrate the S3 objects of this bucket instead of the files (ARTIFACTS_S3_BUCKET)")
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	if s.server.Storage == "sqlite" {
+		return errors.New("the sqlite storage migrates its schema when it is opened; migrate the files it imports instead")
+	}
+
+	artifacts := []artifact{
+		{format.Pantry, s.agent.ArtifactsPantryPath, storage.NewFilePantryState(s.agent.ArtifactsPantryPath)},
+		{format.Recipes, s.agent.ArtifactsRecipesPath, storage.NewFilePantryState(s.agent.ArtifactsRecipesPath)},
+	}
+	if s3c.Bucket != "" {
+		awsCfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		s3Client := s3.NewFromConfig(awsCfg)
+		artifacts = []artifact{
+			{format.Pantry, "s3://" + s3c.Bucket + "/" + s3c.PantryKey, storage.NewS3PantryState(s3Client, s3c.Bucket, s3c.PantryKey)},
+			{format.Recipes, "s3://" + s3c.Bucket + "/" + s3c.RecipesKey, storage.NewS3PantryState(s3Client, s3c.Bucket, s3c.RecipesKey)},
+		}
+	}
+
+	var (
+		results []migration
+		failed  int
+	)
+	for _, a := range artifacts {
+		m, err := migrate(ctx, a, *dryRun)
+		if err != nil {
+			m.Status, m.Error = "failed", err.Error()
+			failed++
+		}
+		results = append(results, m)
+	}
+	if err := s.print(results, func(w io.Writer) {
+		fmt.Fprintln(w, "KIND\tLOCATION\tFROM\tTO\tSTATUS")
+		for _, m := range results {
+			status := m.Status
+			if m.Error != "" {
+				status += ": " + m.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", m.Kind, m.Location, m.From, m.To, status)
+		}
+	}); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d documents were not migrated", failed)
+	}
+	return nil
+}
+
+func migrate(ctx context.Context, a artifact, dryRun bool) (migration, error) {
+	m := migration{Kind: a.kind, Location: a.location, To: format.CurrentVersion(a.kind)}
+	raw, version, err := a.state.LoadVersion(ctx)
+	if err != nil {
+		return m, err
+	}
+	upgraded, from, err := format.Upgrade(a.kind, raw)
+	if err != nil {
+		return m, err
+	}
+	m.From = from
+	switch {
+	case string(upgraded) == string(raw):
+		m.Status = "up to date"
+	case dryRun:
+		m.Status = "would migrate"
+	default:
+		if _, err := a.state.Save(ctx, append(upgraded, '\n'), version); err != nil {
+			return m, err
+		}
+		m.Status = "migrated"
+	}
+	return m, nil
+}
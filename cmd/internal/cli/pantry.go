@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"pantryagent/tools"
+)
+
+func runPantry(ctx context.Context, s *settings, args []string) error {
+	return dispatch(ctx, s, "pantry", []command{
+		{"list", "list the ingredients and their freshness", runPantryList},
+		{"add", "record a purchase: add <name> <qty> <unit>", runPantryAdd},
+		{"consume", "record a use: consume <name> <qty> <unit>", runPantryConsume},
+	}, args)
+}
+
+// pantryList is the pantry as pantry_get reports it.
+type pantryList struct {
+	Ingredients []struct {
+		Name     string  `json:"name"`
+		Qty      float64 `json:"qty"`
+		Unit     string  `json:"unit"`
+		DaysLeft int     `json:"days_left"`
+		Expires  string  `json:"expires,omitempty"`
+	} `json:"ingredients"`
+}
+
+func runPantryList(ctx context.Context, s *settings, args []string) error {
+	fs := s.flags("pantry list")
+	date := fs.String("date", "", "list the pantry at the end of this day (YYYY-MM-DD, default today)")
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	ps, _, closeStorage, err := s.openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeStorage() // nolint: errcheck
+
+	out, err := tools.NewPantryGet(ps).Run(ctx, map[string]any{"date": *date})
+	if err != nil {
+		return err
+	}
+	var list pantryList
+	if err := remarshal(out["pantry"], &list); err != nil {
+		return err
+	}
+	return s.print(list, func(w io.Writer) {
+		fmt.Fprintln(w, "NAME\tQTY\tUNIT\tDAYS_LEFT\tEXPIRES")
+		for _, ing := range list.Ingredients {
+			daysLeft := strconv.Itoa(ing.DaysLeft)
+			if ing.DaysLeft >= tools.NonPerishableDaysLeft {
+				daysLeft = "-"
+			}
+			fmt.Fprintf(w, "%s\t%g\t%s\t%s\t%s\n", ing.Name, ing.Qty, ing.Unit, daysLeft, ing.Expires)
+		}
+	})
+}
+
+func runPantryAdd(ctx context.Context, s *settings, args []string) error {
+	fs := s.flags("pantry add")
+	expires := fs.String("expires", "", "expiry date of the ingredient (YYYY-MM-DD)")
+	nonPerishable := fs.Bool("non-perishable", false, "the ingredient never expires")
+	note := fs.String("note", "", "note recorded with the purchase")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pantry pantry add [flags] <name> <qty> <unit>\n\n")
+		fs.PrintDefaults()
+	}
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return errUsage
+	}
+	event, err := parseEvent(fs.Args(), tools.Purchased)
+	if err != nil {
+		return err
+	}
+	if *expires != "" {
+		if event.Expires, err = tools.ParseDate(*expires); err != nil {
+			return err
+		}
+	}
+	event.NonPerishable = *nonPerishable
+	event.Note = *note
+	return s.record(ctx, event)
+}
+
+func runPantryConsume(ctx context.Context, s *settings, args []string) error {
+	fs := s.flags("pantry consume")
+	note := fs.String("note", "", "note recorded with the use")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pantry pantry consume [flags] <name> <qty> <unit>\n\n")
+		fs.PrintDefaults()
+	}
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return errUsage
+	}
+	event, err := parseEvent(fs.Args(), tools.Consumed)
+	if err != nil {
+		return err
+	}
+	event.Note = *note
+	return s.record(ctx, event)
+}
+
+// parseEvent returns the event of type t for the arguments <name> <qty> <unit>.
+func parseEvent(args []string, t tools.PantryEventType) (tools.PantryEvent, error) {
+	qty, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return tools.PantryEvent{}, fmt.Errorf("invalid qty %q", args[1])
+	}
+	return tools.PantryEvent{Type: t, At: time.Now(), Name: args[0], Qty: qty, Unit: args[2], Source: "cli"}, nil
+}
+
+// record appends event to the pantry's log and prints it.
+func (s *settings) record(ctx context.Context, event tools.PantryEvent) error {
+	if err := event.Validate(); err != nil {
+		return err
+	}
+	ps, _, closeStorage, err := s.openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeStorage() // nolint: errcheck
+
+	if _, err := tools.RecordPantryEvents(ctx, ps, []tools.PantryEvent{event}, 0); err != nil {
+		return err
+	}
+	slog.Info("PANTRY: Event recorded", "type", event.Type, "name", event.Name, "qty", event.Qty, "unit", event.Unit)
+	return s.print(event, func(w io.Writer) {
+		fmt.Fprintf(w, "%s %g %s of %s\n", event.Type, event.Qty, event.Unit, event.Name)
+	})
+}
+
+// remarshal converts v, e.g. a tool's output, to out through JSON.
+func remarshal(v, out any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"pantryagent"
+	"pantryagent/planner"
+	"pantryagent/slack"
+	"pantryagent/tools"
+)
+
+// planResult is the JSON output of plan.
+type planResult struct {
+	Backend string `json:"backend"`
+	Task    string `json:"task"`
+	Output  string `json:"output"`
+	// Plan is the meal plan extracted from Output, if it holds a valid one.
+	Plan *pantryagent.MealPlan `json:"plan,omitempty"`
+	Log  string                `json:"log,omitempty"`
+	// Slack is the message the plan was posted as, with -slack.
+	Slack *slack.MessageRef `json:"slack,omitempty"`
+}
+
+// runPlan plans the task given as arguments (planner.DefaultTask if none) on a backend.
+func runPlan(ctx context.Context, s *settings, args []string) error {
+	fs := s.flags("plan")
+	backendName := fs.String("backend", planner.Mock, "coordinator backend: mock, ollama or bedrock")
+	withOtel := fs.Bool("otel", false, "export traces and metrics with OpenTelemetry (OTEL_* variables)")
+	logDir := fs.String("log-dir", "logs", "directory of the coordination log; empty writes none")
+	fs.StringVar(&s.slack.Channel, "slack", s.slack.Channel, "Slack channel to post the plan to with SLACK_BOT_TOKEN (SLACK_CHANNEL)")
+	s.modelFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pantry plan [flags] [task]\n\n")
+		fs.PrintDefaults()
+	}
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	task := strings.Join(fs.Args(), " ")
+	if task == "" {
+		task = planner.DefaultTask
+	}
+	if err := s.loadModel(*backendName); err != nil {
+		return err
+	}
+	if s.slack.Channel != "" && s.slack.BotToken == "" {
+		return errors.New("posting to Slack needs SLACK_BOT_TOKEN")
+	}
+
+	p, err := s.openPlanning(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := p.Close(); err != nil {
+			slog.Error("SETUP: Failed to close", "error", err)
+		}
+	}()
+
+	if *withOtel {
+		tracerProvider, meterProvider, otelShutdown, err := pantryagent.InitOtel(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to initialize OpenTelemetry: %w", err)
+		}
+		defer func() {
+			if err := otelShutdown(context.WithoutCancel(ctx)); err != nil {
+				slog.Error("SETUP: Failed to shutdown OpenTelemetry", "error", err)
+			}
+		}()
+		p.deps.TracerProvider = tracerProvider
+		p.deps.MeterProvider = meterProvider
+
+		var span trace.Span
+		ctx, span = tracerProvider.Tracer("pantry").Start(ctx, "plan", trace.WithAttributes(
+			attribute.String("backend", *backendName),
+			attribute.String("model.id", s.model.ModelID),
+			attribute.Int("model.max_tokens", int(s.model.MaxTokens)),
+			attribute.Float64("model.temperature", float64(s.model.Temperature)),
+			attribute.Float64("model.top_p", float64(s.model.TopP)),
+		))
+		defer span.End()
+	}
+
+	backend, err := newBackend(ctx, *backendName, p.deps)
+	if err != nil {
+		return err
+	}
+
+	res := planResult{Backend: backend.Name(), Task: task}
+	logger, closeLog, err := newCoordinationLogger(*logDir, s.model.ModelID)
+	if err != nil {
+		return err
+	}
+	if *logDir != "" {
+		res.Log = logger.path
+	}
+	res.Output, err = backend.Plan(ctx, planner.Run{Task: task, Logger: logger})
+	if err := closeLog(); err != nil {
+		slog.Error("SETUP: Failed to flush coordination log", "error", err)
+	}
+	if err != nil {
+		return err
+	}
+	if plan, _, err := pantryagent.ExtractMealPlan(res.Output); err == nil {
+		res.Plan = &plan
+		p.savePlan(ctx, task, s.backendModel(*backendName), plan)
+	}
+	if s.slack.Channel != "" {
+		ref, err := s.postToSlack(ctx, p, res)
+		if err != nil {
+			return fmt.Errorf("failed to post to Slack: %w", err)
+		}
+		res.Slack = &ref
+	}
+
+	return s.print(res, func(w io.Writer) {
+		if res.Plan == nil {
+			fmt.Fprintln(w, res.Output)
+		} else {
+			printMealPlan(w, *res.Plan)
+		}
+		if res.Log != "" {
+			fmt.Fprintf(w, "\nLog: %s\n", res.Log)
+		}
+		if res.Slack != nil {
+			fmt.Fprintf(w, "Posted to Slack: %s %s\n", res.Slack.Channel, res.Slack.TS)
+		}
+	})
+}
+
+func printMealPlan(w io.Writer, plan pantryagent.MealPlan) {
+	if plan.Summary != "" {
+		fmt.Fprintf(w, "%s\n\n", plan.Summary)
+	}
+	fmt.Fprintln(w, "DAY\tMEAL\tID\tSERVINGS")
+	for _, day := range plan.DaysPlanned {
+		for _, meal := range day.Meals {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\n", day.Day, meal.Name, meal.ID, meal.Servings)
+		}
+	}
+}
+
+// postToSlack posts the plan, with what it takes from the pantry and the shopping list, or the output as
+// is if it holds no plan.
+func (s *settings) postToSlack(ctx context.Context, p *planning, res planResult) (slack.MessageRef, error) {
+	msg := slack.Message{Text: res.Output}
+	if res.Plan != nil {
+		report, err := p.planReport(ctx, *res.Plan)
+		if err != nil {
+			return slack.MessageRef{}, err
+		}
+		msg = slack.RenderPlan(report)
+	}
+	return slack.NewWebAPIClient(s.slack.BotToken, http.DefaultClient).Post(ctx, s.slack.Channel, msg)
+}
+
+// planReport checks plan against the stored recipes and pantry as of today.
+func (p *planning) planReport(ctx context.Context, plan pantryagent.MealPlan) (slack.PlanReport, error) {
+	recipes, pantry, err := p.load(ctx)
+	if err != nil {
+		return slack.PlanReport{}, err
+	}
+	return slack.NewPlanReport(plan, recipes, pantry, tools.DateOf(time.Now()))
+}
+
+// coordinationLog is a coordination logger writing to a file in a log directory, or discarding the
+// iterations without one.
+type coordinationLog struct {
+	pantryagent.CoordinationLogger
+	path string
+}
+
+// newCoordinationLogger returns a logger writing to a new file of dir named after the model, and a
+// function flushing and closing it.
+func newCoordinationLogger(dir, modelID string) (*coordinationLog, func() error, error) {
+	if dir == "" {
+		return &coordinationLog{CoordinationLogger: pantryagent.NewNoOpCoordinationLogger()}, func() error { return nil }, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	path := filepath.Join(dir, filepath.Base(pantryagent.NewCoordinationLogFilePath(modelID)))
+	logFile, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	logger := pantryagent.NewFileCoordinationLogger(logFile)
+	cleanup := func() error {
+		return errors.Join(logger.Flush(), logFile.Close())
+	}
+	return &coordinationLog{CoordinationLogger: logger, path: path}, cleanup, nil
+}
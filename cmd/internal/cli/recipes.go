@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"pantryagent/tools"
+)
+
+func runRecipes(ctx context.Context, s *settings, args []string) error {
+	return dispatch(ctx, s, "recipes", []command{
+		{"list", "list the recipes", runRecipesList},
+		{"show", "show a recipe: show <id>", runRecipesShow},
+		{"validate", "validate the catalog, or a catalog file: validate [file]", runRecipesValidate},
+	}, args)
+}
+
+// loadRecipes returns the catalog of the configured storage.
+func (s *settings) loadRecipes(ctx context.Context) ([]tools.Recipe, error) {
+	_, rs, closeStorage, err := s.openStorage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeStorage() // nolint: errcheck
+
+	b, err := rs.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read recipes: %w", err)
+	}
+	return tools.ParseRecipes(b)
+}
+
+func runRecipesList(ctx context.Context, s *settings, args []string) error {
+	fs := s.flags("recipes list")
+	mealType := fs.String("meal-type", "", "only list recipes of this meal type, e.g. dinner")
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	recipes, err := s.loadRecipes(ctx)
+	if err != nil {
+		return err
+	}
+	if *mealType != "" {
+		recipes = slices.DeleteFunc(recipes, func(r tools.Recipe) bool { return !slices.Contains(r.MealTypes, *mealType) })
+	}
+	return s.print(recipes, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tMEAL_TYPES\tSERVINGS")
+		for _, r := range recipes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", r.ID, r.Name, strings.Join(r.MealTypes, ","), r.Servings)
+		}
+	})
+}
+
+func runRecipesShow(ctx context.Context, s *settings, args []string) error {
+	fs := s.flags("recipes show")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pantry recipes show [flags] <id>\n\n")
+		fs.PrintDefaults()
+	}
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	id := fs.Arg(0)
+
+	_, rs, closeStorage, err := s.openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeStorage() // nolint: errcheck
+
+	// The JSON output is the recipe as stored, with the fields Recipe does not type
+	out, err := tools.NewRecipeGet(rs).Run(ctx, map[string]any{})
+	if err != nil {
+		return err
+	}
+	var stored []map[string]any
+	if err := remarshal(out["recipes"], &stored); err != nil {
+		return err
+	}
+	i := slices.IndexFunc(stored, func(r map[string]any) bool { return r["id"] == id })
+	if i < 0 {
+		return fmt.Errorf("unknown recipe %q", id)
+	}
+	var recipe tools.Recipe
+	if err := remarshal(stored[i], &recipe); err != nil {
+		return err
+	}
+	return s.print(stored[i], func(w io.Writer) {
+		fmt.Fprintf(w, "%s (%s)\nMeal types: %s\nServings: %d\n\n", recipe.Name, recipe.ID, strings.Join(recipe.MealTypes, ", "), recipe.Servings)
+		fmt.Fprintln(w, "INGREDIENT\tQTY\tUNIT")
+		for _, ing := range recipe.Ingredients {
+			fmt.Fprintf(w, "%s\t%g\t%s\n", ing.Name, ing.Qty, ing.Unit)
+		}
+	})
+}
+
+// validation is the JSON output of recipes validate.
+type validation struct {
+	Recipes  int      `json:"recipes"`
+	Problems []string `json:"problems"`
+}
+
+// runRecipesValidate checks the catalog, and fails if it has problems.
+func runRecipesValidate(ctx context.Context, s *settings, args []string) error {
+	fs := s.flags("recipes validate")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pantry recipes validate [flags] [file]\n\n")
+		fs.PrintDefaults()
+	}
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+
+	var (
+		recipes []tools.Recipe
+		err     error
+	)
+	switch fs.NArg() {
+	case 0:
+		recipes, err = s.loadRecipes(ctx)
+	case 1:
+		var b []byte
+		if b, err = os.ReadFile(fs.Arg(0)); err == nil {
+			recipes, err = tools.ParseRecipes(b)
+		}
+	default:
+		fs.Usage()
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+
+	res := validation{Recipes: len(recipes), Problems: tools.ValidateRecipes(recipes)}
+	if res.Problems == nil {
+		res.Problems = []string{}
+	}
+	if err := s.print(res, func(w io.Writer) {
+		for _, p := range res.Problems {
+			fmt.Fprintln(w, p)
+		}
+		fmt.Fprintf(w, "%d recipes, %d problems\n", res.Recipes, len(res.Problems))
+	}); err != nil {
+		return err
+	}
+	if len(res.Problems) > 0 {
+		return fmt.Errorf("catalog has %d problems", len(res.Problems))
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"pantryagent"
+	"pantryagent/jobs"
+	"pantryagent/planner"
+	"pantryagent/server"
+	"pantryagent/slack"
+	"pantryagent/tools"
+	"pantryagent/tools/storage/sqlite"
+)
+
+// runServe serves the HTTP API until ctx ends; see the server package for the routes.
+func runServe(ctx context.Context, s *settings, args []string) error {
+	fs := s.flags("serve")
+	fs.StringVar(&s.server.Addr, "addr", s.server.Addr, "address to serve on (SERVER_ADDR)")
+	fs.Func("backends", "comma-separated backends plans can run on, the first is the default (SERVER_BACKENDS)", func(v string) error {
+		s.server.Backends = strings.Split(v, ",")
+		return nil
+	})
+	fs.StringVar(&s.server.JobsPath, "jobs", s.server.JobsPath, "database of the job queue; empty disables it (SERVER_JOBS_PATH)")
+	fs.IntVar(&s.server.Workers, "workers", s.server.Workers, "queued jobs running at once (SERVER_WORKERS)")
+	s.modelFlags(fs)
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	// The server logs its requests and runs
+	slog.SetLogLoggerLevel(slog.LevelInfo)
+	if len(s.server.Backends) == 0 {
+		return errors.New("no backends configured")
+	}
+	if err := s.loadModel(s.server.Backends...); err != nil {
+		return err
+	}
+
+	p, err := s.openPlanning(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := p.Close(); err != nil {
+			slog.Error("SETUP: Failed to close", "error", err)
+		}
+	}()
+
+	var backends []planner.Backend
+	modelIDs := make(map[string]string)
+	for _, name := range s.server.Backends {
+		backend, err := newBackend(ctx, name, p.deps)
+		if err != nil {
+			return err
+		}
+		backends = append(backends, backend)
+		modelIDs[backend.Name()] = s.backendModel(name)
+	}
+
+	limits, err := jobs.ParseLimits(s.server.BackendLimits)
+	if err != nil {
+		return fmt.Errorf("failed to parse backend limits: %w", err)
+	}
+	queue, closeQueue, err := newJobQueue(ctx, s.server.JobsPath)
+	if err != nil {
+		return fmt.Errorf("failed to open job queue: %w", err)
+	}
+	defer closeQueue() // nolint: errcheck
+
+	api, err := server.New(server.Options{
+		Backends:       planner.NewBackends(backends...),
+		DefaultBackend: s.server.Backends[0],
+		Registry:       p.deps.Registry,
+		Pantry:         p.pantry,
+		Recipes:        p.recipes,
+		MaxRuns:        s.server.MaxRuns,
+		Jobs:           queue,
+		Pool: jobs.PoolOptions{
+			Workers: s.server.Workers,
+			Limits:  limits,
+			Backoff: s.server.JobBackoff,
+		},
+		JobAttempts: s.server.JobAttempts,
+		History:     p.history,
+		ModelIDs:    modelIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create server: %w", err)
+	}
+
+	handler := api.Handler()
+	var slackApp *slack.Handler
+	if s.slack.SigningSecret != "" {
+		slackApp = s.newSlackHandler(p, backends[0], modelIDs[backends[0].Name()])
+		mux := http.NewServeMux()
+		mux.Handle("/", handler)
+		slackApp.Register(mux)
+		handler = mux
+	}
+
+	srv := &http.Server{Addr: s.server.Addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+	slog.Info("SERVER: Serving", "addr", s.server.Addr, "backends", s.server.Backends, "storage", s.server.Storage, "jobs", s.server.JobsPath, "slack", slackApp != nil)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Requests in flight, background runs and jobs share the shutdown timeout; runs still going when it
+	// ends are cancelled, and their jobs requeued
+	slog.Info("SERVER: Shutting down", "timeout", s.server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.server.ShutdownTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		if err := api.Shutdown(shutdownCtx); err != nil {
+			slog.Error("SERVER: Cancelled plan runs in progress", "error", err)
+		}
+	})
+	if slackApp != nil {
+		wg.Go(func() {
+			if err := slackApp.Shutdown(shutdownCtx); err != nil {
+				slog.Error("SLACK: Cancelled plans in progress", "error", err)
+			}
+		})
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("SERVER: Failed to shut down", "error", err)
+	}
+	wg.Wait()
+	return nil
+}
+
+// newSlackHandler returns the Slack app answering "/pantry plan" and mentions with plans of backend, and
+// applying the plans people accept to the pantry. Mentions are only answered with SLACK_BOT_TOKEN.
+func (s *settings) newSlackHandler(p *planning, backend planner.Backend, modelID string) *slack.Handler {
+	var client *slack.WebAPIClient
+	if s.slack.BotToken != "" {
+		client = slack.NewWebAPIClient(s.slack.BotToken, http.DefaultClient)
+	}
+	return slack.NewHandler(slack.HandlerOptions{
+		SigningSecret: s.slack.SigningSecret,
+		Planner:       &slackPlanner{planning: p, backend: backend, modelID: modelID},
+		DefaultTask:   planner.DefaultTask,
+		Client:        client,
+	})
+}
+
+// slackPlanner plans for the Slack app on a backend, against the storage.
+type slackPlanner struct {
+	*planning
+	backend planner.Backend
+	modelID string
+}
+
+var _ slack.Planner = (*slackPlanner)(nil)
+
+func (sp *slackPlanner) Plan(ctx context.Context, task string) (*pantryagent.MealPlan, string, error) {
+	output, err := sp.backend.Plan(ctx, planner.Run{Task: task, Logger: pantryagent.NewNoOpCoordinationLogger()})
+	if err != nil {
+		return nil, "", err
+	}
+	plan, _, err := pantryagent.ExtractMealPlan(output)
+	if err != nil {
+		return nil, output, nil
+	}
+	sp.savePlan(ctx, task, sp.modelID, plan)
+	return &plan, output, nil
+}
+
+func (sp *slackPlanner) Report(ctx context.Context, plan pantryagent.MealPlan) (slack.PlanReport, error) {
+	return sp.planReport(ctx, plan)
+}
+
+// Accept records the consumption of what the plan uses in the pantry's log.
+func (sp *slackPlanner) Accept(ctx context.Context, report slack.PlanReport, user string) error {
+	now := time.Now()
+	events := make([]tools.PantryEvent, len(report.Uses))
+	for i, use := range report.Uses {
+		events[i] = tools.PantryEvent{
+			Type:   tools.Consumed,
+			At:     now,
+			Name:   use.Name,
+			Qty:    use.Qty,
+			Unit:   use.Unit,
+			Source: "slack",
+			Note:   fmt.Sprintf("meal plan accepted by %s", user),
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	if _, err := tools.RecordPantryEvents(ctx, sp.pantry, events, 0); err != nil {
+		return err
+	}
+	slog.Info("PANTRY: Meal plan accepted", "user", user, "events", len(events))
+	return nil
+}
+
+// newJobQueue opens the job queue in the SQLite database at path and returns it with a function closing
+// it. An empty path disables the queue.
+func newJobQueue(ctx context.Context, path string) (jobs.Queue, func() error, error) {
+	if path == "" {
+		return nil, func() error { return nil }, nil
+	}
+	store, err := sqlite.Open(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store.Jobs(), store.Close, nil
+}
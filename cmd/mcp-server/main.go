@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joeshaw/envdecode"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"pantryagent"
+	"pantryagent/mcp"
+	"pantryagent/tools"
+	"pantryagent/tools/storage"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var agentConfig pantryagent.AgentConfig
+	if err := envdecode.Decode(&agentConfig); err != nil {
+		log.Fatalf("SETUP: Failed to decode: %s", err)
+	}
+
+	var mcpConfig pantryagent.MCPServerConfig
+	if err := envdecode.Decode(&mcpConfig); err != nil {
+		log.Fatalf("SETUP: Failed to decode: %s", err)
+	}
+
+	ps := storage.NewFilePantryState(agentConfig.ArtifactsPantryPath)
+	rs := storage.NewFileRecipeState(agentConfig.ArtifactsRecipesPath)
+	registry, err := tools.NewRegistry(ps, rs)
+	if err != nil {
+		log.Fatalf("SETUP: Failed to create tool registry: %s", err)
+	}
+
+	server := mcp.NewServer(registry, version)
+
+	// stdio by default, so desktop assistants can launch the server as a subprocess; logs go to stderr
+	if mcpConfig.HTTPAddr == "" {
+		slog.Info("MCP_SERVER: Serving over stdio")
+		if err := server.Run(ctx, sdk.NewStdioTransport()); err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatalf("MCP_SERVER: %s", err)
+		}
+		return
+	}
+
+	handler := sdk.NewStreamableHTTPHandler(func(*http.Request) *sdk.Server { return server }, nil)
+	srv := &http.Server{Addr: mcpConfig.HTTPAddr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("MCP_SERVER: Failed to shut down", "error", err)
+		}
+	}()
+
+	slog.Info("MCP_SERVER: Serving streamable HTTP", "addr", mcpConfig.HTTPAddr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("MCP_SERVER: %s", err)
+	}
+}
@@ -0,0 +1,13 @@
+// Command migrate upgrades the pantry and recipe artifacts to the current format version; it is
+// "pantry migrate" and takes the same flags.
+package main
+
+import (
+	"os"
+
+	"pantryagent/cmd/internal/cli"
+)
+
+func main() {
+	cli.Main(append([]string{"migrate"}, os.Args[1:]...))
+}
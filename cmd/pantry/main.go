@@ -0,0 +1,27 @@
+// Command pantry is the meal planner's command line: it plans meals on any backend, manages the pantry
+// and recipes, shows coordination logs and past plans, evaluates backends, serves the HTTP API and maintains the
+// storage.
+//
+//	pantry plan [-backend mock|ollama|bedrock] [task]
+//	pantry pantry list|add|consume
+//	pantry recipes list|validate|show
+//	pantry logs show [file]
+//	pantry history [-limit n]
+//	pantry eval [-backend name] [-cases file]
+//	pantry serve
+//	pantry migrate [-dry-run] [-s3-bucket name]
+//	pantry import
+//
+// Every command reads the environment configuration described in the README; flags override it. Output
+// is human-readable text, or JSON with -o json. Run a command with -h for its flags.
+package main
+
+import (
+	"os"
+
+	"pantryagent/cmd/internal/cli"
+)
+
+func main() {
+	cli.Main(os.Args[1:])
+}
@@ -0,0 +1,12 @@
+// Command server serves the meal planner's HTTP API; it is "pantry serve" and takes the same flags.
+package main
+
+import (
+	"os"
+
+	"pantryagent/cmd/internal/cli"
+)
+
+func main() {
+	cli.Main(append([]string{"serve"}, os.Args[1:]...))
+}
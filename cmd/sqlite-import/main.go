@@ -0,0 +1,13 @@
+// Command sqlite-import imports the pantry and recipe artifacts into the SQLite database; it is
+// "pantry import" and takes the same flags.
+package main
+
+import (
+	"os"
+
+	"pantryagent/cmd/internal/cli"
+)
+
+func main() {
+	cli.Main(append([]string{"import"}, os.Args[1:]...))
+}
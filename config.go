@@ -1,15 +1,110 @@
 package pantryagent
 
+import (
+	"time"
+
+	"pantryagent/prompts"
+)
+
 type ModelConfig struct {
-	ModelID     string  `env:"MODEL_ID,required"`
-	MaxTokens   int32   `env:"MAX_TOKENS,default=1024"`
-	Temperature float32 `env:"TEMPERATURE,default=0.2"`
-	TopP        float32 `env:"TOP_P,default=0.9"`
+	ModelID   string `env:"MODEL_ID,required"`
+	MaxTokens int32  `env:"MAX_TOKENS,default=1024"`
+	// MaxTokensCeiling lets runs retry truncated responses with doubled budgets up to this many tokens.
+	MaxTokensCeiling int32   `env:"MAX_TOKENS_CEILING"`
+	Temperature      float32 `env:"TEMPERATURE,default=0.2"`
+	TopP             float32 `env:"TOP_P,default=0.9"`
+	// FallbackModels are tried in order when MODEL_ID fails, e.g. "bedrock:<model id>;ollama:llama3.1".
+	FallbackModels []string `env:"FALLBACK_MODELS"`
+	// CriticModelID enables the critic agent reviewing final plans on the given model, of the same
+	// provider as the planner (Bedrock or Ollama).
+	CriticModelID string `env:"CRITIC_MODEL_ID"`
+}
+
+// MCPServerConfig configures cmd/mcp-server.
+type MCPServerConfig struct {
+	// HTTPAddr serves MCP over streamable HTTP on this address (e.g. ":8080") instead of stdio.
+	HTTPAddr string `env:"MCP_HTTP_ADDR"`
+}
+
+// ServerConfig configures pantry serve (cmd/pantry).
+type ServerConfig struct {
+	Addr string `env:"SERVER_ADDR,default=:8080"`
+	// Backends are the coordinator backends plans can run on (mock, ollama, bedrock); the first is the
+	// default for requests naming none.
+	Backends []string `env:"SERVER_BACKENDS,default=mock;ollama"`
+	// Storage holds the pantry and recipes: "file" (the artifacts) or "sqlite" (SQLITE_PATH).
+	Storage string `env:"SERVER_STORAGE,default=file"`
+	// MaxRuns is how many plan runs are kept in memory for the API to report.
+	MaxRuns int `env:"SERVER_MAX_RUNS,default=100"`
+	// ShutdownTimeout is how long shutdown waits for plan runs in progress before cancelling them.
+	ShutdownTimeout time.Duration `env:"SERVER_SHUTDOWN_TIMEOUT,default=30s"`
+	// JobsPath is the SQLite database of the plan job queue; empty disables /jobs.
+	JobsPath string `env:"SERVER_JOBS_PATH,default=jobs.db"`
+	// Workers is how many queued jobs run at once.
+	Workers int `env:"SERVER_WORKERS,default=2"`
+	// BackendLimits caps the jobs running at once per backend, e.g. "bedrock=1;ollama=2".
+	BackendLimits []string `env:"SERVER_BACKEND_LIMITS,default=bedrock=1"`
+	// JobAttempts is the attempts of jobs whose request sets none.
+	JobAttempts int `env:"SERVER_JOB_ATTEMPTS,default=3"`
+	// JobBackoff delays the first retry of a failed job; later retries double it, up to 10 minutes.
+	JobBackoff time.Duration `env:"SERVER_JOB_BACKOFF,default=10s"`
+}
+
+// SlackConfig configures posting plans to Slack through the Web API, and the Slack app served by pantry
+// serve.
+type SlackConfig struct {
+	// BotToken is the token ("xoxb-...") of the Slack app's bot user.
+	BotToken string `env:"SLACK_BOT_TOKEN"`
+	// Channel is where pantry plan posts plans; empty posts none.
+	Channel string `env:"SLACK_CHANNEL"`
+	// SigningSecret verifies the slash commands and events Slack sends to pantry serve; empty serves
+	// no Slack routes.
+	SigningSecret string `env:"SLACK_SIGNING_SECRET"`
 }
 
 type AgentConfig struct {
 	ArtifactsPantryPath  string `env:"ARTIFACTS_PANTRY_PATH,default=artifacts/pantry.json"`
 	ArtifactsRecipesPath string `env:"ARTIFACTS_RECIPES_PATH,default=artifacts/recipes.json"`
 	BaseOllamaEndpoint   string `env:"BASE_OLLAMA_ENDPOINT,default=http://localhost:11434"`
-	MaxIterations        int    `env:"MAX_ITERATIONS,default=10"`
+	// SQLitePath is the database of the embedded SQL storage backend, see tools/storage/sqlite.
+	SQLitePath    string `env:"SQLITE_PATH,default=pantry.db"`
+	MaxIterations int    `env:"MAX_ITERATIONS,default=10"`
+	// MaxToolFailures caps consecutive tool failures fed back to the model; negative aborts on the first one.
+	MaxToolFailures int `env:"MAX_TOOL_FAILURES,default=3"`
+	// MaxContinuations caps the continuations requested for a truncated answer; negative disables them.
+	MaxContinuations int `env:"MAX_CONTINUATIONS,default=2"`
+	// MCPServers adds the tools of MCP servers, e.g. "grocery=npx -y grocery-mcp;calendar=https://example.com/mcp".
+	// Each server's tools are named "<server>__<tool>"; see mcp.ParseServerSpec.
+	MCPServers []string `env:"MCP_SERVERS"`
+	// PromptsDir overlays the embedded prompt templates with <name>/<version>.tmpl files.
+	PromptsDir string `env:"PROMPTS_DIR"`
+	// PromptVersions pins prompt template versions, e.g. "bedrock/system=v1;critic/system=v1".
+	PromptVersions []string `env:"PROMPT_VERSIONS"`
+	// Household, PlanDays and PlanServings are rendered into the system prompts when set.
+	Household    string `env:"HOUSEHOLD"`
+	PlanDays     int    `env:"PLAN_DAYS"`
+	PlanServings int    `env:"PLAN_SERVINGS"`
+}
+
+// Prompts returns the configured prompt registry and the variables to render its templates with.
+func (c AgentConfig) Prompts() (*prompts.Registry, prompts.Vars, error) {
+	r, err := prompts.Configure(c.PromptsDir, c.PromptVersions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, prompts.Vars{"Household": c.Household, "Days": c.PlanDays, "Servings": c.PlanServings}, nil
+}
+
+// ToolRecovery returns the configured tool failure recovery.
+func (c AgentConfig) ToolRecovery() ToolRecovery {
+	return ToolRecovery{MaxConsecutiveFailures: c.MaxToolFailures}
+}
+
+// TruncationRecovery returns the configured truncation recovery for a client configured with model.
+func (c AgentConfig) TruncationRecovery(model ModelConfig) TruncationRecovery {
+	return TruncationRecovery{
+		MaxContinuations: c.MaxContinuations,
+		MaxTokens:        int(model.MaxTokens),
+		MaxTokensCeiling: int(model.MaxTokensCeiling),
+	}
 }
@@ -3,6 +3,7 @@ package bedrock
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sort"
@@ -10,9 +11,12 @@ import (
 	"time"
 
 	"pantryagent"
+	"pantryagent/prompts"
+	"pantryagent/tools"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 // Coordinator is responsible for managing the interaction between the LLM, tools, and output channel.
@@ -24,6 +28,11 @@ type Coordinator struct {
 	pantry         map[string]any
 	recipes        []any
 	tracerProvider *trace.TracerProvider
+	tracer         oteltrace.Tracer
+	hooks          pantryagent.Hooks
+	prompts        *prompts.Registry
+	promptVars     prompts.Vars
+	truncation     pantryagent.TruncationRecovery
 }
 
 type llmClient interface {
@@ -40,30 +49,83 @@ func NewCoordinator(llm llmClient, toolRegistry pantryagent.ToolProvider, pantry
 		pantry:         pantryData,
 		recipes:        recipeData,
 		tracerProvider: tracerProvider,
+		tracer:         otel.Tracer(pantryagent.TracerNameBedrock),
+		prompts:        prompts.Default(),
 	}
 }
 
+// Use appends hooks to the coordinator's hook chain and returns the coordinator for chaining.
+func (c *Coordinator) Use(hooks ...pantryagent.Hook) *Coordinator {
+	c.hooks = append(c.hooks, hooks...)
+	return c
+}
+
+// WithPrompts sets the registry the system prompt and nudges are rendered from, and the variables they are
+// rendered with. It returns the coordinator for chaining.
+func (c *Coordinator) WithPrompts(r *prompts.Registry, vars prompts.Vars) *Coordinator {
+	c.prompts, c.promptVars = r, vars
+	return c
+}
+
+// WithTruncationRecovery configures how the coordinator recovers from responses cut off by the output
+// token limit. It returns the coordinator for chaining.
+func (c *Coordinator) WithTruncationRecovery(r pantryagent.TruncationRecovery) *Coordinator {
+	c.truncation = r
+	return c
+}
+
 // Run executes the coordination process for a given task.
 func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
-	ctx, span := otel.Tracer(pantryagent.TracerNameBedrock).Start(ctx, "Coordinator.Run")
+	ctx, span := c.tracer.Start(ctx, "Coordinator.Run")
 	defer span.End()
 
 	slog.Info("COORDINATOR: Starting run", "task", task)
 
-	prompt, err := NewPrompt(task, c.toolProvider)
+	runEvent := pantryagent.RunEvent{Task: task, Tools: len(c.toolProvider.GetTools())}
+	if err := c.hooks.BeforeRun(ctx, &runEvent); err != nil {
+		return "", fmt.Errorf("run aborted by hook: %w", err)
+	}
+
+	start := time.Now()
+	rec := c.prompts.Recorder(c.promptVars)
+	out, iterations, err := c.run(ctx, task, rec)
+
+	runEvent.Output, runEvent.Iterations, runEvent.Duration, runEvent.Err = out, iterations, time.Since(start), err
+	runEvent.Prompts = rec.Versions()
+	if herr := c.hooks.AfterRun(ctx, &runEvent); herr != nil && err == nil {
+		return "", herr
+	}
+	return runEvent.Output, err
+}
+
+// run is the coordination loop. It returns the final output and the number of iterations used.
+func (c *Coordinator) run(ctx context.Context, task string, rec *prompts.Recorder) (string, int, error) {
+	prompt, err := NewPromptWith(rec, task, c.toolProvider)
 	if err != nil {
-		return "", fmt.Errorf("failed to apply system prompt: %w", err)
+		return "", 0, fmt.Errorf("failed to apply system prompt: %w", err)
 	}
 
 	var finalOut string
 	toolsAlreadyCalled := make(map[string]int) // Track how many times each tool has been called
 
-	for iter := 0; iter < c.maxIterations; iter++ {
+	truncation := pantryagent.Truncation[Message]{TruncationRecovery: c.truncation}
+
+	iter := 0
+	for ; iter < c.maxIterations; iter++ {
+		iterCtx, _ := c.tracer.Start(ctx, fmt.Sprintf("Coordinator.Run.Iteration.%d", iter+1))
+		iterStart := time.Now()
 		iterLog := pantryagent.IterationLog{Iteration: iter + 1, Timestamp: time.Now()}
+		invokeEvent := pantryagent.InvokeEvent{
+			Iteration: iter + 1,
+			Prompt:    &prompt,
+			Messages:  len(prompt.Messages),
+			Tools:     len(prompt.Tools),
+		}
 
 		// Log prompt
 		if b, merr := json.Marshal(prompt); merr == nil {
 			iterLog.LLMInput = string(b)
+			invokeEvent.PromptBytes = len(b)
 			slog.Info("COORDINATOR: Sending prompt to LLM",
 				"iteration", iter+1,
 				"messages_count", len(prompt.Messages),
@@ -86,12 +148,25 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 			)
 		}
 
+		if err := c.hooks.BeforeInvoke(iterCtx, &invokeEvent); err != nil {
+			iterLog.Error = err.Error()
+			c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+			return "", iter + 1, fmt.Errorf("invoke aborted by hook: %w", err)
+		}
+
 		// 1) Invoke model
-		res, err := c.llm.Invoke(ctx, prompt)
+		invokeStart := time.Now()
+		res, err := c.llm.Invoke(iterCtx, prompt)
+		invokeEvent.Response, invokeEvent.Err, invokeEvent.Duration = &res, err, time.Since(invokeStart)
+		invokeEvent.Content, invokeEvent.ContentLength, invokeEvent.ToolCalls = res.Content, len(res.Content), len(res.ToolCalls)
+		invokeEvent.Model, iterLog.Model = res.Model, res.Model
+		if herr := c.hooks.AfterInvoke(iterCtx, &invokeEvent); herr != nil && err == nil {
+			err = fmt.Errorf("response rejected by hook: %w", herr)
+		}
 		if err != nil {
 			iterLog.Error = err.Error()
-			c.logIteration(iterLog)
-			return "", fmt.Errorf("invoke failed: %w", err)
+			c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+			return "", iter + 1, fmt.Errorf("invoke failed: %w", err)
 		}
 		iterLog.LLMOutput = res
 
@@ -101,44 +176,77 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 			"tool_calls", len(res.ToolCalls),
 		)
 
+		if res.Truncated {
+			if truncation.CanContinue(res.Content, len(res.ToolCalls)) {
+				slog.Warn("COORDINATOR: Response truncated; requesting continuation", "iteration", iter+1, "continuations", truncation.Continuations())
+				nudge, err := rec.Render(prompts.NudgeContinueTruncated, nil)
+				if err != nil {
+					return "", iter + 1, c.failIteration(iterCtx, rec, &iterLog, iterStart, err)
+				}
+				prompt.Messages = truncation.Continue(prompt.Messages, res.Content,
+					Message{Role: "assistant", Content: []MessagePart{{Type: "text", Text: res.Content}}},
+					Message{Role: "user", Content: []MessagePart{{Type: "text", Text: nudge}}},
+				)
+				iterLog.Error = "response truncated; continuation requested"
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeTruncated, iterStart)
+				continue
+			}
+			if budget, ok := truncation.NextBudget(prompt.MaxTokens); ok {
+				// Start the answer over with a larger budget
+				slog.Warn("COORDINATOR: Response truncated; retrying with a larger budget", "iteration", iter+1, "max_tokens", budget)
+				prompt.Messages = truncation.Restart(prompt.Messages)
+				prompt.MaxTokens = budget
+				iterLog.Error = fmt.Sprintf("response truncated; retrying with max tokens %d", budget)
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeTruncated, iterStart)
+				continue
+			}
+			slog.Warn("COORDINATOR: Response truncated; no recovery left", "iteration", iter+1)
+		}
+		// Stitch a continued answer together and drop the continuation exchange from the conversation
+		prompt.Messages, res.Content = truncation.Finish(prompt.Messages, res.Content)
+
 		// If the assistant returned no tool calls, treat content as a potential final plan.
 		if len(res.ToolCalls) == 0 {
 			slog.Info("COORDINATOR: No tool calls; attempting to treat output as final plan", "iteration", iter+1, "content_length", len(res.Content))
-			finalJSON := strings.TrimSpace(res.Content)
-
-			// Validate final JSON structure.
-			if finalJSON == "" || !strings.HasPrefix(finalJSON, "{") || !strings.HasSuffix(finalJSON, "}") {
-				slog.Info("COORDINATOR: Output is not valid JSON format", "iteration", iter+1, "starts_with_brace", strings.HasPrefix(finalJSON, "{"), "ends_with_brace", strings.HasSuffix(finalJSON, "}"))
+			content := strings.TrimSpace(res.Content)
+			candidate := pantryagent.FinalCandidateEvent{Iteration: iter + 1, Content: content}
+
+			// Extract the plan JSON from fences or prose and validate its shape.
+			mealPlan, finalJSON, perr := pantryagent.ExtractMealPlan(content)
+			if errors.Is(perr, pantryagent.ErrNoPlanJSON) {
+				slog.Info("COORDINATOR: Output contains no JSON object", "iteration", iter+1)
+				c.rejectCandidate(iterCtx, &candidate, pantryagent.RejectionNotJSON)
 				// Not a final plan; ask it to proceed with tools for interactive context.
 				slog.Info("COORDINATOR: Requesting tools to build interactive context", "iteration", iter+1)
+				nudge, err := rec.Render(prompts.BedrockNotJSON, nil)
+				if err != nil {
+					return "", iter + 1, c.failIteration(iterCtx, rec, &iterLog, iterStart, err)
+				}
 				prompt.Messages = append(prompt.Messages, Message{
-					Role: "user",
-					Content: []MessagePart{{
-						Type: "text",
-						Text: `{"tool_calls":[{"name":"pantry_get","input":{"current_day":0}},{"name":"recipe_get","input":{"meal_types":["dinner"]}}]}`,
-					}},
+					Role:    "user",
+					Content: []MessagePart{{Type: "text", Text: nudge}},
 				})
-				c.logIteration(iterLog)
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeRejected, iterStart)
 				continue
 			}
-
-			// Validate shape with MealPlan type.
-			var mealPlan pantryagent.MealPlan
-			if err := json.Unmarshal([]byte(finalJSON), &mealPlan); err != nil || !mealPlan.IsValid() {
-				slog.Info("COORDINATOR: Final JSON failed schema validation", "error", err, "iteration", iter+1)
+			if perr != nil {
+				slog.Info("COORDINATOR: Final JSON failed schema validation", "error", perr, "iteration", iter+1)
+				c.rejectCandidate(iterCtx, &candidate, pantryagent.RejectionInvalidPlan)
 				// Ask the model to restate as valid JSON per schema.
 				msg := map[string]any{
 					"error":  "invalid_final_json",
-					"reason": fmt.Sprintf("parse/shape error: %v", err),
+					"reason": perr.Error(),
 				}
 				b, _ := json.Marshal(msg)
 				prompt.Messages = append(prompt.Messages, Message{
 					Role:    "user",
 					Content: []MessagePart{{Type: "text", Text: string(b)}},
 				})
-				c.logIteration(iterLog)
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeRejected, iterStart)
 				continue
 			}
+			candidate.Content = finalJSON
+			candidate.Plan = &mealPlan
 
 			// Feasibility check against static pantry/recipes data.
 			slog.Info("COORDINATOR: Running feasibility check",
@@ -153,9 +261,15 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 				}(),
 				"recipes_count", len(c.recipes))
 
+			feasibilityStart := time.Now()
 			feasible, probs, ferr := c.checkFeasible(finalJSON)
+			candidate.FeasibilityChecked = true
+			candidate.FeasibilityDuration = time.Since(feasibilityStart)
+			candidate.Problems = probs
+
 			if ferr != nil {
 				slog.Error("COORDINATOR: Feasibility check failed", "error", ferr, "iteration", iter+1)
+				c.rejectCandidate(iterCtx, &candidate, pantryagent.RejectionFeasibilityError)
 				msg := map[string]any{
 					"error":  "feasibility_check_failed",
 					"reason": ferr.Error(),
@@ -165,17 +279,22 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 					Role:    "user",
 					Content: []MessagePart{{Type: "text", Text: string(b)}},
 				})
-				c.logIteration(iterLog)
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeRejected, iterStart)
 				continue
 			}
 
 			if !feasible {
 				// Tell the model exactly why and ask it to re-plan (no mutations in this project).
 				slog.Warn("COORDINATOR: Feasibility check failed", "iteration", iter+1, "problems", probs)
+				c.rejectCandidate(iterCtx, &candidate, pantryagent.RejectionInfeasible)
+				hint, err := rec.Render(prompts.NudgeInfeasiblePlan, nil)
+				if err != nil {
+					return "", iter + 1, c.failIteration(iterCtx, rec, &iterLog, iterStart, err)
+				}
 				msg := map[string]any{
 					"error":   "infeasible_plan",
 					"details": probs,
-					"hint":    "Revise recipe choices so all required ingredients (with units) fit the pantry; then re-send final JSON.",
+					"hint":    hint,
 				}
 				b, _ := json.Marshal(msg)
 				prompt.Messages = append(prompt.Messages, Message{
@@ -183,13 +302,40 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 					Content: []MessagePart{{Type: "text", Text: string(b)}},
 				})
 				iterLog.Error = "infeasible final plan"
-				c.logIteration(iterLog)
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeRejected, iterStart)
 				continue
 			}
 
-			// Feasible — accept and finish.
-			finalOut = finalJSON
-			c.logIteration(iterLog)
+			// Feasible — give hooks the final say (guardrails, redaction).
+			if herr := c.hooks.OnFinalCandidate(iterCtx, &candidate); herr != nil {
+				slog.Warn("COORDINATOR: Final plan rejected by hook", "iteration", iter+1, "error", herr)
+				c.rejectCandidate(iterCtx, &candidate, pantryagent.RejectionHook)
+				hint, err := rec.Render(prompts.NudgeFinalPlanRejected, prompts.Vars{"Reason": herr.Error()})
+				if err != nil {
+					return "", iter + 1, c.failIteration(iterCtx, rec, &iterLog, iterStart, err)
+				}
+				msg := map[string]any{
+					"error":  "final_plan_rejected",
+					"reason": herr.Error(),
+					"hint":   hint,
+				}
+				var detailer pantryagent.RejectionDetailer
+				if errors.As(herr, &detailer) {
+					msg["details"] = detailer.RejectionDetails()
+				}
+				b, _ := json.Marshal(msg)
+				prompt.Messages = append(prompt.Messages, Message{
+					Role:    "user",
+					Content: []MessagePart{{Type: "text", Text: string(b)}},
+				})
+				iterLog.Error = "final plan rejected by hook"
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeRejected, iterStart)
+				continue
+			}
+
+			// Accept and finish.
+			finalOut = candidate.Content
+			c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFinal, iterStart)
 			break
 		}
 
@@ -208,9 +354,13 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 
 		if hasExcessiveRepetition {
 			// Provide more direct guidance without executing tools
+			hint, err := rec.Render(prompts.NudgeExcessiveToolRepetition, nil)
+			if err != nil {
+				return "", iter + 1, c.failIteration(iterCtx, rec, &iterLog, iterStart, err)
+			}
 			msg := map[string]any{
 				"error": "excessive_tool_repetition",
-				"hint":  "You've already gathered pantry and recipe data multiple times. Use the existing data to select feasible recipes that fit the available ingredients and provide the final JSON plan directly.",
+				"hint":  hint,
 			}
 			b, _ := json.Marshal(msg)
 			prompt.Messages = append(prompt.Messages, Message{
@@ -218,7 +368,7 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 				Content: []MessagePart{{Type: "text", Text: string(b)}},
 			})
 			iterLog.Error = "excessive tool repetition"
-			c.logIteration(iterLog)
+			c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeRepetitionPrevented, iterStart)
 			continue
 		}
 
@@ -244,37 +394,12 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 		var toolResults []ToolResult
 
 		for _, call := range res.ToolCalls {
-			tlog := pantryagent.ToolCallLog{Name: call.Name, Input: call.Input}
-			tool, gerr := c.toolProvider.GetTool(call.Name)
-			if gerr != nil {
-				tlog.Error = gerr.Error()
-				toolCallLogs = append(toolCallLogs, tlog)
-				toolResults = append(toolResults, ToolResult{
-					ToolUseID: call.ToolUseID,
-					ToolName:  call.Name,
-					Data:      map[string]any{"error": fmt.Sprintf("tool %q not found: %v", call.Name, gerr)},
-				})
-				continue
-			}
-
-			result, rerr := tool.Run(ctx, call.Input)
-			if rerr != nil {
-				tlog.Error = rerr.Error()
-				toolCallLogs = append(toolCallLogs, tlog)
-				toolResults = append(toolResults, ToolResult{
-					ToolUseID: call.ToolUseID,
-					ToolName:  tool.Name(),
-					Data:      map[string]any{"error": fmt.Sprintf("tool %q failed: %v", call.Name, rerr)},
-				})
-				continue
-			}
-
-			tlog.Output = result
+			tlog, data := c.callTool(iterCtx, iter+1, call)
 			toolCallLogs = append(toolCallLogs, tlog)
 			toolResults = append(toolResults, ToolResult{
 				ToolUseID: call.ToolUseID,
-				ToolName:  tool.Name(),
-				Data:      result,
+				ToolName:  call.Name,
+				Data:      data,
 			})
 		}
 
@@ -283,10 +408,75 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 		}
 
 		iterLog.ToolCalls = toolCallLogs
-		c.logIteration(iterLog)
+		c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeToolCalls, iterStart)
+	}
+
+	return finalOut, min(iter+1, c.maxIterations), nil
+}
+
+// callTool runs a single tool call through the hook chain. It returns the call's log entry and the
+// data to feed back to the model, which is an error payload when the tool is unknown or fails.
+func (c *Coordinator) callTool(ctx context.Context, iteration int, call tools.Call) (pantryagent.ToolCallLog, map[string]any) {
+	event := pantryagent.ToolCallEvent{Iteration: iteration, Name: call.Name, Input: call.Input}
+	tlog := pantryagent.ToolCallLog{Name: call.Name, Input: call.Input}
+
+	if err := c.hooks.BeforeToolCall(ctx, &event); err != nil {
+		tlog.Error = err.Error()
+		return tlog, map[string]any{"error": fmt.Sprintf("tool %q rejected: %v", call.Name, err)}
+	}
+	tlog.Name, tlog.Input = event.Name, event.Input
+
+	tool, gerr := c.toolProvider.GetTool(event.Name)
+	if gerr != nil {
+		event.Err = gerr
+		_ = c.hooks.AfterToolCall(ctx, &event)
+		tlog.Error = gerr.Error()
+		return tlog, map[string]any{"error": fmt.Sprintf("tool %q not found: %v", call.Name, gerr)}
+	}
+
+	start := time.Now()
+	result, rerr := tool.Run(ctx, event.Input)
+	event.Output, event.Err, event.Duration = result, rerr, time.Since(start)
+	if herr := c.hooks.AfterToolCall(ctx, &event); herr != nil && rerr == nil {
+		rerr = herr
 	}
+	if rerr != nil {
+		tlog.Error = rerr.Error()
+		if toolErr, ok := tools.AsToolError(rerr); ok {
+			return tlog, toolErr.Payload()
+		}
+		return tlog, map[string]any{"error": fmt.Sprintf("tool %q failed: %v", call.Name, rerr)}
+	}
+
+	tlog.Output = event.Output
+	return tlog, event.Output
+}
+
+// rejectCandidate reports a final candidate the coordinator rejected to the hooks.
+// Hook errors are ignored since the candidate is rejected anyway.
+func (c *Coordinator) rejectCandidate(ctx context.Context, candidate *pantryagent.FinalCandidateEvent, reason string) {
+	candidate.Rejection = reason
+	_ = c.hooks.OnFinalCandidate(ctx, candidate)
+}
+
+// endIteration runs the iteration end hooks, logs the iteration and ends its span.
+func (c *Coordinator) endIteration(ctx context.Context, rec *prompts.Recorder, iterLog *pantryagent.IterationLog, outcome string, started time.Time) {
+	iterLog.Prompts = rec.Versions()
+	_ = c.hooks.OnIterationEnd(ctx, &pantryagent.IterationEndEvent{
+		Iteration: iterLog.Iteration,
+		Outcome:   outcome,
+		Duration:  time.Since(started),
+		Log:       iterLog,
+	})
+	c.logIteration(*iterLog)
+	oteltrace.SpanFromContext(ctx).End()
+}
 
-	return finalOut, nil
+// failIteration ends an iteration that failed with err and returns err.
+func (c *Coordinator) failIteration(ctx context.Context, rec *prompts.Recorder, iterLog *pantryagent.IterationLog, started time.Time, err error) error {
+	iterLog.Error = err.Error()
+	c.endIteration(ctx, rec, iterLog, pantryagent.OutcomeFailed, started)
+	return err
 }
 
 // checkFeasible validates that a candidate final JSON meal plan is doable with the
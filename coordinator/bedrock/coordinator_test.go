@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"os"
 	"pantryagent"
+	"pantryagent/prompts"
 	"pantryagent/tools"
 	"pantryagent/tools/storage"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -19,9 +22,11 @@ import (
 type mockLLM struct {
 	responses []Response
 	callCount int
+	prompts   []Prompt
 }
 
 func (m *mockLLM) Invoke(ctx context.Context, prompt Prompt) (Response, error) {
+	m.prompts = append(m.prompts, prompt)
 	if m.callCount >= len(m.responses) {
 		return Response{}, errors.New("no more responses available")
 	}
@@ -211,6 +216,21 @@ func TestCoordinatorRun(t *testing.T) {
 				assert.True(t, mealPlan.IsValid())
 			},
 		},
+		{
+			name:          "fenced plan with prose and trailing commas is accepted",
+			task:          "Plan meals",
+			maxIterations: 5,
+			llmResponses: []Response{
+				{Content: "Here is the plan:\n```json\n" + strings.Replace(validMealPlanJSON(), `"servings": 1`, `"servings": 1,`, 1) + "\n```\nLet me know if you need changes."},
+			},
+			resultValidator: func(t *testing.T, result string) {
+				var mealPlan pantryagent.MealPlan
+				err := json.Unmarshal([]byte(result), &mealPlan)
+				require.NoError(t, err)
+				assert.True(t, mealPlan.IsValid())
+				assert.True(t, strings.HasPrefix(result, "{"))
+			},
+		},
 		{
 			name:          "max iterations reached",
 			task:          "Plan meals",
@@ -599,3 +619,281 @@ func TestCoordinatorEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+// recordingHook records the callbacks it receives and optionally rewrites or rejects traffic.
+type recordingHook struct {
+	pantryagent.NopHook
+	calls           []string
+	outcomes        []string
+	rejections      []string
+	rejectFinalOnce error
+	toolInput       map[string]any
+	runPrompts      map[string]string
+}
+
+func (h *recordingHook) BeforeRun(ctx context.Context, e *pantryagent.RunEvent) error {
+	h.calls = append(h.calls, "BeforeRun")
+	return nil
+}
+
+func (h *recordingHook) AfterRun(ctx context.Context, e *pantryagent.RunEvent) error {
+	h.calls = append(h.calls, "AfterRun")
+	h.runPrompts = e.Prompts
+	return nil
+}
+
+func (h *recordingHook) BeforeInvoke(ctx context.Context, e *pantryagent.InvokeEvent) error {
+	h.calls = append(h.calls, "BeforeInvoke")
+	return nil
+}
+
+func (h *recordingHook) AfterInvoke(ctx context.Context, e *pantryagent.InvokeEvent) error {
+	h.calls = append(h.calls, "AfterInvoke")
+	return nil
+}
+
+func (h *recordingHook) BeforeToolCall(ctx context.Context, e *pantryagent.ToolCallEvent) error {
+	h.calls = append(h.calls, "BeforeToolCall")
+	if h.toolInput != nil {
+		e.Input = h.toolInput
+	}
+	return nil
+}
+
+func (h *recordingHook) AfterToolCall(ctx context.Context, e *pantryagent.ToolCallEvent) error {
+	h.calls = append(h.calls, "AfterToolCall")
+	return nil
+}
+
+func (h *recordingHook) OnFinalCandidate(ctx context.Context, e *pantryagent.FinalCandidateEvent) error {
+	h.calls = append(h.calls, "OnFinalCandidate")
+	if e.Rejection != "" {
+		h.rejections = append(h.rejections, e.Rejection)
+	}
+	if e.Rejection == "" && h.rejectFinalOnce != nil {
+		err := h.rejectFinalOnce
+		h.rejectFinalOnce = nil
+		return err
+	}
+	return nil
+}
+
+func (h *recordingHook) OnIterationEnd(ctx context.Context, e *pantryagent.IterationEndEvent) error {
+	h.calls = append(h.calls, "OnIterationEnd")
+	h.outcomes = append(h.outcomes, e.Outcome)
+	return nil
+}
+
+func TestCoordinatorHooks(t *testing.T) {
+	t.Run("callbacks are dispatched in order", func(t *testing.T) {
+		registry, err := setupTestRegistry()
+		require.NoError(t, err)
+
+		hook := &recordingHook{}
+		mockLLMClient := newMockLLM(
+			Response{ToolCalls: []tools.Call{{Name: "pantry_get", Input: map[string]any{"current_day": 0}}}},
+			Response{Content: validMealPlanJSON()},
+		)
+		coordinator := NewCoordinator(mockLLMClient, registry, validPantryData(), validRecipeData(), 5,
+			pantryagent.NewNoOpCoordinationLogger(), trace.NewTracerProvider()).Use(hook)
+
+		result, err := coordinator.Run(context.Background(), "Plan meals")
+		require.NoError(t, err)
+		assert.NotEmpty(t, result)
+
+		assert.Equal(t, []string{
+			"BeforeRun",
+			"BeforeInvoke", "AfterInvoke", "BeforeToolCall", "AfterToolCall", "OnIterationEnd",
+			"BeforeInvoke", "AfterInvoke", "OnFinalCandidate", "OnIterationEnd",
+			"AfterRun",
+		}, hook.calls)
+		assert.Equal(t, []string{pantryagent.OutcomeToolCalls, pantryagent.OutcomeFinal}, hook.outcomes)
+	})
+
+	t.Run("final candidate rejected by hook is sent back to the model", func(t *testing.T) {
+		registry, err := setupTestRegistry()
+		require.NoError(t, err)
+
+		hook := &recordingHook{rejectFinalOnce: errors.New("plan repeats the same meal")}
+		mockLLMClient := newMockLLM(
+			Response{Content: validMealPlanJSON()},
+			Response{Content: validMealPlanJSON()},
+		)
+		coordinator := NewCoordinator(mockLLMClient, registry, validPantryData(), validRecipeData(), 5,
+			pantryagent.NewNoOpCoordinationLogger(), trace.NewTracerProvider()).Use(hook)
+
+		result, err := coordinator.Run(context.Background(), "Plan meals")
+		require.NoError(t, err)
+		assert.NotEmpty(t, result)
+		assert.Equal(t, 2, mockLLMClient.callCount)
+		assert.Equal(t, []string{pantryagent.OutcomeRejected, pantryagent.OutcomeFinal}, hook.outcomes)
+		assert.Equal(t, []string{pantryagent.RejectionHook}, hook.rejections, "reports the rejection to the hooks")
+	})
+
+	t.Run("tool input rewritten by hook reaches the tool", func(t *testing.T) {
+		hook := &recordingHook{toolInput: map[string]any{"meal_types": []any{"lunch"}}}
+		logger := &capturingLogger{}
+		mockLLMClient := newMockLLM(
+			Response{ToolCalls: []tools.Call{{Name: "recipe_get", Input: map[string]any{}}}},
+			Response{Content: validMealPlanJSON()},
+		)
+		recipeBytes, _ := json.Marshal(validRecipeData())
+		registry, err := tools.NewRegistry(storage.NewTestPantryState([]byte(`{}`)), storage.NewTestRecipeState(recipeBytes))
+		require.NoError(t, err)
+
+		coordinator := NewCoordinator(mockLLMClient, registry, validPantryData(), validRecipeData(), 5,
+			logger, trace.NewTracerProvider()).Use(hook)
+
+		_, err = coordinator.Run(context.Background(), "Plan meals")
+		require.NoError(t, err)
+
+		require.NotEmpty(t, logger.iterations)
+		require.Len(t, logger.iterations[0].ToolCalls, 1)
+		recipes, ok := logger.iterations[0].ToolCalls[0].Output["recipes"].([]map[string]any)
+		require.True(t, ok)
+		require.Len(t, recipes, 1)
+		assert.Equal(t, "lunch_grilled_cheese", recipes[0]["id"])
+	})
+
+	t.Run("before run error aborts the run", func(t *testing.T) {
+		registry, err := setupTestRegistry()
+		require.NoError(t, err)
+
+		mockLLMClient := newMockLLM(Response{Content: validMealPlanJSON()})
+		coordinator := NewCoordinator(mockLLMClient, registry, validPantryData(), validRecipeData(), 5,
+			pantryagent.NewNoOpCoordinationLogger(), trace.NewTracerProvider()).Use(abortingHook{})
+
+		_, err = coordinator.Run(context.Background(), "Plan meals")
+		assert.ErrorContains(t, err, "run aborted by hook")
+		assert.Equal(t, 0, mockLLMClient.callCount)
+	})
+}
+
+type abortingHook struct{ pantryagent.NopHook }
+
+func (abortingHook) BeforeRun(ctx context.Context, e *pantryagent.RunEvent) error {
+	return errors.New("budget exhausted")
+}
+
+// capturingLogger keeps iteration logs in memory for assertions.
+type capturingLogger struct {
+	iterations []pantryagent.IterationLog
+}
+
+func (l *capturingLogger) LogIteration(iteration pantryagent.IterationLog) error {
+	l.iterations = append(l.iterations, iteration)
+	return nil
+}
+
+func TestCoordinatorPrompts(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bedrock", "system"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bedrock", "system", "v2.tmpl"), []byte("Plan meals for {{.Household}}.\n"), 0o644))
+	promptRegistry, err := prompts.Configure(dir, nil)
+	require.NoError(t, err)
+
+	registry, err := setupTestRegistry()
+	require.NoError(t, err)
+
+	hook := &recordingHook{}
+	logger := &capturingLogger{}
+	mockLLMClient := newMockLLM(
+		Response{Content: "Let me think about this."},
+		Response{Content: validMealPlanJSON()},
+	)
+	coordinator := NewCoordinator(mockLLMClient, registry, validPantryData(), validRecipeData(), 5,
+		logger, trace.NewTracerProvider()).Use(hook).WithPrompts(promptRegistry, prompts.Vars{"Household": "two adults"})
+
+	_, err = coordinator.Run(context.Background(), "Plan meals")
+	require.NoError(t, err)
+
+	// The latest system prompt is rendered with the run's variables
+	require.Len(t, mockLLMClient.prompts, 2)
+	assert.Equal(t, "Plan meals for two adults.", mockLLMClient.prompts[0].Messages[0].Content.Join())
+
+	// The nudge comes from the registry too, and every version used is recorded
+	nudge, err := prompts.Default().Render(prompts.BedrockNotJSON, nil)
+	require.NoError(t, err)
+	msgs := mockLLMClient.prompts[1].Messages
+	assert.Equal(t, nudge, msgs[len(msgs)-1].Content.Join())
+
+	want := map[string]string{prompts.BedrockSystem: "v2", prompts.BedrockNotJSON: "v2"}
+	assert.Equal(t, want, hook.runPrompts)
+	require.Len(t, logger.iterations, 2)
+	assert.Equal(t, want, logger.iterations[0].Prompts)
+}
+
+func TestCoordinatorTruncation(t *testing.T) {
+	registry, err := setupTestRegistry()
+	require.NoError(t, err)
+	plan := validMealPlanJSON()
+	cut := len(plan) / 2
+
+	t.Run("truncated answer is continued and stitched", func(t *testing.T) {
+		llm := newMockLLM(
+			Response{Content: plan[:cut], Truncated: true},
+			Response{Content: plan[cut:]},
+		)
+		hook := &recordingHook{}
+		result, err := NewCoordinator(llm, registry, validPantryData(), validRecipeData(), 5, &capturingLogger{}, trace.NewTracerProvider()).
+			Use(hook).Run(context.Background(), "Plan meals")
+		require.NoError(t, err)
+		assert.JSONEq(t, plan, result)
+
+		// The partial answer is sent back with a request to continue
+		require.Len(t, llm.prompts, 2)
+		msgs := llm.prompts[1].Messages
+		require.Len(t, msgs, 4)
+		assert.Equal(t, "assistant", msgs[2].Role)
+		assert.Equal(t, plan[:cut], msgs[2].Content.Join())
+		nudge, err := prompts.Default().Render(prompts.NudgeContinueTruncated, nil)
+		require.NoError(t, err)
+		assert.Equal(t, nudge, msgs[3].Content.Join())
+
+		assert.Equal(t, []string{pantryagent.OutcomeTruncated, pantryagent.OutcomeFinal}, hook.outcomes)
+	})
+
+	t.Run("budget is raised up to the ceiling", func(t *testing.T) {
+		llm := newMockLLM(
+			Response{Content: plan[:cut], Truncated: true},
+			Response{Content: plan[:cut], Truncated: true},
+			Response{Content: plan[:cut], Truncated: true},
+			Response{Content: plan},
+		)
+		hook := &recordingHook{}
+		recovery := pantryagent.TruncationRecovery{MaxContinuations: -1, MaxTokens: 1024, MaxTokensCeiling: 3000}
+		result, err := NewCoordinator(llm, registry, validPantryData(), validRecipeData(), 5, &capturingLogger{}, trace.NewTracerProvider()).
+			Use(hook).WithTruncationRecovery(recovery).Run(context.Background(), "Plan meals")
+		require.NoError(t, err)
+		assert.JSONEq(t, plan, result)
+
+		require.Len(t, llm.prompts, 4)
+		var budgets []int
+		for _, p := range llm.prompts {
+			budgets = append(budgets, p.MaxTokens)
+		}
+		assert.Equal(t, []int{0, 2048, 3000, 3000}, budgets)
+
+		// At the ceiling the truncated answer is validated (and rejected) like any other
+		want := []string{pantryagent.OutcomeTruncated, pantryagent.OutcomeTruncated, pantryagent.OutcomeRejected, pantryagent.OutcomeFinal}
+		assert.Equal(t, want, hook.outcomes)
+	})
+
+	t.Run("continuation is dropped when the budget is raised", func(t *testing.T) {
+		llm := newMockLLM(
+			Response{Content: plan[:cut], Truncated: true},
+			Response{Content: plan[cut:], Truncated: true},
+			Response{Content: plan},
+		)
+		recovery := pantryagent.TruncationRecovery{MaxContinuations: 1, MaxTokens: 1024, MaxTokensCeiling: 4096}
+		result, err := NewCoordinator(llm, registry, validPantryData(), validRecipeData(), 5, &capturingLogger{}, trace.NewTracerProvider()).
+			WithTruncationRecovery(recovery).Run(context.Background(), "Plan meals")
+		require.NoError(t, err)
+		assert.JSONEq(t, plan, result)
+
+		require.Len(t, llm.prompts, 3)
+		assert.Len(t, llm.prompts[1].Messages, 4)
+		assert.Len(t, llm.prompts[2].Messages, 2)
+		assert.Equal(t, 2048, llm.prompts[2].MaxTokens)
+	})
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"pantryagent"
 	"strings"
 
 	"pantryagent/tools"
@@ -68,6 +67,9 @@ func NewLLMClient(brc bedrockRuntimeClient, opts LLMOptions) *LLMClient {
 	}
 }
 
+// ModelID returns the Bedrock model (or inference profile) ID the client invokes.
+func (c *LLMClient) ModelID() string { return c.opts.ModelID }
+
 func (c *LLMClient) Invoke(ctx context.Context, prompt Prompt) (Response, error) {
 	slog.Info("LLM_CLIENT: Invoked", "messages_len", len(prompt.Messages))
 
@@ -167,17 +169,25 @@ func (c *LLMClient) Invoke(ctx context.Context, prompt Prompt) (Response, error)
 		slog.Info("LLM_CLIENT: Registered tool", "name", t.Name, "description", t.Description, "input_schema", t.InputSchema)
 	}
 
+	maxTokens := c.opts.MaxTokens
+	if prompt.MaxTokens > 0 {
+		maxTokens = int32(prompt.MaxTokens)
+	}
+
 	// Invoke the Bedrock Converse API
 	in := &bedrockruntime.ConverseInput{
 		ModelId:  &c.opts.ModelID,
 		System:   sys,
 		Messages: msgs,
 		InferenceConfig: &types.InferenceConfiguration{
-			MaxTokens:   aws.Int32(c.opts.MaxTokens),
+			MaxTokens:   aws.Int32(maxTokens),
 			Temperature: aws.Float32(c.opts.Temperature),
 			TopP:        aws.Float32(c.opts.TopP),
 		},
-		ToolConfig: &types.ToolConfiguration{Tools: tools, ToolChoice: &types.ToolChoiceMemberAuto{}},
+	}
+	// Bedrock rejects a tool configuration without tools, e.g. for prompts that only need text back
+	if len(tools) > 0 {
+		in.ToolConfig = &types.ToolConfiguration{Tools: tools, ToolChoice: &types.ToolChoiceMemberAuto{}}
 	}
 	out, err := c.brc.Converse(ctx, in)
 	if err != nil {
@@ -200,7 +210,7 @@ func (c *LLMClient) Invoke(ctx context.Context, prompt Prompt) (Response, error)
 			return Response{}, fmt.Errorf("failed to parse tool calls: %w", err)
 		}
 		slog.Info("LLM_CLIENT: Extracted tool calls", "calls_len", len(calls))
-		return Response{ToolCalls: calls}, nil
+		return Response{ToolCalls: calls, Model: c.opts.ModelID}, nil
 
 	case "end_turn", "stop_sequence":
 		text, err := textFromOutput(out)
@@ -208,17 +218,24 @@ func (c *LLMClient) Invoke(ctx context.Context, prompt Prompt) (Response, error)
 			return Response{}, fmt.Errorf("failed to extract final text: %w", err)
 		}
 
-		// Validate the final output against the MealPlan schema
-		if err := json.Unmarshal([]byte(text), &pantryagent.MealPlan{}); err != nil {
-			return Response{}, fmt.Errorf("final output not valid JSON: %w", err)
-		}
-
+		// The coordinator extracts and validates the plan; final text may wrap it in fences or prose.
 		slog.Info("LLM_CLIENT: Extracted final text", "text_len", len(text))
-		return Response{Content: text}, nil
+		return Response{Content: text, Model: c.opts.ModelID}, nil
 
 	case "max_tokens":
-		slog.Warn("LLM_CLIENT: Model hit MaxTokens limit; consider increasing MaxTokens or chunking")
-		return Response{}, fmt.Errorf("model hit MaxTokens limit; consider increasing MaxTokens or chunking")
+		// Return what was generated; the coordinator continues the answer or retries with a larger budget.
+		slog.Warn("LLM_CLIENT: Model hit MaxTokens limit", "max_tokens", maxTokens)
+		text, err := textFromOutput(out)
+		if err != nil {
+			return Response{}, fmt.Errorf("failed to extract truncated text: %w", err)
+		}
+		// Tool use cut off mid-input can't be parsed; the coordinator retries it
+		calls, err := toolCallsFromOutput(out)
+		if err != nil {
+			slog.Warn("LLM_CLIENT: Dropping truncated tool calls", "error", err)
+			calls = nil
+		}
+		return Response{Content: text, ToolCalls: calls, Model: c.opts.ModelID, Truncated: true}, nil
 
 	case "safety", "content_filtered":
 		slog.Warn("LLM_CLIENT: Model response blocked by Bedrock safety filters")
@@ -234,7 +251,7 @@ func (c *LLMClient) Invoke(ctx context.Context, prompt Prompt) (Response, error)
 		if err != nil {
 			return Response{}, fmt.Errorf("failed to parse tool calls: %w", err)
 		}
-		return Response{Content: text, ToolCalls: calls}, nil
+		return Response{Content: text, ToolCalls: calls, Model: c.opts.ModelID}, nil
 	}
 }
 
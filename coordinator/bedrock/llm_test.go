@@ -18,9 +18,11 @@ import (
 type mockBedrockClient struct {
 	response *bedrockruntime.ConverseOutput
 	err      error
+	input    *bedrockruntime.ConverseInput
 }
 
 func (m *mockBedrockClient) Converse(ctx context.Context, input *bedrockruntime.ConverseInput, opts ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error) {
+	m.input = input
 	return m.response, m.err
 }
 
@@ -114,7 +116,7 @@ func TestLLMClient_Invoke(t *testing.T) {
 					LatencyMs: aws.Int64(100),
 				},
 			},
-			expectedResp: Response{Content: `{"meals": []}`},
+			expectedResp: Response{Content: `{"meals": []}`, Model: defaultModelID},
 		},
 		{
 			name: "tool use response",
@@ -150,10 +152,11 @@ func TestLLMClient_Invoke(t *testing.T) {
 				ToolCalls: []tools.Call{
 					{Name: "pantry_get", Input: map[string]any{}, ToolUseID: "test-id"},
 				},
+				Model: defaultModelID,
 			},
 		},
 		{
-			name: "max tokens error",
+			name: "max tokens returns truncated text",
 			prompt: Prompt{
 				Messages: []Message{
 					{Role: "user", Content: MessageParts{{Type: "text", Text: "Hello"}}},
@@ -161,6 +164,13 @@ func TestLLMClient_Invoke(t *testing.T) {
 			},
 			mockResponse: &bedrockruntime.ConverseOutput{
 				StopReason: "max_tokens",
+				Output: &types.ConverseOutputMemberMessage{
+					Value: types.Message{
+						Content: []types.ContentBlock{
+							&types.ContentBlockMemberText{Value: `{"summary": "Plan", "days_pl`},
+						},
+					},
+				},
 				Usage: &types.TokenUsage{
 					InputTokens:  aws.Int32(10),
 					OutputTokens: aws.Int32(20),
@@ -169,7 +179,7 @@ func TestLLMClient_Invoke(t *testing.T) {
 					LatencyMs: aws.Int64(100),
 				},
 			},
-			expectedError: "model hit MaxTokens limit",
+			expectedResp: Response{Content: `{"summary": "Plan", "days_pl`, Model: defaultModelID, Truncated: true},
 		},
 		{
 			name: "safety filter error",
@@ -191,7 +201,7 @@ func TestLLMClient_Invoke(t *testing.T) {
 			expectedError: "model response blocked by Bedrock safety filters",
 		},
 		{
-			name: "invalid final JSON",
+			name: "final text is not validated",
 			prompt: Prompt{
 				Messages: []Message{
 					{Role: "user", Content: MessageParts{{Type: "text", Text: "Hello"}}},
@@ -214,7 +224,7 @@ func TestLLMClient_Invoke(t *testing.T) {
 					LatencyMs: aws.Int64(100),
 				},
 			},
-			expectedError: "final output not valid JSON",
+			expectedResp: Response{Content: "invalid json", Model: defaultModelID},
 		},
 		{
 			name: "bedrock API error",
@@ -250,6 +260,28 @@ func TestLLMClient_Invoke(t *testing.T) {
 	}
 }
 
+func TestLLMClient_InvokeMaxTokens(t *testing.T) {
+	out := &bedrockruntime.ConverseOutput{
+		StopReason: "end_turn",
+		Output: &types.ConverseOutputMemberMessage{
+			Value: types.Message{Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: "ok"}}},
+		},
+		Usage:   &types.TokenUsage{InputTokens: aws.Int32(10), OutputTokens: aws.Int32(20)},
+		Metrics: &types.ConverseMetrics{LatencyMs: aws.Int64(100)},
+	}
+	mockClient := &mockBedrockClient{response: out}
+	llmClient := NewLLMClient(mockClient, LLMOptions{MaxTokens: 1024})
+	msgs := []Message{{Role: "user", Content: MessageParts{{Type: "text", Text: "Hello"}}}}
+
+	_, err := llmClient.Invoke(context.Background(), Prompt{Messages: msgs})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1024), aws.ToInt32(mockClient.input.InferenceConfig.MaxTokens))
+
+	_, err = llmClient.Invoke(context.Background(), Prompt{Messages: msgs, MaxTokens: 4096})
+	require.NoError(t, err)
+	assert.Equal(t, int32(4096), aws.ToInt32(mockClient.input.InferenceConfig.MaxTokens))
+}
+
 func TestTextFromOutput(t *testing.T) {
 	tests := []struct {
 		name     string
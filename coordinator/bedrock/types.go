@@ -57,6 +57,9 @@ func NewToolResultMessage(results []ToolResult) Message {
 type Response struct {
 	Content   string       `json:"content,omitempty"`
 	ToolCalls []tools.Call `json:"tool_calls,omitempty"`
+	Model     string       `json:"model,omitempty"` // model that produced the response
+	// Truncated reports that the response was cut off by the output token limit.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 // ParseModelOutput parses model output text to extract both tool calls and remaining content.
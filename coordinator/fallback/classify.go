@@ -0,0 +1,89 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"pantryagent/coordinator/ollama"
+
+	"github.com/aws/smithy-go"
+)
+
+// ErrorClass groups backend errors by what they mean for the fallback chain.
+type ErrorClass string
+
+const (
+	// ClassUnavailable means the backend could not be reached or is overloaded (outage, throttling,
+	// connection refused). The next backend is tried; the failed one is tried again on the next turn.
+	ClassUnavailable ErrorClass = "unavailable"
+	// ClassAccessDenied means the model cannot be used at all (access not granted, model not found or
+	// not pulled). The next backend is tried and the client skips the failed one from then on, in
+	// every later run too.
+	ClassAccessDenied ErrorClass = "access_denied"
+	// ClassModelError means the backend answered but could not produce a usable response.
+	// The next backend is tried.
+	ClassModelError ErrorClass = "model_error"
+	// ClassCanceled means the caller's context is done. The chain stops.
+	ClassCanceled ErrorClass = "canceled"
+	// ClassUnknown is any other error. The chain stops, since another model is unlikely to help.
+	ClassUnknown ErrorClass = "unknown"
+)
+
+// Classify maps errors returned by the Bedrock and Ollama clients to an ErrorClass.
+func Classify(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ClassCanceled
+	}
+
+	// Bedrock (AWS API errors)
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "AccessDeniedException", "ResourceNotFoundException", "UnrecognizedClientException":
+			return ClassAccessDenied
+		case "ThrottlingException", "ServiceUnavailableException", "InternalServerException",
+			"ModelNotReadyException", "ModelTimeoutException", "ServiceQuotaExceededException":
+			return ClassUnavailable
+		case "ModelErrorException", "ValidationException":
+			return ClassModelError
+		}
+		return ClassUnknown
+	}
+
+	// Ollama (HTTP status errors)
+	var statusErr *ollama.StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusNotFound,
+			statusErr.StatusCode == http.StatusUnauthorized,
+			statusErr.StatusCode == http.StatusForbidden:
+			return ClassAccessDenied
+		case statusErr.StatusCode == http.StatusTooManyRequests,
+			statusErr.StatusCode >= http.StatusInternalServerError:
+			return ClassUnavailable
+		}
+		return ClassUnknown
+	}
+
+	// Transport errors, e.g. Ollama not running
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ClassUnavailable
+	}
+
+	return ClassUnknown
+}
+
+// failover reports whether errors of the class should move on to the next backend.
+func (c ErrorClass) failover() bool {
+	switch c {
+	case ClassUnavailable, ClassAccessDenied, ClassModelError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+// Package fallback provides an LLM client that fails over across an ordered chain of backends,
+// e.g. Bedrock Sonnet → Bedrock Haiku → local Ollama.
+//
+// The chain speaks the Bedrock prompt format, so it can be used wherever a bedrock.LLMClient is;
+// prompts are translated for backends of other providers.
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"pantryagent/coordinator/bedrock"
+	"pantryagent/coordinator/ollama"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+type llmClient interface {
+	Invoke(ctx context.Context, prompt bedrock.Prompt) (bedrock.Response, error)
+}
+
+// Backend is a single link of the chain.
+type Backend struct {
+	// Name identifies the backend in logs and errors, e.g. "bedrock:us.anthropic.claude-3-7-sonnet-20250219-v1:0".
+	Name   string
+	Client llmClient
+}
+
+// Bedrock returns a backend invoking a Bedrock model.
+func Bedrock(c *bedrock.LLMClient) Backend {
+	return Backend{Name: "bedrock:" + c.ModelID(), Client: c}
+}
+
+// Ollama returns a backend invoking an Ollama model, translating prompts and responses.
+func Ollama(c *ollama.Client) Backend {
+	return Backend{Name: "ollama:" + c.ModelID(), Client: &ollamaBackend{client: c}}
+}
+
+type ClientOpts struct {
+	Backends []Backend
+	// Classify maps backend errors to classes deciding whether to fail over. Defaults to Classify.
+	Classify func(error) ErrorClass
+}
+
+// Client invokes the first backend of the chain and moves on to the next one when an invocation fails
+// with an error classified as worth failing over. Backends denying access are skipped by the client
+// from then on, across runs. A Client is safe for concurrent use.
+type Client struct {
+	backends []Backend
+	classify func(error) ErrorClass
+
+	mu       sync.Mutex
+	disabled map[string]bool
+}
+
+func NewClient(opts ClientOpts) (*Client, error) {
+	if len(opts.Backends) == 0 {
+		return nil, fmt.Errorf("no backends configured")
+	}
+	if opts.Classify == nil {
+		opts.Classify = Classify
+	}
+	return &Client{
+		backends: opts.Backends,
+		classify: opts.Classify,
+		disabled: make(map[string]bool),
+	}, nil
+}
+
+func (c *Client) Invoke(ctx context.Context, prompt bedrock.Prompt) (bedrock.Response, error) {
+	var errs []error
+	for i, b := range c.backends {
+		if c.isDisabled(b.Name) {
+			continue
+		}
+
+		res, err := b.Client.Invoke(ctx, prompt)
+		if err == nil {
+			if res.Model == "" {
+				res.Model = b.Name
+			}
+			if i > 0 {
+				slog.Info("FALLBACK: Response produced by fallback backend", "backend", b.Name, "position", i)
+			}
+			return res, nil
+		}
+
+		class := c.classify(err)
+		errs = append(errs, fmt.Errorf("%s (%s): %w", b.Name, class, err))
+		if !class.failover() {
+			return bedrock.Response{}, fmt.Errorf("backend %s failed: %w", b.Name, err)
+		}
+		if class == ClassAccessDenied {
+			c.disable(b.Name)
+		}
+		slog.Warn("FALLBACK: Backend failed; trying next", "backend", b.Name, "class", class, "error", err)
+	}
+
+	if len(errs) == 0 {
+		return bedrock.Response{}, fmt.Errorf("all backends disabled: %s", strings.Join(c.names(), ", "))
+	}
+	return bedrock.Response{}, fmt.Errorf("all backends failed: %w", errors.Join(errs...))
+}
+
+func (c *Client) isDisabled(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.disabled[name]
+}
+
+func (c *Client) disable(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.disabled[name] = true
+}
+
+func (c *Client) names() []string {
+	names := make([]string, len(c.backends))
+	for i, b := range c.backends {
+		names[i] = b.Name
+	}
+	return names
+}
+
+type bedrockRuntimeClient interface {
+	Converse(context.Context, *bedrockruntime.ConverseInput, ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error)
+}
+
+// NewChain returns a client invoking the Bedrock model configured by primary first, then the backends
+// described by specs in order. Each spec is "bedrock:<model id>" or "ollama:<model>"; Bedrock fallbacks
+// reuse the primary's options except for the model ID, Ollama ones use ollamaOpts except for the model.
+func NewChain(brc bedrockRuntimeClient, primary bedrock.LLMOptions, ollamaOpts ollama.ClientOpts, specs []string) (*Client, error) {
+	backends := []Backend{Bedrock(bedrock.NewLLMClient(brc, primary))}
+	for _, spec := range specs {
+		provider, modelID, err := ParseSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		switch provider {
+		case "bedrock":
+			opts := primary
+			opts.ModelID = modelID
+			backends = append(backends, Bedrock(bedrock.NewLLMClient(brc, opts)))
+		case "ollama":
+			opts := ollamaOpts
+			opts.ModelID = modelID
+			oc, err := ollama.NewClient(opts)
+			if err != nil {
+				return nil, fmt.Errorf("fallback %q: %w", spec, err)
+			}
+			backends = append(backends, Ollama(oc))
+		}
+	}
+	return NewClient(ClientOpts{Backends: backends})
+}
+
+// ParseSpec splits a "provider:model" backend spec. Model IDs may contain colons themselves.
+func ParseSpec(spec string) (provider, modelID string, err error) {
+	provider, modelID, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok || modelID == "" {
+		return "", "", fmt.Errorf("invalid backend %q: want provider:model", spec)
+	}
+	switch provider {
+	case "bedrock", "ollama":
+		return provider, modelID, nil
+	}
+	return "", "", fmt.Errorf("invalid backend %q: unknown provider %q", spec, provider)
+}
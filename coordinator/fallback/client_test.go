@@ -0,0 +1,286 @@
+package fallback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"pantryagent/coordinator/bedrock"
+	"pantryagent/coordinator/ollama"
+	"pantryagent/tools"
+
+	"github.com/aws/smithy-go"
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockLLM struct {
+	model string
+	errs  []error
+	calls int
+}
+
+func (m *mockLLM) Invoke(ctx context.Context, prompt bedrock.Prompt) (bedrock.Response, error) {
+	m.calls++
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		if err != nil {
+			return bedrock.Response{}, err
+		}
+	}
+	return bedrock.Response{Content: `{"summary":"ok"}`, Model: m.model}, nil
+}
+
+func apiError(code string) error {
+	return &smithy.OperationError{
+		ServiceID:     "Bedrock Runtime",
+		OperationName: "Converse",
+		Err:           &smithy.GenericAPIError{Code: code, Message: "mock"},
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"access denied", apiError("AccessDeniedException"), ClassAccessDenied},
+		{"model not found", apiError("ResourceNotFoundException"), ClassAccessDenied},
+		{"throttled", apiError("ThrottlingException"), ClassUnavailable},
+		{"outage", apiError("ServiceUnavailableException"), ClassUnavailable},
+		{"model error", apiError("ModelErrorException"), ClassModelError},
+		{"other api error", apiError("ConflictException"), ClassUnknown},
+		{"ollama model not pulled", &ollama.StatusError{StatusCode: http.StatusNotFound}, ClassAccessDenied},
+		{"ollama overloaded", &ollama.StatusError{StatusCode: http.StatusServiceUnavailable}, ClassUnavailable},
+		{"ollama bad request", &ollama.StatusError{StatusCode: http.StatusBadRequest}, ClassUnknown},
+		{"connection refused", fmt.Errorf("post: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), ClassUnavailable},
+		{"canceled", fmt.Errorf("invoke: %w", context.Canceled), ClassCanceled},
+		{"plain error", errors.New("final output not valid JSON"), ClassUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Classify(tt.err))
+		})
+	}
+}
+
+func TestClient_Invoke(t *testing.T) {
+	t.Run("primary succeeds", func(t *testing.T) {
+		primary := &mockLLM{model: "sonnet"}
+		secondary := &mockLLM{model: "haiku"}
+		c, err := NewClient(ClientOpts{Backends: []Backend{{Name: "a", Client: primary}, {Name: "b", Client: secondary}}})
+		require.NoError(t, err)
+
+		res, err := c.Invoke(context.Background(), bedrock.Prompt{})
+		require.NoError(t, err)
+		assert.Equal(t, "sonnet", res.Model)
+		assert.Equal(t, 0, secondary.calls)
+	})
+
+	t.Run("fails over on classified errors", func(t *testing.T) {
+		primary := &mockLLM{model: "sonnet", errs: []error{apiError("ThrottlingException")}}
+		secondary := &mockLLM{model: "haiku"}
+		c, err := NewClient(ClientOpts{Backends: []Backend{{Name: "a", Client: primary}, {Name: "b", Client: secondary}}})
+		require.NoError(t, err)
+
+		res, err := c.Invoke(context.Background(), bedrock.Prompt{})
+		require.NoError(t, err)
+		assert.Equal(t, "haiku", res.Model)
+
+		// Unavailable backends are tried again on the next turn
+		res, err = c.Invoke(context.Background(), bedrock.Prompt{})
+		require.NoError(t, err)
+		assert.Equal(t, "sonnet", res.Model)
+		assert.Equal(t, 2, primary.calls)
+	})
+
+	t.Run("skips backends denying access from then on", func(t *testing.T) {
+		primary := &mockLLM{model: "sonnet", errs: []error{apiError("AccessDeniedException")}}
+		secondary := &mockLLM{model: "haiku"}
+		c, err := NewClient(ClientOpts{Backends: []Backend{{Name: "a", Client: primary}, {Name: "b", Client: secondary}}})
+		require.NoError(t, err)
+
+		for range 2 {
+			res, err := c.Invoke(context.Background(), bedrock.Prompt{})
+			require.NoError(t, err)
+			assert.Equal(t, "haiku", res.Model)
+		}
+		assert.Equal(t, 1, primary.calls)
+	})
+
+	t.Run("does not fail over on unknown errors", func(t *testing.T) {
+		primary := &mockLLM{errs: []error{errors.New("model response blocked by Bedrock safety filters")}}
+		secondary := &mockLLM{model: "haiku"}
+		c, err := NewClient(ClientOpts{Backends: []Backend{{Name: "a", Client: primary}, {Name: "b", Client: secondary}}})
+		require.NoError(t, err)
+
+		_, err = c.Invoke(context.Background(), bedrock.Prompt{})
+		assert.ErrorContains(t, err, "backend a failed")
+		assert.Equal(t, 0, secondary.calls)
+	})
+
+	t.Run("all backends fail", func(t *testing.T) {
+		primary := &mockLLM{errs: []error{apiError("ServiceUnavailableException")}}
+		secondary := &mockLLM{errs: []error{&ollama.StatusError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}}}
+		c, err := NewClient(ClientOpts{Backends: []Backend{{Name: "a", Client: primary}, {Name: "b", Client: secondary}}})
+		require.NoError(t, err)
+
+		_, err = c.Invoke(context.Background(), bedrock.Prompt{})
+		assert.ErrorContains(t, err, "all backends failed")
+		assert.ErrorContains(t, err, "a (unavailable)")
+		assert.ErrorContains(t, err, "b (unavailable)")
+	})
+
+	t.Run("model defaults to backend name", func(t *testing.T) {
+		c, err := NewClient(ClientOpts{Backends: []Backend{{Name: "bedrock:x", Client: &mockLLM{}}}})
+		require.NoError(t, err)
+
+		res, err := c.Invoke(context.Background(), bedrock.Prompt{})
+		require.NoError(t, err)
+		assert.Equal(t, "bedrock:x", res.Model)
+	})
+
+	t.Run("no backends", func(t *testing.T) {
+		_, err := NewClient(ClientOpts{})
+		assert.Error(t, err)
+	})
+}
+
+// deniedLLM denies access on every call; it is safe for concurrent use.
+type deniedLLM struct{ calls atomic.Int64 }
+
+func (m *deniedLLM) Invoke(context.Context, bedrock.Prompt) (bedrock.Response, error) {
+	m.calls.Add(1)
+	return bedrock.Response{}, apiError("AccessDeniedException")
+}
+
+// toolCallingOllama answers every prompt with a tool call.
+type toolCallingOllama struct{}
+
+func (toolCallingOllama) Invoke(context.Context, ollama.Prompt) (ollama.Response, error) {
+	return ollama.Response{Model: "llama3.1", ToolCalls: []ollama.ToolCall{{Name: "pantry_get"}}}, nil
+}
+
+func TestClient_InvokeConcurrently(t *testing.T) {
+	denied := &deniedLLM{}
+	c, err := NewClient(ClientOpts{Backends: []Backend{
+		{Name: "a", Client: denied},
+		{Name: "b", Client: &ollamaBackend{client: toolCallingOllama{}}},
+	}})
+	require.NoError(t, err)
+
+	const runs = 20
+	ids := make(chan string, runs)
+	var wg sync.WaitGroup
+	for range runs {
+		wg.Go(func() {
+			res, err := c.Invoke(context.Background(), bedrock.Prompt{})
+			assert.NoError(t, err)
+			for _, call := range res.ToolCalls {
+				ids <- call.ToolUseID
+			}
+		})
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := map[string]bool{}
+	for id := range ids {
+		assert.False(t, seen[id], "tool use ID %s is unique", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, runs)
+	assert.LessOrEqual(t, denied.calls.Load(), int64(runs))
+	assert.True(t, c.isDisabled("a"))
+}
+
+func TestOllamaBackend(t *testing.T) {
+	var got struct {
+		Model    string           `json:"model"`
+		Messages []ollama.Message `json:"messages"`
+		Tools    []ollama.Tool    `json:"tools"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &got))
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"recipe_get","arguments":{"meal_types":["dinner"]}}}]}}`)
+	}))
+	defer server.Close()
+
+	oc, err := ollama.NewClient(ollama.ClientOpts{
+		BaseEndpoint: server.URL,
+		ModelID:      "llama3.1",
+		Prompt:       ollama.Prompt{Messages: []ollama.Message{{Role: "system", Content: "ollama system prompt"}}},
+		HTTPClient:   http.DefaultClient,
+	})
+	require.NoError(t, err)
+
+	backend := Ollama(oc)
+	assert.Equal(t, "ollama:llama3.1", backend.Name)
+
+	prompt := bedrock.Prompt{
+		Messages: []bedrock.Message{
+			{Role: "system", Content: bedrock.MessageParts{{Type: "text", Text: "bedrock system prompt"}}},
+			{Role: "user", Content: bedrock.MessageParts{{Type: "text", Text: "Plan dinners"}}},
+			{Role: "assistant", Content: bedrock.MessageParts{
+				{Type: "tool_use", ToolUseID: "t1", ToolName: "pantry_get", Data: map[string]any{"current_day": 0}},
+			}},
+			bedrock.NewToolResultMessage([]bedrock.ToolResult{
+				{ToolUseID: "t1", ToolName: "pantry_get", Data: map[string]any{"pantry": map[string]any{"ingredients": []any{}}}},
+			}),
+		},
+		Tools: []bedrock.Tool{{
+			Name:        "recipe_get",
+			Description: "Gets recipes",
+			InputSchema: &jsonschema.Schema{Type: "object", Properties: map[string]*jsonschema.Schema{"meal_types": {Type: "array"}}},
+		}},
+	}
+
+	res, err := backend.Client.Invoke(context.Background(), prompt)
+	require.NoError(t, err)
+
+	// Request is in Ollama's native format, with the client's own system prompt
+	assert.Equal(t, "llama3.1", got.Model)
+	require.Len(t, got.Messages, 3)
+	assert.Equal(t, ollama.Message{Role: "system", Content: "ollama system prompt"}, got.Messages[0])
+	assert.Equal(t, ollama.Message{Role: "user", Content: "Plan dinners"}, got.Messages[1])
+	assert.Equal(t, "tool", got.Messages[2].Role)
+	assert.Equal(t, "pantry_get", got.Messages[2].Name)
+	assert.JSONEq(t, `{"pantry":{"ingredients":[]}}`, got.Messages[2].Content)
+	require.Len(t, got.Tools, 1)
+	assert.Equal(t, "function", got.Tools[0].Type)
+	assert.Equal(t, "recipe_get", got.Tools[0].Function.Name)
+
+	// Response is in Bedrock's format, with tool use IDs to pair results with
+	assert.Equal(t, "llama3.1", res.Model)
+	assert.Equal(t, []tools.Call{{Name: "recipe_get", Input: map[string]any{"meal_types": []any{"dinner"}}, ToolUseID: "ollama_1"}}, res.ToolCalls)
+}
+
+func TestParseSpec(t *testing.T) {
+	provider, model, err := ParseSpec("bedrock:us.anthropic.claude-3-5-haiku-20241022-v1:0")
+	require.NoError(t, err)
+	assert.Equal(t, "bedrock", provider)
+	assert.Equal(t, "us.anthropic.claude-3-5-haiku-20241022-v1:0", model)
+
+	provider, model, err = ParseSpec(" ollama:llama3.1 ")
+	require.NoError(t, err)
+	assert.Equal(t, "ollama", provider)
+	assert.Equal(t, "llama3.1", model)
+
+	for _, spec := range []string{"llama3.1", "openai:gpt", "bedrock:"} {
+		_, _, err := ParseSpec(spec)
+		assert.Error(t, err, spec)
+	}
+}
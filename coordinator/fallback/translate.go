@@ -0,0 +1,97 @@
+package fallback
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"pantryagent/coordinator/bedrock"
+	"pantryagent/coordinator/ollama"
+	"pantryagent/tools"
+)
+
+// ollamaClient is the subset of ollama.Client used by the Ollama backend.
+type ollamaClient interface {
+	Invoke(ctx context.Context, prompt ollama.Prompt) (ollama.Response, error)
+}
+
+// ollamaBackend adapts an Ollama client to the Bedrock prompt format used by the chain.
+type ollamaBackend struct {
+	client ollamaClient
+
+	mu    sync.Mutex
+	calls int // used to generate tool use IDs, which Ollama does not have
+}
+
+func (b *ollamaBackend) Invoke(ctx context.Context, prompt bedrock.Prompt) (bedrock.Response, error) {
+	res, err := b.client.Invoke(ctx, ToOllamaPrompt(prompt))
+	if err != nil {
+		return bedrock.Response{}, err
+	}
+	return b.fromOllamaResponse(res), nil
+}
+
+// fromOllamaResponse converts an Ollama response to a Bedrock response. Tool calls get synthetic
+// tool use IDs so their results can be paired with them, whichever backend handles the next turn.
+func (b *ollamaBackend) fromOllamaResponse(res ollama.Response) bedrock.Response {
+	out := bedrock.Response{Content: res.Content, Model: res.Model, Truncated: res.Truncated}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, call := range res.ToolCalls {
+		b.calls++
+		out.ToolCalls = append(out.ToolCalls, tools.Call{
+			Name:      call.Name,
+			Input:     call.Args,
+			ToolUseID: fmt.Sprintf("ollama_%d", b.calls),
+		})
+	}
+	return out
+}
+
+// ToOllamaPrompt converts a Bedrock prompt to Ollama's native tool calling format.
+//
+// System messages are kept, but the Ollama client replaces them with its own system prompt.
+// Tool results become role "tool" messages. Assistant tool use parts are dropped since Ollama
+// messages do not carry tool calls; the tool results that follow them carry the tool names.
+func ToOllamaPrompt(prompt bedrock.Prompt) ollama.Prompt {
+	out := ollama.Prompt{
+		Messages:  make([]ollama.Message, 0, len(prompt.Messages)),
+		Tools:     make([]ollama.Tool, 0, len(prompt.Tools)),
+		MaxTokens: prompt.MaxTokens,
+	}
+
+	for _, m := range prompt.Messages {
+		var text strings.Builder
+		for _, part := range m.Content {
+			switch part.Type {
+			case "text":
+				if text.Len() > 0 {
+					text.WriteByte('\n')
+				}
+				text.WriteString(part.Text)
+
+			case "tool_result":
+				payload, err := json.Marshal(part.Data)
+				if err != nil {
+					payload = []byte(fmt.Sprintf(`{"error":%q}`, err.Error()))
+				}
+				out.Messages = append(out.Messages, ollama.Message{
+					Role:    "tool",
+					Name:    part.ToolName,
+					Content: string(payload),
+				})
+			}
+		}
+		if text.Len() > 0 {
+			out.Messages = append(out.Messages, ollama.Message{Role: m.Role, Content: text.String()})
+		}
+	}
+
+	for _, t := range prompt.Tools {
+		out.Tools = append(out.Tools, ollama.NewTool(t.Name, t.Description, t.InputSchema))
+	}
+
+	return out
+}
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"pantryagent"
+	"pantryagent/prompts"
+	"pantryagent/tools"
 )
 
 // Coordinator is responsible for managing the interaction between the LLM, tools, and output channel.
@@ -16,6 +18,10 @@ type Coordinator struct {
 	toolProvider  pantryagent.ToolProvider
 	maxIterations int
 	logger        pantryagent.CoordinationLogger
+	hooks         pantryagent.Hooks
+	recovery      pantryagent.ToolRecovery
+	prompts       *prompts.Registry
+	promptVars    prompts.Vars
 }
 
 // llmClient interface for mock-specific client. It's fake and just returns canned responses.
@@ -30,21 +36,64 @@ func NewCoordinator(llm llmClient, tp pantryagent.ToolProvider, maxIter int, log
 		toolProvider:  tp,
 		maxIterations: maxIter,
 		logger:        log,
+		prompts:       prompts.Default(),
 	}
 }
 
+// Use appends hooks to the coordinator's hook chain and returns the coordinator for chaining.
+func (c *Coordinator) Use(hooks ...pantryagent.Hook) *Coordinator {
+	c.hooks = append(c.hooks, hooks...)
+	return c
+}
+
+// WithPrompts sets the registry the system prompt and nudges are rendered from, and the variables they are
+// rendered with. It returns the coordinator for chaining.
+func (c *Coordinator) WithPrompts(r *prompts.Registry, vars prompts.Vars) *Coordinator {
+	c.prompts, c.promptVars = r, vars
+	return c
+}
+
+// WithToolRecovery configures how the coordinator recovers from failed tool calls. It returns the
+// coordinator for chaining.
+func (c *Coordinator) WithToolRecovery(r pantryagent.ToolRecovery) *Coordinator {
+	c.recovery = r
+	return c
+}
+
 // Run executes the coordination process for a given task.
 func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 	slog.Info("COORDINATOR: Starting run", "task", task)
 
-	prompt, err := NewPrompt(task, c.toolProvider)
+	runEvent := pantryagent.RunEvent{Task: task, Tools: len(c.toolProvider.GetTools())}
+	if err := c.hooks.BeforeRun(ctx, &runEvent); err != nil {
+		return "", fmt.Errorf("run aborted by hook: %w", err)
+	}
+
+	start := time.Now()
+	rec := c.prompts.Recorder(c.promptVars)
+	out, iterations, err := c.run(ctx, task, rec)
+
+	runEvent.Output, runEvent.Iterations, runEvent.Duration, runEvent.Err = out, iterations, time.Since(start), err
+	runEvent.Prompts = rec.Versions()
+	if herr := c.hooks.AfterRun(ctx, &runEvent); herr != nil && err == nil {
+		return "", herr
+	}
+	return runEvent.Output, err
+}
+
+// run is the coordination loop. It returns the final output and the number of iterations used.
+func (c *Coordinator) run(ctx context.Context, task string, rec *prompts.Recorder) (string, int, error) {
+	prompt, err := NewPromptWith(rec, task, c.toolProvider)
 	if err != nil {
-		return "", fmt.Errorf("failed to apply system prompt: %w", err)
+		return "", 0, fmt.Errorf("failed to apply system prompt: %w", err)
 	}
 
 	var finalOut string
+	failures := 0 // consecutive tool failures, see pantryagent.ToolRecovery
 
-	for iter := 0; iter < c.maxIterations; iter++ {
+	iter := 0
+	for ; iter < c.maxIterations; iter++ {
+		iterStart := time.Now()
 		iterLog := pantryagent.IterationLog{Iteration: iter + 1, Timestamp: time.Now()}
 
 		// Serialize the prompt for debugging
@@ -52,8 +101,8 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 		if err != nil {
 			err := fmt.Errorf("failed to marshal prompt: %w", err)
 			iterLog.Error = err.Error()
-			c.logIteration(iterLog)
-			return finalOut, err
+			c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+			return finalOut, iter + 1, err
 		}
 		iterLog.LLMInput = string(promptJSON)
 		promptSize := len(promptJSON)
@@ -80,12 +129,31 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 			"last_message_preview", lastMessagePreview(),
 		)
 
+		invokeEvent := pantryagent.InvokeEvent{
+			Iteration:   iter + 1,
+			Prompt:      &prompt,
+			PromptBytes: promptSize,
+			Messages:    len(prompt.Messages),
+			Tools:       len(prompt.Tools),
+		}
+		if err := c.hooks.BeforeInvoke(ctx, &invokeEvent); err != nil {
+			iterLog.Error = err.Error()
+			c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+			return finalOut, iter + 1, fmt.Errorf("invoke aborted by hook: %w", err)
+		}
+
 		// Invoke model
+		invokeStart := time.Now()
 		res, err := c.llm.Invoke(ctx, prompt)
+		invokeEvent.Response, invokeEvent.Err, invokeEvent.Duration = &res, err, time.Since(invokeStart)
+		invokeEvent.Content, invokeEvent.ContentLength, invokeEvent.ToolCalls = res.Content, len(res.Content), len(res.ToolCalls)
+		if herr := c.hooks.AfterInvoke(ctx, &invokeEvent); herr != nil && err == nil {
+			err = fmt.Errorf("response rejected by hook: %w", herr)
+		}
 		if err != nil {
 			iterLog.Error = err.Error()
-			c.logIteration(iterLog)
-			return finalOut, fmt.Errorf("failed to invoke LLM: %w", err)
+			c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+			return finalOut, iter + 1, fmt.Errorf("failed to invoke LLM: %w", err)
 		}
 		iterLog.LLMOutput = res
 
@@ -93,8 +161,8 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 		contentLengthBeforeParsing := len(res.Content)
 		if err := res.ParseModelOutput(); err != nil {
 			iterLog.Error = fmt.Sprintf("failed to parse model output: %v", err)
-			c.logIteration(iterLog)
-			return finalOut, fmt.Errorf("failed to parse model output: %w", err)
+			c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+			return finalOut, iter + 1, fmt.Errorf("failed to parse model output: %w", err)
 		}
 
 		slog.Info("COORDINATOR: LLM response received",
@@ -105,25 +173,32 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 
 		// Final? (only accept if pantry get + recipe get have occurred)
 		if res.Content != "" {
+			candidate := pantryagent.FinalCandidateEvent{Iteration: iter + 1, Content: res.Content}
 			usedPantryGet := prompt.HasToolResultInContent("pantry_get")
 			usedRecipeGet := prompt.HasToolResultInContent("recipe_get")
 
 			if !(usedPantryGet && usedRecipeGet) {
+				candidate.Rejection = pantryagent.RejectionMissingToolResults
+				_ = c.hooks.OnFinalCandidate(ctx, &candidate)
+
 				// Nudge the model back to tool planning
-				correction := `{
-					"tool_calls": [
-						{ "name": "pantry_get", "input": { "current_day": 0 } },
-						{ "name": "recipe_get", "input": { "meal_types": ["dinner"] } }
-					]
-				}`
+				nudge, err := rec.Render(prompts.MockMissingToolResults, nil)
+				if err != nil {
+					iterLog.Error = err.Error()
+					c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+					return finalOut, iter + 1, err
+				}
+				correction, err := rec.Render(prompts.MockToolCallsCorrection, nil)
+				if err != nil {
+					iterLog.Error = err.Error()
+					c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+					return finalOut, iter + 1, err
+				}
 
 				prompt.Messages = append(prompt.Messages,
 					Message{
-						Role: "user",
-						Content: []MessagePart{{
-							Type: "text",
-							Text: `Your last output was a final plan but you did not retrieve the pantry nor the recipe get. Use "pantry_get" for pantry data and "recipe_get" for recipes before finalizing.`,
-						}},
+						Role:    "user",
+						Content: []MessagePart{{Type: "text", Text: nudge}},
 					},
 					Message{
 						Role:    "assistant",
@@ -131,14 +206,33 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 					},
 				)
 
-				c.logIteration(iterLog)
+				c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeNudged, iterStart)
 				continue
 			}
 
-			slog.Info("COORDINATOR: Content is final output, ending run", "iteration", iter+1, "content_length", len(res.Content))
+			if herr := c.hooks.OnFinalCandidate(ctx, &candidate); herr != nil {
+				slog.Warn("COORDINATOR: Final output rejected by hook", "iteration", iter+1, "error", herr)
+				candidate.Rejection = pantryagent.RejectionHook
+				_ = c.hooks.OnFinalCandidate(ctx, &candidate)
+				nudge, err := rec.Render(prompts.NudgeFinalRejected, prompts.Vars{"Reason": herr.Error()})
+				if err != nil {
+					iterLog.Error = err.Error()
+					c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+					return finalOut, iter + 1, err
+				}
+				prompt.Messages = append(prompt.Messages, Message{
+					Role:    "user",
+					Content: []MessagePart{{Type: "text", Text: nudge}},
+				})
+				iterLog.Error = "final output rejected by hook"
+				c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeRejected, iterStart)
+				continue
+			}
 
-			finalOut = res.Content
-			c.logIteration(iterLog)
+			slog.Info("COORDINATOR: Content is final output, ending run", "iteration", iter+1, "content_length", len(candidate.Content))
+
+			finalOut = candidate.Content
+			c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeFinal, iterStart)
 			break
 		}
 
@@ -146,62 +240,106 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 		if len(res.ToolCalls) == 0 {
 			err := fmt.Errorf("COORDINATOR: no tool_calls and no final in response")
 			iterLog.Error = err.Error()
-			c.logIteration(iterLog)
-			return finalOut, err
+			c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+			return finalOut, iter + 1, err
 		}
 
 		var toolCallLogs []pantryagent.ToolCallLog
+		iterFailed := false
 		for _, call := range res.ToolCalls {
 			slog.Info("COORDINATOR: Handling tool call", "name", call.Name, "iteration", iter+1)
 
-			toolLog := pantryagent.ToolCallLog{Name: call.Name, Input: call.Input}
-
-			tool, err := c.toolProvider.GetTool(call.Name)
-			if err != nil {
-				toolLog.Error = err.Error()
-				toolCallLogs = append(toolCallLogs, toolLog)
-				iterLog.ToolCalls = toolCallLogs
-				c.logIteration(iterLog)
-				return finalOut, fmt.Errorf("failed to get tool %q: %w", call.Name, err)
-			}
-
-			result, err := tool.Run(ctx, call.Input)
-			if err != nil {
-				toolLog.Error = err.Error()
-				toolCallLogs = append(toolCallLogs, toolLog)
-				iterLog.ToolCalls = toolCallLogs
-				c.logIteration(iterLog)
-				return finalOut, fmt.Errorf("failed to run tool %q: %w", call.Name, err)
-			}
-
-			toolLog.Output = result
+			toolLog, result, toolErr := c.callTool(ctx, iter+1, call)
 			toolCallLogs = append(toolCallLogs, toolLog)
 
-			payload, err := json.Marshal(result)
-			if err != nil {
-				iterLog.Error = fmt.Sprintf("failed to marshal tool result: %v", err)
-				c.logIteration(iterLog)
-				return finalOut, fmt.Errorf("failed to marshal tool result: %w", err)
-			}
-
-			prompt.Messages = append(
-				prompt.Messages,
-				Message{
+			var msg Message
+			if toolErr != nil {
+				iterFailed = true
+				failures++
+				if !c.recovery.Recovers(failures) {
+					iterLog.ToolCalls = toolCallLogs
+					c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+					return finalOut, iter + 1, c.recovery.AbortError(failures, toolErr)
+				}
+				// Unknown tools, failures and schema violations are fed back to the model so it can correct itself
+				slog.Warn("COORDINATOR: Tool error returned to model", "name", call.Name, "code", toolErr.Code, "error", toolErr.Message, "consecutive_failures", failures)
+				payload, _ := json.Marshal(toolErr.Payload())
+				msg = Message{Role: "tool", Content: []MessagePart{{Type: "text", Text: string(payload)}}}
+			} else {
+				payload, err := json.Marshal(result)
+				if err != nil {
+					iterLog.Error = fmt.Sprintf("failed to marshal tool result: %v", err)
+					c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+					return finalOut, iter + 1, fmt.Errorf("failed to marshal tool result: %w", err)
+				}
+				msg = Message{
 					Role: "user",
 					Content: []MessagePart{
-						{Type: "text", Text: fmt.Sprintf(`{"tool_result":"%s","data":%s}`, tool.Name(), string(payload))},
+						{Type: "text", Text: fmt.Sprintf(`{"tool_result":"%s","data":%s}`, toolLog.Name, string(payload))},
 					},
-				},
-			)
+				}
+			}
+			prompt.Messages = append(prompt.Messages, msg)
 
 			slog.Info("COORDINATOR: Tool executed, appended message", "name", call.Name, "iteration", iter+1)
 		}
 
+		if !iterFailed {
+			failures = 0
+		}
+
 		iterLog.ToolCalls = toolCallLogs
-		c.logIteration(iterLog)
+		c.endIteration(ctx, rec, &iterLog, pantryagent.OutcomeToolCalls, iterStart)
 	}
 
-	return finalOut, nil
+	return finalOut, min(iter+1, c.maxIterations), nil
+}
+
+// callTool runs a single tool call through the hook chain. It returns the call's log entry and the tool's
+// output, or the error to report to the model when the call is rejected, the tool is unknown or it fails.
+func (c *Coordinator) callTool(ctx context.Context, iteration int, call tools.Call) (pantryagent.ToolCallLog, map[string]any, *tools.ToolError) {
+	event := pantryagent.ToolCallEvent{Iteration: iteration, Name: call.Name, Input: call.Input}
+	toolLog := pantryagent.ToolCallLog{Name: call.Name, Input: call.Input}
+
+	if err := c.hooks.BeforeToolCall(ctx, &event); err != nil {
+		toolLog.Error = err.Error()
+		return toolLog, nil, &tools.ToolError{Tool: call.Name, Code: tools.ErrCodeToolRejected, Message: err.Error(), Err: err}
+	}
+	toolLog.Name, toolLog.Input = event.Name, event.Input
+
+	tool, err := c.toolProvider.GetTool(event.Name)
+	if err != nil {
+		event.Err = err
+		_ = c.hooks.AfterToolCall(ctx, &event)
+		toolLog.Error = err.Error()
+		return toolLog, nil, tools.NewUnknownToolError(event.Name, c.toolProvider.GetTools(), err)
+	}
+
+	start := time.Now()
+	result, err := tool.Run(ctx, event.Input)
+	event.Output, event.Err, event.Duration = result, err, time.Since(start)
+	if herr := c.hooks.AfterToolCall(ctx, &event); herr != nil && err == nil {
+		err = herr
+	}
+	if err != nil {
+		toolLog.Error = err.Error()
+		return toolLog, nil, tools.AsCallError(event.Name, tools.ErrCodeToolFailed, err)
+	}
+
+	toolLog.Output = event.Output
+	return toolLog, event.Output, nil
+}
+
+// endIteration runs the iteration end hooks and logs the iteration.
+func (c *Coordinator) endIteration(ctx context.Context, rec *prompts.Recorder, iterLog *pantryagent.IterationLog, outcome string, started time.Time) {
+	iterLog.Prompts = rec.Versions()
+	_ = c.hooks.OnIterationEnd(ctx, &pantryagent.IterationEndEvent{
+		Iteration: iterLog.Iteration,
+		Outcome:   outcome,
+		Duration:  time.Since(started),
+		Log:       iterLog,
+	})
+	c.logIteration(*iterLog)
 }
 
 // logIteration logs a step using the configured logger, handling errors gracefully
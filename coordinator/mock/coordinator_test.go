@@ -329,3 +329,36 @@ func TestCoordinatorToolIntegration(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, mealPlan.IsValid())
 }
+
+// recordingLLM wraps the mock LLM and keeps the last prompt it received.
+type recordingLLM struct {
+	*LLMClient
+	lastPrompt Prompt
+}
+
+func (r *recordingLLM) Invoke(ctx context.Context, prompt Prompt) (Response, error) {
+	r.lastPrompt = prompt
+	return r.LLMClient.Invoke(ctx, prompt)
+}
+
+func TestMockCoordinatorToolRecovery(t *testing.T) {
+	registry, err := tools.NewRegistry(storage.NewTestPantryStateWithError(), storage.NewTestRecipeState([]byte("[]")))
+	require.NoError(t, err)
+	llm := &recordingLLM{LLMClient: NewLLMClient(Prompt{})}
+
+	coordinator := NewCoordinator(llm, registry, 5, pantryagent.NewNoOpCoordinationLogger()).
+		WithToolRecovery(pantryagent.ToolRecovery{MaxConsecutiveFailures: 1})
+	_, err = coordinator.Run(context.Background(), "Plan meals")
+	assert.ErrorContains(t, err, "giving up after 2 consecutive tool failures: failed to run tool \"pantry_get\"")
+
+	// The first failure was sent back to the model as a structured tool message
+	var toolMsgs []string
+	for _, msg := range llm.lastPrompt.Messages {
+		if msg.Role == "tool" {
+			toolMsgs = append(toolMsgs, msg.Content.Join())
+		}
+	}
+	require.Len(t, toolMsgs, 1)
+	assert.Contains(t, toolMsgs[0], `"error":"tool_failed"`)
+	assert.Contains(t, toolMsgs[0], `"tool":"pantry_get"`)
+}
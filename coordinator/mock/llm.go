@@ -20,7 +20,7 @@ func (m *LLMClient) Invoke(ctx context.Context, prompt Prompt) (Response, error)
 	if !prompt.HasToolResultInContent("pantry_get") && !prompt.HasToolResultInContent("recipe_get") {
 		plan := map[string]any{
 			"tool_calls": []map[string]any{
-				{"name": "pantry_get", "input": map[string]any{"current_day": 0}},
+				{"name": "pantry_get", "input": map[string]any{}},
 				{"name": "recipe_get", "input": map[string]any{"meal_types": []string{"dinner"}}},
 			},
 		}
@@ -62,7 +62,7 @@ func (m *LLMClient) Invoke(ctx context.Context, prompt Prompt) (Response, error)
 	// Phase 3: fallback plan
 	plan := map[string]any{
 		"tool_calls": []map[string]any{
-			{"name": "pantry_get", "input": map[string]any{"current_day": 0}},
+			{"name": "pantry_get", "input": map[string]any{}},
 			{"name": "recipe_get", "input": map[string]any{"meal_types": []string{"dinner"}}},
 		},
 	}
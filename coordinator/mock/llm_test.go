@@ -49,8 +49,7 @@ func TestMockLLMClient_Invoke(t *testing.T) {
 		// Verify tool inputs
 		for _, call := range response.ToolCalls {
 			if call.Name == "pantry_get" {
-				assert.Contains(t, call.Input, "current_day", "pantry_get should have current_day input")
-				assert.Equal(t, 0, int(call.Input["current_day"].(float64)), "current_day should be 0")
+				assert.Empty(t, call.Input, "pantry_get should ask for today's pantry")
 			}
 			if call.Name == "recipe_get" {
 				assert.Contains(t, call.Input, "meal_types", "recipe_get should have meal_types input")
@@ -1,12 +1,12 @@
 package mock
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"pantryagent"
 
+	"pantryagent/prompts"
 	"pantryagent/tools"
 )
 
@@ -16,6 +16,11 @@ type Prompt struct {
 }
 
 func NewPrompt(task string, tp pantryagent.ToolProvider) (Prompt, error) {
+	return NewPromptWith(prompts.Default().Recorder(nil), task, tp)
+}
+
+// NewPromptWith creates a prompt whose system prompt is rendered by rec.
+func NewPromptWith(rec *prompts.Recorder, task string, tp pantryagent.ToolProvider) (Prompt, error) {
 	tools := tp.GetTools()
 	toolsJSON, err := json.Marshal(tools)
 	if err != nil {
@@ -23,7 +28,10 @@ func NewPrompt(task string, tp pantryagent.ToolProvider) (Prompt, error) {
 		return Prompt{}, fmt.Errorf("failed to marshal tools: %w", err)
 	}
 
-	systemPrompt := bytes.ReplaceAll([]byte(systemPromptTemplate), []byte("%TOOLS_JSON%"), toolsJSON)
+	systemPrompt, err := rec.Render(prompts.MockSystem, prompts.Vars{"ToolsJSON": string(toolsJSON)})
+	if err != nil {
+		return Prompt{}, err
+	}
 
 	return Prompt{
 		Messages: []Message{
@@ -32,7 +40,7 @@ func NewPrompt(task string, tp pantryagent.ToolProvider) (Prompt, error) {
 				Content: []MessagePart{
 					{
 						Type: "text",
-						Text: string(systemPrompt),
+						Text: systemPrompt,
 					},
 				},
 			},
@@ -50,64 +58,6 @@ func NewPrompt(task string, tp pantryagent.ToolProvider) (Prompt, error) {
 	}, nil
 }
 
-const systemPromptTemplate = `You are a meal-planning coordinator.
-
-GOAL:
-Plan meals over the user-specified days and servings, using the tools to gather pantry state and available recipes, then return the final meal plan JSON.
-
-FINAL OUTPUT FORMAT:
-When you are ready to complete the task, return ONLY the JSON object - no explanations, no text before or after, no markdown formatting. Start immediately with { and end with }.
-
-Example of correct final response format:
-{
-  "summary": "3-day dinner plan...",
-  "days_planned": [...]
-}
-
-JSON Schema:
-{
-  "summary": string,                         // <= 400 chars: overview of the plan and prioritization of perishables
-  "days_planned": [                          // MUST contain at least one element
-    {
-      "day": integer,                        // starting at 1
-      "meals": [                             // 1..M meals for that day
-        {
-          "id": string,                      // recipe id
-          "name": string,                    // recipe name
-          "servings": integer                // servings for this meal
-        }
-      ]
-    }
-  ]
-}
-
-If any field has no content, use an empty array [] or "" appropriately.  
-days_planned must NEVER be empty — always return at least one day.  
-The JSON must be valid UTF-8, with no commentary, no markdown, and no trailing commas.  
-
-TOOL USE:
-When you need more information, use the provided tools directly through the tool interface.  
-Do not wrap tool requests in JSON text such as {"tool_calls":[...]}.  
-Do not echo tool results yourself — the coordinator will supply them.  
-
-CRITICAL RULES:
-- When returning the final meal plan, output ONLY the JSON object with no explanatory text before or after it
-- Final output must be valid JSON only (no explanations or code fences)
-- Never invent recipe IDs (only use from recipe_get).
-- Never assume unit conversions; mismatched units are unusable.
-- Always call pantry_get before finalizing.
-- Always call recipe_get before selecting meals.
-- Prioritize ingredients with the lowest days_left when choosing meals.
-- The coordinator will check feasibility; your final plan must fit the pantry without shortages or unit mismatches.
-- days_planned must always contain at least one element.
-- Call pantry_get and recipe_get at most once each per session.
-- Reuse the latest tool_result content already provided; do not re-call a tool unless the coordinator says the data changed.
-- If you already have pantry + recipes, proceed to planning and produce the final JSON.
-
-Available tools (JSON description):  
-%TOOLS_JSON%
-`
-
 // HasToolResult returns true if a tool result for the specified tool name exists in the prompt's message history.
 // It checks for a message with role "tool" whose first content part contains a JSON object
 // with a "tool_result" field equal to the given tool name.
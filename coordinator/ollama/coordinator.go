@@ -8,9 +8,12 @@ import (
 	"time"
 
 	"pantryagent"
+	"pantryagent/prompts"
+	"pantryagent/tools"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 // Coordinator is responsible for managing the interaction between the LLM, tools, and output channel.
@@ -20,6 +23,12 @@ type Coordinator struct {
 	maxIterations  int
 	logger         pantryagent.CoordinationLogger
 	tracerProvider *trace.TracerProvider
+	tracer         oteltrace.Tracer
+	hooks          pantryagent.Hooks
+	recovery       pantryagent.ToolRecovery
+	truncation     pantryagent.TruncationRecovery
+	prompts        *prompts.Registry
+	promptVars     prompts.Vars
 }
 
 // llmClient interface for ollama-specific client
@@ -35,29 +44,90 @@ func NewCoordinator(llm llmClient, tp pantryagent.ToolProvider, maxIter int, log
 		maxIterations:  maxIter,
 		logger:         log,
 		tracerProvider: tracerProvider,
+		tracer:         otel.Tracer(pantryagent.TracerNameOllama),
+		prompts:        prompts.Default(),
 	}
 }
 
+// Use appends hooks to the coordinator's hook chain and returns the coordinator for chaining.
+func (c *Coordinator) Use(hooks ...pantryagent.Hook) *Coordinator {
+	c.hooks = append(c.hooks, hooks...)
+	return c
+}
+
+// WithPrompts sets the registry the system prompt and nudges are rendered from, and the variables they are
+// rendered with. It returns the coordinator for chaining.
+func (c *Coordinator) WithPrompts(r *prompts.Registry, vars prompts.Vars) *Coordinator {
+	c.prompts, c.promptVars = r, vars
+	return c
+}
+
+// WithToolRecovery configures how the coordinator recovers from failed tool calls. It returns the
+// coordinator for chaining.
+func (c *Coordinator) WithToolRecovery(r pantryagent.ToolRecovery) *Coordinator {
+	c.recovery = r
+	return c
+}
+
+// WithTruncationRecovery configures how the coordinator recovers from responses cut off by the output
+// token limit. It returns the coordinator for chaining.
+func (c *Coordinator) WithTruncationRecovery(r pantryagent.TruncationRecovery) *Coordinator {
+	c.truncation = r
+	return c
+}
+
 // Run executes the coordination process for a given task.
 func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
-	ctx, span := otel.Tracer(pantryagent.TracerNameOllama).Start(ctx, "Coordinator.Run")
+	ctx, span := c.tracer.Start(ctx, "Coordinator.Run")
 	defer span.End()
 
 	slog.Info("COORDINATOR: Starting run", "task", task)
 
-	prompt, err := NewPrompt(task, c.toolProvider)
+	runEvent := pantryagent.RunEvent{Task: task, Tools: len(c.toolProvider.GetTools())}
+	if err := c.hooks.BeforeRun(ctx, &runEvent); err != nil {
+		return "", fmt.Errorf("run aborted by hook: %w", err)
+	}
+
+	start := time.Now()
+	rec := c.prompts.Recorder(c.promptVars)
+	out, iterations, err := c.run(ctx, task, rec)
+
+	runEvent.Output, runEvent.Iterations, runEvent.Duration, runEvent.Err = out, iterations, time.Since(start), err
+	runEvent.Prompts = rec.Versions()
+	if herr := c.hooks.AfterRun(ctx, &runEvent); herr != nil && err == nil {
+		return "", herr
+	}
+	return runEvent.Output, err
+}
+
+// run is the coordination loop. It returns the final output and the number of iterations used.
+func (c *Coordinator) run(ctx context.Context, task string, rec *prompts.Recorder) (string, int, error) {
+	prompt, err := NewPromptWith(rec, task, c.toolProvider)
 	if err != nil {
-		return "", fmt.Errorf("failed to apply system prompt: %w", err)
+		return "", 0, fmt.Errorf("failed to apply system prompt: %w", err)
 	}
 
 	var finalOut string
+	failures := 0 // consecutive tool failures, see pantryagent.ToolRecovery
+
+	truncation := pantryagent.Truncation[Message]{TruncationRecovery: c.truncation}
 
-	for iter := 0; iter < c.maxIterations; iter++ {
+	iter := 0
+	for ; iter < c.maxIterations; iter++ {
+		iterCtx, _ := c.tracer.Start(ctx, fmt.Sprintf("Coordinator.Run.Iteration.%d", iter+1))
+		iterStart := time.Now()
 		iterLog := pantryagent.IterationLog{Iteration: iter + 1, Timestamp: time.Now()}
+		invokeEvent := pantryagent.InvokeEvent{
+			Iteration: iter + 1,
+			Prompt:    &prompt,
+			Messages:  len(prompt.Messages),
+			Tools:     len(prompt.Tools),
+		}
 
 		// Log prompt
 		if b, merr := json.Marshal(prompt); merr == nil {
 			iterLog.LLMInput = string(b)
+			invokeEvent.PromptBytes = len(b)
 			slog.Info("COORDINATOR: Sending prompt to LLM",
 				"iteration", iter+1,
 				"messages_count", len(prompt.Messages),
@@ -76,12 +146,25 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 			)
 		}
 
+		if err := c.hooks.BeforeInvoke(iterCtx, &invokeEvent); err != nil {
+			iterLog.Error = err.Error()
+			c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+			return finalOut, iter + 1, fmt.Errorf("invoke aborted by hook: %w", err)
+		}
+
 		// 1) Invoke model
-		res, err := c.llm.Invoke(ctx, prompt)
+		invokeStart := time.Now()
+		res, err := c.llm.Invoke(iterCtx, prompt)
+		invokeEvent.Response, invokeEvent.Err, invokeEvent.Duration = &res, err, time.Since(invokeStart)
+		invokeEvent.Content, invokeEvent.ContentLength, invokeEvent.ToolCalls = res.Content, len(res.Content), len(res.ToolCalls)
+		invokeEvent.Model, iterLog.Model = res.Model, res.Model
+		if herr := c.hooks.AfterInvoke(iterCtx, &invokeEvent); herr != nil && err == nil {
+			err = fmt.Errorf("response rejected by hook: %w", herr)
+		}
 		if err != nil {
 			iterLog.Error = err.Error()
-			c.logIteration(iterLog)
-			return finalOut, fmt.Errorf("failed to invoke LLM: %w", err)
+			c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+			return finalOut, iter + 1, fmt.Errorf("failed to invoke LLM: %w", err)
 		}
 		iterLog.LLMOutput = res
 
@@ -91,27 +174,99 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 			"tool_calls", len(res.ToolCalls),
 		)
 
+		if res.Truncated {
+			if truncation.CanContinue(res.Content, len(res.ToolCalls)) {
+				slog.Warn("COORDINATOR: Response truncated; requesting continuation", "iteration", iter+1, "continuations", truncation.Continuations())
+				nudge, err := rec.Render(prompts.NudgeContinueTruncated, nil)
+				if err != nil {
+					iterLog.Error = err.Error()
+					c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+					return finalOut, iter + 1, err
+				}
+				prompt.Messages = truncation.Continue(prompt.Messages, res.Content,
+					Message{Role: "assistant", Content: res.Content},
+					Message{Role: "user", Content: nudge},
+				)
+				iterLog.Error = "response truncated; continuation requested"
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeTruncated, iterStart)
+				continue
+			}
+			if budget, ok := truncation.NextBudget(prompt.MaxTokens); ok {
+				// Start the answer over with a larger budget
+				slog.Warn("COORDINATOR: Response truncated; retrying with a larger budget", "iteration", iter+1, "max_tokens", budget)
+				prompt.Messages = truncation.Restart(prompt.Messages)
+				prompt.MaxTokens = budget
+				iterLog.Error = fmt.Sprintf("response truncated; retrying with max tokens %d", budget)
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeTruncated, iterStart)
+				continue
+			}
+			slog.Warn("COORDINATOR: Response truncated; no recovery left", "iteration", iter+1)
+		}
+		// Stitch a continued answer together and drop the continuation exchange from the conversation
+		prompt.Messages, res.Content = truncation.Finish(prompt.Messages, res.Content)
+
 		// 2a) Final JSON path (no tool calls)
 		if len(res.ToolCalls) == 0 && res.Content != "" {
+			candidate := pantryagent.FinalCandidateEvent{Iteration: iter + 1, Content: res.Content}
+
 			// Accept final only if we have pantry_get and recipe_get results in history
 			if !(prompt.HasToolResult("pantry_get") && prompt.HasToolResult("recipe_get")) {
 				slog.Info("COORDINATOR: Missing required tool results; nudging model to call tools", "iteration", iter+1)
+				candidate.Rejection = pantryagent.RejectionMissingToolResults
+				_ = c.hooks.OnFinalCandidate(iterCtx, &candidate)
 
 				// Nudge the model to call tools natively
-				prompt.Messages = append(prompt.Messages,
-					Message{
-						Role:    "user",
-						Content: "Before finalizing, call pantry_get (with current_day) and recipe_get (optionally with meal_types). Then use those results and return ONLY the final JSON object.",
-					},
-				)
-				c.logIteration(iterLog)
+				nudge, err := rec.Render(prompts.NudgeMissingToolResults, nil)
+				if err != nil {
+					iterLog.Error = err.Error()
+					c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+					return finalOut, iter + 1, err
+				}
+				prompt.Messages = append(prompt.Messages, Message{Role: "user", Content: nudge})
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeNudged, iterStart)
+				continue
+			}
+
+			// Extract the plan from fences or prose and validate it before anything else sees it.
+			mealPlan, finalJSON, perr := pantryagent.ExtractMealPlan(res.Content)
+			if perr != nil {
+				slog.Info("COORDINATOR: Final response is not a valid meal plan", "iteration", iter+1, "error", perr)
+				candidate.Rejection = pantryagent.RejectionInvalidPlan
+				_ = c.hooks.OnFinalCandidate(iterCtx, &candidate)
+
+				nudge, err := rec.Render(prompts.NudgeInvalidFinalJSON, prompts.Vars{"Reason": perr.Error()})
+				if err != nil {
+					iterLog.Error = err.Error()
+					c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+					return finalOut, iter + 1, err
+				}
+				prompt.Messages = append(prompt.Messages, Message{Role: "user", Content: nudge})
+				iterLog.Error = perr.Error()
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeRejected, iterStart)
+				continue
+			}
+			candidate.Content, candidate.Plan = finalJSON, &mealPlan
+
+			// We have the required tool results and a valid plan; give hooks the final say, then accept it.
+			if herr := c.hooks.OnFinalCandidate(iterCtx, &candidate); herr != nil {
+				slog.Warn("COORDINATOR: Final response rejected by hook", "iteration", iter+1, "error", herr)
+				candidate.Rejection = pantryagent.RejectionHook
+				_ = c.hooks.OnFinalCandidate(iterCtx, &candidate)
+				nudge, err := rec.Render(prompts.NudgeFinalRejected, prompts.Vars{"Reason": herr.Error()})
+				if err != nil {
+					iterLog.Error = err.Error()
+					c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+					return finalOut, iter + 1, err
+				}
+				prompt.Messages = append(prompt.Messages, Message{Role: "user", Content: nudge})
+				iterLog.Error = "final response rejected by hook"
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeRejected, iterStart)
 				continue
 			}
 
-			// We have the required tool results; accept the model’s final JSON as-is.
 			slog.Info("COORDINATOR: Content looks final; ending run", "iteration", iter+1)
-			finalOut = res.Content
-			c.logIteration(iterLog)
+			finalOut = candidate.Content
+			c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFinal, iterStart)
 			break
 		}
 
@@ -119,8 +274,8 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 		if len(res.ToolCalls) == 0 && res.Content == "" {
 			err := fmt.Errorf("no tool_calls and no final content")
 			iterLog.Error = err.Error()
-			c.logIteration(iterLog)
-			return "", err
+			c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+			return "", iter + 1, err
 		}
 
 		var toolCallLogs []pantryagent.ToolCallLog
@@ -130,44 +285,37 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 			slog.Info("COORDINATOR: Deduped tool calls", "requested", len(res.ToolCalls), "kept", len(toolCalls))
 		}
 
+		iterFailed := false
 		for _, call := range toolCalls {
 			slog.Info("COORDINATOR: Handling tool call", "name", call.Name, "iteration", iter+1)
 
-			toolLog := pantryagent.ToolCallLog{Name: call.Name, Input: call.Args}
-
-			tool, err := c.toolProvider.GetTool(call.Name)
-			if err != nil {
-				toolLog.Error = err.Error()
-				toolCallLogs = append(toolCallLogs, toolLog)
-				iterLog.ToolCalls = toolCallLogs
-				c.logIteration(iterLog)
-				return finalOut, fmt.Errorf("failed to get tool %q: %w", call.Name, err)
-			}
-
-			result, err := tool.Run(ctx, call.Args)
-			if err != nil {
-				toolLog.Error = err.Error()
-				toolCallLogs = append(toolCallLogs, toolLog)
-				iterLog.ToolCalls = toolCallLogs
-				c.logIteration(iterLog)
-				return "", fmt.Errorf("failed to run tool %q: %w", call.Name, err)
-			}
-
-			toolLog.Output = result
+			toolLog, result, toolErr := c.callTool(iterCtx, iter+1, call)
 			toolCallLogs = append(toolCallLogs, toolLog)
+			if toolErr != nil {
+				iterFailed = true
+				failures++
+				if !c.recovery.Recovers(failures) {
+					iterLog.ToolCalls = toolCallLogs
+					c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+					return finalOut, iter + 1, c.recovery.AbortError(failures, toolErr)
+				}
+				// Unknown tools, failures and schema violations are fed back to the model so it can correct itself
+				slog.Warn("COORDINATOR: Tool error returned to model", "name", call.Name, "code", toolErr.Code, "error", toolErr.Message, "consecutive_failures", failures)
+				result = toolErr.Payload()
+			}
 
 			payload, err := json.Marshal(result)
 			if err != nil {
 				iterLog.Error = fmt.Sprintf("failed to marshal tool result: %v", err)
-				c.logIteration(iterLog)
-				return finalOut, fmt.Errorf("failed to marshal tool result: %w", err)
+				c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeFailed, iterStart)
+				return finalOut, iter + 1, fmt.Errorf("failed to marshal tool result: %w", err)
 			}
 
 			prompt.Messages = append(
 				prompt.Messages,
 				Message{
 					Role:    "tool",
-					Name:    tool.Name(),
+					Name:    toolLog.Name,
 					Content: string(payload),
 				},
 			)
@@ -175,11 +323,50 @@ func (c *Coordinator) Run(ctx context.Context, task string) (string, error) {
 			slog.Info("COORDINATOR: Tool executed, appended message", "name", call.Name, "iteration", iter+1)
 		}
 
+		if !iterFailed {
+			failures = 0
+		}
+
 		iterLog.ToolCalls = toolCallLogs
-		c.logIteration(iterLog)
+		c.endIteration(iterCtx, rec, &iterLog, pantryagent.OutcomeToolCalls, iterStart)
 	}
 
-	return finalOut, nil
+	return finalOut, min(iter+1, c.maxIterations), nil
+}
+
+// callTool runs a single tool call through the hook chain. It returns the call's log entry and the tool's
+// output, or the error to report to the model when the call is rejected, the tool is unknown or it fails.
+func (c *Coordinator) callTool(ctx context.Context, iteration int, call ToolCall) (pantryagent.ToolCallLog, map[string]any, *tools.ToolError) {
+	event := pantryagent.ToolCallEvent{Iteration: iteration, Name: call.Name, Input: call.Args}
+	toolLog := pantryagent.ToolCallLog{Name: call.Name, Input: call.Args}
+
+	if err := c.hooks.BeforeToolCall(ctx, &event); err != nil {
+		toolLog.Error = err.Error()
+		return toolLog, nil, &tools.ToolError{Tool: call.Name, Code: tools.ErrCodeToolRejected, Message: err.Error(), Err: err}
+	}
+	toolLog.Name, toolLog.Input = event.Name, event.Input
+
+	tool, err := c.toolProvider.GetTool(event.Name)
+	if err != nil {
+		event.Err = err
+		_ = c.hooks.AfterToolCall(ctx, &event)
+		toolLog.Error = err.Error()
+		return toolLog, nil, tools.NewUnknownToolError(event.Name, c.toolProvider.GetTools(), err)
+	}
+
+	start := time.Now()
+	result, err := tool.Run(ctx, event.Input)
+	event.Output, event.Err, event.Duration = result, err, time.Since(start)
+	if herr := c.hooks.AfterToolCall(ctx, &event); herr != nil && err == nil {
+		err = herr
+	}
+	if err != nil {
+		toolLog.Error = err.Error()
+		return toolLog, nil, tools.AsCallError(event.Name, tools.ErrCodeToolFailed, err)
+	}
+
+	toolLog.Output = event.Output
+	return toolLog, event.Output, nil
 }
 
 // dedupeToolCalls keeps only the first call per tool name (or name+args hash).
@@ -200,6 +387,19 @@ func dedupeToolCalls(calls []ToolCall) []ToolCall {
 	return out
 }
 
+// endIteration runs the iteration end hooks, logs the iteration and ends its span.
+func (c *Coordinator) endIteration(ctx context.Context, rec *prompts.Recorder, iterLog *pantryagent.IterationLog, outcome string, started time.Time) {
+	iterLog.Prompts = rec.Versions()
+	_ = c.hooks.OnIterationEnd(ctx, &pantryagent.IterationEndEvent{
+		Iteration: iterLog.Iteration,
+		Outcome:   outcome,
+		Duration:  time.Since(started),
+		Log:       iterLog,
+	})
+	c.logIteration(*iterLog)
+	oteltrace.SpanFromContext(ctx).End()
+}
+
 // logIteration logs a step using the configured logger, handling errors gracefully
 func (c *Coordinator) logIteration(iteration pantryagent.IterationLog) {
 	if c.logger != nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"pantryagent"
@@ -15,12 +16,14 @@ import (
 
 // Mock LLM Client
 type mockLLMClient struct {
-	responses []Response
-	callCount int
-	shouldErr bool
+	responses  []Response
+	callCount  int
+	shouldErr  bool
+	lastPrompt Prompt
 }
 
 func (m *mockLLMClient) Invoke(ctx context.Context, prompt Prompt) (Response, error) {
+	m.lastPrompt = prompt
 	if m.shouldErr {
 		return Response{}, errors.New("mock LLM error")
 	}
@@ -182,30 +185,18 @@ func TestCoordinator_Run(t *testing.T) {
 			expectError:  true,
 		},
 		{
-			name: "tool error",
-			llmResponses: []Response{
-				{
-					ToolCalls: []ToolCall{
-						{Name: "pantry_get", Args: map[string]any{"current_day": 0}},
-					},
-				},
-			},
+			name:         "tool keeps failing",
+			llmResponses: repeat(Response{ToolCalls: []ToolCall{{Name: "pantry_get", Args: map[string]any{"current_day": 0}}}}, 4),
 			tools: []tools.Tool{
 				&mockTool{name: "pantry_get", shouldErr: true},
 			},
 			expectError: true,
 		},
 		{
-			name: "tool not found",
-			llmResponses: []Response{
-				{
-					ToolCalls: []ToolCall{
-						{Name: "nonexistent_tool", Args: map[string]any{}},
-					},
-				},
-			},
-			tools:       []tools.Tool{},
-			expectError: true,
+			name:         "unknown tool keeps being called",
+			llmResponses: repeat(Response{ToolCalls: []ToolCall{{Name: "nonexistent_tool", Args: map[string]any{}}}}, 4),
+			tools:        []tools.Tool{},
+			expectError:  true,
 		},
 		{
 			name: "empty response error",
@@ -297,6 +288,110 @@ func TestCoordinator_Run_WithToolResults(t *testing.T) {
 	}
 }
 
+func TestCoordinator_Run_MessyFinalAnswer(t *testing.T) {
+	final := `{"summary": "Quick plan", "days_planned": [{"day": 1, "meals": [{"id": "recipe1", "name": "Quick Meal", "servings": 2}]}]}`
+	tp := &mockToolProvider{tools: []tools.Tool{&mockTool{name: "pantry_get"}, &mockTool{name: "recipe_get"}}}
+	llm := &mockLLMClient{
+		responses: []Response{
+			{ToolCalls: []ToolCall{{Name: "pantry_get", Args: map[string]any{}}, {Name: "recipe_get", Args: map[string]any{}}}},
+			{Content: `Here is the plan: {"summary": "Quick plan", "days_planned": [{"day": 1, "meals": []}]}`},
+			{Content: "Here is your plan:\n```json\n" + final + "\n```\nEnjoy!"},
+		},
+	}
+
+	coord := NewCoordinator(llm, tp, 5, pantryagent.NewNoOpCoordinationLogger(), trace.NewTracerProvider())
+	result, err := coord.Run(context.Background(), "Plan meals")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result != final {
+		t.Errorf("Expected result %q, got %q", final, result)
+	}
+
+	// The invalid plan was sent back with the failing field
+	nudge := llm.lastPrompt.Messages[len(llm.lastPrompt.Messages)-1]
+	if nudge.Role != "user" || !strings.Contains(nudge.Content, "days_planned[0].meals must not be empty") {
+		t.Errorf("Expected a nudge naming the invalid field, got %+v", nudge)
+	}
+}
+
+func TestCoordinator_Run_Truncation(t *testing.T) {
+	final := `{"summary": "Quick plan", "days_planned": [{"day": 1, "meals": [{"id": "recipe1", "name": "Quick Meal", "servings": 2}]}]}`
+	cut := len(final) / 2
+	tp := &mockToolProvider{tools: []tools.Tool{&mockTool{name: "pantry_get"}, &mockTool{name: "recipe_get"}}}
+	llm := &mockLLMClient{
+		responses: []Response{
+			{ToolCalls: []ToolCall{{Name: "pantry_get", Args: map[string]any{}}, {Name: "recipe_get", Args: map[string]any{}}}},
+			{Content: final[:cut], Truncated: true},
+			{Content: final[cut:]},
+		},
+	}
+
+	coord := NewCoordinator(llm, tp, 5, pantryagent.NewNoOpCoordinationLogger(), trace.NewTracerProvider())
+	result, err := coord.Run(context.Background(), "Plan meals")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result != final {
+		t.Errorf("Expected stitched result %q, got %q", final, result)
+	}
+
+	// The continuation was requested with the partial answer in the conversation
+	msgs := llm.lastPrompt.Messages
+	if n := len(msgs); n < 2 || msgs[n-2].Role != "assistant" || msgs[n-2].Content != final[:cut] || msgs[n-1].Role != "user" {
+		t.Errorf("Expected the partial answer followed by a continuation request, got %+v", msgs)
+	}
+}
+
+func TestCoordinator_Run_InvalidToolInput(t *testing.T) {
+	pantryTool := &mockTool{name: "pantry_get"}
+	validated, err := tools.WithValidation(pantryTool)
+	if err != nil {
+		t.Fatalf("WithValidation: %v", err)
+	}
+	tp := &mockToolProvider{tools: []tools.Tool{validated, &mockTool{name: "recipe_get"}}}
+
+	final := `{"summary": "Quick plan", "days_planned": [{"day": 1, "meals": [{"id": "recipe1", "name": "Quick Meal", "servings": 2}]}]}`
+	llm := &mockLLMClient{
+		responses: []Response{
+			{ToolCalls: []ToolCall{{Name: "pantry_get", Args: map[string]any{"current_day": "three"}}}},
+			{ToolCalls: []ToolCall{
+				{Name: "pantry_get", Args: map[string]any{"current_day": 3.0}},
+				{Name: "recipe_get", Args: map[string]any{}},
+			}},
+			{Content: final},
+		},
+	}
+
+	coord := NewCoordinator(llm, tp, 5, pantryagent.NewNoOpCoordinationLogger(), trace.NewTracerProvider())
+	result, err := coord.Run(context.Background(), "Plan meals")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result != final {
+		t.Errorf("Expected result %q, got %q", final, result)
+	}
+
+	// The invalid call must not reach the tool; the valid retry must
+	if pantryTool.callCount != 1 {
+		t.Errorf("Expected pantry_get to run 1 time, ran %d times", pantryTool.callCount)
+	}
+
+	var errorMsg *Message
+	for i, msg := range llm.lastPrompt.Messages {
+		if msg.Role == "tool" && strings.Contains(msg.Content, tools.ErrCodeInvalidInput) {
+			errorMsg = &llm.lastPrompt.Messages[i]
+			break
+		}
+	}
+	if errorMsg == nil {
+		t.Fatalf("Expected an %s tool message in the conversation", tools.ErrCodeInvalidInput)
+	}
+	if !strings.Contains(errorMsg.Content, "current_day") {
+		t.Errorf("Expected tool error to mention current_day, got %s", errorMsg.Content)
+	}
+}
+
 func TestDedupeToolCalls(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -349,3 +444,102 @@ func TestDedupeToolCalls(t *testing.T) {
 		})
 	}
 }
+
+func repeat(res Response, n int) []Response {
+	out := make([]Response, n)
+	for i := range out {
+		out[i] = res
+	}
+	return out
+}
+
+func TestCoordinator_Run_ToolRecovery(t *testing.T) {
+	final := `{"summary": "Quick plan", "days_planned": [{"day": 1, "meals": [{"id": "recipe1", "name": "Quick Meal", "servings": 2}]}]}`
+
+	t.Run("unknown tool is reported with the valid tool names", func(t *testing.T) {
+		pantryTool, recipeTool := &mockTool{name: "pantry_get"}, &mockTool{name: "recipe_get"}
+		tp := &mockToolProvider{tools: []tools.Tool{recipeTool, pantryTool}}
+		llm := &mockLLMClient{responses: []Response{
+			{ToolCalls: []ToolCall{{Name: "get_pantry", Args: map[string]any{}}}},
+			{ToolCalls: []ToolCall{{Name: "pantry_get", Args: map[string]any{}}, {Name: "recipe_get", Args: map[string]any{}}}},
+			{Content: final},
+		}}
+
+		coord := NewCoordinator(llm, tp, 5, pantryagent.NewNoOpCoordinationLogger(), trace.NewTracerProvider())
+		result, err := coord.Run(context.Background(), "Plan meals")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if result != final {
+			t.Errorf("Expected result %q, got %q", final, result)
+		}
+
+		errorMsg := llm.lastPrompt.Messages[2]
+		if errorMsg.Role != "tool" || errorMsg.Name != "get_pantry" {
+			t.Fatalf("Expected a tool message for get_pantry, got %+v", errorMsg)
+		}
+		for _, want := range []string{`"error":"unknown_tool"`, `"valid_tools":["pantry_get","recipe_get"]`} {
+			if !strings.Contains(errorMsg.Content, want) {
+				t.Errorf("Expected tool error to contain %s, got %s", want, errorMsg.Content)
+			}
+		}
+	})
+
+	t.Run("failures are capped", func(t *testing.T) {
+		failing := &mockTool{name: "pantry_get", shouldErr: true}
+		llm := &mockLLMClient{responses: repeat(Response{ToolCalls: []ToolCall{{Name: "pantry_get", Args: map[string]any{}}}}, 5)}
+
+		coord := NewCoordinator(llm, &mockToolProvider{tools: []tools.Tool{failing}}, 5, pantryagent.NewNoOpCoordinationLogger(), trace.NewTracerProvider()).
+			WithToolRecovery(pantryagent.ToolRecovery{MaxConsecutiveFailures: 2})
+		_, err := coord.Run(context.Background(), "Plan meals")
+		if err == nil || !strings.Contains(err.Error(), "giving up after 3 consecutive tool failures") {
+			t.Fatalf("Expected consecutive failures error, got: %v", err)
+		}
+		if failing.callCount != 3 {
+			t.Errorf("Expected pantry_get to run 3 times, ran %d times", failing.callCount)
+		}
+		if !strings.Contains(llm.lastPrompt.Messages[len(llm.lastPrompt.Messages)-1].Content, `"error":"tool_failed"`) {
+			t.Errorf("Expected the failure to be fed back, got %s", llm.lastPrompt.Messages[len(llm.lastPrompt.Messages)-1].Content)
+		}
+	})
+
+	t.Run("successful iterations reset the count", func(t *testing.T) {
+		flaky := &flakyTool{mockTool: mockTool{name: "pantry_get"}, failures: map[int]bool{1: true, 2: true, 4: true, 5: true}}
+		var responses []Response
+		for range 6 {
+			responses = append(responses, Response{ToolCalls: []ToolCall{{Name: "pantry_get", Args: map[string]any{}}}})
+		}
+		llm := &mockLLMClient{responses: responses}
+
+		coord := NewCoordinator(llm, &mockToolProvider{tools: []tools.Tool{flaky}}, 6, pantryagent.NewNoOpCoordinationLogger(), trace.NewTracerProvider()).
+			WithToolRecovery(pantryagent.ToolRecovery{MaxConsecutiveFailures: 2})
+		if _, err := coord.Run(context.Background(), "Plan meals"); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	})
+
+	t.Run("negative cap aborts on the first failure", func(t *testing.T) {
+		llm := &mockLLMClient{responses: []Response{{ToolCalls: []ToolCall{{Name: "nonexistent_tool", Args: map[string]any{}}}}}}
+
+		coord := NewCoordinator(llm, &mockToolProvider{}, 5, pantryagent.NewNoOpCoordinationLogger(), trace.NewTracerProvider()).
+			WithToolRecovery(pantryagent.ToolRecovery{MaxConsecutiveFailures: -1})
+		_, err := coord.Run(context.Background(), "Plan meals")
+		if err == nil || err.Error() != `failed to get tool "nonexistent_tool": tool not found: nonexistent_tool` {
+			t.Fatalf("Expected tool lookup error, got: %v", err)
+		}
+	})
+}
+
+// flakyTool fails on the given (1-based) calls.
+type flakyTool struct {
+	mockTool
+	failures map[int]bool
+}
+
+func (f *flakyTool) Run(ctx context.Context, input map[string]any) (map[string]any, error) {
+	out, _ := f.mockTool.Run(ctx, input)
+	if f.failures[f.callCount] {
+		return nil, fmt.Errorf("flaky failure %d", f.callCount)
+	}
+	return out, nil
+}
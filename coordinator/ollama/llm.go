@@ -18,6 +18,7 @@ type options struct {
 	TopP          float64 `json:"top_p,omitempty"`
 	RepeatPenalty float64 `json:"repeat_penalty,omitempty"`
 	NumCtx        int     `json:"num_ctx,omitempty"`
+	NumPredict    int     `json:"num_predict,omitempty"`
 }
 
 type Client struct {
@@ -33,6 +34,8 @@ type ClientOpts struct {
 	ModelID      string
 	Prompt       Prompt
 	HTTPClient   pantryagent.HTTPClient
+	// MaxTokens caps the tokens generated per response; zero leaves it to Ollama.
+	MaxTokens int
 }
 
 func NewClient(opts ClientOpts) (*Client, error) {
@@ -50,10 +53,26 @@ func NewClient(opts ClientOpts) (*Client, error) {
 			TopP:          0.9,
 			RepeatPenalty: 1.05,
 			NumCtx:        16384, // instructor note: 16384 used as a safe default; raise if your machine can handle it
+			NumPredict:    opts.MaxTokens,
 		},
 	}, nil
 }
 
+// ModelID returns the Ollama model the client invokes.
+func (c *Client) ModelID() string { return c.model }
+
+// StatusError is returned by Invoke when the Ollama API responds with a non-200 status,
+// e.g. 404 when the model has not been pulled.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("LLM_CLIENT: %s: %s", e.Status, e.Body)
+}
+
 type wireToolCall struct {
 	Function struct {
 		Name      string         `json:"name"`
@@ -70,6 +89,8 @@ type wireMessage struct {
 
 type wireResponse struct {
 	Message wireMessage `json:"message"`
+	// DoneReason is "length" when generation hit num_predict or the context size.
+	DoneReason string `json:"done_reason,omitempty"`
 	// other metadata omitted but available
 }
 
@@ -91,12 +112,17 @@ func (c *Client) Invoke(ctx context.Context, prompt Prompt) (Response, error) {
 		return Response{}, err
 	}
 
+	opts := c.options
+	if prompt.MaxTokens > 0 {
+		opts.NumPredict = prompt.MaxTokens
+	}
+
 	reqBody := wireRequest{
 		Model:    c.model,
 		Messages: msgs,
 		Tools:    prompt.Tools,
 		Stream:   false,
-		Options:  c.options,
+		Options:  opts,
 	}
 	reqBytes, err := json.Marshal(reqBody)
 	if err != nil {
@@ -117,13 +143,18 @@ func (c *Client) Invoke(ctx context.Context, prompt Prompt) (Response, error) {
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return Response{}, fmt.Errorf("LLM_CLIENT: %s: %s", resp.Status, string(body))
+		return Response{}, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
 	}
 
 	var wr wireResponse
 	if err := json.Unmarshal(body, &wr); err != nil {
 		slog.Warn("LLM_CLIENT: decode failed, returning raw", "err", err, "body", string(body))
-		return Response{Content: string(body)}, nil
+		return Response{Content: string(body), Model: c.model}, nil
+	}
+
+	truncated := wr.DoneReason == "length"
+	if truncated {
+		slog.Warn("LLM_CLIENT: Response truncated", "num_predict", opts.NumPredict, "num_ctx", opts.NumCtx)
 	}
 
 	if len(wr.Message.ToolCalls) > 0 {
@@ -134,11 +165,11 @@ func (c *Client) Invoke(ctx context.Context, prompt Prompt) (Response, error) {
 				Args: call.Function.Arguments,
 			})
 		}
-		return Response{Content: wr.Message.Content, ToolCalls: tc}, nil
+		return Response{Content: wr.Message.Content, ToolCalls: tc, Model: c.model, Truncated: truncated}, nil
 	}
 
 	// Return the model’s content verbatim; Likely the final response.
-	return Response{Content: wr.Message.Content}, nil
+	return Response{Content: wr.Message.Content, Model: c.model, Truncated: truncated}, nil
 }
 
 // buildRequest converts the high-level Prompt into Ollama chat messages.
@@ -13,9 +13,11 @@ import (
 type mockHTTPClient struct {
 	response *http.Response
 	err      error
+	request  *http.Request
 }
 
 func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	m.request = req
 	return m.response, m.err
 }
 
@@ -171,6 +173,15 @@ func TestClient_Invoke(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "truncated response",
+			mockResponse: createMockResponse(200, `{
+				"message": {"role": "assistant", "content": "{\"summary\": \"Plan\", \"days_pl"},
+				"done_reason": "length"
+			}`),
+			prompt:         Prompt{Messages: []Message{{Role: "user", Content: "Plan meals"}}},
+			expectedResult: Response{Content: `{"summary": "Plan", "days_pl`, Truncated: true},
+		},
 		{
 			name:         "HTTP error",
 			mockResponse: createMockResponse(500, `{"error": "Internal server error"}`),
@@ -249,6 +260,9 @@ func TestClient_Invoke(t *testing.T) {
 			if result.Content != tt.expectedResult.Content {
 				t.Errorf("Invoke() content = %v, want %v", result.Content, tt.expectedResult.Content)
 			}
+			if result.Truncated != tt.expectedResult.Truncated {
+				t.Errorf("Invoke() truncated = %v, want %v", result.Truncated, tt.expectedResult.Truncated)
+			}
 
 			if len(result.ToolCalls) != len(tt.expectedResult.ToolCalls) {
 				t.Errorf("Invoke() tool calls count = %v, want %v", len(result.ToolCalls), len(tt.expectedResult.ToolCalls))
@@ -271,6 +285,42 @@ func TestClient_Invoke(t *testing.T) {
 	}
 }
 
+func TestClient_Invoke_MaxTokens(t *testing.T) {
+	prompt, err := NewPrompt("Plan meals", &mockToolProvider{})
+	if err != nil {
+		t.Fatalf("NewPrompt: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		maxTokens int
+		want      int
+	}{
+		{name: "client budget", want: 1024},
+		{name: "prompt override", maxTokens: 4096, want: 4096},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient := &mockHTTPClient{response: createMockResponse(200, `{"message": {"role": "assistant", "content": "ok"}}`)}
+			client, err := NewClient(ClientOpts{ModelID: "llama3.2", Prompt: prompt, HTTPClient: httpClient, MaxTokens: 1024})
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+
+			if _, err := client.Invoke(context.Background(), Prompt{Messages: []Message{{Role: "user", Content: "Plan meals"}}, MaxTokens: tt.maxTokens}); err != nil {
+				t.Fatalf("Invoke: %v", err)
+			}
+
+			var body wireRequest
+			if err := json.NewDecoder(httpClient.request.Body).Decode(&body); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+			if body.Options.NumPredict != tt.want {
+				t.Errorf("num_predict = %d, want %d", body.Options.NumPredict, tt.want)
+			}
+		})
+	}
+}
+
 func TestClient_buildRequest(t *testing.T) {
 	tests := []struct {
 		name         string
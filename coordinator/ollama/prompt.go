@@ -1,34 +1,30 @@
 package ollama
 
-import "pantryagent"
+import (
+	"pantryagent"
+	"pantryagent/prompts"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+)
 
 // NewPrompt creates a prompt structure compatible with Ollama's native tool calling format.
 // It includes the system prompt, user task, and tools converted to Ollama's expected schema.
 func NewPrompt(task string, tp pantryagent.ToolProvider) (Prompt, error) {
+	return NewPromptWith(prompts.Default().Recorder(nil), task, tp)
+}
+
+// NewPromptWith creates a prompt whose system prompt is rendered by rec.
+func NewPromptWith(rec *prompts.Recorder, task string, tp pantryagent.ToolProvider) (Prompt, error) {
+	systemPrompt, err := rec.Render(prompts.OllamaSystem, nil)
+	if err != nil {
+		return Prompt{}, err
+	}
 	tools := tp.GetTools()
 
 	// Convert tools to Ollama format
 	ollamaTools := make([]Tool, len(tools))
 	for i, tool := range tools {
-		// Get the input schema and convert it to the parameters format
-		schema := tool.InputSchema()
-		parameters := map[string]interface{}{
-			"type":       "object",
-			"properties": schema.Properties,
-		}
-
-		if len(schema.Required) > 0 {
-			parameters["required"] = schema.Required
-		}
-
-		ollamaTools[i] = Tool{
-			Type: "function",
-			Function: ToolSchema{
-				Name:        tool.Name(),
-				Description: tool.Description(),
-				Parameters:  parameters,
-			},
-		}
+		ollamaTools[i] = NewTool(tool.Name(), tool.Description(), tool.InputSchema())
 	}
 
 	return Prompt{
@@ -46,51 +42,23 @@ func NewPrompt(task string, tp pantryagent.ToolProvider) (Prompt, error) {
 	}, nil
 }
 
-const systemPrompt string = `You are a meal‑planning assistant.
+// NewTool converts a tool definition to Ollama's native function format.
+func NewTool(name, description string, schema *jsonschema.Schema) Tool {
+	parameters := map[string]interface{}{
+		"type":       "object",
+		"properties": schema.Properties,
+	}
 
-GOAL
-Plan meals over the user-specified days and servings, using the tools to gather pantry state and available recipes, then return the final meal plan.
+	if len(schema.Required) > 0 {
+		parameters["required"] = schema.Required
+	}
 
-OUTPUT CONTRACT
-- Your final response must be ONE valid JSON object only (no extra text, no markdown, no code fences). Start with '{' and end with '}'.
-- UTF‑8, no trailing commas.
-- Shape:
-{
-  "summary": string,                 // <= 400 chars
-  "days_planned": [                  // at least one element
-    {
-      "day": integer,                // starting at 1
-      "meals": [
-        { "id": string, "name": string, "servings": integer }
-      ]
-    }
-  ]
+	return Tool{
+		Type: "function",
+		Function: ToolSchema{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		},
+	}
 }
-
-TOOLS
-- You have access to tools defined in the "tools" array (function name, description, JSON schema).
-- When you need data, CALL THE TOOL natively (do NOT print a JSON blob that describes a call).
-- After the coordinator sends back a tool result (role:"tool"), USE it to continue planning.
-- Do not re‑call a tool unless the coordinator indicates the data changed.
-- Tool discipline: Call pantry_get once and recipe_get once. If their results are already present (role:“tool”), do not call them again. Proceed directly to planning and return the final JSON.
-
-PLANNING RULES
-- Always retrieve pantry first with pantry_get (include "current_day" in arguments).
-- Always retrieve recipes with recipe_get (you may include "meal_types": ["dinner"] to filter).
-- Never invent recipe IDs. Only select from the recipe_get results.
-- Do not assume unit conversions; a unit mismatch makes a recipe unusable.
-- Prioritize ingredients with the smallest days_left.
-- Ensure the plan is feasible with the provided pantry (no shortages, no unit mismatches).
-- If you already have both pantry and recipes (via role:"tool" messages), proceed to planning and output the final JSON.
-
-WORKFLOW (typical)
-1) Call pantry_get with {"current_day": 0} (or the provided current day).
-2) Call recipe_get, optionally with {"meal_types": ["dinner"]}.
-3) Compare recipe ingredient needs vs. pantry: exclude any with missing items or unit conflicts.
-4) Choose meals to use soon‑to‑expire perishables first.
-5) Return the final JSON object (no commentary).
-
-REMINDERS
-- Use native tool calls only.
-- Do not echo tool results.
-- Final answer MUST be just the JSON object.`
@@ -88,6 +88,17 @@ func TestPrompt_HasToolResult(t *testing.T) {
 		assert.False(t, prompt.HasToolResult("recipe_get"))
 	})
 
+	t.Run("tool errors are not results", func(t *testing.T) {
+		prompt := prompt
+		prompt.Messages = append(prompt.Messages[:len(prompt.Messages):len(prompt.Messages)], Message{
+			Role:    "tool",
+			Name:    "pantry_get",
+			Content: `{"error":"tool_failed","tool":"pantry_get","message":"storage unavailable"}`,
+		})
+
+		assert.False(t, prompt.HasToolResult("pantry_get"))
+	})
+
 	t.Run("with tool results", func(t *testing.T) {
 		// Add a message with tool result (using Ollama's role:"tool" format)
 		prompt.Messages = append(prompt.Messages, Message{
@@ -1,6 +1,7 @@
 package ollama
 
 import (
+	"encoding/json"
 	"strings"
 )
 
@@ -15,19 +16,31 @@ type Message struct {
 type Prompt struct {
 	Messages []Message `json:"messages"`
 	Tools    []Tool    `json:"tools,omitempty"`
+	// MaxTokens overrides the client's output token budget (num_predict) for this request when set.
+	MaxTokens int `json:"max_tokens,omitempty"`
 }
 
 // HasToolResult returns true if a tool result for the specified tool name exists in the prompt's message history.
 // For Ollama native tool calling, it checks for messages with role "tool" and a matching "name" field.
+// Tool errors fed back to the model (see tools.ToolError) are not results.
 func (op *Prompt) HasToolResult(tool string) bool {
 	for _, msg := range op.Messages {
-		if msg.Role == "tool" && msg.Name == tool {
+		if msg.Role == "tool" && msg.Name == tool && !isToolError(msg.Content) {
 			return true
 		}
 	}
 	return false
 }
 
+// isToolError reports whether content is a tools.ToolError payload.
+func isToolError(content string) bool {
+	var payload struct {
+		Error string `json:"error"`
+		Tool  string `json:"tool"`
+	}
+	return json.Unmarshal([]byte(content), &payload) == nil && payload.Error != "" && payload.Tool != ""
+}
+
 // HasToolResultInContent returns true if a tool result for the specified tool name exists in any message content.
 // This checks for tool results embedded in message content as JSON strings.
 func (op *Prompt) HasToolResultInContent(tool string) bool {
@@ -57,6 +70,9 @@ type ToolSchema struct {
 type Response struct {
 	Content   string     `json:"content,omitempty"`
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Model     string     `json:"model,omitempty"` // model that produced the response
+	// Truncated reports that the response was cut off by the output token or context limit.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 // ToolCall represents a tool call made by the model
@@ -0,0 +1,174 @@
+// Package critic implements a critic agent reviewing candidate meal plans against the user's task,
+// nutrition and variety. Plans can be perfectly feasible with the pantry and still ignore what was
+// asked for; the critic returns structured objections for the planner to address.
+package critic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"pantryagent"
+	"pantryagent/coordinator/bedrock"
+	"pantryagent/prompts"
+)
+
+// Objection categories.
+const (
+	CategoryTask      = "task"
+	CategoryNutrition = "nutrition"
+	CategoryVariety   = "variety"
+)
+
+// Objection severities. Only blocking objections reject a plan.
+const (
+	SeverityBlocking = "blocking"
+	SeverityMinor    = "minor"
+)
+
+// Objection is a single problem the critic found in a plan.
+type Objection struct {
+	Category string `json:"category"`
+	Severity string `json:"severity"`
+	Day      int    `json:"day,omitempty"`
+	MealID   string `json:"meal_id,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Review is the critic's verdict on a plan.
+type Review struct {
+	Approved   bool        `json:"approved"`
+	Objections []Objection `json:"objections"`
+	Model      string      `json:"model,omitempty"`
+	// PromptVersion is the version of the critic system prompt used for the review.
+	PromptVersion string `json:"prompt_version,omitempty"`
+}
+
+// Blocking returns the objections that must be addressed before the plan is accepted.
+func (r Review) Blocking() []Objection {
+	var out []Objection
+	for _, o := range r.Objections {
+		if o.Severity == SeverityBlocking {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// llmClient is satisfied by bedrock.LLMClient and fallback.Client, so the critic can run on a different
+// (e.g. cheaper) model than the planner.
+type llmClient interface {
+	Invoke(ctx context.Context, prompt bedrock.Prompt) (bedrock.Response, error)
+}
+
+type Options struct {
+	// Recipes is the recipe catalog; recipes used by a plan are shown to the critic so it can judge
+	// nutrition and variety from their ingredients.
+	Recipes []any
+	// Prompts provides the critic system prompt. Defaults to prompts.Default().
+	Prompts *prompts.Registry
+}
+
+type Critic struct {
+	llm     llmClient
+	recipes map[string]any
+	prompts *prompts.Registry
+}
+
+func New(llm llmClient, opts Options) *Critic {
+	recipes := make(map[string]any, len(opts.Recipes))
+	for _, r := range opts.Recipes {
+		if m, ok := r.(map[string]any); ok {
+			if id, ok := m["id"].(string); ok {
+				recipes[id] = m
+			}
+		}
+	}
+	if opts.Prompts == nil {
+		opts.Prompts = prompts.Default()
+	}
+	return &Critic{llm: llm, recipes: recipes, prompts: opts.Prompts}
+}
+
+// Review asks the critic model to review plan against task.
+func (c *Critic) Review(ctx context.Context, task string, plan pantryagent.MealPlan) (Review, error) {
+	planJSON, err := json.Marshal(plan)
+	if err != nil {
+		return Review{}, fmt.Errorf("marshal plan: %w", err)
+	}
+
+	var used []any
+	seen := map[string]bool{}
+	for _, day := range plan.DaysPlanned {
+		for _, meal := range day.Meals {
+			if r, ok := c.recipes[meal.ID]; ok && !seen[meal.ID] {
+				seen[meal.ID] = true
+				used = append(used, r)
+			}
+		}
+	}
+	recipesJSON, _ := json.Marshal(used)
+
+	system, err := c.prompts.Get(prompts.CriticSystem)
+	if err != nil {
+		return Review{}, err
+	}
+	systemPrompt, err := system.Render(nil)
+	if err != nil {
+		return Review{}, err
+	}
+
+	input := fmt.Sprintf("TASK:\n%s\n\nPLAN:\n%s\n\nRECIPES USED:\n%s", task, planJSON, recipesJSON)
+	prompt := bedrock.Prompt{
+		Messages: []bedrock.Message{
+			{Role: "system", Content: bedrock.MessageParts{{Type: "text", Text: systemPrompt}}},
+			{Role: "user", Content: bedrock.MessageParts{{Type: "text", Text: input}}},
+		},
+	}
+
+	res, err := c.llm.Invoke(ctx, prompt)
+	if err != nil {
+		return Review{}, fmt.Errorf("invoke critic: %w", err)
+	}
+
+	if res.Truncated {
+		return Review{}, fmt.Errorf("critic response truncated by the output token limit")
+	}
+
+	review, err := parseReview(res.Content)
+	if err != nil {
+		return Review{}, err
+	}
+	review.Model, review.PromptVersion = res.Model, system.Version
+
+	slog.Info("CRITIC: Plan reviewed", "approved", review.Approved, "objections", len(review.Objections), "blocking", len(review.Blocking()), "model", review.Model)
+	return review, nil
+}
+
+// parseReview extracts the review JSON object from the critic's response, tolerating surrounding text.
+func parseReview(content string) (Review, error) {
+	text, err := pantryagent.ExtractJSON(content)
+	if errors.Is(err, pantryagent.ErrNoPlanJSON) {
+		return Review{}, fmt.Errorf("critic response is not JSON: %q", content)
+	}
+	if err != nil {
+		return Review{}, fmt.Errorf("parse critic response: %w", err)
+	}
+
+	var review Review
+	if err := json.Unmarshal([]byte(text), &review); err != nil {
+		return Review{}, fmt.Errorf("parse critic response: %w", err)
+	}
+	for i, o := range review.Objections {
+		if o.Severity != SeverityMinor {
+			review.Objections[i].Severity = SeverityBlocking
+		}
+	}
+	// A review with blocking objections is never an approval, whatever the model said
+	if len(review.Blocking()) > 0 {
+		review.Approved = false
+	}
+	return review, nil
+}
@@ -0,0 +1,267 @@
+package critic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"pantryagent"
+	"pantryagent/coordinator/bedrock"
+	"pantryagent/coordinator/ollama"
+	"pantryagent/tools"
+	"pantryagent/tools/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// mockLLM returns canned responses and records the prompts it received.
+type mockLLM struct {
+	responses []bedrock.Response
+	err       error
+	prompts   []bedrock.Prompt
+}
+
+func (m *mockLLM) Invoke(ctx context.Context, prompt bedrock.Prompt) (bedrock.Response, error) {
+	m.prompts = append(m.prompts, prompt)
+	if m.err != nil {
+		return bedrock.Response{}, m.err
+	}
+	if len(m.prompts) > len(m.responses) {
+		return bedrock.Response{}, errors.New("no more responses available")
+	}
+	return m.responses[len(m.prompts)-1], nil
+}
+
+func testRecipes() []any {
+	return []any{
+		map[string]any{
+			"id": "cheese-toast", "name": "Cheese Toast", "meal_types": []any{"dinner"}, "servings": 1.0,
+			"ingredients": []any{
+				map[string]any{"name": "bread", "qty": 2.0, "unit": "slice"},
+				map[string]any{"name": "cheese", "qty": 50.0, "unit": "g"},
+			},
+		},
+		map[string]any{
+			"id": "omelet", "name": "Omelet", "meal_types": []any{"dinner"}, "servings": 1.0,
+			"ingredients": []any{
+				map[string]any{"name": "egg", "qty": 3.0, "unit": "count"},
+			},
+		},
+	}
+}
+
+func testPlan() pantryagent.MealPlan {
+	return pantryagent.MealPlan{
+		Summary: "Two dinners",
+		DaysPlanned: []pantryagent.DayPlan{
+			{Day: 1, Meals: []pantryagent.Meal{{ID: "cheese-toast", Name: "Cheese Toast", Servings: 1}}},
+			{Day: 2, Meals: []pantryagent.Meal{{ID: "cheese-toast", Name: "Cheese Toast", Servings: 1}}},
+		},
+	}
+}
+
+func TestCritic_Review(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectError   bool
+		expectApprove bool
+		expectBlock   int
+	}{
+		{
+			name:          "approved",
+			content:       `{"approved": true, "objections": []}`,
+			expectApprove: true,
+		},
+		{
+			name:          "minor objections only",
+			content:       `{"approved": true, "objections": [{"category": "nutrition", "severity": "minor", "message": "Light on vegetables"}]}`,
+			expectApprove: true,
+		},
+		{
+			name:        "blocking objection overrides approval",
+			content:     `{"approved": true, "objections": [{"category": "variety", "severity": "blocking", "day": 2, "message": "Same recipe two days in a row"}]}`,
+			expectBlock: 1,
+		},
+		{
+			name:        "unknown severity is blocking",
+			content:     "Here is my review:\n" + `{"approved": false, "objections": [{"category": "task", "message": "Plans 2 days, 3 were requested"}]}`,
+			expectBlock: 1,
+		},
+		{
+			name:        "braces around the review",
+			content:     "Checked {cheese-toast} twice.\n```json\n" + `{"approved": false, "objections": [{"category": "variety", "severity": "blocking", "message": "Use a {different} dinner"}]}` + "\n```\nSee {notes}.",
+			expectBlock: 1,
+		},
+		{
+			name:        "not JSON",
+			content:     "Looks good to me!",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &mockLLM{responses: []bedrock.Response{{Content: tt.content, Model: "haiku"}}}
+			c := New(llm, Options{Recipes: testRecipes()})
+
+			review, err := c.Review(context.Background(), "Plan 2 dinners", testPlan())
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectApprove, review.Approved)
+			assert.Len(t, review.Blocking(), tt.expectBlock)
+			assert.Equal(t, "haiku", review.Model)
+
+			// The critic sees the task, the plan and the recipes it uses, and has no tools
+			require.Len(t, llm.prompts, 1)
+			input := llm.prompts[0].Messages[1].Content.Join()
+			assert.Contains(t, input, "Plan 2 dinners")
+			assert.Contains(t, input, `"days_planned"`)
+			assert.Contains(t, input, `"cheese"`)
+			assert.NotContains(t, input, `"omelet"`)
+			assert.Empty(t, llm.prompts[0].Tools)
+		})
+	}
+}
+
+func TestHook(t *testing.T) {
+	blocking := bedrock.Response{Content: `{"approved": false, "objections": [{"category": "variety", "severity": "blocking", "day": 2, "message": "Same recipe two days in a row"}]}`}
+	approved := bedrock.Response{Content: `{"approved": true, "objections": []}`}
+	plan := testPlan()
+
+	t.Run("rejects plans with blocking objections", func(t *testing.T) {
+		h := NewHook(New(&mockLLM{responses: []bedrock.Response{blocking}}, Options{}), HookOpts{})
+		require.NoError(t, h.BeforeRun(context.Background(), &pantryagent.RunEvent{Task: "Plan 2 dinners"}))
+
+		err := h.OnFinalCandidate(context.Background(), &pantryagent.FinalCandidateEvent{Plan: &plan})
+		var objections *ObjectionsError
+		require.ErrorAs(t, err, &objections)
+		assert.EqualError(t, err, "critic objections: variety (day 2): Same recipe two days in a row")
+
+		var detailer pantryagent.RejectionDetailer
+		require.ErrorAs(t, err, &detailer)
+		assert.Equal(t, objections.Objections, detailer.RejectionDetails())
+		assert.Len(t, h.Reviews(), 1)
+	})
+
+	t.Run("parses the candidate content when the plan is not set", func(t *testing.T) {
+		llm := &mockLLM{responses: []bedrock.Response{approved}}
+		h := NewHook(New(llm, Options{}), HookOpts{})
+
+		err := h.OnFinalCandidate(context.Background(), &pantryagent.FinalCandidateEvent{
+			Content: `{"summary":"x","days_planned":[{"day":1,"meals":[{"id":"omelet","name":"Omelet","servings":1}]}]}`,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, llm.prompts, 1)
+	})
+
+	t.Run("ignores candidates rejected by the coordinator", func(t *testing.T) {
+		llm := &mockLLM{responses: []bedrock.Response{blocking}}
+		h := NewHook(New(llm, Options{}), HookOpts{})
+
+		err := h.OnFinalCandidate(context.Background(), &pantryagent.FinalCandidateEvent{Plan: &plan, Rejection: pantryagent.RejectionInfeasible})
+		assert.NoError(t, err)
+		assert.Empty(t, llm.prompts)
+	})
+
+	t.Run("accepts after max rounds", func(t *testing.T) {
+		llm := &mockLLM{responses: []bedrock.Response{blocking, blocking}}
+		h := NewHook(New(llm, Options{}), HookOpts{MaxRounds: 1})
+
+		assert.Error(t, h.OnFinalCandidate(context.Background(), &pantryagent.FinalCandidateEvent{Plan: &plan}))
+		assert.NoError(t, h.OnFinalCandidate(context.Background(), &pantryagent.FinalCandidateEvent{Plan: &plan}))
+		assert.Len(t, llm.prompts, 1)
+
+		// A new run starts over
+		require.NoError(t, h.BeforeRun(context.Background(), &pantryagent.RunEvent{}))
+		assert.Error(t, h.OnFinalCandidate(context.Background(), &pantryagent.FinalCandidateEvent{Plan: &plan}))
+	})
+
+	t.Run("accepts when the critic fails", func(t *testing.T) {
+		h := NewHook(New(&mockLLM{err: errors.New("throttled")}, Options{}), HookOpts{})
+		assert.NoError(t, h.OnFinalCandidate(context.Background(), &pantryagent.FinalCandidateEvent{Plan: &plan}))
+	})
+}
+
+func TestHook_WithBedrockCoordinator(t *testing.T) {
+	pantry := map[string]any{
+		"ingredients": []any{
+			map[string]any{"name": "bread", "qty": 8.0, "unit": "slice", "days_left": 2.0},
+			map[string]any{"name": "cheese", "qty": 200.0, "unit": "g", "days_left": 3.0},
+			map[string]any{"name": "egg", "qty": 6.0, "unit": "count", "days_left": 5.0},
+		},
+	}
+	registry, err := tools.NewRegistry(storage.NewTestPantryState([]byte(`{}`)), storage.NewTestRecipeState([]byte(`[]`)))
+	require.NoError(t, err)
+
+	repetitive := `{"summary":"Two dinners","days_planned":[{"day":1,"meals":[{"id":"cheese-toast","name":"Cheese Toast","servings":1}]},{"day":2,"meals":[{"id":"cheese-toast","name":"Cheese Toast","servings":1}]}]}`
+	varied := `{"summary":"Two dinners","days_planned":[{"day":1,"meals":[{"id":"cheese-toast","name":"Cheese Toast","servings":1}]},{"day":2,"meals":[{"id":"omelet","name":"Omelet","servings":1}]}]}`
+	planner := &mockLLM{responses: []bedrock.Response{{Content: repetitive}, {Content: varied}}}
+	criticLLM := &mockLLM{responses: []bedrock.Response{
+		{Content: `{"approved": false, "objections": [{"category": "variety", "severity": "blocking", "day": 2, "message": "Same recipe two days in a row"}]}`},
+		{Content: `{"approved": true, "objections": []}`},
+	}}
+
+	coordinator := bedrock.NewCoordinator(planner, registry, pantry, testRecipes(), 5,
+		pantryagent.NewNoOpCoordinationLogger(), trace.NewTracerProvider())
+	coordinator.Use(NewHook(New(criticLLM, Options{Recipes: testRecipes()}), HookOpts{}))
+
+	out, err := coordinator.Run(context.Background(), "Plan 2 dinners")
+	require.NoError(t, err)
+	assert.Equal(t, varied, out)
+
+	// The planner got the structured objections back
+	require.Len(t, planner.prompts, 2)
+	msgs := planner.prompts[1].Messages
+	feedback := msgs[len(msgs)-1].Content.Join()
+	assert.Contains(t, feedback, `"error":"final_plan_rejected"`)
+	assert.Contains(t, feedback, `"details":[{"category":"variety","severity":"blocking","day":2,"message":"Same recipe two days in a row"}]`)
+}
+
+// ollamaPlanner returns canned Ollama responses and records the prompts it received.
+type ollamaPlanner struct {
+	responses []ollama.Response
+	prompts   []ollama.Prompt
+}
+
+func (m *ollamaPlanner) Invoke(ctx context.Context, prompt ollama.Prompt) (ollama.Response, error) {
+	m.prompts = append(m.prompts, prompt)
+	if len(m.prompts) > len(m.responses) {
+		return ollama.Response{}, errors.New("no more responses available")
+	}
+	return m.responses[len(m.prompts)-1], nil
+}
+
+func TestHook_WithOllamaCoordinator(t *testing.T) {
+	registry, err := tools.NewRegistry(storage.NewTestPantryState([]byte(`{}`)), storage.NewTestRecipeState([]byte(`[]`)))
+	require.NoError(t, err)
+
+	repetitive := `{"summary":"Two dinners","days_planned":[{"day":1,"meals":[{"id":"cheese-toast","name":"Cheese Toast","servings":1}]},{"day":2,"meals":[{"id":"cheese-toast","name":"Cheese Toast","servings":1}]}]}`
+	varied := `{"summary":"Two dinners","days_planned":[{"day":1,"meals":[{"id":"cheese-toast","name":"Cheese Toast","servings":1}]},{"day":2,"meals":[{"id":"omelet","name":"Omelet","servings":1}]}]}`
+	planner := &ollamaPlanner{responses: []ollama.Response{
+		{ToolCalls: []ollama.ToolCall{{Name: "pantry_get", Args: map[string]any{}}, {Name: "recipe_get", Args: map[string]any{}}}},
+		{Content: repetitive},
+		{Content: varied},
+	}}
+	criticLLM := &mockLLM{responses: []bedrock.Response{
+		{Content: `{"approved": false, "objections": [{"category": "variety", "severity": "blocking", "day": 2, "message": "Same recipe two days in a row"}]}`},
+		{Content: `{"approved": true, "objections": []}`},
+	}}
+
+	coordinator := ollama.NewCoordinator(planner, registry, 5, pantryagent.NewNoOpCoordinationLogger(), trace.NewTracerProvider())
+	coordinator.Use(NewHook(New(criticLLM, Options{Recipes: testRecipes()}), HookOpts{}))
+
+	out, err := coordinator.Run(context.Background(), "Plan 2 dinners")
+	require.NoError(t, err)
+	assert.JSONEq(t, varied, out)
+
+	// The planner got the objections back
+	require.Len(t, planner.prompts, 3)
+	msgs := planner.prompts[2].Messages
+	assert.Contains(t, msgs[len(msgs)-1].Content, "variety (day 2): Same recipe two days in a row")
+}
@@ -0,0 +1,102 @@
+package critic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"pantryagent"
+)
+
+// defaultMaxRounds is the default number of times the critic may reject final candidates in a run.
+const defaultMaxRounds = 2
+
+// Hook runs the critic on every final candidate the coordinator would accept, rejecting candidates with
+// blocking objections so the planner revises them. Attach it with the coordinator's Use method.
+//
+// The critic is advisory: if it fails, or has already rejected MaxRounds candidates, the candidate is
+// accepted so the critic can never prevent a run from completing.
+type Hook struct {
+	pantryagent.NopHook
+
+	critic    *Critic
+	maxRounds int
+
+	task    string
+	rounds  int
+	reviews []Review
+}
+
+type HookOpts struct {
+	// MaxRounds caps the rejections per run. Defaults to 2.
+	MaxRounds int
+}
+
+func NewHook(c *Critic, opts HookOpts) *Hook {
+	if opts.MaxRounds <= 0 {
+		opts.MaxRounds = defaultMaxRounds
+	}
+	return &Hook{critic: c, maxRounds: opts.MaxRounds}
+}
+
+// Reviews returns the reviews of the current (or last) run.
+func (h *Hook) Reviews() []Review { return h.reviews }
+
+func (h *Hook) BeforeRun(ctx context.Context, e *pantryagent.RunEvent) error {
+	h.task, h.rounds, h.reviews = e.Task, 0, nil
+	return nil
+}
+
+func (h *Hook) OnFinalCandidate(ctx context.Context, e *pantryagent.FinalCandidateEvent) error {
+	if e.Rejection != "" {
+		return nil // already rejected by the coordinator
+	}
+	if h.rounds >= h.maxRounds {
+		slog.Info("CRITIC: Max rounds reached; accepting plan", "rounds", h.rounds, "iteration", e.Iteration)
+		return nil
+	}
+
+	plan := e.Plan
+	if plan == nil {
+		plan = &pantryagent.MealPlan{}
+		if err := json.Unmarshal([]byte(e.Content), plan); err != nil {
+			return nil // not a plan the critic can review; the coordinator handles it
+		}
+	}
+
+	review, err := h.critic.Review(ctx, h.task, *plan)
+	if err != nil {
+		slog.Warn("CRITIC: Review failed; accepting plan", "error", err, "iteration", e.Iteration)
+		return nil
+	}
+	h.reviews = append(h.reviews, review)
+
+	blocking := review.Blocking()
+	if len(blocking) == 0 {
+		return nil
+	}
+	h.rounds++
+	return &ObjectionsError{Objections: blocking}
+}
+
+// ObjectionsError rejects a final candidate with the critic's blocking objections.
+type ObjectionsError struct {
+	Objections []Objection
+}
+
+func (e *ObjectionsError) Error() string {
+	msgs := make([]string, len(e.Objections))
+	for i, o := range e.Objections {
+		where := ""
+		if o.Day > 0 {
+			where = fmt.Sprintf(" (day %d)", o.Day)
+		}
+		msgs[i] = fmt.Sprintf("%s%s: %s", o.Category, where, o.Message)
+	}
+	return "critic objections: " + strings.Join(msgs, "; ")
+}
+
+// RejectionDetails implements pantryagent.RejectionDetailer.
+func (e *ObjectionsError) RejectionDetails() any { return e.Objections }
@@ -0,0 +1,195 @@
+// Package eval runs evaluation cases (plan tasks) on a planner backend and checks the plans they produce:
+// that a valid meal plan can be extracted, covers the requested days and servings, only uses recipes of
+// the catalog, and needs no more of each ingredient than the pantry holds. See the eval command of
+// cmd/pantry.
+package eval
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"pantryagent"
+	"pantryagent/planner"
+	"pantryagent/tools"
+)
+
+//go:embed cases.json
+var defaultCases []byte
+
+// Case is a plan task and what its plan must satisfy.
+type Case struct {
+	Name string `json:"name"`
+	Task string `json:"task"`
+	// Days, if positive, is the number of days the plan must cover.
+	Days int `json:"days,omitempty"`
+	// Servings, if positive, is the servings of every meal.
+	Servings int `json:"servings,omitempty"`
+}
+
+// DefaultCases returns the built-in cases.
+func DefaultCases() []Case {
+	cases, err := parseCases(defaultCases)
+	if err != nil {
+		panic(err) // embedded
+	}
+	return cases
+}
+
+// LoadCases reads cases from a JSON file holding an array of Case.
+func LoadCases(path string) ([]Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cases, err := parseCases(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cases, nil
+}
+
+func parseCases(data []byte) ([]Case, error) {
+	var cases []Case
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return nil, fmt.Errorf("parse cases: %w", err)
+	}
+	for i, c := range cases {
+		if c.Name == "" || c.Task == "" {
+			return nil, fmt.Errorf("case %d: name and task are required", i)
+		}
+	}
+	return cases, nil
+}
+
+// Result is the outcome of a case.
+type Result struct {
+	Case    string `json:"case"`
+	Backend string `json:"backend"`
+	Passed  bool   `json:"passed"`
+	// Problems are the checks the plan failed.
+	Problems   []string              `json:"problems,omitempty"`
+	Error      string                `json:"error,omitempty"`
+	Iterations int                   `json:"iterations"`
+	Duration   time.Duration         `json:"duration_ns"`
+	Plan       *pantryagent.MealPlan `json:"plan,omitempty"`
+}
+
+// Run runs the cases on backend in turn and checks their plans with checker.
+func Run(ctx context.Context, backend planner.Backend, checker *Checker, cases []Case) []Result {
+	results := make([]Result, 0, len(cases))
+	for _, c := range cases {
+		log := pantryagent.NewMemoryCoordinationLogger()
+		started := time.Now()
+		output, err := backend.Plan(ctx, planner.Run{Task: c.Task, Logger: log})
+		res := Result{Case: c.Name, Backend: backend.Name(), Iterations: len(log.Iterations()), Duration: time.Since(started)}
+		if err != nil {
+			res.Error = err.Error()
+			results = append(results, res)
+			continue
+		}
+		plan, _, err := pantryagent.ExtractMealPlan(output)
+		if err != nil {
+			res.Problems = []string{fmt.Sprintf("no valid meal plan: %s", err)}
+			results = append(results, res)
+			continue
+		}
+		res.Plan = &plan
+		res.Problems = checker.Check(c, plan)
+		res.Passed = len(res.Problems) == 0
+		results = append(results, res)
+	}
+	return results
+}
+
+// Checker checks plans against a recipe catalog and the pantry.
+type Checker struct {
+	recipes map[string]tools.Recipe
+	// stock is the pantry's quantity of each ingredient by lower-case name and unit
+	stock map[string]map[string]float64
+}
+
+// NewChecker returns a checker for the given recipes and the current ingredients of pantry.
+func NewChecker(recipes []tools.Recipe, pantry *tools.Pantry) (*Checker, error) {
+	current, err := pantry.Current()
+	if err != nil {
+		return nil, err
+	}
+	c := &Checker{recipes: make(map[string]tools.Recipe, len(recipes)), stock: map[string]map[string]float64{}}
+	for _, r := range recipes {
+		c.recipes[r.ID] = r
+	}
+	for _, ing := range current.Ingredients {
+		name := normalize(ing.Name)
+		if c.stock[name] == nil {
+			c.stock[name] = map[string]float64{}
+		}
+		c.stock[name][ing.Unit] += ing.Qty
+	}
+	return c, nil
+}
+
+// Check returns the problems of a plan for case c; none if it passes.
+func (c *Checker) Check(cs Case, plan pantryagent.MealPlan) []string {
+	var problems []string
+	if cs.Days > 0 && len(plan.DaysPlanned) != cs.Days {
+		problems = append(problems, fmt.Sprintf("plans %d days, want %d", len(plan.DaysPlanned), cs.Days))
+	}
+
+	type need struct {
+		name, unit string
+		qty        float64
+	}
+	var (
+		needs []*need
+		index = map[string]*need{}
+	)
+	for _, day := range plan.DaysPlanned {
+		for _, meal := range day.Meals {
+			if cs.Servings > 0 && meal.Servings != cs.Servings {
+				problems = append(problems, fmt.Sprintf("day %d: %q has %d servings, want %d", day.Day, meal.ID, meal.Servings, cs.Servings))
+			}
+			recipe, ok := c.recipes[meal.ID]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("day %d: unknown recipe %q", day.Day, meal.ID))
+				continue
+			}
+			if recipe.Servings <= 0 {
+				continue
+			}
+			scale := float64(meal.Servings) / float64(recipe.Servings)
+			for _, ing := range recipe.Ingredients {
+				key := normalize(ing.Name) + "\x00" + ing.Unit
+				n, ok := index[key]
+				if !ok {
+					n = &need{name: normalize(ing.Name), unit: ing.Unit}
+					index[key] = n
+					needs = append(needs, n)
+				}
+				n.qty += ing.Qty * scale
+			}
+		}
+	}
+
+	const eps = 1e-9
+	for _, n := range needs {
+		stock, ok := c.stock[n.name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("needs %g %s of %s, which the pantry lacks", n.qty, n.unit, n.name))
+		case stock[n.unit] == 0:
+			problems = append(problems, fmt.Sprintf("needs %g %s of %s, which the pantry holds in other units", n.qty, n.unit, n.name))
+		case n.qty > stock[n.unit]+eps:
+			problems = append(problems, fmt.Sprintf("needs %g %s of %s, the pantry holds %g", n.qty, n.unit, n.name, stock[n.unit]))
+		}
+	}
+	return problems
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,116 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pantryagent"
+	"pantryagent/planner"
+	"pantryagent/prompts"
+	"pantryagent/tools"
+	"pantryagent/tools/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultCases(t *testing.T) {
+	cases := DefaultCases()
+	require.NotEmpty(t, cases)
+	assert.Equal(t, planner.DefaultTask, cases[0].Task)
+
+	path := filepath.Join(t.TempDir(), "cases.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name": "one", "task": "Plan one dinner", "days": 1}]`), 0o644))
+	loaded, err := LoadCases(path)
+	require.NoError(t, err)
+	assert.Equal(t, []Case{{Name: "one", Task: "Plan one dinner", Days: 1}}, loaded)
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name": "one"}]`), 0o644))
+	_, err = LoadCases(path)
+	assert.ErrorContains(t, err, "name and task are required")
+}
+
+func TestChecker(t *testing.T) {
+	recipes := []tools.Recipe{
+		{ID: "soup", Name: "Soup", Servings: 2, Ingredients: []tools.RecipeIngredient{{Name: "Carrot", Qty: 2, Unit: "count"}, {Name: "stock", Qty: 500, Unit: "ml"}}},
+		{ID: "toast", Name: "Toast", Servings: 1, Ingredients: []tools.RecipeIngredient{{Name: "bread", Qty: 2, Unit: "slice"}}},
+	}
+	pantry := &tools.Pantry{Ingredients: []tools.Ingredient{
+		{Name: "carrot", Qty: 3, Unit: "count"},
+		{Name: "carrot", Qty: 1, Unit: "count"},
+		{Name: "stock", Qty: 1, Unit: "L"},
+		{Name: "bread", Qty: 4, Unit: "slice"},
+	}}
+	checker, err := NewChecker(recipes, pantry)
+	require.NoError(t, err)
+
+	day := func(n int, meals ...pantryagent.Meal) pantryagent.DayPlan {
+		return pantryagent.DayPlan{Day: n, Meals: meals}
+	}
+	toast := pantryagent.Meal{ID: "toast", Name: "Toast", Servings: 1}
+	assert.Empty(t, checker.Check(Case{Days: 2, Servings: 1}, pantryagent.MealPlan{DaysPlanned: []pantryagent.DayPlan{day(1, toast), day(2, toast)}}))
+
+	soup := pantryagent.Meal{ID: "soup", Name: "Soup", Servings: 4}
+	problems := checker.Check(Case{Days: 3, Servings: 1}, pantryagent.MealPlan{DaysPlanned: []pantryagent.DayPlan{
+		day(1, soup, toast),
+		day(2, toast, pantryagent.Meal{ID: "pie", Servings: 1}),
+	}})
+	// 4 carrots and 4 slices of bread are just enough
+	assert.Equal(t, []string{
+		"plans 2 days, want 3",
+		`day 1: "soup" has 4 servings, want 1`,
+		`day 2: unknown recipe "pie"`,
+		"needs 1000 ml of stock, which the pantry holds in other units",
+	}, problems)
+
+	problems = checker.Check(Case{}, pantryagent.MealPlan{DaysPlanned: []pantryagent.DayPlan{
+		day(1, pantryagent.Meal{ID: "toast", Servings: 3}),
+	}})
+	assert.Equal(t, []string{"needs 6 slice of bread, the pantry holds 4"}, problems)
+}
+
+// failingBackend fails every plan.
+type failingBackend struct{}
+
+func (failingBackend) Name() string { return "failing" }
+func (failingBackend) Plan(ctx context.Context, run planner.Run) (string, error) {
+	return "", errors.New("model unavailable")
+}
+
+func TestRun(t *testing.T) {
+	ps := storage.NewFilePantryState("../artifacts/pantry.json")
+	rs := storage.NewFileRecipeState("../artifacts/recipes.json")
+	registry, err := tools.NewRegistry(ps, rs)
+	require.NoError(t, err)
+	promptRegistry, err := prompts.Configure("", nil)
+	require.NoError(t, err)
+
+	rawRecipes, err := rs.Load(context.Background())
+	require.NoError(t, err)
+	recipes, err := tools.ParseRecipes(rawRecipes)
+	require.NoError(t, err)
+	rawPantry, err := ps.Load(context.Background())
+	require.NoError(t, err)
+	pantry, err := tools.ParsePantry(rawPantry)
+	require.NoError(t, err)
+	checker, err := NewChecker(recipes, pantry)
+	require.NoError(t, err)
+
+	mock := planner.NewMock(planner.Deps{Registry: registry, Prompts: promptRegistry, Agent: pantryagent.AgentConfig{MaxIterations: 5}})
+	results := Run(context.Background(), mock, checker, []Case{{Name: "default", Task: planner.DefaultTask, Days: 3, Servings: 2}})
+	require.Len(t, results, 1)
+	res := results[0]
+	assert.Equal(t, "mock", res.Backend)
+	assert.Equal(t, 2, res.Iterations)
+	require.NotNil(t, res.Plan)
+	// The mock coordinator answers with a canned plan, which is valid but not from the catalog
+	assert.False(t, res.Passed)
+	assert.Contains(t, res.Problems, `day 1: unknown recipe "dinner_bean_chili"`)
+
+	results = Run(context.Background(), failingBackend{}, checker, []Case{{Name: "default", Task: planner.DefaultTask}})
+	assert.False(t, results[0].Passed)
+	assert.Equal(t, "model unavailable", results[0].Error)
+}
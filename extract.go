@@ -0,0 +1,221 @@
+package pantryagent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrNoPlanJSON is returned (wrapped) by ExtractMealPlan and ExtractJSON when the content contains no
+// JSON object at all.
+var ErrNoPlanJSON = errors.New("no JSON object found")
+
+// PlanError is a parse or validation error of a final answer. Line and Column locate parse errors in
+// the content passed to ExtractMealPlan; they are zero when the error has no position.
+type PlanError struct {
+	Line   int
+	Column int
+	Msg    string
+	Err    error
+}
+
+func (e *PlanError) Error() string {
+	if e.Line == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+func (e *PlanError) Unwrap() error { return e.Err }
+
+var codeFence = regexp.MustCompile("(?s)```[a-zA-Z]*[ \t]*\r?\n(.*?)```")
+
+// ExtractMealPlan finds the meal plan JSON in a model's final answer and validates it. Models often wrap
+// the JSON in markdown code fences or prose, or leave trailing commas; the JSON is looked for in code
+// fences first, then as the first balanced object in the text, and trailing commas are dropped.
+//
+// It returns the plan and its JSON text, without trailing commas. Errors are *PlanError, wrapping ErrNoPlanJSON when
+// the content contains no JSON object.
+func ExtractMealPlan(content string) (MealPlan, string, error) {
+	spans := candidates(content)
+	if len(spans) == 0 {
+		return MealPlan{}, "", &PlanError{Msg: "final answer is not JSON: " + ErrNoPlanJSON.Error(), Err: ErrNoPlanJSON}
+	}
+
+	var firstErr error
+	for _, s := range spans {
+		plan, text, err := decodePlan(content, s)
+		if err == nil {
+			return plan, text, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return MealPlan{}, "", firstErr
+}
+
+// ExtractJSON finds the first JSON object in a model's answer the way ExtractMealPlan does, in code
+// fences first, then in the text, and returns it without trailing commas. Braces in the surrounding
+// prose or in JSON strings do not confuse it.
+func ExtractJSON(content string) (string, error) {
+	spans := candidates(content)
+	if len(spans) == 0 {
+		return "", fmt.Errorf("answer is not JSON: %w", ErrNoPlanJSON)
+	}
+
+	var firstErr error
+	for _, s := range spans {
+		blanked, cleaned := stripTrailingCommas(content[s.start:s.end])
+		var v map[string]any
+		err := json.Unmarshal([]byte(blanked), &v)
+		if err == nil {
+			return cleaned, nil
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("invalid JSON: %w", err)
+		}
+	}
+	return "", firstErr
+}
+
+type span struct{ start, end int }
+
+// candidates returns the spans of content that may hold the plan, most likely first: objects in code
+// fences, then the objects in the text outside them.
+func candidates(content string) []span {
+	var spans []span
+	fences := codeFence.FindAllStringSubmatchIndex(content, -1)
+	for _, m := range fences {
+		body := content[m[2]:m[3]]
+		if start := strings.IndexByte(body, '{'); start >= 0 && strings.TrimSpace(body[:start]) == "" {
+			// Bound the object by its fence so a broken one doesn't swallow the rest of the answer.
+			spans = append(spans, objectSpan(content[:m[3]], m[2]+start))
+		}
+	}
+
+	for i := 0; i < len(content); {
+		if len(fences) > 0 && i >= fences[0][0] {
+			i, fences = fences[0][1], fences[1:]
+			continue
+		}
+		next := strings.IndexByte(content[i:], '{')
+		if next < 0 {
+			break
+		}
+		if start := i + next; len(fences) == 0 || start < fences[0][0] {
+			s := objectSpan(content, start)
+			spans = append(spans, s)
+			i = s.end
+			continue
+		}
+		i = fences[0][0]
+	}
+	return spans
+}
+
+// objectSpan returns the span of the JSON object starting at start, up to its matching closing brace,
+// or up to the end of content when the object is not closed (e.g. truncated output).
+func objectSpan(content string, start int) span {
+	depth, inString, escaped := 0, false, false
+	for i := start; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return span{start, i + 1}
+			}
+		}
+	}
+	return span{start, len(content)}
+}
+
+// decodePlan decodes and validates the plan in s. Trailing commas are blanked out rather than removed
+// so that error offsets still match content.
+func decodePlan(content string, s span) (MealPlan, string, error) {
+	raw := content[s.start:s.end]
+	blanked, cleaned := stripTrailingCommas(raw)
+
+	var plan MealPlan
+	if err := json.Unmarshal([]byte(blanked), &plan); err != nil {
+		offset := -1
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			offset = int(syntaxErr.Offset)
+		case errors.As(err, &typeErr):
+			offset = int(typeErr.Offset)
+		}
+		perr := &PlanError{Msg: "invalid plan JSON: " + err.Error(), Err: err}
+		if offset >= 0 {
+			// Offsets count the bytes read, including the offending one.
+			perr.Line, perr.Column = position(content, s.start+max(offset-1, 0))
+		}
+		return MealPlan{}, "", perr
+	}
+	if err := plan.Validate(); err != nil {
+		return MealPlan{}, "", &PlanError{Msg: "invalid plan: " + err.Error(), Err: err}
+	}
+	return plan, cleaned, nil
+}
+
+// stripTrailingCommas returns raw with commas before a closing bracket replaced by spaces, and removed.
+func stripTrailingCommas(raw string) (blanked, cleaned string) {
+	b := []byte(raw)
+	var drop []int
+	inString, escaped := false, false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case !inString && c == ',':
+			j := i + 1
+			for j < len(b) && strings.IndexByte(" \t\r\n", b[j]) >= 0 {
+				j++
+			}
+			if j < len(b) && (b[j] == '}' || b[j] == ']') {
+				drop = append(drop, i)
+			}
+		}
+	}
+	if len(drop) == 0 {
+		return raw, raw
+	}
+
+	var sb strings.Builder
+	prev := 0
+	for _, i := range drop {
+		b[i] = ' '
+		sb.WriteString(raw[prev:i])
+		prev = i + 1
+	}
+	sb.WriteString(raw[prev:])
+	return string(b), sb.String()
+}
+
+// position returns the 1-based line and column of the byte at offset in content.
+func position(content string, offset int) (line, column int) {
+	offset = min(offset, len(content))
+	before := content[:offset]
+	line = strings.Count(before, "\n") + 1
+	column = offset - strings.LastIndexByte(before, '\n')
+	return line, column
+}
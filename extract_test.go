@@ -0,0 +1,113 @@
+package pantryagent
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const planJSON = `{"summary": "Quick plan", "days_planned": [{"day": 1, "meals": [{"id": "r1", "name": "Soup", "servings": 2}]}]}`
+
+func TestExtractMealPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "bare JSON", content: planJSON, want: planJSON},
+		{name: "code fence", content: "```json\n" + planJSON + "\n```", want: planJSON},
+		{name: "unlabelled fence in prose", content: "Here you go:\n\n```\n" + planJSON + "\n```\n\nEnjoy your meals!", want: planJSON},
+		{name: "prose around JSON", content: "Sure! The plan is " + planJSON + " — let me know.", want: planJSON},
+		{name: "braces in strings", content: `Plan: {"summary": "Use {leftovers}", "days_planned": [{"day": 1, "meals": [{"id": "r1", "name": "Soup \"}\"", "servings": 2}]}]} done`,
+			want: `{"summary": "Use {leftovers}", "days_planned": [{"day": 1, "meals": [{"id": "r1", "name": "Soup \"}\"", "servings": 2}]}]}`},
+		{
+			name:    "trailing commas",
+			content: "{\"summary\": \"Quick plan\", \"days_planned\": [{\"day\": 1, \"meals\": [{\"id\": \"r1\", \"name\": \"Soup,\", \"servings\": 2,},],},],}",
+			want:    "{\"summary\": \"Quick plan\", \"days_planned\": [{\"day\": 1, \"meals\": [{\"id\": \"r1\", \"name\": \"Soup,\", \"servings\": 2}]}]}",
+		},
+		{name: "invalid fence falls back to later object", content: "```json\n{\"draft\": true\n```\nFinal: " + planJSON, want: planJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, text, err := ExtractMealPlan(tt.content)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, text)
+			assert.Len(t, plan.DaysPlanned, 1)
+			assert.True(t, plan.IsValid())
+		})
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	text, err := ExtractJSON("Review of {the plan}:\n```json\n{\"approved\": false, \"note\": \"a {b}\",}\n```\nThanks {all}")
+	require.NoError(t, err)
+	assert.Equal(t, `{"approved": false, "note": "a {b}"}`, text)
+
+	text, err = ExtractJSON(`Prose with {braces} first, then {"approved": true}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"approved": true}`, text)
+
+	_, err = ExtractJSON("no JSON here")
+	assert.ErrorIs(t, err, ErrNoPlanJSON)
+}
+
+func TestExtractMealPlan_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		line, column int
+		contains     string
+		noJSON       bool
+	}{
+		{name: "prose only", content: "I will help you plan meals.", contains: "not JSON", noJSON: true},
+		{name: "empty", content: "  ", noJSON: true},
+		{
+			name:     "syntax error position",
+			content:  "Plan:\n{\n  \"summary\": \"x\",\n  \"days_planned\": [oops]\n}",
+			line:     4,
+			column:   20,
+			contains: "invalid character 'o'",
+		},
+		{name: "truncated", content: "```json\n{\"summary\": \"x\", \"days_planned\": [", line: 2, contains: "unexpected end of JSON input"},
+		{name: "wrong type", content: `{"summary": "x", "days_planned": [{"day": "one"}]}`, line: 1, contains: "days_planned.0.day"},
+		{
+			name:     "invalid field",
+			content:  `{"summary": "x", "days_planned": [{"day": 1, "meals": [{"id": "r1", "name": "Soup", "servings": 0}]}]}`,
+			contains: "days_planned[0].meals[0].servings must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ExtractMealPlan(tt.content)
+			require.Error(t, err)
+			assert.Equal(t, tt.noJSON, errors.Is(err, ErrNoPlanJSON))
+			assert.ErrorContains(t, err, tt.contains)
+
+			var perr *PlanError
+			require.ErrorAs(t, err, &perr)
+			assert.Equal(t, tt.line, perr.Line)
+			if tt.column != 0 {
+				assert.Equal(t, tt.column, perr.Column)
+			}
+		})
+	}
+}
+
+func TestMealPlanValidate(t *testing.T) {
+	plan := MealPlan{Summary: "x", DaysPlanned: []DayPlan{
+		{Day: 1, Meals: []Meal{{ID: "a", Name: "A", Servings: 1}}},
+		{Day: 2, Meals: []Meal{{ID: "b", Servings: 1}}},
+	}}
+	assert.EqualError(t, plan.Validate(), "days_planned[1].meals[0].name must not be empty")
+	assert.False(t, plan.IsValid())
+
+	plan.DaysPlanned[1].Meals[0].Name = "B"
+	assert.NoError(t, plan.Validate())
+	assert.True(t, plan.IsValid())
+
+	assert.EqualError(t, (&MealPlan{}).Validate(), "days_planned must not be empty")
+}
@@ -0,0 +1,224 @@
+package pantryagent
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Hook observes or modifies the traffic flowing through a coordinator run.
+//
+// Coordinators call hooks at fixed points of their loop. Events are passed by pointer so a hook can
+// rewrite what the coordinator is about to use (e.g. redact a tool input or a final plan). Returning
+// an error from a hook aborts or rejects the step it was called for; see each event type for details.
+//
+// Embed NopHook to implement only the callbacks you care about.
+type Hook interface {
+	// BeforeRun is called once before the first iteration. An error aborts the run.
+	BeforeRun(ctx context.Context, e *RunEvent) error
+	// AfterRun is called once when the run ends, successfully or not. An error is returned from Run
+	// if the run itself succeeded.
+	AfterRun(ctx context.Context, e *RunEvent) error
+	// BeforeInvoke is called before the model is invoked. An error aborts the run.
+	BeforeInvoke(ctx context.Context, e *InvokeEvent) error
+	// AfterInvoke is called after the model responded, or failed to. An error aborts the run.
+	AfterInvoke(ctx context.Context, e *InvokeEvent) error
+	// BeforeToolCall is called before a tool is looked up and run. An error skips the tool and is
+	// handled by the coordinator like a tool failure.
+	BeforeToolCall(ctx context.Context, e *ToolCallEvent) error
+	// AfterToolCall is called after a tool ran, or failed to. An error is handled by the coordinator
+	// like a tool failure.
+	AfterToolCall(ctx context.Context, e *ToolCallEvent) error
+	// OnFinalCandidate is called for every response the coordinator considers as a final answer,
+	// after its own validation. An error rejects a candidate the coordinator would have accepted; the
+	// candidate is then reported again with Rejection set to RejectionHook. A candidate without a
+	// rejection may still be rejected by a later hook, so count accepted answers in AfterRun.
+	OnFinalCandidate(ctx context.Context, e *FinalCandidateEvent) error
+	// OnIterationEnd is called at the end of every iteration, before the iteration is logged.
+	// Errors are logged and otherwise ignored since the iteration already happened.
+	OnIterationEnd(ctx context.Context, e *IterationEndEvent) error
+}
+
+// RunEvent describes a whole coordination run.
+type RunEvent struct {
+	Task       string
+	Tools      int               // number of tools offered to the model
+	Output     string            // final output, set for AfterRun
+	Iterations int               // iterations used, set for AfterRun
+	Duration   time.Duration     // total run time, set for AfterRun
+	Err        error             // run error, set for AfterRun
+	Prompts    map[string]string // prompt template versions used by the run, by name; set for AfterRun
+}
+
+// InvokeEvent describes a single model invocation.
+//
+// Prompt and Response hold pointers to the coordinator's own provider-specific types
+// (e.g. *bedrock.Prompt, *ollama.Response); hooks may type-assert and modify them in place.
+type InvokeEvent struct {
+	Iteration     int
+	Prompt        any
+	PromptBytes   int
+	Messages      int
+	Tools         int
+	Response      any           // set for AfterInvoke
+	Model         string        // model that produced the response, set for AfterInvoke
+	Content       string        // response text, set for AfterInvoke
+	ContentLength int           // set for AfterInvoke
+	ToolCalls     int           // set for AfterInvoke
+	Duration      time.Duration // set for AfterInvoke
+	Err           error         // set for AfterInvoke
+}
+
+// ToolCallEvent describes a single tool call requested by the model.
+// Name and Input may be rewritten by BeforeToolCall, Output by AfterToolCall.
+type ToolCallEvent struct {
+	Iteration int
+	Name      string
+	Input     map[string]any
+	Output    map[string]any // set for AfterToolCall
+	Duration  time.Duration  // set for AfterToolCall
+	Err       error          // set for AfterToolCall
+}
+
+// FinalCandidateEvent describes a model response the coordinator considered as a final answer.
+type FinalCandidateEvent struct {
+	Iteration int
+	// Content is the candidate text. Hooks may rewrite it; the rewritten content is what the
+	// coordinator returns if the candidate is accepted.
+	Content string
+	// Plan is the parsed candidate, nil when the content is not a valid meal plan.
+	Plan *MealPlan
+	// Rejection is the reason the coordinator rejected the candidate, empty when it is accepted.
+	Rejection string
+	// FeasibilityChecked reports whether a feasibility check was run on the candidate.
+	FeasibilityChecked bool
+	// FeasibilityDuration is the time the feasibility check took.
+	FeasibilityDuration time.Duration
+	// Problems lists feasibility problems found in the candidate.
+	Problems []string
+}
+
+// Rejection reasons reported in FinalCandidateEvent.Rejection.
+const (
+	RejectionNotJSON            = "not_json_format"
+	RejectionInvalidPlan        = "schema_validation_failed"
+	RejectionFeasibilityError   = "feasibility_check_error"
+	RejectionInfeasible         = "feasibility_check_failed"
+	RejectionMissingToolResults = "missing_tool_results"
+	RejectionHook               = "hook_rejected"
+)
+
+// RejectionDetailer is implemented by OnFinalCandidate errors carrying structured details (e.g. a list
+// of objections) that coordinators pass on to the model along with the error message.
+type RejectionDetailer interface {
+	RejectionDetails() any
+}
+
+// IterationEndEvent describes a finished iteration.
+type IterationEndEvent struct {
+	Iteration int
+	Outcome   string
+	Duration  time.Duration
+	// Log is the iteration log about to be written; hooks may annotate or redact it.
+	Log *IterationLog
+}
+
+// Iteration outcomes reported in IterationEndEvent.Outcome.
+const (
+	OutcomeToolCalls           = "tool_calls"
+	OutcomeFinal               = "final"
+	OutcomeRejected            = "candidate_rejected"
+	OutcomeNudged              = "nudged"
+	OutcomeRepetitionPrevented = "tool_repetition_prevented"
+	OutcomeTruncated           = "truncated"
+	OutcomeFailed              = "failed"
+)
+
+// NopHook implements Hook with no-op callbacks. Embed it to implement only some callbacks.
+type NopHook struct{}
+
+func (NopHook) BeforeRun(context.Context, *RunEvent) error                   { return nil }
+func (NopHook) AfterRun(context.Context, *RunEvent) error                    { return nil }
+func (NopHook) BeforeInvoke(context.Context, *InvokeEvent) error             { return nil }
+func (NopHook) AfterInvoke(context.Context, *InvokeEvent) error              { return nil }
+func (NopHook) BeforeToolCall(context.Context, *ToolCallEvent) error         { return nil }
+func (NopHook) AfterToolCall(context.Context, *ToolCallEvent) error          { return nil }
+func (NopHook) OnFinalCandidate(context.Context, *FinalCandidateEvent) error { return nil }
+func (NopHook) OnIterationEnd(context.Context, *IterationEndEvent) error     { return nil }
+
+// Hooks is an ordered chain of hooks. Each callback is dispatched to the hooks in order and stops at
+// the first error, so earlier hooks see (and may modify) events before later ones.
+type Hooks []Hook
+
+func (hs Hooks) BeforeRun(ctx context.Context, e *RunEvent) error {
+	for _, h := range hs {
+		if err := h.BeforeRun(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hs Hooks) AfterRun(ctx context.Context, e *RunEvent) error {
+	for _, h := range hs {
+		if err := h.AfterRun(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hs Hooks) BeforeInvoke(ctx context.Context, e *InvokeEvent) error {
+	for _, h := range hs {
+		if err := h.BeforeInvoke(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hs Hooks) AfterInvoke(ctx context.Context, e *InvokeEvent) error {
+	for _, h := range hs {
+		if err := h.AfterInvoke(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hs Hooks) BeforeToolCall(ctx context.Context, e *ToolCallEvent) error {
+	for _, h := range hs {
+		if err := h.BeforeToolCall(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hs Hooks) AfterToolCall(ctx context.Context, e *ToolCallEvent) error {
+	for _, h := range hs {
+		if err := h.AfterToolCall(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hs Hooks) OnFinalCandidate(ctx context.Context, e *FinalCandidateEvent) error {
+	for _, h := range hs {
+		if err := h.OnFinalCandidate(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnIterationEnd dispatches to every hook regardless of errors, logging each one, and returns nil.
+func (hs Hooks) OnIterationEnd(ctx context.Context, e *IterationEndEvent) error {
+	for _, h := range hs {
+		if err := h.OnIterationEnd(ctx, e); err != nil {
+			slog.Error("HOOKS: OnIterationEnd failed", "error", err, "iteration", e.Iteration)
+		}
+	}
+	return nil
+}
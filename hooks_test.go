@@ -0,0 +1,74 @@
+package pantryagent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type orderHook struct {
+	NopHook
+	name  string
+	seen  *[]string
+	err   error
+	label string
+}
+
+func (h orderHook) BeforeToolCall(ctx context.Context, e *ToolCallEvent) error {
+	*h.seen = append(*h.seen, h.name+":"+e.Name)
+	if h.label != "" {
+		e.Name = h.label
+	}
+	return h.err
+}
+
+func (h orderHook) OnIterationEnd(ctx context.Context, e *IterationEndEvent) error {
+	*h.seen = append(*h.seen, h.name)
+	return h.err
+}
+
+func TestHooks(t *testing.T) {
+	t.Run("dispatches in order and lets earlier hooks modify events", func(t *testing.T) {
+		var seen []string
+		hooks := Hooks{
+			orderHook{name: "first", seen: &seen, label: "renamed"},
+			orderHook{name: "second", seen: &seen},
+		}
+
+		err := hooks.BeforeToolCall(context.Background(), &ToolCallEvent{Name: "pantry_get"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"first:pantry_get", "second:renamed"}, seen)
+	})
+
+	t.Run("stops at the first error", func(t *testing.T) {
+		var seen []string
+		hooks := Hooks{
+			orderHook{name: "first", seen: &seen, err: errors.New("denied")},
+			orderHook{name: "second", seen: &seen},
+		}
+
+		err := hooks.BeforeToolCall(context.Background(), &ToolCallEvent{Name: "pantry_get"})
+		assert.EqualError(t, err, "denied")
+		assert.Equal(t, []string{"first:pantry_get"}, seen)
+	})
+
+	t.Run("iteration end reaches every hook despite errors", func(t *testing.T) {
+		var seen []string
+		hooks := Hooks{
+			orderHook{name: "first", seen: &seen, err: errors.New("boom")},
+			orderHook{name: "second", seen: &seen},
+		}
+
+		err := hooks.OnIterationEnd(context.Background(), &IterationEndEvent{Iteration: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"first", "second"}, seen)
+	})
+
+	t.Run("empty chain is a no-op", func(t *testing.T) {
+		var hooks Hooks
+		assert.NoError(t, hooks.BeforeRun(context.Background(), &RunEvent{}))
+		assert.NoError(t, hooks.OnFinalCandidate(context.Background(), &FinalCandidateEvent{}))
+	})
+}
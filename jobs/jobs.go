@@ -0,0 +1,146 @@
+// Package jobs queues plan runs persistently and processes them with a pool of workers, so that bursts
+// of requests are accepted right away and worked off at a pace the backends can take.
+//
+// A Queue (see sqlite.Store.Jobs) holds the jobs; a Pool claims due jobs, caps the jobs running at once
+// per backend, retries failed attempts with exponential backoff and dead-letters jobs that run out of
+// attempts, to be retried by hand. Jobs enqueued with an idempotency key are only enqueued once, so
+// clients can safely resend a request after a timeout.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the state of a job.
+type Status string
+
+const (
+	// Pending jobs wait for a worker, possibly until a retry is due (RunAfter).
+	Pending Status = "pending"
+	Running Status = "running"
+	// Succeeded jobs have an Output.
+	Succeeded Status = "succeeded"
+	// Dead jobs failed every attempt, or failed permanently; Error is the last failure.
+	Dead Status = "dead"
+)
+
+// DefaultMaxAttempts is the number of attempts of jobs enqueued without one.
+const DefaultMaxAttempts = 3
+
+var (
+	ErrNotFound = errors.New("job not found")
+	// ErrNotDead is returned when retrying a job that is not dead-lettered.
+	ErrNotDead = errors.New("job is not dead-lettered")
+)
+
+// Job is a queued plan run.
+type Job struct {
+	ID             string `json:"id"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	Backend        string `json:"backend"`
+	Task           string `json:"task"`
+	Status         Status `json:"status"`
+	// Attempts counts the attempts started so far, including a running one.
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// RunAfter is when a pending job is due; retries are delayed by the backoff.
+	RunAfter time.Time `json:"run_after"`
+	Output   string    `json:"output,omitempty"`
+	// Error is the failure of the last attempt.
+	Error string `json:"error,omitempty"`
+}
+
+// Request is a job to enqueue.
+type Request struct {
+	Backend string
+	Task    string
+	// IdempotencyKey, if set, makes enqueueing the same key again return the first job.
+	IdempotencyKey string
+	// MaxAttempts is DefaultMaxAttempts if not positive.
+	MaxAttempts int
+}
+
+// ListOptions filters the jobs returned by Queue.List.
+type ListOptions struct {
+	// Status, if set, only returns jobs in that state.
+	Status Status
+	Limit  int
+}
+
+// Queue is persistent job storage. Implementations must be safe for concurrent use.
+type Queue interface {
+	// Enqueue adds a pending job for req, due now. If a job was already enqueued with req's idempotency
+	// key, it returns that job instead, with created false.
+	Enqueue(ctx context.Context, req Request) (job Job, created bool, err error)
+	// Claim marks the oldest job due at now, with a backend not in busy, as running and counts an
+	// attempt. ok is false if there is none.
+	Claim(ctx context.Context, now time.Time, busy []string) (job Job, ok bool, err error)
+	// Complete marks a running job as succeeded with output.
+	Complete(ctx context.Context, id, output string) error
+	// Fail records the failed attempt of a running job, which is retried at retryAt, or dead-lettered if
+	// retryAt is zero.
+	Fail(ctx context.Context, id string, cause error, retryAt time.Time) error
+	// Release returns a running job to the queue without counting its attempt, e.g. when the worker
+	// running it is shut down.
+	Release(ctx context.Context, id string) error
+	// Recover releases the jobs left running by a process that stopped without finishing them, and
+	// returns how many there were. Only one process may work off a queue.
+	Recover(ctx context.Context) (int, error)
+	// Retry requeues a dead-lettered job with a fresh set of attempts; other jobs give ErrNotDead.
+	Retry(ctx context.Context, id string) (Job, error)
+	// Get returns a job, or ErrNotFound.
+	Get(ctx context.Context, id string) (Job, error)
+	// List returns the jobs matching opts, newest first.
+	List(ctx context.Context, opts ListOptions) ([]Job, error)
+}
+
+// NewID returns a random job ID.
+func NewID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b) // never fails, see crypto/rand.Read
+	return hex.EncodeToString(b)
+}
+
+// permanentError is a failure that retrying cannot fix.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure that retrying cannot fix (e.g. an unknown backend); the Pool
+// dead-letters the job right away.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// ParseLimits parses per-backend concurrency limits given as "<backend>=<n>", e.g. "bedrock=2".
+func ParseLimits(specs []string) (map[string]int, error) {
+	limits := make(map[string]int, len(specs))
+	for _, spec := range specs {
+		backend, n, ok := strings.Cut(strings.TrimSpace(spec), "=")
+		limit, err := strconv.Atoi(strings.TrimSpace(n))
+		if !ok || backend == "" || err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid backend limit %q: want <backend>=<positive number>", spec)
+		}
+		limits[strings.TrimSpace(backend)] = limit
+	}
+	return limits, nil
+}
@@ -0,0 +1,213 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Runner runs a job's attempt and returns its output. The context is cancelled when the pool is shut
+// down before the attempt finished; the job then goes back to the queue.
+type Runner func(ctx context.Context, job Job) (string, error)
+
+// PoolOptions configures a Pool.
+type PoolOptions struct {
+	// Workers is the number of jobs run at once (1 if not positive).
+	Workers int
+	// Limits caps the jobs run at once per backend, e.g. to stay under Bedrock's throttling; backends
+	// without a limit can use every worker.
+	Limits map[string]int
+	// Backoff delays the first retry of a job (10s if not positive), and doubles for each later one up
+	// to MaxBackoff (10m if not positive).
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// PollInterval is how often idle workers look for retries coming due (1s if not positive). Jobs
+	// enqueued through Notify are picked up right away.
+	PollInterval time.Duration
+}
+
+func (o PoolOptions) withDefaults() PoolOptions {
+	if o.Workers <= 0 {
+		o.Workers = 1
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = 10 * time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 10 * time.Minute
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	return o
+}
+
+// Pool works off a Queue with a fixed number of workers.
+type Pool struct {
+	queue Queue
+	run   Runner
+	opts  PoolOptions
+	now   func() time.Time
+
+	// mu serializes claims, so that per-backend limits hold
+	mu      sync.Mutex
+	running map[string]int
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+	// ctx is passed to runners; it is cancelled when Shutdown times out
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewPool returns a pool running the jobs of queue with run. Call Start to start its workers.
+func NewPool(queue Queue, run Runner, opts PoolOptions) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Pool{
+		queue:   queue,
+		run:     run,
+		opts:    opts.withDefaults(),
+		now:     time.Now,
+		running: make(map[string]int),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start requeues the jobs a previous process left running and starts the workers.
+func (p *Pool) Start(ctx context.Context) error {
+	n, err := p.queue.Recover(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Warn("JOBS: Requeued jobs left running", "jobs", n)
+	}
+	for range p.opts.Workers {
+		p.wg.Go(p.work)
+	}
+	slog.Info("JOBS: Started workers", "workers", p.opts.Workers, "limits", p.opts.Limits)
+	return nil
+}
+
+// Notify wakes an idle worker, e.g. after enqueueing a job.
+func (p *Pool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Shutdown stops claiming jobs and waits for the running ones to finish. If ctx ends first, they are
+// cancelled and put back in the queue, and Shutdown returns ctx's error once the workers have stopped.
+// Calling it again is a no-op once the workers have stopped.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work() {
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		job, ok, err := p.claim()
+		if err != nil {
+			slog.Error("JOBS: Failed to claim a job", "error", err)
+		}
+		if ok {
+			// Another job may be waiting for a worker
+			p.Notify()
+			p.process(job)
+			continue
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-p.wake:
+		case <-time.After(p.opts.PollInterval):
+		}
+	}
+}
+
+// claim claims a job of a backend below its limit.
+func (p *Pool) claim() (Job, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var busy []string
+	for backend, limit := range p.opts.Limits {
+		if p.running[backend] >= limit {
+			busy = append(busy, backend)
+		}
+	}
+	job, ok, err := p.queue.Claim(p.ctx, p.now(), busy)
+	if ok {
+		p.running[job.Backend]++
+	}
+	return job, ok, err
+}
+
+func (p *Pool) process(job Job) {
+	slog.Info("JOBS: Running job", "job", job.ID, "backend", job.Backend, "attempt", job.Attempts, "max_attempts", job.MaxAttempts)
+	output, err := p.run(p.ctx, job)
+
+	p.mu.Lock()
+	p.running[job.Backend]--
+	p.mu.Unlock()
+	p.Notify()
+
+	// The queue is updated even when the pool's context was cancelled
+	ctx := context.WithoutCancel(p.ctx)
+	switch {
+	case err == nil:
+		if err = p.queue.Complete(ctx, job.ID, output); err == nil {
+			slog.Info("JOBS: Job succeeded", "job", job.ID)
+		}
+	case p.ctx.Err() != nil && errors.Is(err, context.Canceled):
+		slog.Warn("JOBS: Job interrupted by shutdown; requeued", "job", job.ID)
+		err = p.queue.Release(ctx, job.ID)
+	case IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		slog.Error("JOBS: Job failed; dead-lettered", "job", job.ID, "attempt", job.Attempts, "error", err)
+		err = p.queue.Fail(ctx, job.ID, err, time.Time{})
+	default:
+		retryAt := p.now().Add(p.backoff(job.Attempts))
+		slog.Warn("JOBS: Job failed; retrying", "job", job.ID, "attempt", job.Attempts, "retry_at", retryAt, "error", err)
+		err = p.queue.Fail(ctx, job.ID, err, retryAt)
+	}
+	if err != nil {
+		slog.Error("JOBS: Failed to update job", "job", job.ID, "error", err)
+	}
+}
+
+// backoff returns the delay before retrying after the given attempt.
+func (p *Pool) backoff(attempt int) time.Duration {
+	d := p.opts.Backoff
+	for i := 1; i < attempt && d < p.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.opts.MaxBackoff)
+}
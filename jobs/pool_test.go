@@ -0,0 +1,162 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pantryagent/jobs"
+	"pantryagent/tools/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newQueue(t *testing.T) jobs.Queue {
+	t.Helper()
+	store, err := sqlite.Open(context.Background(), ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store.Jobs()
+}
+
+func enqueue(t *testing.T, q jobs.Queue, backend string, maxAttempts int) jobs.Job {
+	t.Helper()
+	job, _, err := q.Enqueue(context.Background(), jobs.Request{Backend: backend, Task: "plan", MaxAttempts: maxAttempts})
+	require.NoError(t, err)
+	return job
+}
+
+// startPool starts a pool that is shut down when the test ends.
+func startPool(t *testing.T, q jobs.Queue, run jobs.Runner, opts jobs.PoolOptions) *jobs.Pool {
+	t.Helper()
+	opts.PollInterval = 10 * time.Millisecond
+	pool := jobs.NewPool(q, run, opts)
+	require.NoError(t, pool.Start(context.Background()))
+	t.Cleanup(func() { _ = pool.Shutdown(context.Background()) })
+	return pool
+}
+
+func waitForStatus(t *testing.T, q jobs.Queue, id string, status jobs.Status) jobs.Job {
+	t.Helper()
+	var job jobs.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = q.Get(context.Background(), id)
+		require.NoError(t, err)
+		return job.Status == status
+	}, 5*time.Second, 10*time.Millisecond, "job %s never got %s", id, status)
+	return job
+}
+
+func TestPool(t *testing.T) {
+	q := newQueue(t)
+	var calls atomic.Int32
+	pool := startPool(t, q, func(ctx context.Context, job jobs.Job) (string, error) {
+		switch job.Backend {
+		case "flaky":
+			if job.Attempts < 2 {
+				return "", errors.New("throttled")
+			}
+		case "broken":
+			return "", errors.New("model unavailable")
+		case "unknown":
+			calls.Add(1)
+			return "", jobs.Permanent(errors.New("unknown backend"))
+		}
+		return "plan for " + job.ID, nil
+	}, jobs.PoolOptions{Workers: 2, Backoff: time.Millisecond})
+
+	ok := enqueue(t, q, "mock", 0)
+	flaky := enqueue(t, q, "flaky", 0)
+	broken := enqueue(t, q, "broken", 2)
+	unknown := enqueue(t, q, "unknown", 0)
+	pool.Notify()
+
+	job := waitForStatus(t, q, ok.ID, jobs.Succeeded)
+	assert.Equal(t, "plan for "+ok.ID, job.Output)
+	assert.Equal(t, 1, job.Attempts)
+
+	job = waitForStatus(t, q, flaky.ID, jobs.Succeeded)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Empty(t, job.Error)
+
+	job = waitForStatus(t, q, broken.ID, jobs.Dead)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Equal(t, "model unavailable", job.Error)
+
+	job = waitForStatus(t, q, unknown.ID, jobs.Dead)
+	assert.Equal(t, 1, job.Attempts, "permanent failures are not retried")
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestPool_Limits(t *testing.T) {
+	q := newQueue(t)
+	var (
+		mu                  sync.Mutex
+		running, maxRunning int
+	)
+	pool := startPool(t, q, func(ctx context.Context, job jobs.Job) (string, error) {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return "plan", nil
+	}, jobs.PoolOptions{Workers: 4, Limits: map[string]int{"bedrock": 1}})
+
+	var ids []string
+	for range 4 {
+		ids = append(ids, enqueue(t, q, "bedrock", 0).ID)
+	}
+	pool.Notify()
+	for _, id := range ids {
+		waitForStatus(t, q, id, jobs.Succeeded)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, maxRunning)
+}
+
+func TestPool_Shutdown(t *testing.T) {
+	q := newQueue(t)
+	started := make(chan struct{})
+	pool := jobs.NewPool(q, func(ctx context.Context, job jobs.Job) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	}, jobs.PoolOptions{PollInterval: 10 * time.Millisecond})
+	require.NoError(t, pool.Start(context.Background()))
+
+	job := enqueue(t, q, "mock", 0)
+	pool.Notify()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pool.Shutdown(ctx), context.DeadlineExceeded)
+
+	job, err := q.Get(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Pending, job.Status, "interrupted jobs go back to the queue")
+	assert.Equal(t, 0, job.Attempts)
+
+	assert.NoError(t, pool.Shutdown(context.Background()), "shutting down twice")
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := jobs.ParseLimits([]string{"bedrock=2", " ollama = 1 "})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"bedrock": 2, "ollama": 1}, limits)
+
+	for _, spec := range []string{"bedrock", "=2", "bedrock=0", "bedrock=x"} {
+		_, err := jobs.ParseLimits([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
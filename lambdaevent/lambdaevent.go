@@ -0,0 +1,242 @@
+// Package lambdaevent adapts the Lambda event sources of the planner to a plan function: direct
+// invocations with a bare Params payload, HTTP requests from API Gateway (REST and HTTP APIs) and
+// function URLs, SQS batches with partial-batch failure reporting, and EventBridge events such as
+// schedules. Handler tells the sources apart by the shape of the payload, so one function can serve
+// them all. See cmd/coordinator/bedrock/lambda.
+package lambdaevent
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// PlanFunc runs a plan for task and returns the coordinator's output.
+type PlanFunc func(ctx context.Context, task string) (string, error)
+
+// Params is the payload of direct invocations, the body of HTTP requests and SQS messages, and the
+// optional detail of EventBridge events.
+type Params struct {
+	Task string `json:"task"`
+}
+
+// Results is the result of direct invocations and EventBridge events, and the body of successful HTTP
+// responses.
+type Results struct {
+	Output any `json:"output"`
+}
+
+// Options configures a Handler.
+type Options struct {
+	// DefaultTask plans requests and messages naming no task.
+	DefaultTask string
+	// ScheduleTask plans EventBridge events naming no task, e.g. a weekly schedule (DefaultTask if
+	// empty).
+	ScheduleTask string
+}
+
+// Handler dispatches Lambda invocations to the adapter of their event source.
+type Handler struct {
+	plan PlanFunc
+	opts Options
+}
+
+var _ lambda.Handler = (*Handler)(nil)
+
+// NewHandler returns a handler planning with plan.
+func NewHandler(plan PlanFunc, opts Options) *Handler {
+	if opts.ScheduleTask == "" {
+		opts.ScheduleTask = opts.DefaultTask
+	}
+	return &Handler{plan: plan, opts: opts}
+}
+
+// probe holds the fields telling the event sources apart.
+type probe struct {
+	Records []struct {
+		EventSource string `json:"eventSource"`
+	} `json:"Records"`
+	HTTPMethod     string `json:"httpMethod"`
+	RequestContext struct {
+		HTTP struct {
+			Method string `json:"method"`
+		} `json:"http"`
+	} `json:"requestContext"`
+	DetailType string `json:"detail-type"`
+}
+
+// Invoke implements lambda.Handler.
+func (h *Handler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var p probe
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	var (
+		res any
+		err error
+	)
+	switch {
+	case len(p.Records) > 0 && p.Records[0].EventSource == "aws:sqs":
+		res, err = decodeAndHandle(ctx, payload, h.HandleSQS)
+	case p.RequestContext.HTTP.Method != "":
+		// Payload format 2.0: HTTP APIs and function URLs
+		res, err = decodeAndHandle(ctx, payload, h.HandleHTTP)
+	case p.HTTPMethod != "":
+		// Payload format 1.0: REST APIs
+		res, err = decodeAndHandle(ctx, payload, h.HandleProxy)
+	case p.DetailType != "":
+		res, err = decodeAndHandle(ctx, payload, h.HandleEventBridge)
+	default:
+		res, err = decodeAndHandle(ctx, payload, h.HandleDirect)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+func decodeAndHandle[E, R any](ctx context.Context, payload []byte, handle func(context.Context, E) (R, error)) (R, error) {
+	var event E
+	if err := json.Unmarshal(payload, &event); err != nil {
+		var zero R
+		return zero, fmt.Errorf("invalid %T payload: %w", event, err)
+	}
+	return handle(ctx, event)
+}
+
+// HandleDirect plans a direct invocation.
+func (h *Handler) HandleDirect(ctx context.Context, params Params) (Results, error) {
+	output, err := h.run(ctx, "direct", params.Task, h.opts.DefaultTask)
+	if err != nil {
+		return Results{}, err
+	}
+	return Results{Output: output}, nil
+}
+
+// HandleEventBridge plans an EventBridge event: the task of its detail, if any, or the schedule task.
+// A failure is returned, so that EventBridge retries the invocation.
+func (h *Handler) HandleEventBridge(ctx context.Context, event events.EventBridgeEvent) (Results, error) {
+	var params Params
+	if len(event.Detail) > 0 {
+		// Schedules send an empty detail; other events may carry anything
+		_ = json.Unmarshal(event.Detail, &params)
+	}
+	slog.Info("LAMBDA: EventBridge event received", "id", event.ID, "source", event.Source, "detail_type", event.DetailType)
+	output, err := h.run(ctx, "eventbridge", params.Task, h.opts.ScheduleTask)
+	if err != nil {
+		return Results{}, err
+	}
+	return Results{Output: output}, nil
+}
+
+// HandleSQS plans each message of an SQS batch in turn and reports the messages that failed, so that
+// only those are redelivered (the event source mapping needs ReportBatchItemFailures). Messages left
+// when ctx ends are reported as failed without being planned.
+func (h *Handler) HandleSQS(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	res := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}
+	for _, msg := range event.Records {
+		if err := h.handleMessage(ctx, msg); err != nil {
+			slog.Error("LAMBDA: SQS message failed", "message_id", msg.MessageId, "error", err)
+			res.BatchItemFailures = append(res.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: msg.MessageId})
+		}
+	}
+	slog.Info("LAMBDA: SQS batch processed", "messages", len(event.Records), "failures", len(res.BatchItemFailures))
+	return res, nil
+}
+
+func (h *Handler) handleMessage(ctx context.Context, msg events.SQSMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var params Params
+	if err := json.Unmarshal([]byte(msg.Body), &params); err != nil {
+		return fmt.Errorf("invalid message body: %w", err)
+	}
+	output, err := h.run(ctx, "sqs", params.Task, h.opts.DefaultTask)
+	if err != nil {
+		return err
+	}
+	slog.Info("LAMBDA: SQS message planned", "message_id", msg.MessageId, "output", output)
+	return nil
+}
+
+// HandleHTTP plans an HTTP request from an HTTP API or a function URL (payload format 2.0).
+func (h *Handler) HandleHTTP(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	status, headers, body := h.serveHTTP(ctx, req.RequestContext.HTTP.Method, req.Body, req.IsBase64Encoded)
+	return events.APIGatewayV2HTTPResponse{StatusCode: status, Headers: headers, Body: body}, nil
+}
+
+// HandleProxy plans an HTTP request from a REST API (payload format 1.0).
+func (h *Handler) HandleProxy(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	status, headers, body := h.serveHTTP(ctx, req.HTTPMethod, req.Body, req.IsBase64Encoded)
+	return events.APIGatewayProxyResponse{StatusCode: status, Headers: headers, Body: body}, nil
+}
+
+// serveHTTP plans the Params of a POST body (the default task for an empty one) and responds with
+// Results, or with {"error": "..."} and a status telling the client's mistakes (4xx) from the
+// planner's failures (5xx). Failures are responses rather than invocation errors, which API Gateway
+// would turn into a bare 502.
+func (h *Handler) serveHTTP(ctx context.Context, method, body string, base64Encoded bool) (int, map[string]string, string) {
+	if method != http.MethodPost {
+		status, headers, body := jsonResponse(http.StatusMethodNotAllowed, errorBody(fmt.Errorf("method %s not allowed", method)))
+		headers["Allow"] = http.MethodPost
+		return status, headers, body
+	}
+	if base64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return jsonResponse(http.StatusBadRequest, errorBody(fmt.Errorf("invalid base64 body: %w", err)))
+		}
+		body = string(decoded)
+	}
+	var params Params
+	if strings.TrimSpace(body) != "" {
+		if err := json.Unmarshal([]byte(body), &params); err != nil {
+			return jsonResponse(http.StatusBadRequest, errorBody(fmt.Errorf("invalid request body: %w", err)))
+		}
+	}
+
+	output, err := h.run(ctx, "http", params.Task, h.opts.DefaultTask)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return jsonResponse(http.StatusGatewayTimeout, errorBody(err))
+	case err != nil:
+		return jsonResponse(http.StatusInternalServerError, errorBody(err))
+	}
+	return jsonResponse(http.StatusOK, Results{Output: output})
+}
+
+// run plans task, or defaultTask if it is empty.
+func (h *Handler) run(ctx context.Context, source, task, defaultTask string) (string, error) {
+	if task == "" {
+		task = defaultTask
+	}
+	slog.Info("LAMBDA: Planning", "source", source, "task", task)
+	output, err := h.plan(ctx, task)
+	if err != nil {
+		slog.Error("LAMBDA: Planning failed", "source", source, "error", err)
+		return "", err
+	}
+	return output, nil
+}
+
+func errorBody(err error) map[string]string {
+	return map[string]string{"error": err.Error()}
+}
+
+func jsonResponse(status int, v any) (int, map[string]string, string) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		status, body = http.StatusInternalServerError, []byte(`{"error": "failed to encode response"}`)
+	}
+	return status, map[string]string{"Content-Type": "application/json"}, string(body)
+}
@@ -0,0 +1,142 @@
+package lambdaevent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestHandler returns a handler whose plans echo their task, failing for "fail" and timing out for
+// "slow"; tasks records the tasks planned.
+func newTestHandler() (*Handler, *[]string) {
+	var tasks []string
+	plan := func(ctx context.Context, task string) (string, error) {
+		tasks = append(tasks, task)
+		switch task {
+		case "fail":
+			return "", errors.New("model unavailable")
+		case "slow":
+			return "", fmt.Errorf("converse: %w", context.DeadlineExceeded)
+		}
+		return "plan for " + task, nil
+	}
+	return NewHandler(plan, Options{DefaultTask: "default", ScheduleTask: "weekly"}), &tasks
+}
+
+func invoke[R any](t *testing.T, h *Handler, payload string) R {
+	t.Helper()
+	out, err := h.Invoke(context.Background(), []byte(payload))
+	require.NoError(t, err)
+	var res R
+	require.NoError(t, json.Unmarshal(out, &res), string(out))
+	return res
+}
+
+func TestHandler_Direct(t *testing.T) {
+	h, tasks := newTestHandler()
+
+	res := invoke[Results](t, h, `{"task": "dinner"}`)
+	assert.Equal(t, "plan for dinner", res.Output)
+	invoke[Results](t, h, `{}`)
+	assert.Equal(t, []string{"dinner", "default"}, *tasks)
+
+	_, err := h.Invoke(context.Background(), []byte(`{"task": "fail"}`))
+	assert.ErrorContains(t, err, "model unavailable")
+	_, err = h.Invoke(context.Background(), []byte(`not json`))
+	assert.ErrorContains(t, err, "invalid payload")
+}
+
+func TestHandler_HTTP(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		base64 bool
+		status int
+		want   string
+	}{
+		{"plan", http.MethodPost, `{"task": "dinner"}`, false, http.StatusOK, `{"output": "plan for dinner"}`},
+		{"empty body", http.MethodPost, ``, false, http.StatusOK, `{"output": "plan for default"}`},
+		{"base64 body", http.MethodPost, `eyJ0YXNrIjogImx1bmNoIn0=`, true, http.StatusOK, `{"output": "plan for lunch"}`},
+		{"wrong method", http.MethodGet, ``, false, http.StatusMethodNotAllowed, `{"error": "method GET not allowed"}`},
+		{"invalid body", http.MethodPost, `{"task": 1}`, false, http.StatusBadRequest, ``},
+		{"invalid base64", http.MethodPost, `!!!`, true, http.StatusBadRequest, ``},
+		{"failure", http.MethodPost, `{"task": "fail"}`, false, http.StatusInternalServerError, `{"error": "model unavailable"}`},
+		{"timeout", http.MethodPost, `{"task": "slow"}`, false, http.StatusGatewayTimeout, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler()
+
+			// Function URLs and HTTP APIs (payload format 2.0)
+			v2, err := json.Marshal(events.APIGatewayV2HTTPRequest{
+				Version:         "2.0",
+				RawPath:         "/",
+				Body:            tt.body,
+				IsBase64Encoded: tt.base64,
+				RequestContext:  events.APIGatewayV2HTTPRequestContext{HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: tt.method}},
+			})
+			require.NoError(t, err)
+			res := invoke[events.APIGatewayV2HTTPResponse](t, h, string(v2))
+			assert.Equal(t, tt.status, res.StatusCode)
+			assert.Equal(t, "application/json", res.Headers["Content-Type"])
+			if tt.want != "" {
+				assert.JSONEq(t, tt.want, res.Body)
+			} else {
+				assert.Contains(t, res.Body, `"error"`)
+			}
+
+			// REST APIs (payload format 1.0)
+			v1, err := json.Marshal(events.APIGatewayProxyRequest{HTTPMethod: tt.method, Path: "/", Body: tt.body, IsBase64Encoded: tt.base64})
+			require.NoError(t, err)
+			proxy := invoke[events.APIGatewayProxyResponse](t, h, string(v1))
+			assert.Equal(t, tt.status, proxy.StatusCode)
+			assert.Equal(t, res.Body, proxy.Body)
+		})
+	}
+}
+
+func TestHandler_SQS(t *testing.T) {
+	h, tasks := newTestHandler()
+
+	res := invoke[events.SQSEventResponse](t, h, `{"Records": [
+		{"messageId": "1", "eventSource": "aws:sqs", "body": "{\"task\": \"dinner\"}"},
+		{"messageId": "2", "eventSource": "aws:sqs", "body": "{\"task\": \"fail\"}"},
+		{"messageId": "3", "eventSource": "aws:sqs", "body": "not json"},
+		{"messageId": "4", "eventSource": "aws:sqs", "body": "{}"}
+	]}`)
+	assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "2"}, {ItemIdentifier: "3"}}, res.BatchItemFailures)
+	assert.Equal(t, []string{"dinner", "fail", "default"}, *tasks)
+
+	// Messages left when the invocation runs out of time are redelivered
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sqs, err := h.HandleSQS(ctx, events.SQSEvent{Records: []events.SQSMessage{{MessageId: "5", Body: `{}`}}})
+	require.NoError(t, err)
+	assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "5"}}, sqs.BatchItemFailures)
+	assert.Len(t, *tasks, 3)
+}
+
+func TestHandler_EventBridge(t *testing.T) {
+	h, tasks := newTestHandler()
+
+	res := invoke[Results](t, h, `{"id": "1", "detail-type": "Scheduled Event", "source": "aws.scheduler", "detail": {}}`)
+	assert.Equal(t, "plan for weekly", res.Output)
+	invoke[Results](t, h, `{"id": "2", "detail-type": "Plan Requested", "source": "pantry", "detail": {"task": "brunch"}}`)
+	assert.Equal(t, []string{"weekly", "brunch"}, *tasks)
+
+	_, err := h.Invoke(context.Background(), []byte(`{"detail-type": "Plan Requested", "detail": {"task": "fail"}}`))
+	assert.ErrorContains(t, err, "model unavailable", "failures are returned so that EventBridge retries")
+
+	// Without a schedule task, schedules plan the default task
+	h = NewHandler(func(ctx context.Context, task string) (string, error) { return task, nil }, Options{DefaultTask: "default"})
+	res = invoke[Results](t, h, `{"detail-type": "Scheduled Event"}`)
+	assert.Equal(t, "default", res.Output)
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -25,12 +26,14 @@ func NewCoordinationLogFilePath(model string) string {
 
 // IterationLog represents a single iteration in the coordination process
 type IterationLog struct {
-	Iteration int           `json:"iteration"`
-	Timestamp time.Time     `json:"timestamp"`
-	LLMInput  string        `json:"llm_input,omitempty"`
-	LLMOutput any           `json:"llm_output"`
-	ToolCalls []ToolCallLog `json:"tool_calls,omitempty"`
-	Error     string        `json:"error,omitempty"`
+	Iteration int               `json:"iteration"`
+	Timestamp time.Time         `json:"timestamp"`
+	Model     string            `json:"model,omitempty"`   // model that produced this turn
+	Prompts   map[string]string `json:"prompts,omitempty"` // prompt template versions rendered so far, by name
+	LLMInput  string            `json:"llm_input,omitempty"`
+	LLMOutput any               `json:"llm_output"`
+	ToolCalls []ToolCallLog     `json:"tool_calls,omitempty"`
+	Error     string            `json:"error,omitempty"`
 }
 
 // ToolCallLog represents a tool execution within a step
@@ -116,3 +119,30 @@ func (l *StdoutCoordinationLogger) LogIteration(iteration IterationLog) error {
 	fmt.Fprintln(os.Stdout, string(data))
 	return nil
 }
+
+// MemoryCoordinationLogger keeps iterations in memory, e.g. for a server to report a run's log while it
+// is in progress. It is safe for concurrent use.
+type MemoryCoordinationLogger struct {
+	mu         sync.Mutex
+	iterations []IterationLog
+}
+
+// NewMemoryCoordinationLogger creates a new in-memory coordination logger
+func NewMemoryCoordinationLogger() *MemoryCoordinationLogger {
+	return &MemoryCoordinationLogger{}
+}
+
+// LogIteration appends the iteration to the log
+func (l *MemoryCoordinationLogger) LogIteration(iteration IterationLog) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.iterations = append(l.iterations, iteration)
+	return nil
+}
+
+// Iterations returns a copy of the iterations logged so far
+func (l *MemoryCoordinationLogger) Iterations() []IterationLog {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]IterationLog{}, l.iterations...)
+}
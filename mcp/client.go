@@ -0,0 +1,275 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"pantryagent/tools"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// NamespaceSeparator joins a server name and a tool name into the registered tool name, e.g. "grocery__price_get".
+const NamespaceSeparator = "__"
+
+var (
+	validServerName = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+	invalidNameChar = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+)
+
+// ServerSpec describes an MCP server whose tools the agent can use.
+type ServerSpec struct {
+	// Name namespaces the server's tools in the registry.
+	Name string
+	// Command launches a stdio server as a subprocess; URL connects to a streamable HTTP server instead.
+	Command []string
+	URL     string
+}
+
+// ParseServerSpec parses "name=command [args...]" for stdio servers and "name=http(s)://..." for HTTP servers.
+// Arguments are split on whitespace, without shell quoting.
+func ParseServerSpec(s string) (ServerSpec, error) {
+	name, target, ok := strings.Cut(strings.TrimSpace(s), "=")
+	if !ok || strings.TrimSpace(target) == "" {
+		return ServerSpec{}, fmt.Errorf("invalid MCP server %q: want name=command or name=url", s)
+	}
+	name = strings.TrimSpace(name)
+	if !validServerName.MatchString(name) {
+		return ServerSpec{}, fmt.Errorf("invalid MCP server name %q: use letters, digits and '-'", name)
+	}
+
+	target = strings.TrimSpace(target)
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return ServerSpec{Name: name, URL: target}, nil
+	}
+	return ServerSpec{Name: name, Command: strings.Fields(target)}, nil
+}
+
+// Client is a connection to an MCP server.
+type Client struct {
+	spec    ServerSpec
+	session *sdk.ClientSession
+}
+
+// Connect starts or connects to the server described by spec. The caller must Close the client,
+// which also stops a stdio server's subprocess.
+func Connect(ctx context.Context, spec ServerSpec) (*Client, error) {
+	var transport sdk.Transport
+	if spec.URL != "" {
+		transport = sdk.NewStreamableClientTransport(spec.URL, nil)
+	} else {
+		if len(spec.Command) == 0 {
+			return nil, fmt.Errorf("MCP server %q: no command or URL", spec.Name)
+		}
+		cmd := exec.Command(spec.Command[0], spec.Command[1:]...)
+		cmd.Stderr = os.Stderr
+		transport = sdk.NewCommandTransport(cmd)
+	}
+
+	session, err := sdk.NewClient(&sdk.Implementation{Name: ServerName, Version: "client"}, nil).Connect(ctx, transport)
+	if err != nil {
+		return nil, fmt.Errorf("connect to MCP server %q: %w", spec.Name, err)
+	}
+	return &Client{spec: spec, session: session}, nil
+}
+
+// Tools lists the server's tools, named "<server>__<tool>" (see toolName).
+func (c *Client) Tools(ctx context.Context) ([]tools.Tool, error) {
+	var remote []tools.Tool
+	for tool, err := range c.session.Tools(ctx, nil) {
+		if err != nil {
+			return nil, fmt.Errorf("list tools of MCP server %q: %w", c.spec.Name, err)
+		}
+		name := toolName(c.spec.Name, tool.Name)
+		if len(name) < len(c.spec.Name+NamespaceSeparator+tool.Name) {
+			slog.Warn("MCP_CLIENT: Tool name too long; shortened", "server", c.spec.Name, "tool", tool.Name, "name", name)
+		}
+		remote = append(remote, &remoteTool{
+			name:    name,
+			tool:    tool,
+			session: c.session,
+		})
+	}
+	return remote, nil
+}
+
+// toolName returns the registered name of a server's tool, "<server>__<tool>" with the characters model
+// APIs reject replaced by '_'. Names longer than tools.MaxToolNameLength are cut short and end with a
+// hash of the full name instead, so they stay unique and the same from run to run.
+func toolName(server, tool string) string {
+	name := server + NamespaceSeparator + invalidNameChar.ReplaceAllString(tool, "_")
+	if len(name) <= tools.MaxToolNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(server + NamespaceSeparator + tool))
+	suffix := "_" + hex.EncodeToString(sum[:4])
+	return name[:tools.MaxToolNameLength-len(suffix)] + suffix
+}
+
+// Close ends the session.
+func (c *Client) Close() error {
+	return c.session.Close()
+}
+
+// AddServers connects to the servers described by specs (see ParseServerSpec) and adds their tools to registry.
+// The returned function closes all connections; it is safe to call when an error is returned.
+func AddServers(ctx context.Context, registry *tools.Registry, specs []string) (func() error, error) {
+	var clients []*Client
+	closeAll := func() error {
+		var errs []error
+		for _, c := range clients {
+			errs = append(errs, c.Close())
+		}
+		return errors.Join(errs...)
+	}
+
+	for _, s := range specs {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		spec, err := ParseServerSpec(s)
+		if err != nil {
+			return closeAll, err
+		}
+		client, err := Connect(ctx, spec)
+		if err != nil {
+			return closeAll, err
+		}
+		clients = append(clients, client)
+
+		remote, err := client.Tools(ctx)
+		if err != nil {
+			return closeAll, err
+		}
+		if err := register(registry, spec.Name, remote); err != nil {
+			return closeAll, fmt.Errorf("MCP server %q: %w", spec.Name, err)
+		}
+	}
+	return closeAll, nil
+}
+
+// register adds the tools of server to registry, tagged "mcp" and "mcp:<server>". Nothing is registered
+// if any tool is rejected.
+func register(registry *tools.Registry, server string, remote []tools.Tool) error {
+	for i, tool := range remote {
+		meta := tools.Metadata{Tags: []string{"mcp", "mcp:" + server}}
+		if rt, ok := tool.(*remoteTool); ok && rt.tool.Annotations != nil {
+			meta.ReadOnly = rt.tool.Annotations.ReadOnlyHint
+		}
+		if err := registry.Register(tool, meta); err != nil {
+			for _, added := range remote[:i] {
+				registry.Unregister(added.Name())
+			}
+			return err
+		}
+	}
+	for _, tool := range remote {
+		slog.Info("MCP_CLIENT: Registered tool", "server", server, "name", tool.Name())
+	}
+	return nil
+}
+
+// remoteTool is a tool served by an MCP server.
+type remoteTool struct {
+	name    string
+	tool    *sdk.Tool
+	session *sdk.ClientSession
+}
+
+func (t *remoteTool) Name() string { return t.name }
+
+func (t *remoteTool) Title() string {
+	switch {
+	case t.tool.Title != "":
+		return t.tool.Title
+	case t.tool.Annotations != nil && t.tool.Annotations.Title != "":
+		return t.tool.Annotations.Title
+	}
+	return t.tool.Name
+}
+
+func (t *remoteTool) Description() string { return t.tool.Description }
+
+func (t *remoteTool) InputSchema() *jsonschema.Schema { return t.tool.InputSchema }
+
+func (t *remoteTool) OutputSchema() *jsonschema.Schema { return t.tool.OutputSchema }
+
+// Run calls the tool on its server. Failures, whether reported by the tool or caused by the connection,
+// are returned as *tools.ToolError so the model can react to them.
+func (t *remoteTool) Run(ctx context.Context, input map[string]any) (map[string]any, error) {
+	res, err := t.session.CallTool(ctx, &sdk.CallToolParams{Name: t.tool.Name, Arguments: input})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &tools.ToolError{
+			Tool:    t.name,
+			Code:    tools.ErrCodeToolFailed,
+			Message: err.Error(),
+			Hint:    "The tool's server could not complete the call; try again later or continue without this tool.",
+			Err:     err,
+		}
+	}
+
+	text := resultText(res)
+	if res.IsError {
+		return nil, t.resultError(text)
+	}
+
+	// Prefer structured content, then a JSON object in the text, then the text as is
+	if output, ok := res.StructuredContent.(map[string]any); ok {
+		return output, nil
+	}
+	var output map[string]any
+	if err := json.Unmarshal([]byte(text), &output); err == nil && output != nil {
+		return output, nil
+	}
+	if t.tool.OutputSchema != nil {
+		// Wrapped text would not match the schema either
+		return nil, &tools.ToolError{
+			Tool:    t.name,
+			Code:    tools.ErrCodeInvalidOutput,
+			Message: "the tool returned text instead of the structured output its schema describes: " + text,
+			Hint:    "The tool's server misbehaved; continue without this tool.",
+		}
+	}
+	return map[string]any{"content": text}, nil
+}
+
+// resultError converts an error result to a ToolError, keeping the code, message and hint of
+// servers that report tools.ToolError payloads (such as NewServer).
+func (t *remoteTool) resultError(text string) *tools.ToolError {
+	var payload struct {
+		Code    string `json:"error"`
+		Message string `json:"message"`
+		Hint    string `json:"hint"`
+	}
+	if err := json.Unmarshal([]byte(text), &payload); err == nil && payload.Code != "" {
+		return &tools.ToolError{Tool: t.name, Code: payload.Code, Message: payload.Message, Hint: payload.Hint}
+	}
+	if text == "" {
+		text = "the tool reported an error without details"
+	}
+	return &tools.ToolError{Tool: t.name, Code: tools.ErrCodeToolFailed, Message: text}
+}
+
+// resultText joins the text content of res.
+func resultText(res *sdk.CallToolResult) string {
+	var parts []string
+	for _, c := range res.Content {
+		if tc, ok := c.(*sdk.TextContent); ok {
+			parts = append(parts, tc.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,224 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"pantryagent/tools"
+	"pantryagent/tools/storage"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// longToolName is a tool name too long to register once namespaced.
+const longToolName = "check_the_weekly_grocery_flyer_for_discounts_on_the_pantry_items"
+
+// testServerEnv makes the test binary run testServer over stdio instead of the tests, see TestMain.
+const testServerEnv = "PANTRYAGENT_MCP_TEST_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testServerEnv) != "" {
+		if err := testServer().Run(context.Background(), sdk.NewStdioTransport()); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testServer serves pantry_get and recipe_get, plus two plain text tools (one with a long name), a text
+// tool with an output schema and two failing ones, with and without a ToolError payload.
+func testServer() *sdk.Server {
+	pantry := storage.NewTestPantryState([]byte(`{"ingredients": [{"name": "egg", "qty": 12, "unit": "count"}]}`))
+	recipes := storage.NewTestRecipeState([]byte(`[]`))
+	registry, err := tools.NewRegistry(pantry, recipes)
+	if err != nil {
+		panic(err)
+	}
+
+	server := NewServer(registry, "test")
+	server.AddTool(&sdk.Tool{Name: "price.get", Description: "Returns a price as text", InputSchema: &jsonschema.Schema{Type: "object"}},
+		func(context.Context, *sdk.ServerSession, *sdk.CallToolParamsFor[map[string]any]) (*sdk.CallToolResult, error) {
+			return &sdk.CallToolResult{Content: []sdk.Content{&sdk.TextContent{Text: "eggs cost 3.50"}}}, nil
+		})
+	server.AddTool(&sdk.Tool{Name: longToolName, Description: "Returns the flyer as text", InputSchema: &jsonschema.Schema{Type: "object"}},
+		func(context.Context, *sdk.ServerSession, *sdk.CallToolParamsFor[map[string]any]) (*sdk.CallToolResult, error) {
+			return &sdk.CallToolResult{Content: []sdk.Content{&sdk.TextContent{Text: "eggs are on sale"}}}, nil
+		})
+	server.AddTool(&sdk.Tool{
+		Name: "stock", Description: "Describes its output but returns text", InputSchema: &jsonschema.Schema{Type: "object"},
+		OutputSchema: &jsonschema.Schema{Type: "object", Required: []string{"qty"}, Properties: map[string]*jsonschema.Schema{"qty": {Type: "number"}}},
+	}, func(context.Context, *sdk.ServerSession, *sdk.CallToolParamsFor[map[string]any]) (*sdk.CallToolResult, error) {
+		return &sdk.CallToolResult{Content: []sdk.Content{&sdk.TextContent{Text: "12 eggs left"}}}, nil
+	})
+	server.AddTool(&sdk.Tool{Name: "broken", Description: "Always fails", InputSchema: &jsonschema.Schema{Type: "object"}},
+		func(context.Context, *sdk.ServerSession, *sdk.CallToolParamsFor[map[string]any]) (*sdk.CallToolResult, error) {
+			return &sdk.CallToolResult{Content: []sdk.Content{&sdk.TextContent{Text: "upstream unavailable"}}, IsError: true}, nil
+		})
+	server.AddTool(&sdk.Tool{Name: "order", Description: "Always rejected", InputSchema: &jsonschema.Schema{Type: "object"}},
+		func(context.Context, *sdk.ServerSession, *sdk.CallToolParamsFor[map[string]any]) (*sdk.CallToolResult, error) {
+			toolErr := &tools.ToolError{Tool: "order", Code: tools.ErrCodeToolRejected, Message: "orders are disabled", Hint: "Do not order."}
+			return &sdk.CallToolResult{Content: []sdk.Content{textContent(toolErr.Payload())}, IsError: true}, nil
+		})
+	return server
+}
+
+// stdioSpec launches the test binary as a stdio MCP server.
+func stdioSpec(t *testing.T) string {
+	t.Helper()
+	t.Setenv(testServerEnv, "1")
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	return "shop=" + exe
+}
+
+func TestParseServerSpec(t *testing.T) {
+	spec, err := ParseServerSpec("grocery=npx -y grocery-mcp")
+	require.NoError(t, err)
+	assert.Equal(t, ServerSpec{Name: "grocery", Command: []string{"npx", "-y", "grocery-mcp"}}, spec)
+
+	spec, err = ParseServerSpec(" calendar = https://example.com/mcp ")
+	require.NoError(t, err)
+	assert.Equal(t, ServerSpec{Name: "calendar", URL: "https://example.com/mcp"}, spec)
+
+	for _, s := range []string{"grocery", "grocery=", "=npx", "my_shop=npx", "a b=npx"} {
+		_, err := ParseServerSpec(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestToolName(t *testing.T) {
+	assert.Equal(t, "shop__price_get", toolName("shop", "price.get"))
+
+	name := toolName("shop", longToolName)
+	assert.Len(t, name, tools.MaxToolNameLength)
+	assert.True(t, strings.HasPrefix(name, "shop__check_the_weekly_grocery_flyer"), name)
+	assert.Equal(t, name, toolName("shop", longToolName), "the same from run to run")
+	assert.NotEqual(t, name, toolName("shop", longToolName+"s"), "unique")
+}
+
+func TestAddServers_Stdio(t *testing.T) {
+	ctx := context.Background()
+	registry := testRegistry(t, storage.NewTestPantryState(nil))
+
+	closeAll, err := AddServers(ctx, registry, []string{stdioSpec(t)})
+	t.Cleanup(func() { assert.NoError(t, closeAll()) })
+	require.NoError(t, err)
+
+	// Local tools keep their names, remote ones are namespaced
+	for _, name := range []string{"pantry_get", "recipe_get", "shop__pantry_get", "shop__recipe_get", "shop__price_get", "shop__broken", "shop__order"} {
+		_, err := registry.GetTool(name)
+		assert.NoError(t, err, name)
+	}
+
+	tool, err := registry.GetTool("shop__pantry_get")
+	require.NoError(t, err)
+	assert.Equal(t, tools.NewPantryGet(nil).Title(), tool.Title())
+	assert.Equal(t, tools.NewPantryGet(nil).Description(), tool.Description())
+	require.NotNil(t, tool.InputSchema())
+	assert.Contains(t, tool.InputSchema().Properties, "current_day")
+	assert.NotNil(t, tool.OutputSchema())
+	meta, ok := registry.Metadata("shop__pantry_get")
+	require.True(t, ok)
+	assert.Equal(t, []string{"mcp", "mcp:shop"}, meta.Tags)
+
+	t.Run("structured output", func(t *testing.T) {
+		out, err := tool.Run(ctx, map[string]any{"current_day": 0})
+		require.NoError(t, err)
+		ingredients := out["pantry"].(map[string]any)["ingredients"].([]any)
+		assert.Equal(t, "egg", ingredients[0].(map[string]any)["name"])
+	})
+
+	t.Run("text output", func(t *testing.T) {
+		price, err := registry.GetTool("shop__price_get")
+		require.NoError(t, err)
+		assert.Equal(t, "price.get", price.Title())
+		out, err := price.Run(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"content": "eggs cost 3.50"}, out)
+	})
+
+	t.Run("text output with an output schema", func(t *testing.T) {
+		stock, err := registry.GetTool("shop__stock")
+		require.NoError(t, err)
+		_, err = stock.Run(ctx, nil)
+		toolErr, ok := tools.AsToolError(err)
+		require.True(t, ok, "expected a ToolError, got %v", err)
+		assert.Equal(t, tools.ErrCodeInvalidOutput, toolErr.Code)
+		assert.Contains(t, toolErr.Message, "12 eggs left")
+	})
+
+	t.Run("long name", func(t *testing.T) {
+		name := toolName("shop", longToolName)
+		flyer, err := registry.GetTool(name)
+		require.NoError(t, err)
+		out, err := flyer.Run(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"content": "eggs are on sale"}, out)
+	})
+
+	t.Run("tool error payload", func(t *testing.T) {
+		order, err := registry.GetTool("shop__order")
+		require.NoError(t, err)
+		_, err = order.Run(ctx, nil)
+		toolErr, ok := tools.AsToolError(err)
+		require.True(t, ok, "expected a ToolError, got %v", err)
+		assert.Equal(t, &tools.ToolError{Tool: "shop__order", Code: tools.ErrCodeToolRejected, Message: "orders are disabled", Hint: "Do not order."}, toolErr)
+	})
+
+	t.Run("plain error", func(t *testing.T) {
+		broken, err := registry.GetTool("shop__broken")
+		require.NoError(t, err)
+		_, err = broken.Run(ctx, nil)
+		toolErr, ok := tools.AsToolError(err)
+		require.True(t, ok, "expected a ToolError, got %v", err)
+		assert.Equal(t, tools.ErrCodeToolFailed, toolErr.Code)
+		assert.Equal(t, "upstream unavailable", toolErr.Message)
+	})
+}
+
+func TestAddServers_HTTP(t *testing.T) {
+	server := testServer()
+	httpServer := httptest.NewServer(sdk.NewStreamableHTTPHandler(func(*http.Request) *sdk.Server { return server }, nil))
+	defer httpServer.Close()
+
+	ctx := context.Background()
+	registry := testRegistry(t, storage.NewTestPantryState(nil))
+	closeAll, err := AddServers(ctx, registry, []string{"shop=" + httpServer.URL})
+	require.NoError(t, err)
+
+	tool, err := registry.GetTool("shop__recipe_get")
+	require.NoError(t, err)
+	_, err = tool.Run(ctx, map[string]any{})
+	assert.NoError(t, err)
+
+	// Calls after the connection is gone fail as tool errors
+	require.NoError(t, closeAll())
+	_, err = tool.Run(ctx, map[string]any{})
+	toolErr, ok := tools.AsToolError(err)
+	require.True(t, ok, "expected a ToolError, got %v", err)
+	assert.Equal(t, tools.ErrCodeToolFailed, toolErr.Code)
+}
+
+func TestAddServers_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	registry := testRegistry(t, storage.NewTestPantryState(nil))
+	closeAll, err := AddServers(ctx, registry, []string{"missing=/nonexistent/mcp-server"})
+	assert.ErrorContains(t, err, `connect to MCP server "missing"`)
+	assert.NoError(t, closeAll())
+
+	// A second connection to the same server collides on names and adds none of its tools
+	spec := stdioSpec(t)
+	closeAll, err = AddServers(ctx, registry, []string{spec, spec})
+	t.Cleanup(func() { _ = closeAll() })
+	assert.ErrorIs(t, err, tools.ErrDuplicateTool)
+	assert.Len(t, registry.GetTools(), 9)
+}
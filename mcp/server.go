@@ -0,0 +1,62 @@
+// Package mcp connects the tool registry to the Model Context Protocol: NewServer serves the agent's tools to
+// MCP clients such as desktop assistants or other agents, and AddServers registers the tools of external MCP
+// servers (stdio subprocesses or streamable HTTP) so the agent can use them.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"pantryagent"
+	"pantryagent/tools"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ServerName is the implementation name the server reports to clients.
+const ServerName = "pantryagent"
+
+// NewServer returns an MCP server exposing the tools of tp with their names, titles, descriptions and
+// schemas. Tool failures are returned as error results carrying the tools.ToolError payload, the same one
+// the coordinators feed back to models, so clients can correct their calls.
+func NewServer(tp pantryagent.ToolProvider, version string) *sdk.Server {
+	server := sdk.NewServer(&sdk.Implementation{Name: ServerName, Title: "Pantry Agent", Version: version}, nil)
+
+	for _, tool := range tp.GetTools() {
+		server.AddTool(&sdk.Tool{
+			Name:         tool.Name(),
+			Title:        tool.Title(),
+			Description:  tool.Description(),
+			InputSchema:  tool.InputSchema(),
+			OutputSchema: tool.OutputSchema(),
+		}, toolHandler(tool))
+		slog.Info("MCP_SERVER: Registered tool", "name", tool.Name())
+	}
+	return server
+}
+
+// toolHandler runs tool for a tools/call request.
+func toolHandler(tool tools.Tool) sdk.ToolHandler {
+	return func(ctx context.Context, _ *sdk.ServerSession, params *sdk.CallToolParamsFor[map[string]any]) (*sdk.CallToolResult, error) {
+		slog.Info("MCP_SERVER: Calling tool", "name", tool.Name())
+
+		output, err := tool.Run(ctx, params.Arguments)
+		if err != nil {
+			toolErr := tools.AsCallError(tool.Name(), tools.ErrCodeToolFailed, err)
+			slog.Warn("MCP_SERVER: Tool failed", "name", tool.Name(), "code", toolErr.Code, "error", toolErr.Message)
+			return &sdk.CallToolResult{Content: []sdk.Content{textContent(toolErr.Payload())}, IsError: true}, nil
+		}
+
+		// Structured content for clients that understand output schemas, text for the others
+		return &sdk.CallToolResult{Content: []sdk.Content{textContent(output)}, StructuredContent: output}, nil
+	}
+}
+
+func textContent(v map[string]any) *sdk.TextContent {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return &sdk.TextContent{Text: err.Error()}
+	}
+	return &sdk.TextContent{Text: string(b)}
+}
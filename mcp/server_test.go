@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"pantryagent/tools"
+	"pantryagent/tools/storage"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRegistry(t *testing.T, pantry storage.PantryState) *tools.Registry {
+	t.Helper()
+	recipes := storage.NewTestRecipeState([]byte(`[]`))
+	registry, err := tools.NewRegistry(pantry, recipes)
+	require.NoError(t, err)
+	return registry
+}
+
+// connect serves registry in memory and returns a client session connected to it.
+func connect(t *testing.T, registry *tools.Registry) *sdk.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+	serverTransport, clientTransport := sdk.NewInMemoryTransports()
+
+	serverSession, err := NewServer(registry, "test").Connect(ctx, serverTransport)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = serverSession.Close() })
+
+	session, err := sdk.NewClient(&sdk.Implementation{Name: "test-client", Version: "test"}, nil).Connect(ctx, clientTransport)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = session.Close() })
+	return session
+}
+
+func TestServer_ListTools(t *testing.T) {
+	session := connect(t, testRegistry(t, storage.NewTestPantryState([]byte(`{"ingredients": []}`))))
+
+	res, err := session.ListTools(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, res.Tools, 2)
+
+	pantry := tools.NewPantryGet(nil)
+	got := res.Tools[0]
+	assert.Equal(t, pantry.Name(), got.Name)
+	assert.Equal(t, pantry.Title(), got.Title)
+	assert.Equal(t, pantry.Description(), got.Description)
+	require.NotNil(t, got.InputSchema)
+	assert.Contains(t, got.InputSchema.Properties, "current_day")
+	assert.NotNil(t, got.OutputSchema)
+	assert.Equal(t, "recipe_get", res.Tools[1].Name)
+}
+
+func TestServer_CallTool(t *testing.T) {
+	pantry := storage.NewTestPantryState([]byte(`{"ingredients": [{"name": "egg", "qty": 12, "unit": "count"}]}`))
+	session := connect(t, testRegistry(t, pantry))
+
+	res, err := session.CallTool(context.Background(), &sdk.CallToolParams{Name: "pantry_get", Arguments: map[string]any{"current_day": 0}})
+	require.NoError(t, err)
+	require.False(t, res.IsError)
+
+	require.Len(t, res.Content, 1)
+	text, ok := res.Content[0].(*sdk.TextContent)
+	require.True(t, ok)
+	var output map[string]any
+	require.NoError(t, json.Unmarshal([]byte(text.Text), &output))
+	assert.Contains(t, text.Text, `"name":"egg"`)
+	assert.NotNil(t, res.StructuredContent)
+}
+
+func TestServer_CallToolError(t *testing.T) {
+	session := connect(t, testRegistry(t, storage.NewTestPantryStateWithError()))
+
+	res, err := session.CallTool(context.Background(), &sdk.CallToolParams{Name: "pantry_get", Arguments: map[string]any{}})
+	require.NoError(t, err)
+	assert.True(t, res.IsError)
+
+	require.Len(t, res.Content, 1)
+	text, ok := res.Content[0].(*sdk.TextContent)
+	require.True(t, ok)
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal([]byte(text.Text), &payload))
+	assert.Equal(t, tools.ErrCodeToolFailed, payload["error"])
+	assert.Equal(t, "pantry_get", payload["tool"])
+}
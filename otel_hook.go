@@ -0,0 +1,229 @@
+package pantryagent
+
+import (
+	"context"
+	"errors"
+
+	"pantryagent/tools"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OtelHook is a Hook recording the coordinator metrics shared by all backends and adding
+// events to the span found in the callback context.
+type OtelHook struct {
+	NopHook
+
+	runsCounter            metric.Int64Counter
+	runsCompletedCounter   metric.Int64Counter
+	runsFailedCounter      metric.Int64Counter
+	toolCallsCounter       metric.Int64Counter
+	toolCallsFailedCounter metric.Int64Counter
+	iterationCounter       metric.Int64Counter
+	messageCounter         metric.Int64Counter
+
+	promptSizeGauge             metric.Int64Gauge
+	responseContentLengthGauge  metric.Int64Gauge
+	messagesInConversationGauge metric.Int64Gauge
+	toolsAvailableGauge         metric.Int64Gauge
+
+	coordinationDurationHist metric.Float64Histogram
+	iterationDurationHist    metric.Float64Histogram
+	llmResponseTimeHist      metric.Float64Histogram
+	toolExecutionTimeHist    metric.Float64Histogram
+
+	validFinalPlansCounter   metric.Int64Counter
+	invalidFinalPlansCounter metric.Int64Counter
+
+	feasibilityChecksCounter       metric.Int64Counter
+	feasibilityChecksFailedCounter metric.Int64Counter
+	feasibilityCheckTimeHist       metric.Float64Histogram
+	feasibilityProblemsGauge       metric.Int64Gauge
+}
+
+// NewOtelHook creates the hook's instruments on the given meter.
+func NewOtelHook(meter metric.Meter) *OtelHook {
+	h := &OtelHook{}
+
+	h.runsCounter, _ = meter.Int64Counter("coordinator_runs_total",
+		metric.WithDescription("Total number of coordination runs started"))
+	h.runsCompletedCounter, _ = meter.Int64Counter("coordinator_runs_completed_total",
+		metric.WithDescription("Total number of coordination runs completed successfully"))
+	h.runsFailedCounter, _ = meter.Int64Counter("coordinator_runs_failed_total",
+		metric.WithDescription("Total number of coordination runs that failed"))
+	h.toolCallsCounter, _ = meter.Int64Counter("tool_calls_total",
+		metric.WithDescription("Total number of tool calls executed"))
+	h.toolCallsFailedCounter, _ = meter.Int64Counter("tool_calls_failed_total",
+		metric.WithDescription("Total number of tool calls that failed"))
+	h.iterationCounter, _ = meter.Int64Counter("coordinator_iterations_total",
+		metric.WithDescription("Total number of coordination iterations"))
+	h.messageCounter, _ = meter.Int64Counter("coordinator_messages_total",
+		metric.WithDescription("Total number of messages in coordination"))
+
+	// Gauges
+	h.promptSizeGauge, _ = meter.Int64Gauge("prompt_size_bytes",
+		metric.WithDescription("Size of the prompt sent to LLM in bytes"))
+	h.responseContentLengthGauge, _ = meter.Int64Gauge("response_content_length",
+		metric.WithDescription("Length of the response content from LLM"))
+	h.messagesInConversationGauge, _ = meter.Int64Gauge("messages_in_conversation",
+		metric.WithDescription("Number of messages in the current conversation"))
+	h.toolsAvailableGauge, _ = meter.Int64Gauge("tools_available_count",
+		metric.WithDescription("Number of tools available to the coordinator"))
+
+	// Histograms
+	h.coordinationDurationHist, _ = meter.Float64Histogram("coordination_duration_seconds",
+		metric.WithDescription("Total duration of coordination process in seconds"))
+	h.iterationDurationHist, _ = meter.Float64Histogram("iteration_duration_seconds",
+		metric.WithDescription("Duration of individual coordination iterations in seconds"))
+	h.llmResponseTimeHist, _ = meter.Float64Histogram("llm_response_time_seconds",
+		metric.WithDescription("Time taken to receive response from LLM in seconds"))
+	h.toolExecutionTimeHist, _ = meter.Float64Histogram("tool_execution_time_seconds",
+		metric.WithDescription("Time taken to execute individual tools in seconds"))
+
+	// Final candidates
+	h.validFinalPlansCounter, _ = meter.Int64Counter("valid_final_plans_total",
+		metric.WithDescription("Total number of valid final plans generated"))
+	h.invalidFinalPlansCounter, _ = meter.Int64Counter("invalid_final_plans_total",
+		metric.WithDescription("Total number of invalid final plans attempted"))
+
+	// Feasibility (only recorded by coordinators that check feasibility)
+	h.feasibilityChecksCounter, _ = meter.Int64Counter("feasibility_checks_total",
+		metric.WithDescription("Total number of feasibility checks performed"))
+	h.feasibilityChecksFailedCounter, _ = meter.Int64Counter("feasibility_checks_failed_total",
+		metric.WithDescription("Total number of feasibility checks that failed"))
+	h.feasibilityCheckTimeHist, _ = meter.Float64Histogram("feasibility_check_time_seconds",
+		metric.WithDescription("Time taken to perform feasibility checks in seconds"))
+	h.feasibilityProblemsGauge, _ = meter.Int64Gauge("feasibility_problems_count",
+		metric.WithDescription("Number of feasibility problems in the latest check"))
+
+	return h
+}
+
+func (h *OtelHook) BeforeRun(ctx context.Context, e *RunEvent) error {
+	h.runsCounter.Add(ctx, 1)
+	h.toolsAvailableGauge.Record(ctx, int64(e.Tools))
+	return nil
+}
+
+func (h *OtelHook) AfterRun(ctx context.Context, e *RunEvent) error {
+	h.coordinationDurationHist.Record(ctx, e.Duration.Seconds())
+
+	span := trace.SpanFromContext(ctx)
+	for name, version := range e.Prompts {
+		span.SetAttributes(attribute.String("prompt."+name, version))
+	}
+	switch {
+	case e.Err != nil:
+		h.runsFailedCounter.Add(ctx, 1)
+		span.SetStatus(codes.Error, "Run failed")
+		span.RecordError(e.Err)
+	case e.Output == "":
+		h.runsFailedCounter.Add(ctx, 1)
+		span.SetStatus(codes.Error, "Max iterations reached without final output")
+	default:
+		h.runsCompletedCounter.Add(ctx, 1)
+		h.validFinalPlansCounter.Add(ctx, 1)
+	}
+	return nil
+}
+
+func (h *OtelHook) BeforeInvoke(ctx context.Context, e *InvokeEvent) error {
+	h.iterationCounter.Add(ctx, 1)
+	h.promptSizeGauge.Record(ctx, int64(e.PromptBytes))
+	h.messagesInConversationGauge.Record(ctx, int64(e.Messages))
+
+	trace.SpanFromContext(ctx).AddEvent("Sending prompt to LLM", trace.WithAttributes(
+		attribute.Int("iteration", e.Iteration),
+		attribute.Int("messages_count", e.Messages),
+		attribute.Int("tools_count", e.Tools),
+		attribute.Int("prompt_size_bytes", e.PromptBytes),
+	))
+	return nil
+}
+
+func (h *OtelHook) AfterInvoke(ctx context.Context, e *InvokeEvent) error {
+	h.llmResponseTimeHist.Record(ctx, e.Duration.Seconds())
+
+	span := trace.SpanFromContext(ctx)
+	if e.Err != nil {
+		span.SetStatus(codes.Error, "LLM invoke failed")
+		span.RecordError(e.Err)
+		return nil
+	}
+
+	h.responseContentLengthGauge.Record(ctx, int64(e.ContentLength))
+	h.messageCounter.Add(ctx, int64(e.Messages+1)) // +1 for the response message
+
+	span.AddEvent("LLM response received", trace.WithAttributes(
+		attribute.String("model.id", e.Model),
+		attribute.Int("response_content_length", e.ContentLength),
+		attribute.Int("response_tool_calls_length", e.ToolCalls),
+		attribute.Float64("llm_response_time_seconds", e.Duration.Seconds()),
+	))
+	return nil
+}
+
+func (h *OtelHook) BeforeToolCall(ctx context.Context, e *ToolCallEvent) error {
+	h.toolCallsCounter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("tool_name", e.Name),
+	))
+	return nil
+}
+
+func (h *OtelHook) AfterToolCall(ctx context.Context, e *ToolCallEvent) error {
+	if e.Err != nil {
+		errorType := "tool_execution_failed"
+		if errors.Is(e.Err, tools.ErrToolNotFound) {
+			errorType = "tool_not_found"
+		}
+		h.toolCallsFailedCounter.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("tool_name", e.Name),
+			attribute.String("error_type", errorType),
+		))
+		return nil
+	}
+
+	h.toolExecutionTimeHist.Record(ctx, e.Duration.Seconds(), metric.WithAttributes(
+		attribute.String("tool_name", e.Name),
+	))
+	trace.SpanFromContext(ctx).AddEvent("Tool executed successfully", trace.WithAttributes(
+		attribute.String("tool_name", e.Name),
+		attribute.Float64("tool_execution_time_seconds", e.Duration.Seconds()),
+	))
+	return nil
+}
+
+func (h *OtelHook) OnFinalCandidate(ctx context.Context, e *FinalCandidateEvent) error {
+	// A candidate rejected by a hook was reported, and its feasibility check recorded, already.
+	if e.FeasibilityChecked && e.Rejection != RejectionHook {
+		h.feasibilityChecksCounter.Add(ctx, 1)
+		h.feasibilityCheckTimeHist.Record(ctx, e.FeasibilityDuration.Seconds())
+		h.feasibilityProblemsGauge.Record(ctx, int64(len(e.Problems)))
+		if e.Rejection == RejectionInfeasible || e.Rejection == RejectionFeasibilityError {
+			h.feasibilityChecksFailedCounter.Add(ctx, 1)
+		}
+	}
+
+	if e.Rejection != "" {
+		h.invalidFinalPlansCounter.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("validation_error", e.Rejection),
+			attribute.Int("problem_count", len(e.Problems)),
+		))
+		return nil
+	}
+
+	// Later hooks may still reject the candidate; valid plans are counted in AfterRun.
+	trace.SpanFromContext(ctx).AddEvent("Final plan passed validation", trace.WithAttributes(
+		attribute.Int("feasibility_problems_count", len(e.Problems)),
+		attribute.Float64("feasibility_check_time_seconds", e.FeasibilityDuration.Seconds()),
+	))
+	return nil
+}
+
+func (h *OtelHook) OnIterationEnd(ctx context.Context, e *IterationEndEvent) error {
+	h.iterationDurationHist.Record(ctx, e.Duration.Seconds())
+	return nil
+}
@@ -0,0 +1,48 @@
+package pantryagent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// sums collects the totals of the int64 counters read by reader, by metric name.
+func sums(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	totals := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, dp := range sum.DataPoints {
+					totals[m.Name] += dp.Value
+				}
+			}
+		}
+	}
+	return totals
+}
+
+func TestOtelHook_HookRejectedCandidate(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	h := NewOtelHook(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+
+	// A feasible candidate another hook rejects, then the accepted one.
+	candidate := &FinalCandidateEvent{Iteration: 1, FeasibilityChecked: true}
+	require.NoError(t, h.OnFinalCandidate(ctx, candidate))
+	candidate.Rejection = RejectionHook
+	require.NoError(t, h.OnFinalCandidate(ctx, candidate))
+	require.NoError(t, h.OnFinalCandidate(ctx, &FinalCandidateEvent{Iteration: 2, FeasibilityChecked: true}))
+	require.NoError(t, h.AfterRun(ctx, &RunEvent{Output: "{}", Iterations: 2}))
+
+	totals := sums(t, reader)
+	assert.Equal(t, int64(1), totals["valid_final_plans_total"])
+	assert.Equal(t, int64(1), totals["invalid_final_plans_total"])
+	assert.Equal(t, int64(2), totals["feasibility_checks_total"], "counts each check once")
+}
@@ -0,0 +1,213 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"pantryagent"
+	"pantryagent/coordinator/bedrock"
+	"pantryagent/coordinator/fallback"
+	"pantryagent/coordinator/mock"
+	"pantryagent/coordinator/ollama"
+	"pantryagent/critic"
+	"pantryagent/prompts"
+	"pantryagent/tools"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"go.opentelemetry.io/otel"
+)
+
+type mockBackend struct{ deps Deps }
+
+// NewMock returns the backend of the deterministic mock coordinator, which needs no model.
+func NewMock(deps Deps) Backend { return &mockBackend{deps: deps} }
+
+func (b *mockBackend) Name() string { return Mock }
+
+func (b *mockBackend) Plan(ctx context.Context, run Run) (string, error) {
+	d := b.deps
+	prompt, err := mock.NewPromptWith(d.Prompts.Recorder(d.PromptVars), run.Task, d.Registry)
+	if err != nil {
+		return "", fmt.Errorf("failed to apply system prompt: %w", err)
+	}
+	return mock.NewCoordinator(mock.NewLLMClient(prompt), d.Registry, d.Agent.MaxIterations, run.logger()).
+		WithPrompts(d.Prompts, d.PromptVars).
+		WithToolRecovery(d.Agent.ToolRecovery()).
+		Use(run.Hooks...).
+		Run(ctx, run.Task)
+}
+
+type ollamaBackend struct{ deps Deps }
+
+// NewOllama returns the backend of the Ollama coordinator, on Model.ModelID at Agent.BaseOllamaEndpoint.
+func NewOllama(deps Deps) Backend { return &ollamaBackend{deps: deps} }
+
+func (b *ollamaBackend) Name() string { return Ollama }
+
+func (b *ollamaBackend) Plan(ctx context.Context, run Run) (string, error) {
+	d := b.deps
+	prompt, err := ollama.NewPromptWith(d.Prompts.Recorder(d.PromptVars), run.Task, d.Registry)
+	if err != nil {
+		return "", fmt.Errorf("failed to apply system prompt: %w", err)
+	}
+	llm, err := ollama.NewClient(ollama.ClientOpts{
+		BaseEndpoint: d.Agent.BaseOllamaEndpoint,
+		ModelID:      d.Model.ModelID,
+		Prompt:       prompt,
+		HTTPClient:   d.httpClient(),
+		MaxTokens:    int(d.Model.MaxTokens),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create LLM client: %w", err)
+	}
+	coordinator := ollama.NewCoordinator(llm, d.Registry, d.Agent.MaxIterations, run.logger(), d.TracerProvider)
+	if d.MeterProvider != nil {
+		coordinator = ollama.NewInstrumentedCoordinator(llm, d.Registry, d.Agent.MaxIterations, run.logger(),
+			otel.Tracer(pantryagent.TracerNameOllama), d.MeterProvider.Meter(pantryagent.TracerNameOllama)).Coordinator
+	}
+	coordinator.
+		WithPrompts(d.Prompts, d.PromptVars).
+		WithToolRecovery(d.Agent.ToolRecovery()).
+		WithTruncationRecovery(d.Agent.TruncationRecovery(d.Model))
+	if d.Model.CriticModelID != "" {
+		hook, err := b.critic(ctx)
+		if err != nil {
+			return "", err
+		}
+		coordinator.Use(hook)
+	}
+	return coordinator.Use(run.Hooks...).Run(ctx, run.Task)
+}
+
+// critic returns the hook of a critic on the Ollama model Model.CriticModelID. The Ollama client sends
+// its own system prompt, so it is created with the critic's.
+func (b *ollamaBackend) critic(ctx context.Context) (*critic.Hook, error) {
+	d := b.deps
+	_, recipeData, err := loadData(ctx, d.Registry)
+	if err != nil {
+		return nil, err
+	}
+	system, err := d.Prompts.Get(prompts.CriticSystem)
+	if err != nil {
+		return nil, err
+	}
+	systemPrompt, err := system.Render(nil)
+	if err != nil {
+		return nil, err
+	}
+	llm, err := ollama.NewClient(ollama.ClientOpts{
+		BaseEndpoint: d.Agent.BaseOllamaEndpoint,
+		ModelID:      d.Model.CriticModelID,
+		Prompt:       ollama.Prompt{Messages: []ollama.Message{{Role: "system", Content: systemPrompt}}},
+		HTTPClient:   d.httpClient(),
+		MaxTokens:    int(d.Model.MaxTokens),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create critic LLM client: %w", err)
+	}
+	return critic.NewHook(
+		critic.New(fallback.Ollama(llm).Client, critic.Options{Recipes: recipeData, Prompts: d.Prompts}),
+		critic.HookOpts{},
+	), nil
+}
+
+type bedrockRuntimeClient interface {
+	Converse(context.Context, *bedrockruntime.ConverseInput, ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error)
+}
+
+type bedrockBackend struct {
+	deps Deps
+	brc  bedrockRuntimeClient
+}
+
+// NewBedrock returns the backend of the Bedrock coordinator on Model.ModelID, with the configured
+// fallback models and critic.
+func NewBedrock(deps Deps, brc bedrockRuntimeClient) Backend {
+	return &bedrockBackend{deps: deps, brc: brc}
+}
+
+func (b *bedrockBackend) Name() string { return Bedrock }
+
+func (b *bedrockBackend) Plan(ctx context.Context, run Run) (string, error) {
+	d := b.deps
+	pantryData, recipeData, err := loadData(ctx, d.Registry)
+	if err != nil {
+		return "", err
+	}
+
+	opts := bedrock.LLMOptions{
+		ModelID:   d.Model.ModelID,
+		MaxTokens: d.Model.MaxTokens,
+		TopP:      d.Model.TopP,
+	}
+	// Fallback backends (if any) are tried in order when the primary model fails
+	ollamaPrompt, err := ollama.NewPromptWith(d.Prompts.Recorder(d.PromptVars), run.Task, d.Registry)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Ollama prompt: %w", err)
+	}
+	llm, err := fallback.NewChain(b.brc, opts, ollama.ClientOpts{
+		BaseEndpoint: d.Agent.BaseOllamaEndpoint,
+		Prompt:       ollamaPrompt,
+		MaxTokens:    int(d.Model.MaxTokens),
+		HTTPClient:   d.httpClient(),
+	}, d.Model.FallbackModels)
+	if err != nil {
+		return "", fmt.Errorf("failed to create LLM client: %w", err)
+	}
+
+	coordinator := bedrock.NewCoordinator(llm, d.Registry, pantryData, recipeData, d.Agent.MaxIterations, run.logger(), d.TracerProvider)
+	if d.MeterProvider != nil {
+		coordinator = bedrock.NewInstrumentedCoordinator(llm, d.Registry, pantryData, recipeData, d.Agent.MaxIterations, run.logger(),
+			otel.Tracer(pantryagent.TracerNameBedrock), d.MeterProvider.Meter(pantryagent.TracerNameBedrock)).Coordinator
+	}
+	coordinator.
+		WithPrompts(d.Prompts, d.PromptVars).
+		WithTruncationRecovery(d.Agent.TruncationRecovery(d.Model))
+	if d.Model.CriticModelID != "" {
+		// Optional critic reviewing final plans, possibly on a different (cheaper) model
+		criticOpts := opts
+		criticOpts.ModelID = d.Model.CriticModelID
+		coordinator.Use(critic.NewHook(
+			critic.New(bedrock.NewLLMClient(b.brc, criticOpts), critic.Options{Recipes: recipeData, Prompts: d.Prompts}),
+			critic.HookOpts{},
+		))
+	}
+	return coordinator.Use(run.Hooks...).Run(ctx, run.Task)
+}
+
+// loadData returns the pantry and dinner recipes the Bedrock coordinator checks plans against, read
+// through the registry's tools like the model would.
+func loadData(ctx context.Context, registry *tools.Registry) (map[string]any, []any, error) {
+	pantryTool, err := registry.GetTool("pantry_get")
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := pantryTool.Run(ctx, map[string]any{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load pantry: %w", err)
+	}
+	pantryData, ok := out["pantry"].(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid pantry structure: missing 'pantry' key in result")
+	}
+
+	recipeTool, err := registry.GetTool("recipe_get")
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err = recipeTool.Run(ctx, map[string]any{"meal_types": []any{"dinner"}})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load recipes: %w", err)
+	}
+	// Round-trip through JSON to get the []any the coordinator expects
+	b, err := json.Marshal(out["recipes"])
+	if err != nil {
+		return nil, nil, err
+	}
+	var recipeData []any
+	if err := json.Unmarshal(b, &recipeData); err != nil {
+		return nil, nil, fmt.Errorf("invalid recipes data format: %w", err)
+	}
+	return pantryData, recipeData, nil
+}
@@ -0,0 +1,104 @@
+// Package planner runs meal plans on any of the coordinator backends (mock, Ollama, Bedrock), building a
+// fresh coordinator for each run. The pantry CLI (cmd/pantry) and its server pick the backend per command
+// or per request.
+package planner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"pantryagent"
+	"pantryagent/prompts"
+	"pantryagent/tools"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Backend names.
+const (
+	Mock    = "mock"
+	Ollama  = "ollama"
+	Bedrock = "bedrock"
+)
+
+// DefaultTask is the task planned when none is given.
+const DefaultTask = "Plan dinners for the next 3 days for 2 servings each. If perishables will expire, prioritize them. If an ingredient is missing, pick a different recipe. Return a day-by-day plan."
+
+// Run is a plan request.
+type Run struct {
+	Task string
+	// Logger receives the run's iterations; nil discards them.
+	Logger pantryagent.CoordinationLogger
+	// Hooks are added to the coordinator, e.g. to observe the run as it progresses.
+	Hooks []pantryagent.Hook
+}
+
+// Backend plans meals with one kind of coordinator.
+type Backend interface {
+	Name() string
+	// Plan runs the coordinator on the task and returns its final output.
+	Plan(ctx context.Context, run Run) (string, error)
+}
+
+// Deps is what backends share: the tools, configuration and prompts.
+type Deps struct {
+	Registry   *tools.Registry
+	Model      pantryagent.ModelConfig
+	Agent      pantryagent.AgentConfig
+	Prompts    *prompts.Registry
+	PromptVars prompts.Vars
+	// TracerProvider is passed to the coordinators that take one; it may be nil.
+	TracerProvider *sdktrace.TracerProvider
+	// MeterProvider, if set, runs the instrumented Ollama and Bedrock coordinators, which record
+	// metrics on it; their spans go to the global tracer provider.
+	MeterProvider *sdkmetric.MeterProvider
+	// HTTPClient is used to reach Ollama; nil uses http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+func (d Deps) httpClient() *http.Client {
+	if d.HTTPClient != nil {
+		return d.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (r Run) logger() pantryagent.CoordinationLogger {
+	if r.Logger != nil {
+		return r.Logger
+	}
+	return pantryagent.NewNoOpCoordinationLogger()
+}
+
+// Backends is a set of backends by name.
+type Backends map[string]Backend
+
+// NewBackends returns the given backends by name.
+func NewBackends(backends ...Backend) Backends {
+	m := make(Backends, len(backends))
+	for _, b := range backends {
+		m[b.Name()] = b
+	}
+	return m
+}
+
+// Get returns the named backend, or an error listing the available ones.
+func (b Backends) Get(name string) (Backend, error) {
+	if backend, ok := b[name]; ok {
+		return backend, nil
+	}
+	return nil, fmt.Errorf("unknown backend %q (available: %v)", name, b.Names())
+}
+
+// Names returns the backend names, sorted.
+func (b Backends) Names() []string {
+	names := make([]string, 0, len(b))
+	for name := range b {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
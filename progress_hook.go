@@ -0,0 +1,113 @@
+package pantryagent
+
+import (
+	"context"
+	"time"
+)
+
+// ProgressEventType is the kind of a ProgressEvent.
+type ProgressEventType string
+
+const (
+	// ProgressIterationStarted is sent before the model is invoked for an iteration.
+	ProgressIterationStarted ProgressEventType = "iteration_started"
+	// ProgressTextDelta carries model text. The LLM clients do not stream, so each model response
+	// arrives as a single delta.
+	ProgressTextDelta ProgressEventType = "text_delta"
+	// ProgressToolCall is sent before a tool runs, with its input.
+	ProgressToolCall ProgressEventType = "tool_call"
+	// ProgressToolResult is sent after a tool ran, with its output or error.
+	ProgressToolResult ProgressEventType = "tool_result"
+	// ProgressFeasibilityProblems lists the problems found in a candidate plan.
+	ProgressFeasibilityProblems ProgressEventType = "feasibility_problems"
+	// ProgressFinalPlan carries the plan the run returned.
+	ProgressFinalPlan ProgressEventType = "final_plan"
+	// ProgressRunFinished is the last event of a run, with its error if it failed. ProgressHook does not
+	// send it; whoever runs the coordinator does, once Run returned.
+	ProgressRunFinished ProgressEventType = "run_finished"
+)
+
+// ProgressEvent is a step of a coordination run, as streamed to UIs.
+type ProgressEvent struct {
+	// Seq numbers the events of a run from 1; it is set by the receiver of the events, see
+	// ProgressHook.
+	Seq       int               `json:"seq"`
+	Type      ProgressEventType `json:"type"`
+	Time      time.Time         `json:"time"`
+	Iteration int               `json:"iteration,omitempty"`
+	// Text is the model text of a text delta, or the output of a final plan.
+	Text   string         `json:"text,omitempty"`
+	Tool   string         `json:"tool,omitempty"`
+	Input  map[string]any `json:"input,omitempty"`
+	Output map[string]any `json:"output,omitempty"`
+	// DurationMS is how long a tool ran, in milliseconds.
+	DurationMS int64 `json:"duration_ms,omitempty"`
+	// Rejection is why a candidate with feasibility problems was rejected, see FinalCandidateEvent.
+	Rejection string    `json:"rejection,omitempty"`
+	Problems  []string  `json:"problems,omitempty"`
+	Plan      *MealPlan `json:"plan,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// ProgressHook is a Hook turning a run into ProgressEvents, passed to emit as they happen. Add it last
+// so that it sees what the other hooks did, e.g. the tool output they rewrote. A ProgressHook follows a
+// single run.
+type ProgressHook struct {
+	NopHook
+	emit func(ProgressEvent)
+	now  func() time.Time
+}
+
+// NewProgressHook returns a hook sending the run's events to emit, which must not block for long since
+// the coordinator waits for it.
+func NewProgressHook(emit func(ProgressEvent)) *ProgressHook {
+	return &ProgressHook{emit: emit, now: time.Now}
+}
+
+func (h *ProgressHook) send(e ProgressEvent) {
+	e.Time = h.now()
+	h.emit(e)
+}
+
+func (h *ProgressHook) BeforeInvoke(_ context.Context, e *InvokeEvent) error {
+	h.send(ProgressEvent{Type: ProgressIterationStarted, Iteration: e.Iteration})
+	return nil
+}
+
+func (h *ProgressHook) AfterInvoke(_ context.Context, e *InvokeEvent) error {
+	if e.Err == nil && e.Content != "" {
+		h.send(ProgressEvent{Type: ProgressTextDelta, Iteration: e.Iteration, Text: e.Content})
+	}
+	return nil
+}
+
+func (h *ProgressHook) BeforeToolCall(_ context.Context, e *ToolCallEvent) error {
+	h.send(ProgressEvent{Type: ProgressToolCall, Iteration: e.Iteration, Tool: e.Name, Input: e.Input})
+	return nil
+}
+
+func (h *ProgressHook) AfterToolCall(_ context.Context, e *ToolCallEvent) error {
+	event := ProgressEvent{Type: ProgressToolResult, Iteration: e.Iteration, Tool: e.Name, Output: e.Output, DurationMS: e.Duration.Milliseconds()}
+	if e.Err != nil {
+		event.Error = e.Err.Error()
+	}
+	h.send(event)
+	return nil
+}
+
+func (h *ProgressHook) OnFinalCandidate(_ context.Context, e *FinalCandidateEvent) error {
+	if len(e.Problems) > 0 {
+		h.send(ProgressEvent{Type: ProgressFeasibilityProblems, Iteration: e.Iteration, Rejection: e.Rejection, Problems: e.Problems})
+	}
+	return nil
+}
+
+func (h *ProgressHook) AfterRun(_ context.Context, e *RunEvent) error {
+	if e.Err != nil {
+		return nil
+	}
+	if plan, _, err := ExtractMealPlan(e.Output); err == nil {
+		h.send(ProgressEvent{Type: ProgressFinalPlan, Iteration: e.Iterations, Text: e.Output, Plan: &plan})
+	}
+	return nil
+}
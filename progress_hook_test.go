@@ -0,0 +1,51 @@
+package pantryagent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressHook(t *testing.T) {
+	ctx := context.Background()
+	var events []ProgressEvent
+	h := NewProgressHook(func(e ProgressEvent) { events = append(events, e) })
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+
+	plan := `{"summary": "Chili", "days_planned": [{"day": 1, "meals": [{"id": "chili", "name": "Chili", "servings": 2}]}]}`
+	require.NoError(t, h.BeforeInvoke(ctx, &InvokeEvent{Iteration: 1}))
+	// Tool-call-only responses have no text
+	require.NoError(t, h.AfterInvoke(ctx, &InvokeEvent{Iteration: 1, ToolCalls: 1}))
+	require.NoError(t, h.BeforeToolCall(ctx, &ToolCallEvent{Iteration: 1, Name: "pantry_get", Input: map[string]any{"current_day": 0}}))
+	require.NoError(t, h.AfterToolCall(ctx, &ToolCallEvent{Iteration: 1, Name: "pantry_get", Err: errors.New("no pantry"), Duration: 3 * time.Millisecond}))
+	require.NoError(t, h.BeforeInvoke(ctx, &InvokeEvent{Iteration: 2}))
+	require.NoError(t, h.AfterInvoke(ctx, &InvokeEvent{Iteration: 2, Content: plan}))
+	require.NoError(t, h.OnFinalCandidate(ctx, &FinalCandidateEvent{Iteration: 2, Rejection: RejectionInfeasible, Problems: []string{"missing beans"}}))
+	require.NoError(t, h.OnFinalCandidate(ctx, &FinalCandidateEvent{Iteration: 2}))
+	require.NoError(t, h.AfterRun(ctx, &RunEvent{Output: plan, Iterations: 2}))
+
+	var types []ProgressEventType
+	for _, e := range events {
+		types = append(types, e.Type)
+		assert.Equal(t, now, e.Time)
+	}
+	assert.Equal(t, []ProgressEventType{
+		ProgressIterationStarted, ProgressToolCall, ProgressToolResult,
+		ProgressIterationStarted, ProgressTextDelta, ProgressFeasibilityProblems, ProgressFinalPlan,
+	}, types)
+	assert.Equal(t, "no pantry", events[2].Error)
+	assert.Equal(t, int64(3), events[2].DurationMS)
+	assert.Equal(t, []string{"missing beans"}, events[5].Problems)
+	require.NotNil(t, events[6].Plan)
+	assert.Equal(t, "Chili", events[6].Plan.Summary)
+
+	// Failed runs have no final plan
+	events = nil
+	require.NoError(t, h.AfterRun(ctx, &RunEvent{Output: plan, Err: errors.New("boom")}))
+	assert.Empty(t, events)
+}
@@ -0,0 +1,268 @@
+// Package prompts is a registry of named, versioned prompt templates: the coordinators' system prompts and
+// the nudges they send when the model goes off track.
+//
+// Templates are text/template files laid out as <name>/<version>.tmpl, e.g. bedrock/system/v1.tmpl. The
+// defaults are embedded in the binary; a directory with the same layout can add templates or override
+// embedded versions, and versions can be pinned per name. Unpinned names resolve to their latest version.
+package prompts
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Template names used by the coordinators.
+const (
+	BedrockSystem           = "bedrock/system"
+	BedrockNotJSON          = "bedrock/not_json"
+	OllamaSystem            = "ollama/system"
+	MockSystem              = "mock/system"
+	MockMissingToolResults  = "mock/missing_tool_results"
+	MockToolCallsCorrection = "mock/tool_calls_correction"
+	CriticSystem            = "critic/system"
+
+	NudgeExcessiveToolRepetition = "nudge/excessive_tool_repetition"
+	NudgeInfeasiblePlan          = "nudge/infeasible_plan"
+	NudgeFinalPlanRejected       = "nudge/final_plan_rejected"
+	NudgeMissingToolResults      = "nudge/missing_tool_results"
+	NudgeFinalRejected           = "nudge/final_rejected"
+	NudgeInvalidFinalJSON        = "nudge/invalid_final_json"
+	NudgeContinueTruncated       = "nudge/continue_truncated"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+// Vars are the variables available to templates. System prompts understand Days, Servings and
+// Household; templates should guard optional variables with {{if}}.
+type Vars map[string]any
+
+// Template is a single version of a named template.
+type Template struct {
+	Name    string
+	Version string
+	tmpl    *template.Template
+}
+
+// Render executes the template with vars. Surrounding whitespace is trimmed.
+func (t *Template) Render(vars Vars) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("render prompt %s@%s: %w", t.Name, t.Version, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Registry holds templates by name and version.
+type Registry struct {
+	templates map[string]map[string]*Template
+	pins      map[string]string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{templates: map[string]map[string]*Template{}, pins: map[string]string{}}
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultRegistry *Registry
+)
+
+// Default returns the registry of embedded templates.
+func Default() *Registry {
+	defaultOnce.Do(func() {
+		defaultRegistry = NewRegistry()
+		if err := defaultRegistry.Load(embedded); err != nil {
+			panic(fmt.Sprintf("load embedded prompts: %v", err))
+		}
+	})
+	return defaultRegistry
+}
+
+// Configure returns a registry of the embedded templates, overlaid with the templates in dir (if set)
+// and pinned to the given "name=version" versions.
+func Configure(dir string, pins []string) (*Registry, error) {
+	r := NewRegistry()
+	if err := r.Load(embedded); err != nil {
+		return nil, fmt.Errorf("load embedded prompts: %w", err)
+	}
+	if dir != "" {
+		if err := r.Load(os.DirFS(dir)); err != nil {
+			return nil, fmt.Errorf("load prompts from %s: %w", dir, err)
+		}
+	}
+	for _, pin := range pins {
+		name, version, ok := strings.Cut(strings.TrimSpace(pin), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid prompt pin %q: want name=version", pin)
+		}
+		if err := r.Pin(name, version); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Load adds the templates found in fsys, replacing templates of the same name and version. The
+// embedded templates live under a "templates" directory, which is stripped when present.
+func (r *Registry) Load(fsys fs.FS) error {
+	if sub, err := fs.Sub(fsys, "templates"); err == nil {
+		if _, err := fs.Stat(sub, "."); err == nil {
+			fsys = sub
+		}
+	}
+
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != ".tmpl" {
+			return nil
+		}
+		name, file := path.Split(p)
+		name = strings.TrimSuffix(name, "/")
+		version := strings.TrimSuffix(file, ".tmpl")
+		if name == "" {
+			return fmt.Errorf("template %s: want <name>/<version>.tmpl", p)
+		}
+
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(name).Funcs(funcs).Parse(string(b))
+		if err != nil {
+			return fmt.Errorf("parse template %s: %w", p, err)
+		}
+
+		if r.templates[name] == nil {
+			r.templates[name] = map[string]*Template{}
+		}
+		r.templates[name][version] = &Template{Name: name, Version: version, tmpl: tmpl}
+		return nil
+	})
+}
+
+// Pin makes Get return the given version of name instead of the latest one.
+func (r *Registry) Pin(name, version string) error {
+	if _, ok := r.templates[name][version]; !ok {
+		return fmt.Errorf("pin prompt %s@%s: no such template", name, version)
+	}
+	r.pins[name] = version
+	return nil
+}
+
+// Get returns the pinned version of name, or its latest version.
+func (r *Registry) Get(name string) (*Template, error) {
+	versions, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("prompt %q not found", name)
+	}
+	if v, ok := r.pins[name]; ok {
+		return versions[v], nil
+	}
+	return versions[r.Versions(name)[len(versions)-1]], nil
+}
+
+// Render renders the current version of name.
+func (r *Registry) Render(name string, vars Vars) (string, error) {
+	t, err := r.Get(name)
+	if err != nil {
+		return "", err
+	}
+	return t.Render(vars)
+}
+
+// Names returns the registered template names, sorted.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Versions returns the versions of name from oldest to latest. Versions of the form "v<N>" sort
+// numerically, so v10 is newer than v9; other versions sort lexically before them.
+func (r *Registry) Versions(name string) []string {
+	versions := make([]string, 0, len(r.templates[name]))
+	for v := range r.templates[name] {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		ni, iok := versionNumber(versions[i])
+		nj, jok := versionNumber(versions[j])
+		switch {
+		case iok && jok:
+			return ni < nj
+		case iok != jok:
+			return jok
+		}
+		return versions[i] < versions[j]
+	})
+	return versions
+}
+
+func versionNumber(v string) (int, bool) {
+	if !strings.HasPrefix(v, "v") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v[1:])
+	return n, err == nil
+}
+
+// Recorder renders templates for a single run with the run's variables, recording the version of
+// every template it renders.
+type Recorder struct {
+	registry *Registry
+	vars     Vars
+	used     map[string]string
+}
+
+// Recorder returns a recorder rendering templates with vars.
+func (r *Registry) Recorder(vars Vars) *Recorder {
+	return &Recorder{registry: r, vars: vars, used: map[string]string{}}
+}
+
+// Render renders the current version of name with the run's variables, overridden by extra.
+func (rec *Recorder) Render(name string, extra Vars) (string, error) {
+	t, err := rec.registry.Get(name)
+	if err != nil {
+		return "", err
+	}
+
+	vars := make(Vars, len(rec.vars)+len(extra))
+	maps.Copy(vars, rec.vars)
+	maps.Copy(vars, extra)
+
+	text, err := t.Render(vars)
+	if err != nil {
+		return "", err
+	}
+	rec.used[t.Name] = t.Version
+	return text, nil
+}
+
+// Versions returns the versions rendered so far, by template name.
+func (rec *Recorder) Versions() map[string]string {
+	return maps.Clone(rec.used)
+}
@@ -0,0 +1,150 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault(t *testing.T) {
+	r := Default()
+
+	names := []string{
+		BedrockSystem, BedrockNotJSON, OllamaSystem, MockSystem, MockMissingToolResults, MockToolCallsCorrection,
+		CriticSystem, NudgeExcessiveToolRepetition, NudgeInfeasiblePlan, NudgeFinalPlanRejected,
+		NudgeMissingToolResults, NudgeFinalRejected, NudgeInvalidFinalJSON,
+		NudgeContinueTruncated,
+	}
+	assert.ElementsMatch(t, names, r.Names())
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			text, err := r.Render(name, Vars{"Reason": "too many eggs", "ToolsJSON": "[]"})
+			require.NoError(t, err)
+			assert.NotEmpty(t, text)
+			assert.NotContains(t, text, "<no value>")
+		})
+	}
+}
+
+func TestDefault_PantryDate(t *testing.T) {
+	// pantry_get defaults to today; the latest prompts no longer pass current_day
+	for _, name := range []string{OllamaSystem, BedrockNotJSON, NudgeMissingToolResults, MockToolCallsCorrection} {
+		tmpl, err := Default().Get(name)
+		require.NoError(t, err)
+		assert.Equal(t, "v2", tmpl.Version, name)
+		text, err := tmpl.Render(Vars{"ToolsJSON": "[]"})
+		require.NoError(t, err)
+		assert.NotContains(t, text, "current_day", name)
+	}
+}
+
+func TestDefault_SystemPromptVars(t *testing.T) {
+	for _, name := range []string{BedrockSystem, OllamaSystem, MockSystem} {
+		t.Run(name, func(t *testing.T) {
+			plain, err := Default().Render(name, Vars{"ToolsJSON": "[]"})
+			require.NoError(t, err)
+			assert.NotContains(t, plain, "HOUSEHOLD")
+
+			text, err := Default().Render(name, Vars{"ToolsJSON": "[]", "Household": "Two adults, one vegetarian", "Days": 3, "Servings": 2})
+			require.NoError(t, err)
+			assert.Contains(t, text, "HOUSEHOLD\n- Two adults, one vegetarian\n- Plan 3 day(s)")
+			assert.Contains(t, text, "- Use 2 serving(s) per meal")
+		})
+	}
+}
+
+func TestRegistry_Versions(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Load(fstest.MapFS{
+		"greeting/v1.tmpl":  {Data: []byte("Hello")},
+		"greeting/v2.tmpl":  {Data: []byte("Hi {{.Name}}")},
+		"greeting/v10.tmpl": {Data: []byte("  Hey {{.Name}}\n")},
+		"README.md":         {Data: []byte("ignored")},
+	}))
+
+	assert.Equal(t, []string{"v1", "v2", "v10"}, r.Versions("greeting"))
+
+	// Latest version by default, trimmed
+	tmpl, err := r.Get("greeting")
+	require.NoError(t, err)
+	assert.Equal(t, "v10", tmpl.Version)
+	text, err := tmpl.Render(Vars{"Name": "Ana"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hey Ana", text)
+
+	// Pinned version
+	require.NoError(t, r.Pin("greeting", "v2"))
+	text, err = r.Render("greeting", Vars{"Name": "Ana"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi Ana", text)
+
+	assert.Error(t, r.Pin("greeting", "v3"))
+	_, err = r.Get("farewell")
+	assert.Error(t, err)
+}
+
+func TestRegistry_LoadErrors(t *testing.T) {
+	assert.ErrorContains(t, NewRegistry().Load(fstest.MapFS{"v1.tmpl": {Data: []byte("x")}}), "want <name>/<version>.tmpl")
+	assert.ErrorContains(t, NewRegistry().Load(fstest.MapFS{"x/v1.tmpl": {Data: []byte("{{.Unclosed")}}), "parse template x/v1.tmpl")
+}
+
+func TestConfigure(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nudge", "final_rejected"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nudge", "final_rejected", "v2.tmpl"), []byte("Rejected: {{.Reason}}"), 0o644))
+
+	r, err := Configure(dir, nil)
+	require.NoError(t, err)
+	text, err := r.Render(NudgeFinalRejected, Vars{"Reason": "no vegetables"})
+	require.NoError(t, err)
+	assert.Equal(t, "Rejected: no vegetables", text)
+
+	// Pins select embedded versions over newer ones
+	r, err = Configure(dir, []string{" nudge/final_rejected=v1 "})
+	require.NoError(t, err)
+	text, err = r.Render(NudgeFinalRejected, Vars{"Reason": "no vegetables"})
+	require.NoError(t, err)
+	assert.Equal(t, "Your final answer was rejected: no vegetables. Revise it and return ONLY the final JSON object.", text)
+
+	_, err = Configure(dir, []string{"nudge/final_rejected"})
+	assert.ErrorContains(t, err, "want name=version")
+	_, err = Configure(dir, []string{"nudge/final_rejected=v9"})
+	assert.Error(t, err)
+
+	// The default registry is not affected
+	tmpl, err := Default().Get(NudgeFinalRejected)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", tmpl.Version)
+}
+
+func TestRecorder(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Load(fstest.MapFS{
+		"system/v1.tmpl": {Data: []byte("Plan {{.Days}} days for {{.Household}}")},
+		"nudge/v3.tmpl":  {Data: []byte("{{.Reason}}")},
+	}))
+
+	rec := r.Recorder(Vars{"Days": 3, "Household": "two"})
+	assert.Empty(t, rec.Versions())
+
+	text, err := rec.Render("system", Vars{"Household": "four"})
+	require.NoError(t, err)
+	assert.Equal(t, "Plan 3 days for four", text)
+
+	_, err = rec.Render("nudge", Vars{"Reason": "x"})
+	require.NoError(t, err)
+	_, err = rec.Render("missing", nil)
+	assert.Error(t, err)
+
+	versions := rec.Versions()
+	assert.Equal(t, map[string]string{"system": "v1", "nudge": "v3"}, versions)
+
+	// Versions returns a copy
+	versions["system"] = "v9"
+	assert.Equal(t, "v1", rec.Versions()["system"])
+}
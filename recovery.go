@@ -0,0 +1,134 @@
+package pantryagent
+
+import (
+	"fmt"
+	"slices"
+
+	"pantryagent/tools"
+)
+
+const (
+	// DefaultMaxToolFailures is the default number of consecutive tool failures a run recovers from.
+	DefaultMaxToolFailures = 3
+	// DefaultMaxContinuations is the default number of continuations requested for a truncated answer.
+	DefaultMaxContinuations = 2
+)
+
+// ToolRecovery configures how coordinators recover from failed tool calls. Unknown tools, failing tools
+// and calls rejected by hooks are reported to the model as structured tool errors so it can correct
+// itself, until too many failures happen in a row.
+type ToolRecovery struct {
+	// MaxConsecutiveFailures caps the tool failures since the last iteration whose tool calls all
+	// succeeded; the run is aborted on the next one. Zero uses DefaultMaxToolFailures and a negative
+	// value disables recovery, aborting the run on the first failure.
+	MaxConsecutiveFailures int
+}
+
+// Recovers reports whether a run recovers after the given number of consecutive failures.
+func (r ToolRecovery) Recovers(failures int) bool {
+	limit := r.MaxConsecutiveFailures
+	if limit == 0 {
+		limit = DefaultMaxToolFailures
+	}
+	return failures <= limit
+}
+
+// AbortError returns the error ending a run whose tool call failed with err once recovery gave up.
+func (r ToolRecovery) AbortError(failures int, err *tools.ToolError) error {
+	var abort error
+	switch err.Code {
+	case tools.ErrCodeToolRejected:
+		abort = fmt.Errorf("tool %q rejected by hook: %w", err.Tool, err.Err)
+	case tools.ErrCodeUnknownTool:
+		abort = fmt.Errorf("failed to get tool %q: %w", err.Tool, err.Err)
+	default:
+		abort = fmt.Errorf("failed to run tool %q: %w", err.Tool, err)
+	}
+	if failures > 1 {
+		return fmt.Errorf("giving up after %d consecutive tool failures: %w", failures, abort)
+	}
+	return abort
+}
+
+// TruncationRecovery configures how coordinators recover from responses cut off by the output token
+// limit. A truncated answer is continued: the model is asked to carry on where it stopped and the parts
+// are stitched together. When that is not possible (truncated tool calls) or continuations run out, the
+// request is retried from scratch with a larger output token budget, up to MaxTokensCeiling.
+type TruncationRecovery struct {
+	// MaxContinuations caps the continuations requested for one answer. Zero uses
+	// DefaultMaxContinuations and a negative value disables continuations.
+	MaxContinuations int
+	// MaxTokens is the output token budget the LLM client was configured with.
+	MaxTokens int
+	// MaxTokensCeiling is the largest budget retries may raise to; each retry doubles the budget.
+	// Budgets are never raised when it is not above MaxTokens.
+	MaxTokensCeiling int
+}
+
+// Continues reports whether a truncated answer is continued after the given number of continuations.
+func (r TruncationRecovery) Continues(continuations int) bool {
+	limit := r.MaxContinuations
+	if limit == 0 {
+		limit = DefaultMaxContinuations
+	}
+	return continuations < limit
+}
+
+// NextBudget returns the output token budget to retry a truncated response with, given the budget it
+// was requested with (zero for the client's). It reports false once the ceiling is reached.
+func (r TruncationRecovery) NextBudget(current int) (int, bool) {
+	if current == 0 {
+		current = r.MaxTokens
+	}
+	if r.MaxTokensCeiling <= current {
+		return current, false
+	}
+	return min(max(current*2, 1), r.MaxTokensCeiling), true
+}
+
+// Truncation tracks the truncated answer a run is continuing, see TruncationRecovery. M is the
+// coordinator's message type. The zero value of the embedded TruncationRecovery uses the defaults.
+type Truncation[M any] struct {
+	TruncationRecovery
+
+	partial       string
+	continuations int
+	start         int // index of the first continuation message
+}
+
+// Continuations returns the number of continuations requested for the current answer.
+func (t *Truncation[M]) Continuations() int { return t.continuations }
+
+// CanContinue reports whether a truncated response is continued. Truncated tool calls cannot be.
+func (t *Truncation[M]) CanContinue(content string, toolCalls int) bool {
+	return toolCalls == 0 && content != "" && t.Continues(t.continuations)
+}
+
+// Continue records content as the next part of the answer and returns messages with the continuation
+// exchange appended.
+func (t *Truncation[M]) Continue(messages []M, content string, exchange ...M) []M {
+	if t.continuations == 0 {
+		t.start = len(messages)
+	}
+	t.partial += content
+	t.continuations++
+	return append(messages, exchange...)
+}
+
+// Restart drops the answer continued so far and returns messages without its continuation exchange.
+func (t *Truncation[M]) Restart(messages []M) []M {
+	if t.continuations == 0 {
+		return messages
+	}
+	t.partial, t.continuations = "", 0
+	return slices.Clip(messages[:t.start])
+}
+
+// Finish returns the whole answer ending with content, and messages without the continuation exchange.
+func (t *Truncation[M]) Finish(messages []M, content string) ([]M, string) {
+	if t.continuations == 0 {
+		return messages, content
+	}
+	content = t.partial + content
+	return t.Restart(messages), content
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"pantryagent"
+)
+
+// eventLog records the progress events of a run for any number of readers, who can catch up from any
+// point and wait for more.
+type eventLog struct {
+	mu     sync.Mutex
+	events []pantryagent.ProgressEvent
+	closed bool
+	// changed is closed (and replaced) whenever events are appended or the log is closed
+	changed chan struct{}
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{changed: make(chan struct{})}
+}
+
+// append numbers e and adds it to the log; events appended after close are dropped.
+func (l *eventLog) append(e pantryagent.ProgressEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	e.Seq = len(l.events) + 1
+	l.events = append(l.events, e)
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// finish appends the run's last event and closes the log.
+func (l *eventLog) finish(err error) {
+	e := pantryagent.ProgressEvent{Type: pantryagent.ProgressRunFinished, Time: time.Now()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	l.append(e)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// since returns the events after seq, whether the log is closed, and a channel closed on the next
+// change.
+func (l *eventLog) since(seq int) ([]pantryagent.ProgressEvent, bool, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seq = max(0, min(seq, len(l.events)))
+	return append([]pantryagent.ProgressEvent{}, l.events[seq:]...), l.closed, l.changed
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"pantryagent/jobs"
+	"pantryagent/planner"
+)
+
+// jobResponse is a job as reported by the API.
+type jobResponse struct {
+	jobs.Job
+	// RunID is the run of the latest attempt, while the server keeps it (see Options.MaxRuns).
+	RunID string `json:"run_id,omitempty"`
+}
+
+func newJobResponse(job jobs.Job) jobResponse {
+	res := jobResponse{Job: job}
+	if job.Attempts > 0 {
+		res.RunID = attemptRunID(job.ID, job.Attempts)
+	}
+	return res
+}
+
+// createJobRequest is the body of POST /jobs.
+type createJobRequest struct {
+	// Task defaults to planner.DefaultTask.
+	Task string `json:"task"`
+	// Backend defaults to the server's default backend.
+	Backend string `json:"backend"`
+	// IdempotencyKey defaults to the Idempotency-Key header.
+	IdempotencyKey string `json:"idempotency_key"`
+	// MaxAttempts defaults to the server's Options.JobAttempts.
+	MaxAttempts int `json:"max_attempts"`
+}
+
+// withJobs responds 501 to job requests when the server has no job queue.
+func (s *Server) withJobs(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.pool == nil {
+			writeError(w, http.StatusNotImplemented, errors.New("job queue is disabled"))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// createJob enqueues a plan job and responds with it (202) and its location. A request repeating an
+// idempotency key responds with the job enqueued first (200) instead.
+func (s *Server) createJob(w http.ResponseWriter, r *http.Request) {
+	var req createJobRequest
+	if !decodeOptionalBody(w, r, &req) {
+		return
+	}
+	if req.Task == "" {
+		req.Task = planner.DefaultTask
+	}
+	if req.Backend == "" {
+		req.Backend = s.opts.DefaultBackend
+	}
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
+	if req.MaxAttempts < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid max_attempts %d", req.MaxAttempts))
+		return
+	}
+	if req.MaxAttempts == 0 {
+		req.MaxAttempts = s.opts.JobAttempts
+	}
+	if _, err := s.opts.Backends.Get(req.Backend); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	job, created, err := s.opts.Jobs.Enqueue(r.Context(), jobs.Request{
+		Backend:        req.Backend,
+		Task:           req.Task,
+		IdempotencyKey: req.IdempotencyKey,
+		MaxAttempts:    req.MaxAttempts,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	if !created {
+		writeJSON(w, http.StatusOK, newJobResponse(job))
+		return
+	}
+	slog.Info("SERVER: Plan job enqueued", "job", job.ID, "backend", job.Backend)
+	s.pool.Notify()
+	writeJSON(w, http.StatusAccepted, newJobResponse(job))
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	opts := jobs.ListOptions{Status: jobs.Status(r.URL.Query().Get("status")), Limit: defaultListLimit}
+	switch opts.Status {
+	case "", jobs.Pending, jobs.Running, jobs.Succeeded, jobs.Dead:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid status %q", opts.Status))
+		return
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+		opts.Limit = n
+	}
+
+	list, err := s.opts.Jobs.List(r.Context(), opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res := make([]jobResponse, len(list))
+	for i, job := range list {
+		res[i] = newJobResponse(job)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"jobs": res})
+}
+
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request) {
+	job, err := s.opts.Jobs.Get(r.Context(), r.PathValue("id"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newJobResponse(job))
+}
+
+// retryJob requeues a dead-lettered job with a fresh set of attempts.
+func (s *Server) retryJob(w http.ResponseWriter, r *http.Request) {
+	job, err := s.opts.Jobs.Retry(r.Context(), r.PathValue("id"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+		return
+	case errors.Is(err, jobs.ErrNotDead):
+		writeError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	slog.Info("SERVER: Plan job retried", "job", job.ID)
+	s.pool.Notify()
+	writeJSON(w, http.StatusOK, newJobResponse(job))
+}
+
+// runJob runs an attempt of a job as a run, so that its log and progress events are served like those
+// of other runs. A backend the server no longer has fails the job permanently.
+func (s *Server) runJob(ctx context.Context, job jobs.Job) (string, error) {
+	backend, err := s.opts.Backends.Get(job.Backend)
+	if err != nil {
+		return "", jobs.Permanent(err)
+	}
+	run, entry := s.runs.createAttempt(job)
+	return s.execute(ctx, backend, run.ID, job.Task, entry)
+}
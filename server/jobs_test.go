@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"pantryagent/jobs"
+	"pantryagent/planner"
+	"pantryagent/tools/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newJobServer(t *testing.T) *testServer {
+	t.Helper()
+	store, err := sqlite.Open(context.Background(), ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	ts := newTestServer(t, func(opts *Options) {
+		opts.Jobs = store.Jobs()
+		opts.Pool = jobs.PoolOptions{Workers: 2, Backoff: time.Millisecond, PollInterval: 10 * time.Millisecond}
+		opts.JobAttempts = 2
+	})
+	t.Cleanup(func() { _ = ts.Shutdown(context.Background()) })
+	return ts
+}
+
+// waitForJob polls the job until it has status.
+func (ts *testServer) waitForJob(t *testing.T, id string, status jobs.Status) jobResponse {
+	t.Helper()
+	var job jobResponse
+	require.Eventually(t, func() bool {
+		resp := ts.do(t, http.MethodGet, "/jobs/"+id, "", &job)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return job.Status == status
+	}, 5*time.Second, 10*time.Millisecond, "job %s never got %s", id, status)
+	return job
+}
+
+func TestServer_Jobs(t *testing.T) {
+	ts := newJobServer(t)
+
+	var job jobResponse
+	resp := ts.do(t, http.MethodPost, "/jobs", `{"task": "Plan dinner", "idempotency_key": "dinner"}`, &job)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "/jobs/"+job.ID, resp.Header.Get("Location"))
+	assert.Equal(t, planner.Mock, job.Backend)
+	assert.Equal(t, 2, job.MaxAttempts)
+
+	// Resending the request returns the same job
+	var again jobResponse
+	resp = ts.do(t, http.MethodPost, "/jobs", `{"task": "Plan dinner"}`, &again, "Idempotency-Key", "dinner")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, job.ID, again.ID)
+
+	job = ts.waitForJob(t, job.ID, jobs.Succeeded)
+	assert.NotEmpty(t, job.Output)
+	require.Equal(t, job.ID+"-1", job.RunID)
+
+	// The attempt is a run like the others
+	var run Run
+	resp = ts.do(t, http.MethodGet, "/runs/"+job.RunID, "", &run)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, Succeeded, run.Status)
+	assert.Equal(t, job.ID, run.Job)
+	assert.NotNil(t, run.Plan)
+
+	var list struct{ Jobs []jobResponse }
+	resp = ts.do(t, http.MethodGet, "/jobs?status=succeeded&limit=5", "", &list)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, list.Jobs, 1)
+	assert.Equal(t, job.ID, list.Jobs[0].ID)
+
+	// Every field is optional, so is the body
+	resp = ts.do(t, http.MethodPost, "/jobs", "", &again)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, planner.DefaultTask, again.Task)
+}
+
+func TestServer_JobRetry(t *testing.T) {
+	ts := newJobServer(t)
+
+	var job jobResponse
+	resp := ts.do(t, http.MethodPost, "/jobs", `{"backend": "failing"}`, &job)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	job = ts.waitForJob(t, job.ID, jobs.Dead)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Equal(t, "model unavailable", job.Error)
+
+	var run Run
+	resp = ts.do(t, http.MethodGet, "/runs/"+job.RunID, "", &run)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, Failed, run.Status)
+
+	resp = ts.do(t, http.MethodPost, "/jobs/"+job.ID+"/retry", "", &job)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	ts.waitForJob(t, job.ID, jobs.Dead)
+
+	var other jobResponse
+	ts.do(t, http.MethodPost, "/jobs", `{}`, &other)
+	ts.waitForJob(t, other.ID, jobs.Succeeded)
+	resp = ts.do(t, http.MethodPost, "/jobs/"+other.ID+"/retry", "", nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestServer_JobErrors(t *testing.T) {
+	ts := newJobServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"unknown backend", http.MethodPost, "/jobs", `{"backend": "gpt"}`, http.StatusBadRequest},
+		{"negative attempts", http.MethodPost, "/jobs", `{"max_attempts": -1}`, http.StatusBadRequest},
+		{"invalid status", http.MethodGet, "/jobs?status=lost", "", http.StatusBadRequest},
+		{"invalid limit", http.MethodGet, "/jobs?limit=0", "", http.StatusBadRequest},
+		{"unknown job", http.MethodGet, "/jobs/nope", "", http.StatusNotFound},
+		{"retry unknown job", http.MethodPost, "/jobs/nope/retry", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]string
+			resp := ts.do(t, tt.method, tt.path, tt.body, &body)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			assert.NotEmpty(t, body["error"])
+		})
+	}
+
+	// Without a queue, the job routes are disabled
+	resp := newTestServer(t).do(t, http.MethodGet, "/jobs", "", nil)
+	assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+}
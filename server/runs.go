@@ -0,0 +1,177 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"pantryagent"
+	"pantryagent/jobs"
+)
+
+// Status is the state of a plan run.
+type Status string
+
+const (
+	Queued    Status = "queued"
+	Running   Status = "running"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+)
+
+// Run is a plan run as reported by the API.
+type Run struct {
+	ID      string `json:"id"`
+	Task    string `json:"task"`
+	Backend string `json:"backend"`
+	Status  Status `json:"status"`
+	// Job is the queued job this run is an attempt of, if any.
+	Job        string     `json:"job,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Output is the coordinator's final answer; Plan is the meal plan extracted from it, if any.
+	Output string                `json:"output,omitempty"`
+	Plan   *pantryagent.MealPlan `json:"plan,omitempty"`
+	Error  string                `json:"error,omitempty"`
+}
+
+// runEntry is a run with its coordination log and progress events. The run is guarded by the store's
+// mutex; the log and events synchronize themselves.
+type runEntry struct {
+	run    Run
+	log    *pantryagent.MemoryCoordinationLogger
+	events *eventLog
+}
+
+// runStore keeps the most recent runs in memory, evicting the oldest finished ones past max.
+type runStore struct {
+	mu    sync.Mutex
+	max   int
+	order []string // run IDs, oldest first
+	runs  map[string]*runEntry
+	now   func() time.Time
+}
+
+func newRunStore(size int) *runStore {
+	return &runStore{max: size, runs: make(map[string]*runEntry), now: time.Now}
+}
+
+// create adds a queued run and returns it with its entry.
+func (s *runStore) create(task, backend string) (Run, *runEntry) {
+	return s.add(Run{ID: newRunID(), Task: task, Backend: backend})
+}
+
+// createAttempt adds a queued run for the current attempt of job, see attemptRunID.
+func (s *runStore) createAttempt(job jobs.Job) (Run, *runEntry) {
+	return s.add(Run{ID: attemptRunID(job.ID, job.Attempts), Task: job.Task, Backend: job.Backend, Job: job.ID})
+}
+
+func (s *runStore) add(run Run) (Run, *runEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run.Status, run.CreatedAt = Queued, s.now()
+	entry := &runEntry{
+		run:    run,
+		log:    pantryagent.NewMemoryCoordinationLogger(),
+		events: newEventLog(),
+	}
+	s.runs[run.ID] = entry
+	s.order = append(s.order, run.ID)
+	s.evict()
+	return entry.run, entry
+}
+
+// evict drops the oldest finished runs beyond max; runs in progress are kept.
+func (s *runStore) evict() {
+	excess := len(s.order) - s.max
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if excess > 0 {
+			if status := s.runs[id].run.Status; status == Succeeded || status == Failed {
+				delete(s.runs, id)
+				excess--
+				continue
+			}
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+}
+
+func (s *runStore) start(id string) {
+	s.update(id, func(run *Run) {
+		now := s.now()
+		run.Status, run.StartedAt = Running, &now
+	})
+}
+
+// finish records the run's output or error, extracting the meal plan from the output, and ends its
+// progress events.
+func (s *runStore) finish(id, output string, err error) {
+	s.mu.Lock()
+	entry, ok := s.runs[id]
+	if ok {
+		run := &entry.run
+		now := s.now()
+		run.FinishedAt = &now
+		if err != nil {
+			run.Status, run.Error = Failed, err.Error()
+		} else {
+			run.Status, run.Output = Succeeded, output
+			if plan, _, err := pantryagent.ExtractMealPlan(output); err == nil {
+				run.Plan = &plan
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	// After the status changed, so that clients reading the run at the end of the stream see it finished
+	if ok {
+		entry.events.finish(err)
+	}
+}
+
+func (s *runStore) update(id string, f func(run *Run)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.runs[id]; ok {
+		f(&entry.run)
+	}
+}
+
+// get returns the run and its entry.
+func (s *runStore) get(id string) (Run, *runEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.runs[id]
+	if !ok {
+		return Run{}, nil, false
+	}
+	return entry.run, entry, true
+}
+
+// recent returns up to limit runs, newest first.
+func (s *runStore) recent(limit int) []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := make([]Run, 0, min(limit, len(s.order)))
+	for i := len(s.order) - 1; i >= 0 && len(runs) < limit; i-- {
+		runs = append(runs, s.runs[s.order[i]].run)
+	}
+	return runs
+}
+
+// attemptRunID is the ID of the run of a job's attempt.
+func attemptRunID(jobID string, attempt int) string {
+	return fmt.Sprintf("%s-%d", jobID, attempt)
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b) // never fails, see crypto/rand.Read
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,549 @@
+// Package server is the HTTP API of the meal planner: it runs plans on a backend chosen per request,
+// synchronously or in the background, queues plan jobs that a worker pool runs with retries, reports
+// runs and their coordination logs, and reads and updates the pantry and recipes. See the serve command of cmd/pantry.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"pantryagent"
+	"pantryagent/jobs"
+	"pantryagent/planner"
+	"pantryagent/tools"
+	"pantryagent/tools/storage"
+	"pantryagent/tools/storage/format"
+)
+
+const (
+	defaultMaxRuns   = 100
+	defaultListLimit = 20
+	// sseHeartbeat is how often idle event streams get a keep-alive comment.
+	sseHeartbeat = 15 * time.Second
+	// maxBodyBytes caps request bodies; recipe catalogs are the largest.
+	maxBodyBytes = 10 << 20
+)
+
+// ErrShuttingDown is returned for plan requests received after Shutdown started.
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// Options configures a Server.
+type Options struct {
+	Backends planner.Backends
+	// DefaultBackend runs plans whose request names no backend.
+	DefaultBackend string
+	// Registry provides pantry_get, which serves the pantry with freshness.
+	Registry *tools.Registry
+	Pantry   storage.VersionedPantryState
+	// Recipes is replaced through PUT /recipes if it implements storage.RecipeReplacer.
+	Recipes storage.RecipeState
+	// MaxRuns is how many runs are kept in memory for the API to report (100 if not positive).
+	MaxRuns int
+	// Jobs, if set, queues the plan jobs of /jobs, which a pool configured by Pool works off. Without
+	// it, the job routes respond 501.
+	Jobs jobs.Queue
+	Pool jobs.PoolOptions
+	// JobAttempts is the attempts of jobs whose request sets none (jobs.DefaultMaxAttempts if not
+	// positive).
+	JobAttempts int
+	// History, if set, keeps the final plans of runs, with the model of their backend from ModelIDs.
+	History  storage.PlanHistory
+	ModelIDs map[string]string
+}
+
+// Server serves the API. Runs are kept in memory, so they do not survive a restart.
+type Server struct {
+	opts Options
+	runs *runStore
+	pool *jobs.Pool // nil without a job queue
+
+	// ctx is the parent of every run; it is cancelled when Shutdown times out.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	closing bool
+	wg      sync.WaitGroup
+}
+
+// New returns a server with opts. With a job queue, it starts the worker pool, which requeues the jobs
+// a previous server left running.
+func New(opts Options) (*Server, error) {
+	if _, err := opts.Backends.Get(opts.DefaultBackend); err != nil {
+		return nil, fmt.Errorf("default backend: %w", err)
+	}
+	if opts.MaxRuns <= 0 {
+		opts.MaxRuns = defaultMaxRuns
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{opts: opts, runs: newRunStore(opts.MaxRuns), ctx: ctx, cancel: cancel}
+	if opts.Jobs != nil {
+		s.pool = jobs.NewPool(opts.Jobs, s.runJob, opts.Pool)
+		if err := s.pool.Start(ctx); err != nil {
+			cancel()
+			return nil, fmt.Errorf("start worker pool: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Handler returns the API's routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.health)
+	mux.HandleFunc("GET /backends", s.listBackends)
+	mux.HandleFunc("POST /runs", s.createRun)
+	mux.HandleFunc("GET /runs", s.listRuns)
+	mux.HandleFunc("GET /runs/{id}", s.getRun)
+	mux.HandleFunc("GET /runs/{id}/log", s.getRunLog)
+	mux.HandleFunc("GET /runs/{id}/events", s.streamRunEvents)
+	mux.HandleFunc("POST /jobs", s.withJobs(s.createJob))
+	mux.HandleFunc("GET /jobs", s.withJobs(s.listJobs))
+	mux.HandleFunc("GET /jobs/{id}", s.withJobs(s.getJob))
+	mux.HandleFunc("POST /jobs/{id}/retry", s.withJobs(s.retryJob))
+	mux.HandleFunc("GET /pantry", s.getPantry)
+	mux.HandleFunc("GET /pantry/events", s.getPantryEvents)
+	mux.HandleFunc("POST /pantry/events", s.recordPantryEvents)
+	mux.HandleFunc("GET /recipes", s.getRecipes)
+	mux.HandleFunc("PUT /recipes", s.replaceRecipes)
+	return mux
+}
+
+// Shutdown stops accepting plan runs and claiming jobs, and waits for those in progress to finish. If
+// ctx ends first, the remaining runs are cancelled (and fail; interrupted jobs go back to the queue) and
+// Shutdown returns ctx's error once they have stopped.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+
+	if s.pool != nil {
+		if err := s.pool.Shutdown(ctx); err != nil {
+			s.cancel()
+			s.wg.Wait()
+			return err
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// track registers a run in progress, unless the server is shutting down.
+func (s *Server) track() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return ErrShuttingDown
+	}
+	s.wg.Add(1)
+	return nil
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) listBackends(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"backends": s.opts.Backends.Names(),
+		"default":  s.opts.DefaultBackend,
+	})
+}
+
+// createRunRequest is the body of POST /runs.
+type createRunRequest struct {
+	// Task defaults to planner.DefaultTask.
+	Task string `json:"task"`
+	// Backend defaults to the server's default backend.
+	Backend string `json:"backend"`
+	// Async returns 202 Accepted right away instead of waiting for the plan.
+	Async bool `json:"async"`
+}
+
+// createRun starts a plan run. Synchronous runs respond with the finished run (200, whether it
+// succeeded or failed); asynchronous ones with the queued run (202) and its location.
+func (s *Server) createRun(w http.ResponseWriter, r *http.Request) {
+	var req createRunRequest
+	if !decodeOptionalBody(w, r, &req) {
+		return
+	}
+	if req.Task == "" {
+		req.Task = planner.DefaultTask
+	}
+	if req.Backend == "" {
+		req.Backend = s.opts.DefaultBackend
+	}
+	backend, err := s.opts.Backends.Get(req.Backend)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.track(); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	run, entry := s.runs.create(req.Task, backend.Name())
+	slog.Info("SERVER: Plan run created", "run", run.ID, "backend", run.Backend, "async", req.Async)
+	if req.Async {
+		go func() {
+			defer s.wg.Done()
+			s.execute(s.ctx, backend, run.ID, req.Task, entry) // nolint: errcheck
+		}()
+		w.Header().Set("Location", "/runs/"+run.ID)
+		writeJSON(w, http.StatusAccepted, run)
+		return
+	}
+
+	defer s.wg.Done()
+
+	// Synchronous runs stop when the client goes away or when Shutdown times out
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	stop := context.AfterFunc(s.ctx, cancel)
+	defer stop()
+	s.execute(ctx, backend, run.ID, req.Task, entry) // nolint: errcheck
+
+	run, _, _ = s.runs.get(run.ID)
+	writeJSON(w, http.StatusOK, run)
+}
+
+// execute runs a plan and records it in the run id, returning its output.
+func (s *Server) execute(ctx context.Context, backend planner.Backend, id, task string, entry *runEntry) (string, error) {
+	s.runs.start(id)
+	output, err := backend.Plan(ctx, planner.Run{
+		Task:   task,
+		Logger: entry.log,
+		Hooks:  []pantryagent.Hook{pantryagent.NewProgressHook(entry.events.append)},
+	})
+	if err != nil {
+		slog.Error("SERVER: Plan run failed", "run", id, "error", err)
+	} else {
+		slog.Info("SERVER: Plan run succeeded", "run", id)
+	}
+	s.runs.finish(id, output, err)
+	if err == nil && s.opts.History != nil {
+		s.savePlan(context.WithoutCancel(ctx), id, task, backend.Name(), output)
+	}
+	return output, err
+}
+
+// savePlan keeps the plan of a run's output in the history. A run without a plan, or a plan the
+// history failed to keep, is only logged.
+func (s *Server) savePlan(ctx context.Context, id, task, backend, output string) {
+	plan, _, err := pantryagent.ExtractMealPlan(output)
+	if err != nil {
+		slog.Warn("SERVER: Plan run output holds no plan; not kept in the history", "run", id)
+		return
+	}
+	b, err := json.Marshal(plan)
+	if err == nil {
+		_, err = s.opts.History.SavePlan(ctx, task, s.opts.ModelIDs[backend], b)
+	}
+	if err != nil {
+		slog.Error("SERVER: Failed to keep plan in the history", "run", id, "error", err)
+	}
+}
+
+func (s *Server) listRuns(w http.ResponseWriter, r *http.Request) {
+	limit := defaultListLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"runs": s.runs.recent(limit)})
+}
+
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request) {
+	run, _, ok := s.runs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("run %q not found", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+func (s *Server) getRunLog(w http.ResponseWriter, r *http.Request) {
+	run, entry, ok := s.runs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("run %q not found", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":         run.ID,
+		"status":     run.Status,
+		"iterations": entry.log.Iterations(),
+	})
+}
+
+// streamRunEvents streams a run's progress events as Server-Sent Events: every event so far (or those
+// after the Last-Event-ID a reconnecting client sends), then new ones as they happen. The stream ends
+// after the run_finished event.
+func (s *Server) streamRunEvents(w http.ResponseWriter, r *http.Request) {
+	_, entry, ok := s.runs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("run %q not found", r.PathValue("id")))
+		return
+	}
+	seq := 0
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid Last-Event-ID %q", v))
+			return
+		}
+		seq = n
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering, e.g. nginx's
+	w.WriteHeader(http.StatusOK)
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		events, closed, changed := entry.events.since(seq)
+		for _, e := range events {
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+			seq = e.Seq
+		}
+		if err := rc.Flush(); err != nil || closed {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			// A comment keeps idle connections from being closed by proxies
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes e as a Server-Sent Event named after its type, with its sequence number as ID.
+func writeEvent(w io.Writer, e pantryagent.ProgressEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data)
+	return err
+}
+
+// getPantry serves pantry_get's output: the pantry with freshness on the date and current_day query
+// parameters (both optional).
+func (s *Server) getPantry(w http.ResponseWriter, r *http.Request) {
+	input := map[string]any{}
+	if v := r.URL.Query().Get("date"); v != "" {
+		input["date"] = v
+	}
+	if v := r.URL.Query().Get("current_day"); v != "" {
+		day, err := strconv.Atoi(v)
+		if err != nil || day < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid current_day %q", v))
+			return
+		}
+		input["current_day"] = float64(day) // as decoded from a model's JSON tool call
+	}
+
+	tool, err := s.opts.Registry.GetTool("pantry_get")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out, err := tool.Run(r.Context(), input)
+	if toolErr, ok := tools.AsToolError(err); ok && toolErr.Code == tools.ErrCodeInvalidInput {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) getPantryEvents(w http.ResponseWriter, r *http.Request) {
+	pantry, err := s.loadPantry(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	events := pantry.Events
+	if events == nil {
+		events = []tools.PantryEvent{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"events": events})
+}
+
+// recordPantryEventsRequest is the body of POST /pantry/events.
+type recordPantryEventsRequest struct {
+	Events []tools.PantryEvent `json:"events"`
+}
+
+// recordPantryEvents appends events to the pantry's log. Events without a time happen now; events
+// without a source come from "api". It responds with the updated pantry.
+func (s *Server) recordPantryEvents(w http.ResponseWriter, r *http.Request) {
+	var req recordPantryEventsRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if len(req.Events) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("no events"))
+		return
+	}
+	now := time.Now()
+	for i := range req.Events {
+		e := &req.Events[i]
+		if e.At.IsZero() {
+			e.At = now
+		}
+		if e.Source == "" {
+			e.Source = "api"
+		}
+		if err := e.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	// Check the events apply to the current pantry first, so that they are reported as conflicting
+	// with it rather than as a storage failure
+	pantry, err := s.loadPantry(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := pantry.Record(req.Events...); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+
+	updated, err := tools.RecordPantryEvents(r.Context(), s.opts.Pantry, req.Events, 0)
+	switch {
+	case errors.Is(err, storage.ErrConflict), errors.Is(err, tools.ErrInsufficientQuantity):
+		writeError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	slog.Info("SERVER: Pantry events recorded", "events", len(req.Events))
+	writeJSON(w, http.StatusOK, map[string]any{"ingredients": updated.Ingredients})
+}
+
+func (s *Server) loadPantry(ctx context.Context) (*tools.Pantry, error) {
+	data, err := s.opts.Pantry.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load pantry: %w", err)
+	}
+	return tools.ParsePantry(data)
+}
+
+// getRecipes serves the recipe catalog, upgraded to the current format version, without its envelope.
+func (s *Server) getRecipes(w http.ResponseWriter, r *http.Request) {
+	raw, err := s.opts.Recipes.Load(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("load recipes: %w", err))
+		return
+	}
+	data, _, err := format.Decode(format.Recipes, raw)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data) // nolint: errcheck
+}
+
+// replaceRecipes replaces the recipe catalog with the body, a recipes document.
+func (s *Server) replaceRecipes(w http.ResponseWriter, r *http.Request) {
+	replacer, ok := s.opts.Recipes.(storage.RecipeReplacer)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("recipe storage is read-only"))
+		return
+	}
+	var recipes json.RawMessage
+	if !decodeBody(w, r, &recipes) {
+		return
+	}
+	switch err := replacer.Replace(r.Context(), recipes); {
+	case errors.Is(err, storage.ErrInvalidRecipes):
+		writeError(w, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	slog.Info("SERVER: Recipes replaced")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeBody decodes the JSON request body into v, responding 400 if it cannot.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	return decode(w, r, v, false)
+}
+
+// decodeOptionalBody is decodeBody for requests whose fields are all optional: an empty body leaves v
+// unchanged.
+func decodeOptionalBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	return decode(w, r, v, true)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any, optional bool) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err := dec.Decode(v); err != nil && !(optional && errors.Is(err, io.EOF)) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("SERVER: Failed to write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
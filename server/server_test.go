@@ -0,0 +1,485 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"pantryagent"
+	"pantryagent/planner"
+	"pantryagent/prompts"
+	"pantryagent/tools"
+	"pantryagent/tools/storage"
+	"pantryagent/tools/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingBackend plans once release is closed, or fails when its context ends.
+type blockingBackend struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingBackend() *blockingBackend {
+	return &blockingBackend{started: make(chan struct{}, 10), release: make(chan struct{})}
+}
+
+func (b *blockingBackend) Name() string { return "blocking" }
+
+func (b *blockingBackend) Plan(ctx context.Context, run planner.Run) (string, error) {
+	b.started <- struct{}{}
+	for _, h := range run.Hooks {
+		if err := h.BeforeInvoke(ctx, &pantryagent.InvokeEvent{Iteration: 1}); err != nil {
+			return "", err
+		}
+	}
+	if err := run.Logger.LogIteration(pantryagent.IterationLog{Iteration: 1, LLMOutput: "thinking"}); err != nil {
+		return "", err
+	}
+	select {
+	case <-b.release:
+		return `{"summary": "done", "days_planned": [{"day": 1, "meals": [{"id": "soup", "name": "Soup", "servings": 2}]}]}`, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+type testServer struct {
+	*Server
+	url      string
+	blocking *blockingBackend
+}
+
+// failingBackend fails every plan.
+type failingBackend struct{}
+
+func (failingBackend) Name() string { return "failing" }
+
+func (failingBackend) Plan(ctx context.Context, run planner.Run) (string, error) {
+	return "", errors.New("model unavailable")
+}
+
+// newTestServer returns a server on the mock, blocking and failing backends, with options changed by
+// configure.
+func newTestServer(t *testing.T, configure ...func(*Options)) *testServer {
+	t.Helper()
+	recipesPath := filepath.Join(t.TempDir(), "recipes.json")
+	require.NoError(t, storage.NewFileRecipeState(recipesPath).Replace(context.Background(), []byte(`[]`)))
+
+	ps := storage.NewTestPantryState([]byte(`{"ingredients": [{"name": "rice", "qty": 1000, "unit": "g", "days_left": 9999}]}`))
+	rs := storage.NewFileRecipeState(recipesPath)
+	registry, err := tools.NewRegistry(ps, rs)
+	require.NoError(t, err)
+	promptRegistry, err := prompts.Configure("", nil)
+	require.NoError(t, err)
+
+	blocking := newBlockingBackend()
+	deps := planner.Deps{Registry: registry, Prompts: promptRegistry, Agent: pantryagent.AgentConfig{MaxIterations: 5}}
+	opts := Options{
+		Backends:       planner.NewBackends(planner.NewMock(deps), blocking, failingBackend{}),
+		DefaultBackend: planner.Mock,
+		Registry:       registry,
+		Pantry:         ps,
+		Recipes:        rs,
+	}
+	for _, f := range configure {
+		f(&opts)
+	}
+	s, err := New(opts)
+	require.NoError(t, err)
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return &testServer{Server: s, url: ts.URL, blocking: blocking}
+}
+
+// do sends a request with the header key-value pairs and decodes the response body into v, if set.
+func (ts *testServer) do(t *testing.T, method, path, body string, v any, header ...string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.url+path, strings.NewReader(body))
+	require.NoError(t, err)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	if v != nil {
+		require.NoError(t, json.Unmarshal(b, v), string(b))
+	}
+	return resp
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Options{Backends: planner.NewBackends(), DefaultBackend: "ollama"})
+	assert.ErrorContains(t, err, `unknown backend "ollama"`)
+}
+
+func TestServer_SyncRun(t *testing.T) {
+	ts := newTestServer(t)
+
+	var run Run
+	resp := ts.do(t, http.MethodPost, "/runs", `{"task": "Plan dinner"}`, &run)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, Succeeded, run.Status)
+	assert.Equal(t, planner.Mock, run.Backend)
+	assert.Equal(t, "Plan dinner", run.Task)
+	require.NotNil(t, run.Plan)
+	assert.NotEmpty(t, run.Plan.DaysPlanned)
+
+	var log struct {
+		Status     Status                     `json:"status"`
+		Iterations []pantryagent.IterationLog `json:"iterations"`
+	}
+	resp = ts.do(t, http.MethodGet, "/runs/"+run.ID+"/log", "", &log)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, Succeeded, log.Status)
+	assert.NotEmpty(t, log.Iterations)
+
+	var got Run
+	ts.do(t, http.MethodGet, "/runs/"+run.ID, "", &got)
+	assert.Equal(t, run.ID, got.ID)
+
+	// Every field is optional, so is the body
+	resp = ts.do(t, http.MethodPost, "/runs", "", &got)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, planner.DefaultTask, got.Task)
+}
+
+func TestServer_History(t *testing.T) {
+	store, err := sqlite.Open(context.Background(), ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	ts := newTestServer(t, func(o *Options) {
+		o.History, o.ModelIDs = store, map[string]string{planner.Mock: "mock-model"}
+	})
+
+	var run Run
+	resp := ts.do(t, http.MethodPost, "/runs", `{"task": "Plan dinner"}`, &run)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	ts.do(t, http.MethodPost, "/runs", `{"task": "Plan lunch", "backend": "failing"}`, nil)
+
+	plans, err := store.RecentPlans(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, plans, 1, "failed runs keep no plan")
+	assert.Equal(t, "Plan dinner", plans[0].Task)
+	assert.Equal(t, "mock-model", plans[0].ModelID)
+	var plan pantryagent.MealPlan
+	require.NoError(t, json.Unmarshal(plans[0].Plan, &plan))
+	assert.Equal(t, *run.Plan, plan)
+}
+
+func TestServer_AsyncRun(t *testing.T) {
+	ts := newTestServer(t)
+
+	var run Run
+	resp := ts.do(t, http.MethodPost, "/runs", `{"backend": "blocking", "async": true}`, &run)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "/runs/"+run.ID, resp.Header.Get("Location"))
+	assert.Equal(t, planner.DefaultTask, run.Task)
+	<-ts.blocking.started
+
+	var got Run
+	ts.do(t, http.MethodGet, "/runs/"+run.ID, "", &got)
+	assert.Equal(t, Running, got.Status)
+
+	close(ts.blocking.release)
+	require.NoError(t, ts.Shutdown(context.Background()))
+	ts.do(t, http.MethodGet, "/runs/"+run.ID, "", &got)
+	assert.Equal(t, Succeeded, got.Status)
+	require.NotNil(t, got.Plan)
+	assert.Equal(t, "done", got.Plan.Summary)
+}
+
+func TestServer_ListRuns(t *testing.T) {
+	ts := newTestServer(t)
+	for _, task := range []string{"one", "two", "three"} {
+		ts.do(t, http.MethodPost, "/runs", `{"task": "`+task+`"}`, nil)
+	}
+
+	var list struct {
+		Runs []Run `json:"runs"`
+	}
+	ts.do(t, http.MethodGet, "/runs?limit=2", "", &list)
+	require.Len(t, list.Runs, 2)
+	assert.Equal(t, "three", list.Runs[0].Task)
+	assert.Equal(t, "two", list.Runs[1].Task)
+
+	resp := ts.do(t, http.MethodGet, "/runs?limit=zero", "", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServer_Errors(t *testing.T) {
+	ts := newTestServer(t)
+
+	tests := []struct {
+		name, method, path, body string
+		wantStatus               int
+		wantErr                  string
+	}{
+		{name: "unknown backend", method: http.MethodPost, path: "/runs", body: `{"backend": "gpt"}`, wantStatus: http.StatusBadRequest, wantErr: `unknown backend "gpt"`},
+		{name: "invalid body", method: http.MethodPost, path: "/runs", body: `{`, wantStatus: http.StatusBadRequest, wantErr: "invalid request body"},
+		{name: "unknown run", method: http.MethodGet, path: "/runs/nope", wantStatus: http.StatusNotFound, wantErr: `run "nope" not found`},
+		{name: "unknown run log", method: http.MethodGet, path: "/runs/nope/log", wantStatus: http.StatusNotFound, wantErr: "not found"},
+		{name: "invalid date", method: http.MethodGet, path: "/pantry?date=tomorrow", wantStatus: http.StatusBadRequest, wantErr: "want YYYY-MM-DD"},
+		{name: "no events", method: http.MethodPost, path: "/pantry/events", body: `{"events": []}`, wantStatus: http.StatusBadRequest, wantErr: "no events"},
+		{name: "invalid event", method: http.MethodPost, path: "/pantry/events", body: `{"events": [{"type": "eaten", "name": "rice"}]}`, wantStatus: http.StatusBadRequest, wantErr: "unknown pantry event type"},
+		{name: "too much", method: http.MethodPost, path: "/pantry/events", body: `{"events": [{"type": "consumed", "name": "rice", "qty": 2000, "unit": "g"}]}`, wantStatus: http.StatusConflict, wantErr: "1000 left"},
+		{name: "invalid recipes", method: http.MethodPut, path: "/recipes", body: `{"id": "soup"}`, wantStatus: http.StatusBadRequest, wantErr: "parse recipes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body struct {
+				Error string `json:"error"`
+			}
+			resp := ts.do(t, tt.method, tt.path, tt.body, &body)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Contains(t, body.Error, tt.wantErr)
+		})
+	}
+}
+
+func TestServer_Pantry(t *testing.T) {
+	ts := newTestServer(t)
+
+	var updated struct {
+		Ingredients []tools.Ingredient `json:"ingredients"`
+	}
+	resp := ts.do(t, http.MethodPost, "/pantry/events", `{"events": [
+		{"type": "purchased", "name": "milk", "qty": 1, "unit": "L", "expires": "2099-01-01"},
+		{"type": "consumed", "name": "rice", "qty": 200, "unit": "g"}
+	]}`, &updated)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, updated.Ingredients, 2)
+	assert.Equal(t, 800.0, updated.Ingredients[0].Qty)
+
+	var log struct {
+		Events []tools.PantryEvent `json:"events"`
+	}
+	ts.do(t, http.MethodGet, "/pantry/events", "", &log)
+	require.Len(t, log.Events, 2)
+	assert.Equal(t, "api", log.Events[0].Source)
+	assert.False(t, log.Events[0].At.IsZero())
+
+	var pantry struct {
+		Pantry struct {
+			Ingredients []map[string]any `json:"ingredients"`
+		} `json:"pantry"`
+	}
+	ts.do(t, http.MethodGet, "/pantry", "", &pantry)
+	assert.Len(t, pantry.Pantry.Ingredients, 2)
+
+	// Before the events, only the rice was there
+	ts.do(t, http.MethodGet, "/pantry?date=2020-01-01", "", &pantry)
+	require.Len(t, pantry.Pantry.Ingredients, 1)
+	assert.Equal(t, 1000.0, pantry.Pantry.Ingredients[0]["qty"])
+}
+
+func TestServer_PantryQuery(t *testing.T) {
+	ps := storage.NewTestPantryState([]byte(`{"ingredients": [{"name": "milk", "qty": 1, "unit": "L", "perishable_days": 7, "added_day": 1}]}`))
+	ts := newTestServer(t, func(o *Options) {
+		registry, err := tools.NewRegistry(ps, o.Recipes)
+		require.NoError(t, err)
+		o.Registry, o.Pantry = registry, ps
+	})
+
+	daysLeft := func(query string) any {
+		var pantry struct {
+			Pantry struct {
+				Ingredients []map[string]any `json:"ingredients"`
+			} `json:"pantry"`
+		}
+		resp := ts.do(t, http.MethodGet, "/pantry"+query, "", &pantry)
+		require.Equal(t, http.StatusOK, resp.StatusCode, query)
+		require.Len(t, pantry.Pantry.Ingredients, 1)
+		return pantry.Pantry.Ingredients[0]["days_left"]
+	}
+	assert.Equal(t, 8.0, daysLeft(""))
+	assert.Equal(t, 3.0, daysLeft("?current_day=5"), "current_day changes the freshness")
+
+	for _, query := range []string{"?date=March", "?current_day=-1", "?current_day=x"} {
+		resp := ts.do(t, http.MethodGet, "/pantry"+query, "", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+func TestServer_Recipes(t *testing.T) {
+	ts := newTestServer(t)
+
+	resp := ts.do(t, http.MethodPut, "/recipes", `[{"id": "soup", "meal_types": ["dinner"]}]`, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	var recipes []map[string]any
+	ts.do(t, http.MethodGet, "/recipes", "", &recipes)
+	require.Len(t, recipes, 1)
+	assert.Equal(t, "soup", recipes[0]["id"])
+
+	// Failing to store a valid catalog is the server's fault
+	broken := newTestServer(t, func(o *Options) {
+		o.Recipes = storage.NewFileRecipeState(filepath.Join(t.TempDir(), "missing", "recipes.json"))
+	})
+	resp = broken.do(t, http.MethodPut, "/recipes", `[{"id": "soup"}]`, nil)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	ts := newTestServer(t)
+
+	var run Run
+	ts.do(t, http.MethodPost, "/runs", `{"backend": "blocking", "async": true}`, &run)
+	<-ts.blocking.started
+
+	// Runs still in progress when the shutdown times out are cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := ts.Shutdown(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+
+	var got Run
+	ts.do(t, http.MethodGet, "/runs/"+run.ID, "", &got)
+	assert.Equal(t, Failed, got.Status)
+	assert.Contains(t, got.Error, "context canceled")
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	resp := ts.do(t, http.MethodPost, "/runs", `{}`, &body)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, ErrShuttingDown.Error(), body.Error)
+}
+
+func TestRunStore_Evict(t *testing.T) {
+	s := newRunStore(2)
+	first, _ := s.create("one", "mock")
+	s.finish(first.ID, "", errors.New("boom"))
+	second, _ := s.create("two", "mock")
+	third, _ := s.create("three", "mock")
+	fourth, _ := s.create("four", "mock")
+
+	// Runs in progress are kept past the limit
+	_, _, ok := s.get(first.ID)
+	assert.False(t, ok)
+	var ids []string
+	for _, run := range s.recent(10) {
+		ids = append(ids, run.ID)
+	}
+	assert.Equal(t, []string{fourth.ID, third.ID, second.ID}, ids)
+}
+
+type sseEvent struct {
+	id, event string
+	data      pantryagent.ProgressEvent
+}
+
+// readEvents reads Server-Sent Events from r until n were read (or the stream ends, for n < 0).
+func readEvents(t *testing.T, r *bufio.Reader, n int) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var e sseEvent
+	for n < 0 || len(events) < n {
+		line, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			events = append(events, e)
+			e = sseEvent{}
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e.data))
+		}
+	}
+	return events
+}
+
+func (ts *testServer) stream(t *testing.T, id, lastEventID string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, ts.url+"/runs/"+id+"/events", nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, bufio.NewReader(resp.Body)
+}
+
+func TestServer_RunEvents(t *testing.T) {
+	ts := newTestServer(t)
+
+	var run Run
+	ts.do(t, http.MethodPost, "/runs", `{"task": "Plan dinner"}`, &run)
+
+	// A finished run replays its events and ends the stream
+	resp, r := ts.stream(t, run.ID, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	events := readEvents(t, r, -1)
+	var types []string
+	for i, e := range events {
+		types = append(types, e.event)
+		assert.Equal(t, strconv.Itoa(i+1), e.id)
+		assert.Equal(t, i+1, e.data.Seq)
+		assert.Equal(t, e.event, string(e.data.Type))
+	}
+	assert.Equal(t, []string{
+		"iteration_started", "text_delta", "tool_call", "tool_result", "tool_call", "tool_result",
+		"iteration_started", "text_delta", "final_plan", "run_finished",
+	}, types)
+	assert.Equal(t, "pantry_get", events[2].data.Tool)
+	assert.NotEmpty(t, events[3].data.Output)
+	require.NotNil(t, events[8].data.Plan)
+	assert.Empty(t, events[9].data.Error)
+
+	// Reconnecting clients resume after the last event they saw
+	_, r = ts.stream(t, run.ID, "8")
+	events = readEvents(t, r, -1)
+	require.Len(t, events, 2)
+	assert.Equal(t, "final_plan", events[0].event)
+
+	resp = ts.do(t, http.MethodGet, "/runs/nope/events", "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = ts.stream(t, run.ID, "last")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServer_RunEvents_Live(t *testing.T) {
+	ts := newTestServer(t)
+
+	var run Run
+	ts.do(t, http.MethodPost, "/runs", `{"backend": "blocking", "async": true}`, &run)
+	<-ts.blocking.started
+
+	_, r := ts.stream(t, run.ID, "")
+	events := readEvents(t, r, 1)
+	assert.Equal(t, "iteration_started", events[0].event)
+
+	// The stream follows the run until it finishes
+	close(ts.blocking.release)
+	events = readEvents(t, r, -1)
+	require.Len(t, events, 1)
+	assert.Equal(t, "run_finished", events[0].event)
+
+	var got Run
+	ts.do(t, http.MethodGet, "/runs/"+run.ID, "", &got)
+	assert.Equal(t, Succeeded, got.Status)
+}
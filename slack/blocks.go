@@ -0,0 +1,438 @@
+package slack
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"pantryagent"
+	"pantryagent/tools"
+)
+
+// Block is a Block Kit layout block; only the fields of the blocks this package renders are typed.
+type Block struct {
+	Type     string      `json:"type"`
+	BlockID  string      `json:"block_id,omitempty"`
+	Text     *TextObject `json:"text,omitempty"`
+	Elements []any       `json:"elements,omitempty"`
+}
+
+// Button is a Block Kit button element.
+type Button struct {
+	Type     string     `json:"type"`
+	Text     TextObject `json:"text"`
+	ActionID string     `json:"action_id"`
+	Value    string     `json:"value,omitempty"`
+	// Style is "primary", "danger" or empty.
+	Style   string  `json:"style,omitempty"`
+	Confirm *Dialog `json:"confirm,omitempty"`
+}
+
+// Dialog is a Block Kit confirmation dialog, shown before a button's action is sent.
+type Dialog struct {
+	Title   TextObject `json:"title"`
+	Text    TextObject `json:"text"`
+	Confirm TextObject `json:"confirm"`
+	Deny    TextObject `json:"deny"`
+}
+
+// TextObject is a Block Kit text object.
+type TextObject struct {
+	// Type is "plain_text" or "mrkdwn".
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+// Block Kit limits.
+const (
+	maxBlocks        = 50
+	maxHeaderText    = 150
+	maxSectionText   = 3000
+	maxContextText   = 2000
+	maxFallbackText  = 4000
+	maxButtonText    = 75
+	maxActions       = 25
+	truncationMarker = "…"
+)
+
+func header(text string) Block {
+	return Block{Type: "header", Text: &TextObject{Type: "plain_text", Text: truncate(text, maxHeaderText), Emoji: true}}
+}
+
+func section(mrkdwn string) Block {
+	return Block{Type: "section", Text: &TextObject{Type: "mrkdwn", Text: truncate(mrkdwn, maxSectionText)}}
+}
+
+func contextBlock(mrkdwn string) Block {
+	return Block{Type: "context", Elements: []any{TextObject{Type: "mrkdwn", Text: truncate(mrkdwn, maxContextText)}}}
+}
+
+func divider() Block {
+	return Block{Type: "divider"}
+}
+
+func actions(blockID string, elements ...any) Block {
+	return Block{Type: "actions", BlockID: blockID, Elements: elements}
+}
+
+func button(text, actionID, value string) Button {
+	return Button{Type: "button", Text: plainText(truncate(text, maxButtonText)), ActionID: actionID, Value: value}
+}
+
+func plainText(text string) TextObject {
+	return TextObject{Type: "plain_text", Text: text, Emoji: true}
+}
+
+// PerishableDays is how many days before expiring a pantry ingredient is highlighted as perishable.
+const PerishableDays = 3
+
+// PlanReport is a meal plan with what it takes from the pantry, as RenderPlan shows it.
+type PlanReport struct {
+	Plan pantryagent.MealPlan
+	// Problems make the plan infeasible besides the shopping list, e.g. unknown recipes or the
+	// feasibility problems a coordinator reported.
+	Problems []string
+	// Perishables are the pantry ingredients the plan uses that expire within PerishableDays, soonest
+	// first.
+	Perishables []PerishableUse
+	// Shopping is what the pantry lacks for the plan, in the order the plan needs it.
+	Shopping []ShoppingItem
+	// Uses is what the plan takes from the pantry, at most what it holds, in the order the plan needs
+	// it; accepting the plan consumes it.
+	Uses []IngredientUse
+}
+
+// Feasible reports whether the plan can be cooked from the pantry as it is.
+func (r PlanReport) Feasible() bool {
+	return len(r.Problems) == 0 && len(r.Shopping) == 0
+}
+
+// PerishableUse is a perishable pantry ingredient a plan uses.
+type PerishableUse struct {
+	Name string
+	// Qty is what the plan uses, at most what the pantry holds.
+	Qty      float64
+	Unit     string
+	DaysLeft int
+	// Expires is the earliest expiry date of the ingredient, if tracked with dates.
+	Expires tools.Date
+	// Meals are the ids of the meals using it.
+	Meals []string
+}
+
+// ShoppingItem is what to buy of an ingredient.
+type ShoppingItem struct {
+	Name string
+	Qty  float64
+	Unit string
+}
+
+// IngredientUse is how much of a pantry ingredient a plan uses.
+type IngredientUse struct {
+	Name string
+	Qty  float64
+	Unit string
+}
+
+// NewPlanReport checks plan against the recipe catalog and the pantry as of the end of today, in the
+// local time zone: the recipes it names must exist, and what their ingredients need, scaled to the
+// servings of each meal, is taken from the pantry, with the shortfall going to the shopping list.
+// Quantities are only compared in the same unit.
+func NewPlanReport(plan pantryagent.MealPlan, recipes []tools.Recipe, pantry *tools.Pantry, today tools.Date) (PlanReport, error) {
+	current, err := pantry.AsOf(today.EndIn(time.Local))
+	if err != nil {
+		return PlanReport{}, err
+	}
+
+	type lot struct {
+		qty      float64
+		daysLeft int
+		expires  tools.Date
+	}
+	stock := map[string]map[string]*lot{} // by lower-case name and unit
+	for _, ing := range current.Ingredients {
+		name := normalize(ing.Name)
+		if stock[name] == nil {
+			stock[name] = map[string]*lot{}
+		}
+		l, ok := stock[name][ing.Unit]
+		if !ok {
+			l = &lot{daysLeft: tools.NonPerishableDaysLeft}
+			stock[name][ing.Unit] = l
+		}
+		l.qty += ing.Qty
+		if d := ing.DaysLeftOn(today); d < l.daysLeft {
+			l.daysLeft, l.expires = d, ing.Expires
+		}
+	}
+
+	type need struct {
+		name, unit string
+		qty        float64
+		meals      []string
+	}
+	var (
+		needs []*need
+		index = map[string]*need{}
+	)
+	report := PlanReport{Plan: plan}
+	byID := make(map[string]tools.Recipe, len(recipes))
+	for _, r := range recipes {
+		byID[r.ID] = r
+	}
+	for _, day := range plan.DaysPlanned {
+		for _, meal := range day.Meals {
+			recipe, ok := byID[meal.ID]
+			if !ok {
+				report.Problems = append(report.Problems, fmt.Sprintf("day %d: unknown recipe %q", day.Day, meal.ID))
+				continue
+			}
+			if recipe.Servings <= 0 {
+				continue
+			}
+			scale := float64(meal.Servings) / float64(recipe.Servings)
+			for _, ing := range recipe.Ingredients {
+				key := normalize(ing.Name) + "\x00" + ing.Unit
+				n, ok := index[key]
+				if !ok {
+					n = &need{name: normalize(ing.Name), unit: ing.Unit}
+					index[key] = n
+					needs = append(needs, n)
+				}
+				n.qty += ing.Qty * scale
+				if len(n.meals) == 0 || n.meals[len(n.meals)-1] != meal.ID {
+					n.meals = append(n.meals, meal.ID)
+				}
+			}
+		}
+	}
+
+	const eps = 1e-9
+	for _, n := range needs {
+		have, ok := stock[n.name][n.unit]
+		if !ok {
+			report.Shopping = append(report.Shopping, ShoppingItem{Name: n.name, Qty: n.qty, Unit: n.unit})
+			continue
+		}
+		if n.qty > have.qty+eps {
+			report.Shopping = append(report.Shopping, ShoppingItem{Name: n.name, Qty: n.qty - have.qty, Unit: n.unit})
+		}
+		if used := min(n.qty, have.qty); used > eps {
+			report.Uses = append(report.Uses, IngredientUse{Name: n.name, Qty: used, Unit: n.unit})
+		}
+		if have.daysLeft <= PerishableDays {
+			report.Perishables = append(report.Perishables, PerishableUse{
+				Name:     n.name,
+				Qty:      min(n.qty, have.qty),
+				Unit:     n.unit,
+				DaysLeft: have.daysLeft,
+				Expires:  have.expires,
+				Meals:    n.meals,
+			})
+		}
+	}
+	slices.SortStableFunc(report.Perishables, func(a, b PerishableUse) int { return cmp.Compare(a.DaysLeft, b.DaysLeft) })
+	return report, nil
+}
+
+// RenderPlan renders a plan report as a message: a Block Kit layout with a section per day, the
+// servings of each meal and the perishables it uses, followed by the perishables, shopping list and
+// problems; and a plain-text fallback with the same content for notifications and clients without
+// blocks.
+func RenderPlan(r PlanReport) Message {
+	return renderPlan(r, "")
+}
+
+// RenderPlanActions renders a plan report like RenderPlan, with buttons acting on the plan with the given
+// id: a "Swap" button per meal and "Regenerate day" after each day, and "Accept" at the end. Handler
+// answers them.
+func RenderPlanActions(r PlanReport, id string) Message {
+	return renderPlan(r, id)
+}
+
+// renderPlan renders r, with the buttons of the plan planID unless it is empty.
+func renderPlan(r PlanReport, planID string) Message {
+	var (
+		blocks []Block
+		text   strings.Builder
+	)
+	blocks = append(blocks, header("Meal plan"))
+	text.WriteString("Meal plan")
+	if r.Plan.Summary != "" {
+		blocks = append(blocks, section(escape(r.Plan.Summary)))
+		fmt.Fprintf(&text, ": %s", r.Plan.Summary)
+	}
+	text.WriteString("\n")
+	if r.Feasible() {
+		blocks = append(blocks, contextBlock(":white_check_mark: Everything is in the pantry"))
+	} else {
+		blocks = append(blocks, contextBlock(":warning: "+feasibility(r)))
+		fmt.Fprintf(&text, "%s\n", feasibility(r))
+	}
+	blocks = append(blocks, divider())
+
+	usedBy := map[string][]PerishableUse{}
+	for _, p := range r.Perishables {
+		for _, id := range p.Meals {
+			usedBy[id] = append(usedBy[id], p)
+		}
+	}
+	// Leave room for the note on the days left out, the blocks after the days and a note Handler adds
+	// when it updates the message
+	perDay, after := 1, 5
+	if planID != "" {
+		perDay, after = 2, 6
+	}
+	maxDays := (maxBlocks - len(blocks) - 1 - after) / perDay
+	for i, day := range r.Plan.DaysPlanned {
+		if i == maxDays {
+			blocks = append(blocks, contextBlock(fmt.Sprintf("… and %d more days", len(r.Plan.DaysPlanned)-i)))
+		}
+		var lines []string
+		for _, meal := range day.Meals {
+			line := fmt.Sprintf("• *%s* · %s", escape(meal.Name), servings(meal.Servings))
+			fmt.Fprintf(&text, "Day %d: %s (%s)", day.Day, meal.Name, servings(meal.Servings))
+			if uses := usedBy[meal.ID]; len(uses) > 0 {
+				highlights := make([]string, len(uses))
+				names := make([]string, len(uses))
+				for j, p := range uses {
+					highlights[j] = fmt.Sprintf("%s %s (%s)", expiryEmoji(p.DaysLeft), escape(p.Name), expiry(p.DaysLeft))
+					names[j] = fmt.Sprintf("%s (%s)", p.Name, expiry(p.DaysLeft))
+				}
+				line += "\n    uses " + strings.Join(highlights, ", ")
+				fmt.Fprintf(&text, ", uses %s", strings.Join(names, ", "))
+			}
+			lines = append(lines, line)
+			text.WriteString("\n")
+		}
+		if i < maxDays {
+			blocks = append(blocks, section(fmt.Sprintf("*Day %d*\n%s", day.Day, strings.Join(lines, "\n"))))
+			if planID != "" {
+				blocks = append(blocks, dayActions(planID, day))
+			}
+		}
+	}
+
+	if len(r.Perishables) > 0 {
+		lines := make([]string, len(r.Perishables))
+		names := make([]string, len(r.Perishables))
+		for i, p := range r.Perishables {
+			expires := expiry(p.DaysLeft)
+			if !p.Expires.IsZero() {
+				expires += ", " + p.Expires.String()
+			}
+			lines[i] = fmt.Sprintf("%s %s · %g %s · %s", expiryEmoji(p.DaysLeft), escape(p.Name), p.Qty, escape(p.Unit), expires)
+			names[i] = fmt.Sprintf("%s (%s)", p.Name, expiry(p.DaysLeft))
+		}
+		blocks = append(blocks, divider(), section("*Use soon*\n"+strings.Join(lines, "\n")))
+		fmt.Fprintf(&text, "Use soon: %s\n", strings.Join(names, ", "))
+	}
+	if len(r.Shopping) > 0 {
+		lines := make([]string, len(r.Shopping))
+		items := make([]string, len(r.Shopping))
+		for i, item := range r.Shopping {
+			lines[i] = fmt.Sprintf("• %s · %g %s", escape(item.Name), item.Qty, escape(item.Unit))
+			items[i] = fmt.Sprintf("%s %g %s", item.Name, item.Qty, item.Unit)
+		}
+		blocks = append(blocks, section(":shopping_trolley: *Shopping list*\n"+strings.Join(lines, "\n")))
+		fmt.Fprintf(&text, "Shopping list: %s\n", strings.Join(items, ", "))
+	}
+	if len(r.Problems) > 0 {
+		lines := make([]string, len(r.Problems))
+		for i, p := range r.Problems {
+			lines[i] = "• " + escape(p)
+		}
+		blocks = append(blocks, section(":x: *Problems*\n"+strings.Join(lines, "\n")))
+		fmt.Fprintf(&text, "Problems: %s\n", strings.Join(r.Problems, "; "))
+	}
+	if planID != "" {
+		accept := button("Accept", ActionAccept, planID)
+		accept.Style = "primary"
+		accept.Confirm = &Dialog{
+			Title:   plainText("Accept the plan?"),
+			Text:    plainText("What the meals use is taken from the pantry."),
+			Confirm: plainText("Accept"),
+			Deny:    plainText("Cancel"),
+		}
+		blocks = append(blocks, actions("plan", accept))
+	}
+
+	return Message{Text: truncate(strings.TrimSuffix(text.String(), "\n"), maxFallbackText), Blocks: blocks}
+}
+
+// dayActions returns the buttons of a day of the plan id: one swapping each meal, and one regenerating
+// the day.
+func dayActions(id string, day pantryagent.DayPlan) Block {
+	var elements []any
+	for i, meal := range day.Meals {
+		if len(elements) == maxActions-1 {
+			break
+		}
+		// Action IDs must be unique within their block
+		elements = append(elements, button("Swap "+meal.Name, fmt.Sprintf("%s.%d", ActionSwap, i), actionValue{Plan: id, Day: day.Day, Meal: i}.String()))
+	}
+	elements = append(elements, button("Regenerate day", ActionRegenerateDay, actionValue{Plan: id, Day: day.Day, Meal: -1}.String()))
+	return actions(fmt.Sprintf("day_%d", day.Day), elements...)
+}
+
+func feasibility(r PlanReport) string {
+	var parts []string
+	if n := len(r.Shopping); n > 0 {
+		parts = append(parts, plural(n, "ingredient")+" to buy")
+	}
+	if n := len(r.Problems); n > 0 {
+		parts = append(parts, plural(n, "problem"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func servings(n int) string {
+	return plural(n, "serving")
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func expiry(daysLeft int) string {
+	switch {
+	case daysLeft < 0:
+		return "expired"
+	case daysLeft == 0:
+		return "expires today"
+	case daysLeft == 1:
+		return "expires tomorrow"
+	default:
+		return fmt.Sprintf("expires in %d days", daysLeft)
+	}
+}
+
+func expiryEmoji(daysLeft int) string {
+	if daysLeft <= 1 {
+		return ":rotating_light:"
+	}
+	return ":hourglass_flowing_sand:"
+}
+
+// escape escapes the characters Slack's mrkdwn treats as control characters.
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + truncationMarker
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,168 @@
+package slack_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"pantryagent"
+	"pantryagent/slack"
+	"pantryagent/tools"
+
+	should "github.com/stretchr/testify/assert"
+	must "github.com/stretchr/testify/require"
+)
+
+// testCatalog returns recipes, a pantry and the day to report plans on.
+func testCatalog(t *testing.T) ([]tools.Recipe, *tools.Pantry, tools.Date) {
+	t.Helper()
+	today, err := tools.ParseDate("2026-10-18")
+	must.NoError(t, err)
+
+	recipes := []tools.Recipe{
+		{ID: "tacos", Name: "Tacos", MealTypes: []string{"dinner"}, Servings: 2, Ingredients: []tools.RecipeIngredient{
+			{Name: "tortilla", Qty: 4, Unit: "count"},
+			{Name: "Spinach", Qty: 100, Unit: "g"},
+			{Name: "cheese", Qty: 50, Unit: "g"},
+		}},
+		{ID: "soup", Name: "Spinach Soup", MealTypes: []string{"dinner"}, Servings: 4, Ingredients: []tools.RecipeIngredient{
+			{Name: "spinach", Qty: 200, Unit: "g"},
+			{Name: "stock", Qty: 1, Unit: "L"},
+		}},
+		{ID: "salad", Name: "Spinach Salad", MealTypes: []string{"dinner"}, Servings: 1, Ingredients: []tools.RecipeIngredient{
+			{Name: "spinach", Qty: 50, Unit: "g"},
+		}},
+	}
+	pantry := &tools.Pantry{Ingredients: []tools.Ingredient{
+		{Name: "tortilla", Qty: 8, Unit: "count", NonPerishable: true},
+		{Name: "spinach", Qty: 250, Unit: "g", Expires: today.AddDays(2)},
+		{Name: "cheese", Qty: 20, Unit: "g", Expires: today.AddDays(10)},
+	}}
+	return recipes, pantry, today
+}
+
+func testReport(t *testing.T) slack.PlanReport {
+	t.Helper()
+	recipes, pantry, today := testCatalog(t)
+	plan := pantryagent.MealPlan{
+		Summary: "Spinach first & cheap",
+		DaysPlanned: []pantryagent.DayPlan{
+			{Day: 1, Meals: []pantryagent.Meal{{ID: "tacos", Name: "Tacos", Servings: 2}}},
+			{Day: 2, Meals: []pantryagent.Meal{{ID: "soup", Name: "Spinach Soup", Servings: 2}}},
+			{Day: 3, Meals: []pantryagent.Meal{{ID: "pizza", Name: "Pizza", Servings: 2}}},
+		},
+	}
+
+	report, err := slack.NewPlanReport(plan, recipes, pantry, today)
+	must.NoError(t, err)
+	return report
+}
+
+func TestNewPlanReport(t *testing.T) {
+	report := testReport(t)
+
+	should.False(t, report.Feasible())
+	should.Equal(t, []string{`day 3: unknown recipe "pizza"`}, report.Problems)
+	should.Equal(t, []slack.ShoppingItem{
+		{Name: "cheese", Qty: 30, Unit: "g"},
+		{Name: "stock", Qty: 0.5, Unit: "L"},
+	}, report.Shopping)
+	must.Len(t, report.Perishables, 1)
+	spinach := report.Perishables[0]
+	should.Equal(t, "spinach", spinach.Name)
+	should.Equal(t, 200.0, spinach.Qty)
+	should.Equal(t, 2, spinach.DaysLeft)
+	should.Equal(t, "2026-10-20", spinach.Expires.String())
+	should.Equal(t, []string{"tacos", "soup"}, spinach.Meals)
+	should.Equal(t, []slack.IngredientUse{
+		{Name: "tortilla", Qty: 4, Unit: "count"},
+		{Name: "spinach", Qty: 200, Unit: "g"},
+		{Name: "cheese", Qty: 20, Unit: "g"},
+	}, report.Uses)
+}
+
+func TestRenderPlan(t *testing.T) {
+	msg := slack.RenderPlan(testReport(t))
+
+	should.Equal(t, strings.Join([]string{
+		"Meal plan: Spinach first & cheap",
+		"2 ingredients to buy, 1 problem",
+		"Day 1: Tacos (2 servings), uses spinach (expires in 2 days)",
+		"Day 2: Spinach Soup (2 servings), uses spinach (expires in 2 days)",
+		"Day 3: Pizza (2 servings)",
+		"Use soon: spinach (expires in 2 days)",
+		"Shopping list: cheese 30 g, stock 0.5 L",
+		`Problems: day 3: unknown recipe "pizza"`,
+	}, "\n"), msg.Text)
+
+	var types []string
+	for _, b := range msg.Blocks {
+		types = append(types, b.Type)
+	}
+	should.Equal(t, []string{"header", "section", "context", "divider", "section", "section", "section", "divider", "section", "section", "section"}, types)
+	should.Equal(t, "Spinach first &amp; cheap", msg.Blocks[1].Text.Text, "escapes mrkdwn")
+	should.Equal(t, "*Day 1*\n• *Tacos* · 2 servings\n    uses :hourglass_flowing_sand: spinach (expires in 2 days)", msg.Blocks[4].Text.Text)
+	should.Equal(t, "*Use soon*\n:hourglass_flowing_sand: spinach · 200 g · expires in 2 days, 2026-10-20", msg.Blocks[8].Text.Text)
+
+	b, err := json.Marshal(msg.Blocks[2])
+	must.NoError(t, err)
+	should.JSONEq(t, `{"type": "context", "elements": [{"type": "mrkdwn", "text": ":warning: 2 ingredients to buy, 1 problem"}]}`, string(b))
+}
+
+func TestRenderPlan_Feasible(t *testing.T) {
+	msg := slack.RenderPlan(slack.PlanReport{Plan: pantryagent.MealPlan{
+		DaysPlanned: []pantryagent.DayPlan{{Day: 1, Meals: []pantryagent.Meal{{ID: "tacos", Name: "Tacos", Servings: 1}}}},
+	}})
+
+	should.Equal(t, "Meal plan\nDay 1: Tacos (1 serving)", msg.Text)
+	must.Len(t, msg.Blocks, 4)
+	should.Equal(t, ":white_check_mark: Everything is in the pantry", msg.Blocks[1].Elements[0].(slack.TextObject).Text)
+}
+
+func TestRenderPlanActions(t *testing.T) {
+	report := testReport(t)
+	msg := slack.RenderPlanActions(report, "p1")
+
+	should.Equal(t, slack.RenderPlan(report).Text, msg.Text)
+	var types []string
+	for _, b := range msg.Blocks {
+		types = append(types, b.Type)
+	}
+	should.Equal(t, []string{
+		"header", "section", "context", "divider",
+		"section", "actions", "section", "actions", "section", "actions",
+		"divider", "section", "section", "section", "actions",
+	}, types)
+
+	b, err := json.Marshal(msg.Blocks[5])
+	must.NoError(t, err)
+	should.JSONEq(t, `{"type": "actions", "block_id": "day_1", "elements": [
+		{"type": "button", "text": {"type": "plain_text", "text": "Swap Tacos", "emoji": true}, "action_id": "swap.0", "value": "p1:1:0"},
+		{"type": "button", "text": {"type": "plain_text", "text": "Regenerate day", "emoji": true}, "action_id": "regenerate_day", "value": "p1:1:-1"}
+	]}`, string(b))
+
+	accept := msg.Blocks[len(msg.Blocks)-1].Elements[0].(slack.Button)
+	should.Equal(t, slack.ActionAccept, accept.ActionID)
+	should.Equal(t, "p1", accept.Value)
+	should.Equal(t, "primary", accept.Style)
+	should.NotNil(t, accept.Confirm, "asks before changing the pantry")
+}
+
+func TestRenderPlan_ManyDays(t *testing.T) {
+	var plan pantryagent.MealPlan
+	for day := 1; day <= 60; day++ {
+		plan.DaysPlanned = append(plan.DaysPlanned, pantryagent.DayPlan{Day: day, Meals: []pantryagent.Meal{{ID: "tacos", Name: "Tacos", Servings: 2}}})
+	}
+	report := slack.PlanReport{
+		Plan:        plan,
+		Problems:    []string{"too long"},
+		Shopping:    []slack.ShoppingItem{{Name: "stock", Qty: 1, Unit: "L"}},
+		Perishables: []slack.PerishableUse{{Name: "spinach", Qty: 1, Unit: "g", DaysLeft: 1, Meals: []string{"tacos"}}},
+	}
+
+	for name, msg := range map[string]slack.Message{"plain": slack.RenderPlan(report), "actions": slack.RenderPlanActions(report, "p1")} {
+		should.Less(t, len(msg.Blocks), 50, "%s: Slack rejects messages with more than 50 blocks, and Handler adds one", name)
+		should.Contains(t, msg.Text, "Day 60: Tacos", name)
+		should.Equal(t, "Problems: too long", msg.Text[strings.LastIndex(msg.Text, "\n")+1:], name)
+	}
+}
@@ -0,0 +1,383 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pantryagent"
+)
+
+const (
+	// maxSignatureAge is how old a signed request may be, to refuse replayed ones.
+	maxSignatureAge = 5 * time.Minute
+	// defaultPlanTimeout bounds a plan requested from Slack; response URLs expire after 30 minutes.
+	defaultPlanTimeout = 10 * time.Minute
+	defaultMaxPlans    = 100
+	maxRequestBytes    = 1 << 20
+)
+
+// Planner is what a Handler plans with.
+type Planner interface {
+	// Plan runs a plan for task and returns the meal plan, or nil and the output of the run if it holds
+	// none.
+	Plan(ctx context.Context, task string) (*pantryagent.MealPlan, string, error)
+	// Report checks plan against the recipe catalog and the current pantry, see NewPlanReport.
+	Report(ctx context.Context, plan pantryagent.MealPlan) (PlanReport, error)
+	// Accept applies an accepted plan to the pantry, consuming what it uses; user is who accepted it.
+	Accept(ctx context.Context, report PlanReport, user string) error
+}
+
+// HandlerOptions configures a Handler.
+type HandlerOptions struct {
+	// SigningSecret verifies that requests come from Slack; see the app's Basic Information page.
+	SigningSecret string
+	Planner       Planner
+	// DefaultTask is planned for "plan" without a task.
+	DefaultTask string
+	// Client replies to app mentions in their thread; without it, mentions are ignored.
+	Client *WebAPIClient
+	// HTTPClient posts slash command results to their response URL (http.DefaultClient if nil).
+	HTTPClient doer
+	// PlanTimeout bounds each plan (10 minutes if not positive).
+	PlanTimeout time.Duration
+	// MaxPlans is how many posted plans are kept in memory for their buttons (100 if not positive); the
+	// buttons of older plans answer that they expired.
+	MaxPlans int
+}
+
+// Handler serves a Slack app's slash command ("/pantry plan 3 dinners for 2") and Events API
+// subscription (app mentions). It acknowledges requests right away, as Slack wants an answer within 3
+// seconds, and runs their plans in the background: slash commands get the result through their
+// response URL, mentions a reply in their thread. Plans are posted with buttons to accept them, which
+// applies them to the pantry, or to swap a meal or regenerate a day; see handleInteraction.
+type Handler struct {
+	opts  HandlerOptions
+	now   func() time.Time
+	plans *planStore
+
+	// ctx is the parent of every plan; it is cancelled when Shutdown times out.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	closing bool
+	wg      sync.WaitGroup
+}
+
+// NewHandler returns a handler with opts.
+func NewHandler(opts HandlerOptions) *Handler {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.PlanTimeout <= 0 {
+		opts.PlanTimeout = defaultPlanTimeout
+	}
+	if opts.MaxPlans <= 0 {
+		opts.MaxPlans = defaultMaxPlans
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Handler{opts: opts, now: time.Now, plans: newPlanStore(opts.MaxPlans), ctx: ctx, cancel: cancel}
+}
+
+// Register adds the handler's routes to mux: POST /slack/commands, the slash command's request URL,
+// POST /slack/events, the Events API request URL, and POST /slack/interactions, the interactivity
+// request URL.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /slack/commands", h.verified(h.handleCommand))
+	mux.HandleFunc("POST /slack/events", h.verified(h.handleEvent))
+	mux.HandleFunc("POST /slack/interactions", h.verified(h.handleInteraction))
+}
+
+// Shutdown stops starting plans and waits for those in progress to post their results. If ctx ends
+// first, the remaining plans are cancelled and Shutdown returns ctx's error once they have stopped.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		h.cancel()
+		return nil
+	case <-ctx.Done():
+		h.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// verified passes requests with a valid Slack signature to next, with their body, and answers others
+// 401.
+func (h *Handler) verified(next func(w http.ResponseWriter, r *http.Request, body []byte)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+		if err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		if err := h.verify(r.Header, body); err != nil {
+			slog.Warn("SLACK: Rejected request", "path", r.URL.Path, "error", err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		next(w, r, body)
+	}
+}
+
+// verify checks the signature Slack computes over the timestamp and body of a request, see
+// https://api.slack.com/authentication/verifying-requests-from-slack.
+func (h *Handler) verify(header http.Header, body []byte) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid timestamp")
+	}
+	if age := h.now().Sub(time.Unix(seconds, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+		return fmt.Errorf("timestamp is %s off", age.Round(time.Second))
+	}
+	want := Sign(h.opts.SigningSecret, ts, body)
+	if !hmac.Equal([]byte(header.Get("X-Slack-Signature")), []byte(want)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// Sign returns the X-Slack-Signature of a request body sent at timestamp ts (Unix seconds), e.g. to
+// send signed requests in tests.
+func Sign(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", ts)
+	mac.Write(body) // nolint: errcheck
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// responseMessage is the response to a slash command, or a message posted to a response URL.
+type responseMessage struct {
+	// ResponseType is "ephemeral" (only the user sees it) or "in_channel".
+	ResponseType string  `json:"response_type,omitempty"`
+	Text         string  `json:"text"`
+	Blocks       []Block `json:"blocks,omitempty"`
+	// ReplaceOriginal updates the message whose button sent the response URL.
+	ReplaceOriginal bool `json:"replace_original,omitempty"`
+}
+
+// handleCommand answers a slash command: "plan [task]" is acknowledged right away and planned in the
+// background, anything else gets the usage.
+func (h *Handler) handleCommand(w http.ResponseWriter, _ *http.Request, body []byte) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	command, text := form.Get("command"), strings.TrimSpace(form.Get("text"))
+	task, ok := h.parseTask(text)
+	if !ok {
+		writeJSON(w, responseMessage{ResponseType: "ephemeral", Text: usage(command)})
+		return
+	}
+	responseURL := form.Get("response_url")
+	if responseURL == "" {
+		http.Error(w, "missing response_url", http.StatusBadRequest)
+		return
+	}
+
+	user := form.Get("user_id")
+	started := h.start(func(ctx context.Context) {
+		slog.Info("SLACK: Planning for command", "user", user, "channel", form.Get("channel_id"), "task", task)
+		res := responseMessage{ResponseType: "in_channel"}
+		msg, err := h.plan(ctx, task)
+		if err != nil {
+			res = responseMessage{ResponseType: "ephemeral", Text: fmt.Sprintf(":x: Planning failed: %s", err)}
+		} else {
+			res.Text, res.Blocks = msg.Text, msg.Blocks
+		}
+		if err := h.respond(ctx, responseURL, res); err != nil {
+			slog.Error("SLACK: Failed to post command result", "user", user, "error", err)
+		}
+	})
+	if !started {
+		writeJSON(w, responseMessage{ResponseType: "ephemeral", Text: restarting})
+		return
+	}
+	writeJSON(w, responseMessage{ResponseType: "ephemeral", Text: fmt.Sprintf(":hourglass_flowing_sand: Planning: %s", task)})
+}
+
+// respond posts res to a response URL.
+func (h *Handler) respond(ctx context.Context, responseURL string, res responseMessage) error {
+	payload, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to post response: %s", resp.Status)
+	}
+	return nil
+}
+
+// eventEnvelope is an Events API request.
+type eventEnvelope struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	EventID   string `json:"event_id"`
+	Event     struct {
+		Type     string `json:"type"`
+		User     string `json:"user"`
+		Text     string `json:"text"`
+		Channel  string `json:"channel"`
+		TS       string `json:"ts"`
+		ThreadTS string `json:"thread_ts"`
+		BotID    string `json:"bot_id"`
+	} `json:"event"`
+}
+
+// mention matches the user mentions of a message, e.g. "<@U012AB3CD>".
+var mention = regexp.MustCompile(`<@[A-Z0-9]+(\|[^>]*)?>`)
+
+// handleEvent answers Events API requests: the URL verification challenge, and app mentions, which are
+// planned in the background and answered in their thread.
+func (h *Handler) handleEvent(w http.ResponseWriter, r *http.Request, body []byte) {
+	var env eventEnvelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		http.Error(w, "invalid event", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case env.Type == "url_verification":
+		writeJSON(w, map[string]string{"challenge": env.Challenge})
+		return
+	case env.Type != "event_callback" || env.Event.Type != "app_mention" || env.Event.BotID != "" || h.opts.Client == nil:
+		w.WriteHeader(http.StatusOK)
+		return
+	case r.Header.Get("X-Slack-Retry-Num") != "":
+		// Slack retries events it thinks went unanswered; the first delivery is being planned already
+		slog.Info("SLACK: Ignored event retry", "event", env.EventID, "retry", r.Header.Get("X-Slack-Retry-Num"))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	event := env.Event
+	thread := MessageRef{Channel: event.Channel, TS: event.TS}
+	if event.ThreadTS != "" {
+		thread.TS = event.ThreadTS
+	}
+	text := strings.TrimSpace(mention.ReplaceAllString(event.Text, ""))
+	task, ok := h.parseTask(text)
+	started := h.start(func(ctx context.Context) {
+		var msg Message
+		if !ok {
+			msg = Message{Text: usage("")}
+		} else {
+			slog.Info("SLACK: Planning for mention", "user", event.User, "channel", event.Channel, "task", task)
+			var err error
+			if msg, err = h.plan(ctx, task); err != nil {
+				msg = Message{Text: fmt.Sprintf(":x: Planning failed: %s", err)}
+			}
+		}
+		if _, err := h.opts.Client.Reply(ctx, thread, msg); err != nil {
+			slog.Error("SLACK: Failed to reply to mention", "event", env.EventID, "error", err)
+		}
+	})
+	if !started {
+		if _, err := h.opts.Client.Reply(r.Context(), thread, Message{Text: restarting}); err != nil {
+			slog.Error("SLACK: Failed to reply to mention", "event", env.EventID, "error", err)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseTask returns the task of "plan [task]", which is the whole text so that the model reads e.g.
+// "plan 3 dinners for 2", or the default task for a bare "plan".
+func (h *Handler) parseTask(text string) (string, bool) {
+	verb, rest, _ := strings.Cut(text, " ")
+	if !strings.EqualFold(verb, "plan") {
+		return "", false
+	}
+	if strings.TrimSpace(rest) == "" {
+		return h.opts.DefaultTask, h.opts.DefaultTask != ""
+	}
+	return text, true
+}
+
+func usage(command string) string {
+	if command == "" {
+		command = "/pantry"
+	}
+	return fmt.Sprintf("Usage: `%s plan [task]`, e.g. `%s plan 3 dinners for 2`.", command, command)
+}
+
+// restarting answers requests arriving while the handler shuts down.
+const restarting = "The planner is restarting, try again in a minute."
+
+// start runs fn in the background, unless the handler is shutting down.
+func (h *Handler) start(fn func(ctx context.Context)) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing {
+		return false
+	}
+	h.wg.Go(func() { fn(h.ctx) })
+	return true
+}
+
+// plan plans task and returns the plan with its buttons, or the output of the run as is if it holds no
+// plan.
+func (h *Handler) plan(ctx context.Context, task string) (Message, error) {
+	plan, output, err := h.run(ctx, task)
+	if err != nil {
+		return Message{}, err
+	}
+	if plan == nil {
+		return Message{Text: output}, nil
+	}
+	report, err := h.opts.Planner.Report(ctx, *plan)
+	if err != nil {
+		return Message{}, err
+	}
+	return RenderPlanActions(report, h.plans.add(task, *plan)), nil
+}
+
+// run runs the planner on task, within the plan timeout.
+func (h *Handler) run(ctx context.Context, task string) (*pantryagent.MealPlan, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.opts.PlanTimeout)
+	defer cancel()
+	plan, output, err := h.opts.Planner.Plan(ctx, task)
+	if err != nil {
+		slog.Error("SLACK: Planning failed", "task", task, "error", err)
+	}
+	return plan, output, err
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v) // nolint: errcheck
+}
@@ -0,0 +1,309 @@
+package slack_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pantryagent"
+	"pantryagent/slack"
+	"pantryagent/tools"
+
+	should "github.com/stretchr/testify/assert"
+	must "github.com/stretchr/testify/require"
+)
+
+const signingSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+type planFunc func(ctx context.Context, task string) (*pantryagent.MealPlan, string, error)
+
+// fakePlanner plans with a function and reports plans against testCatalog.
+type fakePlanner struct {
+	plan    planFunc
+	recipes []tools.Recipe
+	pantry  *tools.Pantry
+	today   tools.Date
+
+	mu        sync.Mutex
+	tasks     []string
+	accepted  []slack.PlanReport
+	acceptErr error
+}
+
+func (p *fakePlanner) Plan(ctx context.Context, task string) (*pantryagent.MealPlan, string, error) {
+	p.mu.Lock()
+	p.tasks = append(p.tasks, task)
+	p.mu.Unlock()
+	return p.plan(ctx, task)
+}
+
+func (p *fakePlanner) Report(_ context.Context, plan pantryagent.MealPlan) (slack.PlanReport, error) {
+	return slack.NewPlanReport(plan, p.recipes, p.pantry, p.today)
+}
+
+func (p *fakePlanner) Accept(_ context.Context, report slack.PlanReport, user string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.acceptErr == nil {
+		p.accepted = append(p.accepted, report)
+	}
+	return p.acceptErr
+}
+
+func (p *fakePlanner) Tasks() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.tasks)
+}
+
+// slackApp is a Handler served by httptest, with stand-ins for Slack's response URLs and Web API.
+type slackApp struct {
+	srv     *httptest.Server
+	handler *slack.Handler
+	planner *fakePlanner
+	api     *fakeAPI
+	// responses receives what the handler posts to response URLs.
+	responses chan map[string]any
+	responder *httptest.Server
+}
+
+func newSlackApp(t *testing.T, plan planFunc) *slackApp {
+	t.Helper()
+	app := &slackApp{responses: make(chan map[string]any, 10)}
+	app.responder = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		app.responses <- body
+	}))
+	t.Cleanup(app.responder.Close)
+
+	var client *slack.WebAPIClient
+	app.api, client = newFakeAPI(t, func(_ int, w http.ResponseWriter, call apiCall) { ok(w, call) })
+	if plan == nil {
+		plan = func(_ context.Context, task string) (*pantryagent.MealPlan, string, error) {
+			return nil, "Planned: " + task, nil
+		}
+	}
+	app.planner = &fakePlanner{plan: plan}
+	app.planner.recipes, app.planner.pantry, app.planner.today = testCatalog(t)
+	app.handler = slack.NewHandler(slack.HandlerOptions{
+		SigningSecret: signingSecret,
+		Planner:       app.planner,
+		DefaultTask:   "plan the week",
+		Client:        client,
+		HTTPClient:    app.responder.Client(),
+	})
+	mux := http.NewServeMux()
+	app.handler.Register(mux)
+	app.srv = httptest.NewServer(mux)
+	t.Cleanup(app.srv.Close)
+	return app
+}
+
+// send posts body to path signed as Slack would, with extra headers.
+func (a *slackApp) send(t *testing.T, path, contentType, body string, header ...string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, a.srv.URL+path, strings.NewReader(body))
+	must.NoError(t, err)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", slack.Sign(signingSecret, ts, []byte(body)))
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := a.srv.Client().Do(req)
+	must.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	must.NoError(t, err)
+	return resp, string(b)
+}
+
+func (a *slackApp) command(t *testing.T, text string) (*http.Response, string) {
+	t.Helper()
+	form := url.Values{
+		"command":      {"/pantry"},
+		"text":         {text},
+		"user_id":      {"U123"},
+		"channel_id":   {"C123"},
+		"response_url": {a.responder.URL + "/commands/T1/1/abc"},
+	}
+	return a.send(t, "/slack/commands", "application/x-www-form-urlencoded", form.Encode())
+}
+
+func (a *slackApp) response(t *testing.T) map[string]any {
+	t.Helper()
+	select {
+	case body := <-a.responses:
+		return body
+	case <-time.After(5 * time.Second):
+		t.Fatal("no response posted to response_url")
+		return nil
+	}
+}
+
+func TestHandler_Command(t *testing.T) {
+	app := newSlackApp(t, nil)
+
+	resp, body := app.command(t, "plan 3 dinners for 2")
+	must.Equal(t, http.StatusOK, resp.StatusCode)
+	should.JSONEq(t, `{"response_type": "ephemeral", "text": ":hourglass_flowing_sand: Planning: plan 3 dinners for 2"}`, body)
+
+	should.Equal(t, map[string]any{"response_type": "in_channel", "text": "Planned: plan 3 dinners for 2"}, app.response(t))
+	should.Equal(t, []string{"plan 3 dinners for 2"}, app.planner.Tasks())
+}
+
+func TestHandler_CommandDefaultTask(t *testing.T) {
+	app := newSlackApp(t, nil)
+
+	_, body := app.command(t, "  plan ")
+	should.Contains(t, body, "Planning: plan the week")
+	should.Equal(t, "Planned: plan the week", app.response(t)["text"])
+}
+
+func TestHandler_CommandUsage(t *testing.T) {
+	app := newSlackApp(t, nil)
+
+	for _, text := range []string{"", "help", "cook dinner"} {
+		resp, body := app.command(t, text)
+		must.Equal(t, http.StatusOK, resp.StatusCode)
+		should.JSONEq(t, "{\"response_type\": \"ephemeral\", \"text\": \"Usage: `/pantry plan [task]`, e.g. `/pantry plan 3 dinners for 2`.\"}", body, text)
+	}
+	should.Empty(t, app.planner.Tasks())
+}
+
+func TestHandler_CommandPlanFails(t *testing.T) {
+	app := newSlackApp(t, func(context.Context, string) (*pantryagent.MealPlan, string, error) {
+		return nil, "", errors.New("model unavailable")
+	})
+
+	app.command(t, "plan dinner")
+	should.Equal(t, map[string]any{"response_type": "ephemeral", "text": ":x: Planning failed: model unavailable"}, app.response(t))
+}
+
+func TestHandler_AcknowledgesBeforePlanning(t *testing.T) {
+	release := make(chan struct{})
+	app := newSlackApp(t, func(context.Context, string) (*pantryagent.MealPlan, string, error) {
+		<-release
+		return nil, "done", nil
+	})
+
+	started := time.Now()
+	resp, _ := app.command(t, "plan dinner")
+	should.Equal(t, http.StatusOK, resp.StatusCode)
+	should.Less(t, time.Since(started), 3*time.Second, "Slack wants an answer within 3 seconds")
+
+	close(release)
+	should.Equal(t, "done", app.response(t)["text"])
+}
+
+func TestHandler_RejectsUnsigned(t *testing.T) {
+	app := newSlackApp(t, nil)
+	form := url.Values{"text": {"plan dinner"}, "response_url": {app.responder.URL}}.Encode()
+
+	t.Run("bad signature", func(t *testing.T) {
+		resp, _ := app.send(t, "/slack/commands", "application/x-www-form-urlencoded", form, "X-Slack-Signature", "v0=deadbeef")
+		should.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+	t.Run("stale timestamp", func(t *testing.T) {
+		ts := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+		resp, _ := app.send(t, "/slack/commands", "application/x-www-form-urlencoded", form,
+			"X-Slack-Request-Timestamp", ts, "X-Slack-Signature", slack.Sign(signingSecret, ts, []byte(form)))
+		should.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+	t.Run("no signature", func(t *testing.T) {
+		resp, err := app.srv.Client().Post(app.srv.URL+"/slack/events", "application/json", strings.NewReader(`{"type": "url_verification"}`))
+		must.NoError(t, err)
+		resp.Body.Close()
+		should.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+	should.Empty(t, app.planner.Tasks())
+}
+
+func TestHandler_URLVerification(t *testing.T) {
+	app := newSlackApp(t, nil)
+
+	resp, body := app.send(t, "/slack/events", "application/json", `{"type": "url_verification", "token": "x", "challenge": "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`)
+	must.Equal(t, http.StatusOK, resp.StatusCode)
+	should.JSONEq(t, `{"challenge": "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`, body)
+}
+
+func mentionEvent(text, threadTS string) string {
+	b, _ := json.Marshal(map[string]any{
+		"type":     "event_callback",
+		"event_id": "Ev123",
+		"event": map[string]any{
+			"type": "app_mention", "user": "U123", "text": text, "channel": "C123",
+			"ts": "1700000000.000200", "thread_ts": threadTS,
+		},
+	})
+	return string(b)
+}
+
+func TestHandler_AppMention(t *testing.T) {
+	app := newSlackApp(t, nil)
+
+	resp, _ := app.send(t, "/slack/events", "application/json", mentionEvent("<@U0PANTRY> plan 2 lunches", ""))
+	must.Equal(t, http.StatusOK, resp.StatusCode)
+	must.NoError(t, app.handler.Shutdown(context.Background()), "waits for the reply")
+
+	should.Equal(t, []string{"plan 2 lunches"}, app.planner.Tasks())
+	must.Len(t, app.api.calls, 1)
+	should.Equal(t, "chat.postMessage", app.api.calls[0].Method)
+	should.Equal(t, map[string]any{"channel": "C123", "thread_ts": "1700000000.000200", "text": "Planned: plan 2 lunches"}, app.api.calls[0].Body)
+}
+
+func TestHandler_AppMentionInThread(t *testing.T) {
+	app := newSlackApp(t, nil)
+
+	app.send(t, "/slack/events", "application/json", mentionEvent("<@U0PANTRY> hello", "1700000000.000100"))
+	must.NoError(t, app.handler.Shutdown(context.Background()))
+
+	should.Empty(t, app.planner.Tasks())
+	must.Len(t, app.api.calls, 1)
+	should.Equal(t, "1700000000.000100", app.api.calls[0].Body["thread_ts"], "replies in the thread")
+	should.Contains(t, app.api.calls[0].Body["text"], "Usage:")
+}
+
+func TestHandler_IgnoresRetries(t *testing.T) {
+	app := newSlackApp(t, nil)
+
+	resp, _ := app.send(t, "/slack/events", "application/json", mentionEvent("<@U0PANTRY> plan dinner", ""), "X-Slack-Retry-Num", "1")
+	should.Equal(t, http.StatusOK, resp.StatusCode)
+	must.NoError(t, app.handler.Shutdown(context.Background()))
+	should.Empty(t, app.planner.Tasks())
+	should.Empty(t, app.api.calls)
+}
+
+func TestHandler_Shutdown(t *testing.T) {
+	app := newSlackApp(t, func(ctx context.Context, _ string) (*pantryagent.MealPlan, string, error) {
+		<-ctx.Done()
+		return nil, "", ctx.Err()
+	})
+	app.command(t, "plan dinner")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	should.ErrorIs(t, app.handler.Shutdown(ctx), context.DeadlineExceeded, "cancels plans in progress")
+
+	_, body := app.command(t, "plan lunch")
+	should.Contains(t, body, "try again", "refuses new plans")
+
+	resp, _ := app.send(t, "/slack/events", "application/json", mentionEvent("<@U0PANTRY> plan lunch", ""))
+	should.Equal(t, http.StatusOK, resp.StatusCode)
+	must.Len(t, app.api.calls, 1, "tells the mention to retry")
+	should.Equal(t, "chat.postMessage", app.api.calls[0].Method)
+	should.Contains(t, app.api.calls[0].Body["text"], "try again")
+	should.Equal(t, []string{"plan dinner"}, app.planner.Tasks())
+}
@@ -0,0 +1,394 @@
+package slack
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"pantryagent"
+)
+
+// Action IDs of the buttons of a plan. The swap buttons of a day are numbered after the meal they swap,
+// e.g. "swap.0", as action IDs must be unique within a block.
+const (
+	ActionAccept        = "accept"
+	ActionSwap          = "swap"
+	ActionRegenerateDay = "regenerate_day"
+)
+
+// actionValue is the value of a plan's button: the plan, and the day and meal index it acts on (-1 for
+// the whole day).
+type actionValue struct {
+	Plan string
+	Day  int
+	Meal int
+}
+
+func (v actionValue) String() string {
+	return fmt.Sprintf("%s:%d:%d", v.Plan, v.Day, v.Meal)
+}
+
+func parseActionValue(s string) (actionValue, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		// Accept carries the plan only
+		return actionValue{Plan: s}, nil
+	}
+	day, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return actionValue{}, fmt.Errorf("invalid action value %q", s)
+	}
+	meal, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return actionValue{}, fmt.Errorf("invalid action value %q", s)
+	}
+	return actionValue{Plan: parts[0], Day: day, Meal: meal}, nil
+}
+
+var (
+	errPlanExpired  = errors.New("this plan has expired, ask for a new one")
+	errPlanBusy     = errors.New("this plan is being changed, try again when it's done")
+	errPlanAccepted = errors.New("this plan was accepted already")
+)
+
+// postedPlan is a plan posted with buttons.
+type postedPlan struct {
+	task string
+	plan pantryagent.MealPlan
+	// busy is set while a button acts on the plan, so that clicks don't race.
+	busy     bool
+	accepted bool
+}
+
+// planStore keeps the most recent posted plans in memory, evicting the oldest ones past max.
+type planStore struct {
+	mu    sync.Mutex
+	max   int
+	order []string // plan IDs, oldest first
+	plans map[string]*postedPlan
+}
+
+func newPlanStore(size int) *planStore {
+	return &planStore{max: size, plans: make(map[string]*postedPlan)}
+}
+
+// add stores plan, planned for task, and returns its ID.
+func (s *planStore) add(task string, plan pantryagent.MealPlan) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := newPlanID()
+	s.plans[id] = &postedPlan{task: task, plan: plan}
+	s.order = append(s.order, id)
+	for len(s.order) > s.max {
+		delete(s.plans, s.order[0])
+		s.order = s.order[1:]
+	}
+	return id
+}
+
+// claim marks the plan id busy and returns it, unless it expired, is busy or was accepted. The caller
+// must release it.
+func (s *planStore) claim(id string) (postedPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.plans[id]
+	switch {
+	case !ok:
+		return postedPlan{}, errPlanExpired
+	case p.accepted:
+		return postedPlan{}, errPlanAccepted
+	case p.busy:
+		return postedPlan{}, errPlanBusy
+	}
+	p.busy = true
+	return *p, nil
+}
+
+// release ends a claim on the plan id, which now is plan, accepted or not.
+func (s *planStore) release(id string, plan pantryagent.MealPlan, accepted bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.plans[id]; ok {
+		p.plan, p.accepted, p.busy = plan, accepted, false
+	}
+}
+
+func newPlanID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b) // never fails, see crypto/rand.Read
+	return hex.EncodeToString(b)
+}
+
+// interaction is the payload of an interactivity request.
+type interaction struct {
+	Type string `json:"type"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	ResponseURL string `json:"response_url"`
+	Actions     []struct {
+		ActionID string `json:"action_id"`
+		BlockID  string `json:"block_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// handleInteraction answers the buttons of a posted plan: it acknowledges the click right away and acts
+// in the background, updating the plan's message in place through the response URL. Accept applies the
+// plan to the pantry; swap and regenerate re-plan a meal or a day with the planner, keeping the change
+// only if the plan needs nothing more than before.
+func (h *Handler) handleInteraction(w http.ResponseWriter, r *http.Request, body []byte) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	var in interaction
+	if err := json.Unmarshal([]byte(form.Get("payload")), &in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if in.Type != "block_actions" || len(in.Actions) == 0 || in.ResponseURL == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	action := in.Actions[0]
+	name, _, _ := strings.Cut(action.ActionID, ".")
+	value, err := parseActionValue(action.Value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := in.User.ID
+	started := h.start(func(ctx context.Context) {
+		slog.Info("SLACK: Plan action", "action", action.ActionID, "plan", value.Plan, "day", value.Day, "user", user)
+		var err error
+		switch name {
+		case ActionAccept:
+			err = h.accept(ctx, value.Plan, user, in.ResponseURL)
+		case ActionSwap, ActionRegenerateDay:
+			err = h.replan(ctx, value, user, in.ResponseURL)
+		default:
+			err = fmt.Errorf("unknown action %q", action.ActionID)
+		}
+		if err == nil {
+			return
+		}
+		slog.Error("SLACK: Plan action failed", "action", action.ActionID, "plan", value.Plan, "error", err)
+		if err := h.respond(ctx, in.ResponseURL, responseMessage{ResponseType: "ephemeral", Text: ":x: " + capitalize(err.Error())}); err != nil {
+			slog.Error("SLACK: Failed to post action result", "user", user, "error", err)
+		}
+	})
+	if !started {
+		if err := h.respond(r.Context(), in.ResponseURL, responseMessage{ResponseType: "ephemeral", Text: restarting}); err != nil {
+			slog.Error("SLACK: Failed to post action result", "user", user, "error", err)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// accept applies the plan id to the pantry and updates its message, without buttons. It returns the
+// error to tell the user about, if any.
+func (h *Handler) accept(ctx context.Context, id, user, responseURL string) error {
+	posted, err := h.plans.claim(id)
+	if err != nil {
+		return err
+	}
+	report, err := h.opts.Planner.Report(ctx, posted.plan)
+	if err == nil {
+		err = h.opts.Planner.Accept(ctx, report, user)
+	}
+	h.plans.release(id, posted.plan, err == nil)
+	if err != nil {
+		return fmt.Errorf("failed to accept the plan: %w", err)
+	}
+
+	note := fmt.Sprintf(":white_check_mark: Accepted by <@%s>, the pantry is updated", user)
+	return h.respond(ctx, responseURL, replacement(RenderPlan(report), note))
+}
+
+// replan re-plans the meal or the day of the plan v acts on and updates its message. The new plan is
+// kept only if its recipes exist and it needs nothing the old plan didn't; otherwise the old plan is
+// shown again with the reason.
+func (h *Handler) replan(ctx context.Context, v actionValue, user, responseURL string) error {
+	posted, err := h.plans.claim(v.Plan)
+	if err != nil {
+		return err
+	}
+	msg, plan, err := h.replanMessage(ctx, posted, v, user, responseURL)
+	// Release the plan before showing it, so that its buttons work as soon as they are back
+	h.plans.release(v.Plan, plan, false)
+	if err != nil {
+		return err
+	}
+	return h.respond(ctx, responseURL, msg)
+}
+
+// replanMessage shows that the plan is being changed, re-plans it and returns the message showing the
+// result, with the plan to keep.
+func (h *Handler) replanMessage(ctx context.Context, posted postedPlan, v actionValue, user, responseURL string) (responseMessage, pantryagent.MealPlan, error) {
+	plan := posted.plan
+	what, err := slotName(plan, v)
+	if err != nil {
+		return responseMessage{}, plan, err
+	}
+	verb, done := "swap", "Swapped"
+	if v.Meal < 0 {
+		verb, done = "regenerate", "Regenerated"
+	}
+	old, err := h.opts.Planner.Report(ctx, plan)
+	if err != nil {
+		return responseMessage{}, plan, fmt.Errorf("failed to check the plan: %w", err)
+	}
+	progress := replacement(RenderPlan(old), fmt.Sprintf(":hourglass_flowing_sand: <@%s> asked to %s %s…", user, verb, what))
+	if err := h.respond(ctx, responseURL, progress); err != nil {
+		return responseMessage{}, plan, err
+	}
+
+	report, err := h.replanSlot(ctx, posted.task, plan, v, old)
+	if err != nil {
+		slog.Warn("SLACK: Kept plan", "plan", v.Plan, "day", v.Day, "meal", v.Meal, "error", err)
+		return replacement(RenderPlanActions(old, v.Plan), fmt.Sprintf(":warning: Couldn't %s %s: %s", verb, what, err)), plan, nil
+	}
+	return replacement(RenderPlanActions(report, v.Plan), fmt.Sprintf(":repeat: %s %s for <@%s>", done, what, user)), report.Plan, nil
+}
+
+// replanSlot asks the planner for a new meal or day of plan and returns the report of the plan with it,
+// provided it is as feasible as the old one.
+func (h *Handler) replanSlot(ctx context.Context, task string, plan pantryagent.MealPlan, v actionValue, old PlanReport) (PlanReport, error) {
+	planJSON, err := json.Marshal(plan)
+	if err != nil {
+		return PlanReport{}, err
+	}
+	var ask string
+	if v.Meal < 0 {
+		ask = fmt.Sprintf("Plan day %d of this meal plan again with different meals, keeping the other days as they are", v.Day)
+	} else {
+		meal := plan.DaysPlanned[dayIndex(plan, v.Day)].Meals[v.Meal]
+		ask = fmt.Sprintf("Replace %s (%s) on day %d of this meal plan with a different recipe for %s, keeping every other meal as it is",
+			meal.Name, meal.ID, v.Day, servings(meal.Servings))
+	}
+	replanned, _, err := h.run(ctx, fmt.Sprintf("%s\n\n%s, and return the whole plan:\n%s", task, ask, planJSON))
+	if err != nil {
+		return PlanReport{}, err
+	}
+	if replanned == nil {
+		return PlanReport{}, errors.New("the planner returned no plan")
+	}
+	merged, err := replaceSlot(plan, *replanned, v)
+	if err != nil {
+		return PlanReport{}, err
+	}
+	report, err := h.opts.Planner.Report(ctx, merged)
+	if err != nil {
+		return PlanReport{}, err
+	}
+	if err := asFeasible(old, report); err != nil {
+		return PlanReport{}, err
+	}
+	return report, nil
+}
+
+// replacement returns the response replacing the message of a response URL with msg, followed by a
+// note; RenderPlan leaves room for it.
+func replacement(msg Message, note string) responseMessage {
+	blocks := append(slices.Clip(msg.Blocks), contextBlock(note))
+	return responseMessage{Text: msg.Text, Blocks: blocks, ReplaceOriginal: true}
+}
+
+// slotName describes the meal or day v acts on, e.g. "Tacos on day 2" or "day 2".
+func slotName(plan pantryagent.MealPlan, v actionValue) (string, error) {
+	i := dayIndex(plan, v.Day)
+	if i < 0 {
+		return "", fmt.Errorf("the plan has no day %d", v.Day)
+	}
+	if v.Meal < 0 {
+		return fmt.Sprintf("day %d", v.Day), nil
+	}
+	if v.Meal >= len(plan.DaysPlanned[i].Meals) {
+		return "", fmt.Errorf("day %d has no meal %d", v.Day, v.Meal+1)
+	}
+	return fmt.Sprintf("%s on day %d", plan.DaysPlanned[i].Meals[v.Meal].Name, v.Day), nil
+}
+
+// replaceSlot returns plan with the meal or day v acts on taken from replanned; the rest of replanned is
+// ignored, as the planner may not have kept it as it was.
+func replaceSlot(plan, replanned pantryagent.MealPlan, v actionValue) (pantryagent.MealPlan, error) {
+	from := dayIndex(replanned, v.Day)
+	if from < 0 || len(replanned.DaysPlanned[from].Meals) == 0 {
+		return pantryagent.MealPlan{}, fmt.Errorf("the new plan has no meals on day %d", v.Day)
+	}
+	newDay := replanned.DaysPlanned[from]
+	to := dayIndex(plan, v.Day)
+
+	merged := plan
+	merged.DaysPlanned = slices.Clone(plan.DaysPlanned)
+	if v.Meal < 0 {
+		merged.DaysPlanned[to] = pantryagent.DayPlan{Day: v.Day, Meals: slices.Clone(newDay.Meals)}
+		return merged, nil
+	}
+	if v.Meal >= len(newDay.Meals) {
+		return pantryagent.MealPlan{}, fmt.Errorf("the new plan has no meal %d on day %d", v.Meal+1, v.Day)
+	}
+	meal, old := newDay.Meals[v.Meal], plan.DaysPlanned[to].Meals[v.Meal]
+	if meal.ID == old.ID {
+		return pantryagent.MealPlan{}, fmt.Errorf("the planner kept %s", old.Name)
+	}
+	meals := slices.Clone(plan.DaysPlanned[to].Meals)
+	meals[v.Meal] = meal
+	merged.DaysPlanned[to].Meals = meals
+	return merged, nil
+}
+
+// asFeasible checks that a re-planned plan is as feasible as the old one: it has no new problems, such as
+// unknown recipes, and needs nothing more from the shops.
+func asFeasible(old, replanned PlanReport) error {
+	for _, p := range replanned.Problems {
+		if !slices.Contains(old.Problems, p) {
+			return errors.New(p)
+		}
+	}
+	const eps = 1e-9
+	for _, item := range replanned.Shopping {
+		before := 0.0
+		for _, o := range old.Shopping {
+			if o.Name == item.Name && o.Unit == item.Unit {
+				before = o.Qty
+			}
+		}
+		if item.Qty > before+eps {
+			return fmt.Errorf("it needs %g %s more %s than the pantry has", item.Qty-before, item.Unit, item.Name)
+		}
+	}
+	return nil
+}
+
+func dayIndex(plan pantryagent.MealPlan, day int) int {
+	for i, d := range plan.DaysPlanned {
+		if d.Day == day {
+			return i
+		}
+	}
+	return -1
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
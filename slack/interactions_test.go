@@ -0,0 +1,238 @@
+package slack_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"pantryagent"
+	"pantryagent/slack"
+
+	should "github.com/stretchr/testify/assert"
+	must "github.com/stretchr/testify/require"
+)
+
+func tacosPlan() *pantryagent.MealPlan {
+	return &pantryagent.MealPlan{Summary: "Tacos night", DaysPlanned: []pantryagent.DayPlan{
+		{Day: 1, Meals: []pantryagent.Meal{{ID: "tacos", Name: "Tacos", Servings: 2}}},
+	}}
+}
+
+// replanner plans tacos, and answers the swap of day 1 with the given meal.
+func replanner(swapped pantryagent.Meal) planFunc {
+	return func(_ context.Context, task string) (*pantryagent.MealPlan, string, error) {
+		if !strings.Contains(task, "return the whole plan") {
+			return tacosPlan(), "", nil
+		}
+		plan := tacosPlan()
+		plan.Summary = "Rewritten by the planner"
+		plan.DaysPlanned[0].Meals[0] = swapped
+		return plan, "", nil
+	}
+}
+
+// postedPlan posts a plan with the slash command and returns the message posted to the response URL.
+func (a *slackApp) postedPlan(t *testing.T) slack.Message {
+	t.Helper()
+	a.command(t, "plan tacos for 2")
+	return decodeMessage(t, a.response(t))
+}
+
+// decodeMessage decodes a message posted to a response URL, with its buttons and text elements typed.
+func decodeMessage(t *testing.T, body map[string]any) slack.Message {
+	t.Helper()
+	b, err := json.Marshal(body)
+	must.NoError(t, err)
+	var msg struct {
+		slack.Message
+		Blocks []json.RawMessage `json:"blocks"`
+	}
+	must.NoError(t, json.Unmarshal(b, &msg))
+	msg.Message.Blocks = nil
+	for _, raw := range msg.Blocks {
+		var block struct {
+			slack.Block
+			Elements []json.RawMessage `json:"elements"`
+		}
+		must.NoError(t, json.Unmarshal(raw, &block))
+		for _, e := range block.Elements {
+			var element struct{ Type string }
+			must.NoError(t, json.Unmarshal(e, &element))
+			if element.Type == "button" {
+				var button slack.Button
+				must.NoError(t, json.Unmarshal(e, &button))
+				block.Block.Elements = append(block.Block.Elements, button)
+				continue
+			}
+			var text slack.TextObject
+			must.NoError(t, json.Unmarshal(e, &text))
+			block.Block.Elements = append(block.Block.Elements, text)
+		}
+		msg.Message.Blocks = append(msg.Message.Blocks, block.Block)
+	}
+	return msg.Message
+}
+
+// button returns the button of msg with the given action ID.
+func button(t *testing.T, msg slack.Message, actionID string) slack.Button {
+	t.Helper()
+	for _, b := range msg.Blocks {
+		for _, e := range b.Elements {
+			if button, ok := e.(slack.Button); ok && button.ActionID == actionID {
+				return button
+			}
+		}
+	}
+	t.Fatalf("no button %q", actionID)
+	return slack.Button{}
+}
+
+// click sends the interaction of clicking b as user U456.
+func (a *slackApp) click(t *testing.T, b slack.Button) {
+	t.Helper()
+	payload, err := json.Marshal(map[string]any{
+		"type":         "block_actions",
+		"user":         map[string]any{"id": "U456"},
+		"response_url": a.responder.URL + "/actions/T1/2/def",
+		"actions":      []map[string]any{{"action_id": b.ActionID, "block_id": "day_1", "value": b.Value, "type": "button"}},
+	})
+	must.NoError(t, err)
+	resp, _ := a.send(t, "/slack/interactions", "application/x-www-form-urlencoded", url.Values{"payload": {string(payload)}}.Encode())
+	must.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func lastContext(msg slack.Message) string {
+	last := msg.Blocks[len(msg.Blocks)-1]
+	if last.Type != "context" || len(last.Elements) == 0 {
+		return ""
+	}
+	return last.Elements[0].(slack.TextObject).Text
+}
+
+func TestHandler_PlanWithButtons(t *testing.T) {
+	app := newSlackApp(t, replanner(pantryagent.Meal{}))
+
+	msg := app.postedPlan(t)
+	should.Contains(t, msg.Text, "Day 1: Tacos (2 servings)")
+	should.Equal(t, "Swap Tacos", button(t, msg, "swap.0").Text.Text)
+	should.Equal(t, "Regenerate day", button(t, msg, slack.ActionRegenerateDay).Text.Text)
+	should.Equal(t, "Accept", button(t, msg, slack.ActionAccept).Text.Text)
+}
+
+func TestHandler_Accept(t *testing.T) {
+	app := newSlackApp(t, replanner(pantryagent.Meal{}))
+	accept := button(t, app.postedPlan(t), slack.ActionAccept)
+
+	app.click(t, accept)
+	res := app.response(t)
+	should.Equal(t, true, res["replace_original"], "updates the plan's message")
+	msg := decodeMessage(t, res)
+	should.Equal(t, ":white_check_mark: Accepted by <@U456>, the pantry is updated", lastContext(msg))
+	for _, b := range msg.Blocks {
+		should.NotEqual(t, "actions", b.Type, "no buttons once accepted")
+	}
+	must.NoError(t, app.handler.Shutdown(context.Background()))
+
+	must.Len(t, app.planner.accepted, 1)
+	should.Equal(t, []slack.IngredientUse{
+		{Name: "tortilla", Qty: 4, Unit: "count"},
+		{Name: "spinach", Qty: 100, Unit: "g"},
+		{Name: "cheese", Qty: 20, Unit: "g"},
+	}, app.planner.accepted[0].Uses)
+}
+
+func TestHandler_AcceptTwice(t *testing.T) {
+	app := newSlackApp(t, replanner(pantryagent.Meal{}))
+	accept := button(t, app.postedPlan(t), slack.ActionAccept)
+
+	app.click(t, accept)
+	app.response(t)
+	app.click(t, accept)
+	should.Equal(t, map[string]any{"response_type": "ephemeral", "text": ":x: This plan was accepted already"}, app.response(t))
+	must.NoError(t, app.handler.Shutdown(context.Background()))
+	should.Len(t, app.planner.accepted, 1, "applies the plan once")
+}
+
+func TestHandler_ActionWhileShuttingDown(t *testing.T) {
+	app := newSlackApp(t, replanner(pantryagent.Meal{}))
+	accept := button(t, app.postedPlan(t), slack.ActionAccept)
+	must.NoError(t, app.handler.Shutdown(context.Background()))
+
+	app.click(t, accept)
+	res := app.response(t)
+	should.Equal(t, "ephemeral", res["response_type"])
+	should.Contains(t, res["text"], "try again")
+	should.Empty(t, app.planner.accepted, "the click was not acted on")
+}
+
+func TestHandler_Swap(t *testing.T) {
+	app := newSlackApp(t, replanner(pantryagent.Meal{ID: "salad", Name: "Spinach Salad", Servings: 2}))
+	swap := button(t, app.postedPlan(t), "swap.0")
+
+	app.click(t, swap)
+	progress := decodeMessage(t, app.response(t))
+	should.Equal(t, ":hourglass_flowing_sand: <@U456> asked to swap Tacos on day 1…", lastContext(progress))
+
+	msg := decodeMessage(t, app.response(t))
+	should.Equal(t, ":repeat: Swapped Tacos on day 1 for <@U456>", lastContext(msg))
+	should.Contains(t, msg.Text, "Meal plan: Tacos night\n", "keeps the rest of the plan")
+	should.Contains(t, msg.Text, "Day 1: Spinach Salad (2 servings)")
+	should.Equal(t, "Swap Spinach Salad", button(t, msg, "swap.0").Text.Text)
+
+	tasks := app.planner.Tasks()
+	must.Len(t, tasks, 2)
+	should.True(t, strings.HasPrefix(tasks[1], "plan tacos for 2\n\nReplace Tacos (tacos) on day 1"), tasks[1])
+	should.Contains(t, tasks[1], `"id":"tacos"`, "shows the planner the plan")
+}
+
+func TestHandler_SwapKeepsFeasibility(t *testing.T) {
+	for name, tc := range map[string]struct {
+		meal pantryagent.Meal
+		want string
+	}{
+		"unknown recipe": {
+			meal: pantryagent.Meal{ID: "pizza", Name: "Pizza", Servings: 2},
+			want: `:warning: Couldn't swap Tacos on day 1: day 1: unknown recipe "pizza"`,
+		},
+		"shopping": {
+			meal: pantryagent.Meal{ID: "soup", Name: "Spinach Soup", Servings: 8},
+			want: ":warning: Couldn't swap Tacos on day 1: it needs 150 g more spinach than the pantry has",
+		},
+		"same meal": {
+			meal: pantryagent.Meal{ID: "tacos", Name: "Tacos", Servings: 2},
+			want: ":warning: Couldn't swap Tacos on day 1: the planner kept Tacos",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := newSlackApp(t, replanner(tc.meal))
+			app.click(t, button(t, app.postedPlan(t), "swap.0"))
+			app.response(t)
+
+			msg := decodeMessage(t, app.response(t))
+			should.Equal(t, tc.want, lastContext(msg))
+			should.Contains(t, msg.Text, "Day 1: Tacos (2 servings)", "keeps the plan")
+			should.Equal(t, "Swap Tacos", button(t, msg, "swap.0").Text.Text, "with its buttons")
+		})
+	}
+}
+
+func TestHandler_RegenerateDay(t *testing.T) {
+	app := newSlackApp(t, replanner(pantryagent.Meal{ID: "salad", Name: "Spinach Salad", Servings: 2}))
+	app.click(t, button(t, app.postedPlan(t), slack.ActionRegenerateDay))
+
+	should.Equal(t, ":hourglass_flowing_sand: <@U456> asked to regenerate day 1…", lastContext(decodeMessage(t, app.response(t))))
+	msg := decodeMessage(t, app.response(t))
+	should.Equal(t, ":repeat: Regenerated day 1 for <@U456>", lastContext(msg))
+	should.Contains(t, msg.Text, "Day 1: Spinach Salad (2 servings)")
+	should.Contains(t, app.planner.Tasks()[1], "Plan day 1 of this meal plan again")
+}
+
+func TestHandler_ExpiredPlan(t *testing.T) {
+	app := newSlackApp(t, nil)
+
+	app.click(t, slack.Button{ActionID: slack.ActionAccept, Value: "0123456789abcdef"})
+	should.Equal(t, map[string]any{"response_type": "ephemeral", "text": ":x: This plan has expired, ask for a new one"}, app.response(t))
+}
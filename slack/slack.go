@@ -1,3 +1,7 @@
+// Package slack posts plans to Slack: Client through an incoming webhook, which posts to its own
+// channel only, and WebAPIClient through the Web API with a bot token, which can also reply in threads
+// and edit messages. Handler serves a Slack app that plans on request, from a slash command or a mention,
+// and lets people accept a plan or change its meals with buttons.
 package slack
 
 import (
@@ -12,6 +16,7 @@ type doer interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// Client posts through an incoming webhook.
 type Client struct {
 	webhookURL string
 	httpClient doer
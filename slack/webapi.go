@@ -0,0 +1,205 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pantryagent"
+)
+
+// DefaultAPIURL is the base URL of the Slack Web API.
+const DefaultAPIURL = "https://slack.com/api/"
+
+// DefaultMaxRetries is how many times a rate limited call is retried.
+const DefaultMaxRetries = 3
+
+// defaultRetryAfter is the wait after a 429 response without a Retry-After header.
+const defaultRetryAfter = time.Second
+
+// WebAPIClient posts with a bot token through the Slack Web API, which unlike incoming webhooks can post
+// to any channel the bot is in, reply in threads and edit messages.
+type WebAPIClient struct {
+	token      string
+	baseURL    string
+	httpClient doer
+	maxRetries int
+}
+
+var (
+	_ pantryagent.SlackClient = (*WebAPIClient)(nil)
+	_ pantryagent.SlackClient = (*Client)(nil)
+)
+
+// NewWebAPIClient returns a client authenticating with a bot token ("xoxb-...").
+func NewWebAPIClient(token string, httpClient doer) *WebAPIClient {
+	return &WebAPIClient{
+		token:      token,
+		baseURL:    DefaultAPIURL,
+		httpClient: httpClient,
+		maxRetries: DefaultMaxRetries,
+	}
+}
+
+// WithBaseURL sets the base URL of the API, e.g. of a test server.
+func (c *WebAPIClient) WithBaseURL(baseURL string) *WebAPIClient {
+	if baseURL != "" && baseURL[len(baseURL)-1] != '/' {
+		baseURL += "/"
+	}
+	c.baseURL = baseURL
+	return c
+}
+
+// WithMaxRetries sets how many times a rate limited call is retried; 0 disables retries.
+func (c *WebAPIClient) WithMaxRetries(n int) *WebAPIClient {
+	c.maxRetries = max(n, 0)
+	return c
+}
+
+// Message is the content of a message.
+type Message struct {
+	// Text is the message, or the plain-text fallback of Blocks for notifications and clients that do
+	// not show blocks.
+	Text   string  `json:"text"`
+	Blocks []Block `json:"blocks,omitempty"`
+}
+
+// MessageRef identifies a posted message, to update it or reply to it.
+type MessageRef struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// APIError is a call the Web API answered with "ok": false.
+type APIError struct {
+	Method string
+	// Code is Slack's error code, e.g. "channel_not_found" or "not_in_channel".
+	Code string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("slack %s: %s", e.Method, e.Code)
+}
+
+// PostMessage posts message to channel, a channel id or name.
+func (c *WebAPIClient) PostMessage(ctx context.Context, channel string, message string) error {
+	_, err := c.Post(ctx, channel, Message{Text: message})
+	return err
+}
+
+// Post posts msg to channel and returns its reference.
+func (c *WebAPIClient) Post(ctx context.Context, channel string, msg Message) (MessageRef, error) {
+	return c.post(ctx, channel, "", msg)
+}
+
+// Reply posts msg in the thread of parent and returns its reference.
+func (c *WebAPIClient) Reply(ctx context.Context, parent MessageRef, msg Message) (MessageRef, error) {
+	return c.post(ctx, parent.Channel, parent.TS, msg)
+}
+
+func (c *WebAPIClient) post(ctx context.Context, channel, threadTS string, msg Message) (MessageRef, error) {
+	var ref MessageRef
+	err := c.call(ctx, "chat.postMessage", struct {
+		Channel  string `json:"channel"`
+		ThreadTS string `json:"thread_ts,omitempty"`
+		Message
+	}{channel, threadTS, msg}, &ref)
+	return ref, err
+}
+
+// Update replaces the content of a posted message, including its blocks.
+func (c *WebAPIClient) Update(ctx context.Context, ref MessageRef, msg Message) error {
+	blocks := msg.Blocks
+	if blocks == nil {
+		// chat.update keeps the blocks of the message unless given others
+		blocks = []Block{}
+	}
+	return c.call(ctx, "chat.update", struct {
+		MessageRef
+		Text   string  `json:"text"`
+		Blocks []Block `json:"blocks"`
+	}{ref, msg.Text, blocks}, nil)
+}
+
+// call calls a Web API method with a JSON body and decodes the response into out, if not nil. Rate
+// limited calls are retried after the Retry-After the API asks for.
+func (c *WebAPIClient) call(ctx context.Context, method string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		wait, err := c.try(ctx, method, payload, out)
+		if wait == 0 || attempt >= c.maxRetries {
+			return err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// try calls method once. It returns the wait before a retry if the call was rate limited, and the error
+// of the call.
+func (c *WebAPIClient) try(ctx context.Context, method string, payload []byte, out any) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+method, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		io.Copy(io.Discard, resp.Body) // nolint: errcheck
+		return retryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("slack %s: rate limited", method)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("slack %s: %s", method, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return 0, fmt.Errorf("slack %s: invalid response: %w", method, err)
+	}
+	if !status.OK {
+		return 0, &APIError{Method: method, Code: status.Error}
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return 0, fmt.Errorf("slack %s: invalid response: %w", method, err)
+		}
+	}
+	return 0, nil
+}
+
+// retryAfter parses a Retry-After header in seconds.
+func retryAfter(v string) time.Duration {
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds <= 0 {
+		return defaultRetryAfter
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,139 @@
+package slack_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"pantryagent/slack"
+
+	should "github.com/stretchr/testify/assert"
+	must "github.com/stretchr/testify/require"
+)
+
+// fakeAPI records the calls of a WebAPIClient and answers them with respond.
+type fakeAPI struct {
+	mu      sync.Mutex
+	calls   []apiCall
+	respond func(n int, w http.ResponseWriter, call apiCall)
+}
+
+type apiCall struct {
+	Method string
+	Auth   string
+	Body   map[string]any
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, _ := io.ReadAll(r.Body)
+	call := apiCall{Method: r.URL.Path[len("/api/"):], Auth: r.Header.Get("Authorization")}
+	_ = json.Unmarshal(data, &call.Body)
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	n := len(f.calls)
+	f.mu.Unlock()
+	f.respond(n, w, call)
+}
+
+func newFakeAPI(t *testing.T, respond func(n int, w http.ResponseWriter, call apiCall)) (*fakeAPI, *slack.WebAPIClient) {
+	t.Helper()
+	api := &fakeAPI{respond: respond}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	return api, slack.NewWebAPIClient("xoxb-test", srv.Client()).WithBaseURL(srv.URL + "/api")
+}
+
+func ok(w http.ResponseWriter, call apiCall) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "channel": "C123", "ts": "1700000000.000100"}) // nolint: errcheck
+}
+
+func TestWebAPIClient_PostUpdateReply(t *testing.T) {
+	api, client := newFakeAPI(t, func(_ int, w http.ResponseWriter, call apiCall) { ok(w, call) })
+	ctx := context.Background()
+
+	ref, err := client.Post(ctx, "#meals", slack.Message{Text: "Planning..."})
+	must.NoError(t, err)
+	should.Equal(t, slack.MessageRef{Channel: "C123", TS: "1700000000.000100"}, ref)
+
+	must.NoError(t, client.Update(ctx, ref, slack.Message{Text: "Planned 3 dinners"}))
+	_, err = client.Reply(ctx, ref, slack.Message{Text: "Day 1: tacos"})
+	must.NoError(t, err)
+	must.NoError(t, client.PostMessage(ctx, "C999", "hello"))
+
+	must.Len(t, api.calls, 4)
+	for _, call := range api.calls {
+		should.Equal(t, "Bearer xoxb-test", call.Auth)
+	}
+	should.Equal(t, apiCall{Method: "chat.postMessage", Auth: "Bearer xoxb-test", Body: map[string]any{"channel": "#meals", "text": "Planning..."}}, api.calls[0])
+	should.Equal(t, "chat.update", api.calls[1].Method)
+	should.Equal(t, map[string]any{"channel": "C123", "ts": "1700000000.000100", "text": "Planned 3 dinners", "blocks": []any{}}, api.calls[1].Body)
+	should.Equal(t, "chat.postMessage", api.calls[2].Method)
+	should.Equal(t, map[string]any{"channel": "C123", "thread_ts": "1700000000.000100", "text": "Day 1: tacos"}, api.calls[2].Body)
+	should.Equal(t, map[string]any{"channel": "C999", "text": "hello"}, api.calls[3].Body)
+}
+
+func TestWebAPIClient_APIError(t *testing.T) {
+	_, client := newFakeAPI(t, func(_ int, w http.ResponseWriter, _ apiCall) {
+		w.Write([]byte(`{"ok": false, "error": "channel_not_found"}`)) // nolint: errcheck
+	})
+
+	err := client.PostMessage(context.Background(), "#nowhere", "hello")
+	var apiErr *slack.APIError
+	must.ErrorAs(t, err, &apiErr)
+	should.Equal(t, &slack.APIError{Method: "chat.postMessage", Code: "channel_not_found"}, apiErr)
+	should.EqualError(t, err, "slack chat.postMessage: channel_not_found")
+}
+
+func TestWebAPIClient_HTTPError(t *testing.T) {
+	_, client := newFakeAPI(t, func(_ int, w http.ResponseWriter, _ apiCall) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	err := client.PostMessage(context.Background(), "#meals", "hello")
+	should.EqualError(t, err, "slack chat.postMessage: 500 Internal Server Error")
+}
+
+func TestWebAPIClient_RateLimited(t *testing.T) {
+	api, client := newFakeAPI(t, func(n int, w http.ResponseWriter, call apiCall) {
+		if n == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		ok(w, call)
+	})
+
+	started := time.Now()
+	_, err := client.Post(context.Background(), "#meals", slack.Message{Text: "hello"})
+	must.NoError(t, err)
+	should.GreaterOrEqual(t, time.Since(started), time.Second, "waits for Retry-After")
+	should.Len(t, api.calls, 2)
+}
+
+func TestWebAPIClient_RateLimitedGivesUp(t *testing.T) {
+	api, client := newFakeAPI(t, func(_ int, w http.ResponseWriter, _ apiCall) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	t.Run("no retries", func(t *testing.T) {
+		err := client.WithMaxRetries(0).PostMessage(context.Background(), "#meals", "hello")
+		should.EqualError(t, err, "slack chat.postMessage: rate limited")
+		should.Len(t, api.calls, 1)
+	})
+
+	t.Run("context ends while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := client.WithMaxRetries(3).PostMessage(ctx, "#meals", "hello")
+		should.ErrorIs(t, err, context.DeadlineExceeded)
+		should.Len(t, api.calls, 2)
+	})
+}
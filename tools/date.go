@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Date is a calendar day, encoded in JSON as "2006-01-02". The zero Date is unset.
+type Date struct {
+	t time.Time // midnight UTC
+}
+
+// ParseDate parses an ISO 8601 date ("2006-01-02").
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q: want YYYY-MM-DD", s)
+	}
+	return Date{t: t}, nil
+}
+
+// DateOf returns the calendar day of t in t's location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{t: time.Date(y, m, d, 0, 0, 0, 0, time.UTC)}
+}
+
+func (d Date) IsZero() bool { return d.t.IsZero() }
+
+func (d Date) String() string { return d.t.Format(time.DateOnly) }
+
+// AddDays returns the date n days after d.
+func (d Date) AddDays(n int) Date { return Date{t: d.t.AddDate(0, 0, n)} }
+
+// DaysUntil returns the number of days from d to other, negative if other is earlier.
+func (d Date) DaysUntil(other Date) int { return int(other.t.Sub(d.t).Hours() / 24) }
+
+// Before reports whether d is earlier than other.
+func (d Date) Before(other Date) bool { return d.t.Before(other.t) }
+
+// End returns the last instant of the day in UTC.
+func (d Date) End() time.Time { return d.t.Add(24*time.Hour - time.Nanosecond) }
+
+// EndIn returns the last instant of the day in loc, e.g. the time zone the day was taken in by DateOf.
+func (d Date) EndIn(loc *time.Location) time.Time {
+	y, m, day := d.t.Date()
+	return time.Date(y, m, day+1, 0, 0, 0, 0, loc).Add(-time.Nanosecond)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("invalid date: %w", err)
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
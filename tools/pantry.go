@@ -1,5 +1,8 @@
 package tools
 
+// NonPerishableDaysLeft is the days_left pantry_get reports for ingredients that never expire.
+const NonPerishableDaysLeft = 9999
+
 type Ingredient struct {
 	Name           string  `json:"name"`
 	Qty            float64 `json:"qty"`
@@ -7,8 +10,21 @@ type Ingredient struct {
 	DaysLeft       int     `json:"days_left,omitempty"`
 	PerishableDays int     `json:"perishable_days,omitempty"`
 	AddedDay       int     `json:"added_day,omitempty"`
+	// NonPerishable marks ingredients that never expire; pantry_get reports NonPerishableDaysLeft for them.
+	NonPerishable bool `json:"non_perishable,omitempty"`
+	// Expires is the expiry date, for ingredients tracked with real dates rather than relative days.
+	Expires Date `json:"expires,omitzero"`
 }
 
+// Pantry is the stored pantry: the Ingredients it started from, and the log of Events since, oldest
+// first. AsOf derives the ingredients at a given time.
 type Pantry struct {
-	Ingredients []Ingredient `json:"ingredients"`
+	Ingredients []Ingredient  `json:"ingredients"`
+	Events      []PantryEvent `json:"events,omitempty"`
+}
+
+// DaysLeftOn returns the days until the ingredient expires as of today, as pantry_get reports them:
+// negative once expired, NonPerishableDaysLeft if it never does.
+func (i Ingredient) DaysLeftOn(today Date) int {
+	return getDaysLeft(i, 0, today)
 }
@@ -0,0 +1,225 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PantryEventType is what happened to an ingredient.
+type PantryEventType string
+
+const (
+	// Purchased adds Qty of an ingredient as a new lot, expiring on Expires (if known).
+	Purchased PantryEventType = "purchased"
+	// Consumed takes Qty of an ingredient, oldest lot first.
+	Consumed PantryEventType = "consumed"
+	// Discarded throws Qty of an ingredient away, oldest lot first.
+	Discarded PantryEventType = "discarded"
+	// Opened marks the oldest lot of an ingredient as opened, expiring on Expires at the latest.
+	Opened PantryEventType = "opened"
+)
+
+// PantryEvent is an entry of the pantry's append-only event log.
+type PantryEvent struct {
+	Type PantryEventType `json:"type"`
+	At   time.Time       `json:"at"`
+	Name string          `json:"name"`
+	Qty  float64         `json:"qty,omitempty"`
+	Unit string          `json:"unit,omitempty"`
+	// Expires is the expiry date of a purchased lot, or the date an opened lot must be used by.
+	Expires       Date `json:"expires,omitzero"`
+	NonPerishable bool `json:"non_perishable,omitempty"`
+	// Source is who recorded the event, e.g. "cli" or "slack".
+	Source string `json:"source,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+// Validate checks that the event is complete.
+func (e PantryEvent) Validate() error {
+	switch e.Type {
+	case Purchased, Consumed, Discarded:
+		if e.Qty <= 0 || math.IsInf(e.Qty, 0) || math.IsNaN(e.Qty) {
+			return fmt.Errorf("%s %q: qty must be positive", e.Type, e.Name)
+		}
+		if e.Unit == "" {
+			return fmt.Errorf("%s %q: missing unit", e.Type, e.Name)
+		}
+	case Opened:
+	default:
+		return fmt.Errorf("unknown pantry event type %q", e.Type)
+	}
+	if strings.TrimSpace(e.Name) == "" {
+		return fmt.Errorf("%s: missing ingredient name", e.Type)
+	}
+	if e.At.IsZero() {
+		return fmt.Errorf("%s %q: missing time", e.Type, e.Name)
+	}
+	return nil
+}
+
+// AsOf returns the pantry at t: its Ingredients replayed with the events that happened at or before t, in
+// the order they happened (events at the same time in log order), so back-dated events apply where they
+// belong. The result has no events; each ingredient carries the freshness of its oldest lot.
+func (p *Pantry) AsOf(t time.Time) (*Pantry, error) {
+	return p.replay(func(e PantryEvent) bool { return !e.At.After(t) })
+}
+
+// Current returns the pantry with every event of the log replayed.
+func (p *Pantry) Current() (*Pantry, error) {
+	return p.replay(func(PantryEvent) bool { return true })
+}
+
+// Record validates events and appends them to the log, provided the log still replays with them in
+// place, e.g. a back-dated use must find the ingredient in the pantry at its time. On error the pantry is
+// left unchanged.
+func (p *Pantry) Record(events ...PantryEvent) error {
+	for _, e := range events {
+		if err := e.Validate(); err != nil {
+			return err
+		}
+	}
+	candidate := &Pantry{Ingredients: p.Ingredients, Events: append(p.Events[:len(p.Events):len(p.Events)], events...)}
+	if _, err := candidate.Current(); err != nil {
+		return err
+	}
+	p.Events = candidate.Events
+	return nil
+}
+
+// stock is an ingredient's lots, oldest first; each lot is an Ingredient with its own freshness.
+type stock struct {
+	name string
+	unit string
+	lots []Ingredient
+}
+
+func (s *stock) qty() float64 {
+	total := 0.0
+	for _, l := range s.lots {
+		total += l.Qty
+	}
+	return total
+}
+
+func (p *Pantry) replay(include func(PantryEvent) bool) (*Pantry, error) {
+	// An ingredient held in several units has a stock per unit
+	var stocks []*stock
+	find := func(name, unit string) *stock {
+		for _, s := range stocks {
+			if strings.EqualFold(s.name, name) && s.unit == unit {
+				return s
+			}
+		}
+		return nil
+	}
+	// stockFor returns the stock e applies to: the one in its unit, else the first one with lots for
+	// opening (or reporting a unit mismatch), or a used up one a purchase may bring back in its unit.
+	stockFor := func(e PantryEvent) *stock {
+		if e.Type != Opened {
+			if s := find(e.Name, e.Unit); s != nil {
+				return s
+			}
+		}
+		var held, usedUp *stock
+		for _, s := range stocks {
+			switch {
+			case !strings.EqualFold(s.name, e.Name):
+			case len(s.lots) > 0 && held == nil:
+				held = s
+			case len(s.lots) == 0 && usedUp == nil:
+				usedUp = s
+			}
+		}
+		if e.Type == Purchased && usedUp != nil || held == nil {
+			return usedUp
+		}
+		return held
+	}
+
+	for _, ing := range p.Ingredients {
+		if s := find(ing.Name, ing.Unit); s != nil {
+			s.lots = append(s.lots, ing)
+			continue
+		}
+		stocks = append(stocks, &stock{name: ing.Name, unit: ing.Unit, lots: []Ingredient{ing}})
+	}
+
+	events := slices.DeleteFunc(slices.Clone(p.Events), func(e PantryEvent) bool { return !include(e) })
+	slices.SortStableFunc(events, func(a, b PantryEvent) int { return a.At.Compare(b.At) })
+	for _, e := range events {
+		s := stockFor(e)
+		if e.Type == Purchased && s == nil {
+			s = &stock{name: e.Name, unit: e.Unit}
+			stocks = append(stocks, s)
+		}
+		if err := s.apply(e); err != nil {
+			return nil, err
+		}
+	}
+
+	out := &Pantry{Ingredients: []Ingredient{}}
+	for _, s := range stocks {
+		if len(s.lots) == 0 {
+			continue
+		}
+		ing := s.lots[0]
+		ing.Name, ing.Qty = s.name, s.qty()
+		out.Ingredients = append(out.Ingredients, ing)
+	}
+	return out, nil
+}
+
+var eventVerbs = map[PantryEventType]string{Purchased: "add", Consumed: "consume", Discarded: "discard", Opened: "open"}
+
+// apply applies e to the stock, which is nil if the pantry has none of the ingredient.
+func (s *stock) apply(e PantryEvent) error {
+	verb, ok := eventVerbs[e.Type]
+	if !ok {
+		return fmt.Errorf("unknown pantry event type %q", e.Type)
+	}
+	if s == nil {
+		if e.Type == Opened {
+			return fmt.Errorf("open %q: not in pantry", e.Name)
+		}
+		return fmt.Errorf("%s %v %s of %q: %w: not in pantry", verb, e.Qty, e.Unit, e.Name, ErrInsufficientQuantity)
+	}
+	if e.Type == Purchased && len(s.lots) == 0 {
+		s.unit = e.Unit // used up, so the ingredient may come back in another unit
+	}
+	if e.Type != Opened && s.unit != e.Unit {
+		return fmt.Errorf("change %q: unit %q does not match pantry unit %q", e.Name, e.Unit, s.unit)
+	}
+
+	switch e.Type {
+	case Purchased:
+		s.lots = append(s.lots, Ingredient{Name: s.name, Qty: e.Qty, Unit: s.unit, Expires: e.Expires, NonPerishable: e.NonPerishable})
+	case Consumed, Discarded:
+		// Quantities are summed and split across lots, so they are compared with a tolerance: using
+		// 0.1 and then 0.2 of 0.3 must empty the pantry
+		const eps = 1e-9
+		if left := s.qty(); e.Qty > left+eps {
+			return fmt.Errorf("%s %v %s of %q: %w: %v left", verb, e.Qty, e.Unit, e.Name, ErrInsufficientQuantity, left)
+		}
+		remaining := e.Qty
+		for remaining > eps && len(s.lots) > 0 {
+			take := min(remaining, s.lots[0].Qty)
+			s.lots[0].Qty -= take
+			remaining -= take
+			if s.lots[0].Qty <= eps {
+				s.lots = s.lots[1:]
+			}
+		}
+	case Opened:
+		if len(s.lots) == 0 {
+			return fmt.Errorf("open %q: not in pantry", e.Name)
+		}
+		if !e.Expires.IsZero() && (s.lots[0].Expires.IsZero() || e.Expires.Before(s.lots[0].Expires)) {
+			s.lots[0].Expires = e.Expires
+			s.lots[0].NonPerishable = false
+		}
+	}
+	return nil
+}
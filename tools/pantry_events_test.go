@@ -0,0 +1,236 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"pantryagent/tools/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day(t *testing.T, s string) Date {
+	t.Helper()
+	d, err := ParseDate(s)
+	require.NoError(t, err)
+	return d
+}
+
+func TestDate(t *testing.T) {
+	d := day(t, "2025-03-30")
+	assert.Equal(t, "2025-04-02", d.AddDays(3).String())
+	assert.Equal(t, 3, d.DaysUntil(d.AddDays(3)))
+	assert.Equal(t, -30, d.DaysUntil(day(t, "2025-02-28")))
+	assert.Equal(t, d, DateOf(time.Date(2025, 3, 30, 23, 59, 0, 0, time.FixedZone("", -8*3600))))
+	pst := time.FixedZone("PST", -8*3600)
+	assert.Equal(t, time.Date(2025, 3, 30, 23, 59, 59, 999999999, pst), d.EndIn(pst))
+
+	b, err := json.Marshal(struct {
+		Set   Date `json:"set"`
+		Unset Date `json:"unset,omitzero"`
+	}{Set: d})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"set": "2025-03-30"}`, string(b))
+
+	var decoded Date
+	require.NoError(t, json.Unmarshal([]byte(`"2025-03-30"`), &decoded))
+	assert.Equal(t, d, decoded)
+	assert.ErrorContains(t, json.Unmarshal([]byte(`"30/03/2025"`), &decoded), "want YYYY-MM-DD")
+}
+
+func TestPantry_AsOf(t *testing.T) {
+	at := func(s string) time.Time { return day(t, s).End().Add(-time.Hour) }
+	pantry := &Pantry{
+		Ingredients: []Ingredient{{Name: "rice", Qty: 1000, Unit: "g", NonPerishable: true}},
+		Events: []PantryEvent{
+			{Type: Purchased, At: at("2025-03-01"), Name: "milk", Qty: 1, Unit: "L", Expires: day(t, "2025-03-08")},
+			{Type: Purchased, At: at("2025-03-05"), Name: "Milk", Qty: 2, Unit: "L", Expires: day(t, "2025-03-12")},
+			{Type: Consumed, At: at("2025-03-06"), Name: "milk", Qty: 1.5, Unit: "L"},
+			{Type: Opened, At: at("2025-03-06"), Name: "milk", Expires: day(t, "2025-03-09")},
+			{Type: Consumed, At: at("2025-03-07"), Name: "rice", Qty: 1000, Unit: "g"},
+			{Type: Discarded, At: at("2025-03-10"), Name: "milk", Qty: 1.5, Unit: "L"},
+		},
+	}
+
+	tests := []struct {
+		date string
+		want []Ingredient
+	}{
+		{date: "2025-02-28", want: []Ingredient{{Name: "rice", Qty: 1000, Unit: "g", NonPerishable: true}}},
+		{date: "2025-03-05", want: []Ingredient{
+			{Name: "rice", Qty: 1000, Unit: "g", NonPerishable: true},
+			{Name: "milk", Qty: 3, Unit: "L", Expires: day(t, "2025-03-08")},
+		}},
+		// The oldest lot is used up first; the next one is opened and expires sooner
+		{date: "2025-03-07", want: []Ingredient{{Name: "milk", Qty: 1.5, Unit: "L", Expires: day(t, "2025-03-09")}}},
+		{date: "2025-03-10", want: []Ingredient{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			got, err := pantry.AsOf(day(t, tt.date).End())
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Ingredients)
+			assert.Empty(t, got.Events)
+		})
+	}
+}
+
+func TestPantry_Record(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	pantry := &Pantry{Ingredients: []Ingredient{{Name: "egg", Qty: 2, Unit: "count"}}}
+
+	require.NoError(t, pantry.Record(
+		PantryEvent{Type: Consumed, At: now, Name: "egg", Qty: 2, Unit: "count", Source: "cli"},
+		// Used up, so eggs can come back by weight
+		PantryEvent{Type: Purchased, At: now, Name: "egg", Qty: 500, Unit: "g"},
+	))
+	assert.Len(t, pantry.Events, 2)
+
+	tests := []struct {
+		name    string
+		event   PantryEvent
+		wantErr error
+		errMsg  string
+	}{
+		{name: "unknown type", event: PantryEvent{Type: "eaten", At: now, Name: "egg"}, errMsg: `unknown pantry event type "eaten"`},
+		{name: "missing time", event: PantryEvent{Type: Opened, Name: "egg"}, errMsg: "missing time"},
+		{name: "missing name", event: PantryEvent{Type: Opened, At: now}, errMsg: "missing ingredient name"},
+		{name: "missing qty", event: PantryEvent{Type: Purchased, At: now, Name: "egg", Unit: "g"}, errMsg: "qty must be positive"},
+		{name: "too much", event: PantryEvent{Type: Discarded, At: now, Name: "egg", Qty: 600, Unit: "g"}, wantErr: ErrInsufficientQuantity, errMsg: "500 left"},
+		{name: "not in pantry", event: PantryEvent{Type: Consumed, At: now, Name: "tofu", Qty: 1, Unit: "g"}, wantErr: ErrInsufficientQuantity},
+		{name: "open missing", event: PantryEvent{Type: Opened, At: now, Name: "tofu"}, errMsg: `open "tofu": not in pantry`},
+		{name: "unit mismatch", event: PantryEvent{Type: Consumed, At: now, Name: "egg", Qty: 1, Unit: "count"}, errMsg: `unit "count" does not match pantry unit "g"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := pantry.Record(tt.event)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.ErrorContains(t, err, tt.errMsg)
+			assert.Len(t, pantry.Events, 2, "the log must be unchanged on error")
+		})
+	}
+}
+
+func TestPantry_RecordBackDated(t *testing.T) {
+	at := func(s string) time.Time { return day(t, s).End().Add(-time.Hour) }
+	pantry := &Pantry{Ingredients: []Ingredient{}}
+	require.NoError(t, pantry.Record(PantryEvent{Type: Purchased, At: at("2025-10-05"), Name: "milk", Qty: 1, Unit: "L"}))
+
+	err := pantry.Record(PantryEvent{Type: Consumed, At: at("2025-10-03"), Name: "milk", Qty: 1, Unit: "L"})
+	assert.ErrorIs(t, err, ErrInsufficientQuantity, "milk was bought after it was used")
+	assert.Len(t, pantry.Events, 1)
+
+	// A purchase recorded late counts from the day it happened, as the oldest lot
+	require.NoError(t, pantry.Record(
+		PantryEvent{Type: Purchased, At: at("2025-10-02"), Name: "milk", Qty: 2, Unit: "L", Expires: day(t, "2025-10-09")},
+		PantryEvent{Type: Consumed, At: at("2025-10-03"), Name: "milk", Qty: 0.5, Unit: "L"},
+	))
+	got, err := pantry.AsOf(day(t, "2025-10-04").End())
+	require.NoError(t, err)
+	assert.Equal(t, []Ingredient{{Name: "milk", Qty: 1.5, Unit: "L", Expires: day(t, "2025-10-09")}}, got.Ingredients)
+	got, err = pantry.Current()
+	require.NoError(t, err)
+	assert.Equal(t, []Ingredient{{Name: "milk", Qty: 2.5, Unit: "L", Expires: day(t, "2025-10-09")}}, got.Ingredients)
+}
+
+func TestPantry_RecordFractions(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	pantry := &Pantry{Ingredients: []Ingredient{}}
+	require.NoError(t, pantry.Record(
+		PantryEvent{Type: Purchased, At: now, Name: "oil", Qty: 0.1, Unit: "l"},
+		PantryEvent{Type: Purchased, At: now, Name: "oil", Qty: 0.2, Unit: "l"},
+		PantryEvent{Type: Consumed, At: now, Name: "oil", Qty: 0.1, Unit: "l"},
+		PantryEvent{Type: Consumed, At: now, Name: "oil", Qty: 0.2, Unit: "l"},
+		PantryEvent{Type: Purchased, At: now, Name: "vinegar", Qty: 0.3, Unit: "l"},
+		PantryEvent{Type: Consumed, At: now, Name: "vinegar", Qty: 0.1, Unit: "l"},
+		PantryEvent{Type: Consumed, At: now, Name: "vinegar", Qty: 0.2, Unit: "l"},
+	))
+	got, err := pantry.Current()
+	require.NoError(t, err)
+	assert.Empty(t, got.Ingredients, "no rounding leftovers")
+}
+
+func TestPantry_RecordUnits(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	pantry := &Pantry{Ingredients: []Ingredient{
+		{Name: "rice", Qty: 500, Unit: "g", NonPerishable: true},
+		{Name: "rice", Qty: 2, Unit: "cups", NonPerishable: true},
+	}}
+	require.NoError(t, pantry.Record(
+		PantryEvent{Type: Consumed, At: now, Name: "rice", Qty: 1, Unit: "cups"},
+		PantryEvent{Type: Consumed, At: now, Name: "rice", Qty: 100, Unit: "g"},
+	))
+	got, err := pantry.Current()
+	require.NoError(t, err)
+	assert.Equal(t, []Ingredient{
+		{Name: "rice", Qty: 400, Unit: "g", NonPerishable: true},
+		{Name: "rice", Qty: 1, Unit: "cups", NonPerishable: true},
+	}, got.Ingredients)
+
+	err = pantry.Record(PantryEvent{Type: Consumed, At: now, Name: "rice", Qty: 1, Unit: "lb"})
+	assert.ErrorContains(t, err, `unit "lb" does not match pantry unit "g"`)
+}
+
+func TestRecordPantryEvents(t *testing.T) {
+	ctx := context.Background()
+	state := storage.NewTestPantryState([]byte(`{"ingredients": [{"name": "rice", "qty": 1000, "unit": "g", "days_left": 9999}]}`))
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	pantry, err := RecordPantryEvents(ctx, state, []PantryEvent{
+		{Type: Purchased, At: now, Name: "yogurt", Qty: 4, Unit: "count", Expires: day(t, "2025-03-04"), Source: "slack"},
+	}, 0)
+	require.NoError(t, err)
+	assert.Len(t, pantry.Ingredients, 2)
+
+	// pantry_get derives days_left from the expiry date at the requested date
+	tool := NewPantryGet(state)
+	tool.now = func() time.Time { return now }
+	for date, want := range map[string]float64{"": 3, "2025-03-06": -2} {
+		out, err := tool.Run(ctx, map[string]any{"date": date})
+		require.NoError(t, err)
+		ingredients := out["pantry"].(map[string]any)["ingredients"].([]any)
+		require.Len(t, ingredients, 2, date)
+		assert.Equal(t, 9999.0, ingredients[0].(map[string]any)["days_left"], date)
+		assert.Equal(t, want, ingredients[1].(map[string]any)["days_left"], date)
+		assert.Equal(t, "2025-03-04", ingredients[1].(map[string]any)["expires"], date)
+	}
+
+	// Before the purchase, there is no yogurt
+	out, err := tool.Run(ctx, map[string]any{"date": "2025-02-28"})
+	require.NoError(t, err)
+	assert.Len(t, out["pantry"].(map[string]any)["ingredients"], 1)
+
+	_, err = tool.Run(ctx, map[string]any{"date": "March 1st"})
+	assert.ErrorContains(t, err, "want YYYY-MM-DD")
+}
+
+func TestPantryGet_LocalEvening(t *testing.T) {
+	ctx := context.Background()
+	edt := time.FixedZone("EDT", -4*3600)
+	state := storage.NewTestPantryState([]byte(`{"ingredients": [{"name": "egg", "qty": 12, "unit": "count", "non_perishable": true}]}`))
+
+	// 20:00 EDT is already the next day in UTC
+	evening := time.Date(2025, 6, 2, 20, 0, 0, 0, edt)
+	_, err := RecordPantryEvents(ctx, state, []PantryEvent{
+		{Type: Consumed, At: evening, Name: "egg", Qty: 4, Unit: "count", Source: "cli"},
+	}, 0)
+	require.NoError(t, err)
+
+	tool := NewPantryGet(state)
+	tool.now = func() time.Time { return evening.Add(30 * time.Minute) }
+	for _, date := range []string{"", "2025-06-02"} {
+		out, err := tool.Run(ctx, map[string]any{"date": date})
+		require.NoError(t, err)
+		egg := out["pantry"].(map[string]any)["ingredients"].([]any)[0].(map[string]any)
+		assert.Equal(t, 8.0, egg["qty"], "consumed this evening, date %q", date)
+	}
+
+	out, err := tool.Run(ctx, map[string]any{"date": "2025-06-01"})
+	require.NoError(t, err)
+	assert.Equal(t, 12.0, out["pantry"].(map[string]any)["ingredients"].([]any)[0].(map[string]any)["qty"])
+}
@@ -4,28 +4,41 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/jsonschema"
 
 	"pantryagent/tools/storage"
 )
 
-type PantryGet struct{ state storage.PantryState }
+type PantryGet struct {
+	state storage.PantryState
+	now   func() time.Time
+}
 
-func NewPantryGet(state storage.PantryState) *PantryGet { return &PantryGet{state: state} }
+func NewPantryGet(state storage.PantryState) *PantryGet {
+	return &PantryGet{state: state, now: time.Now}
+}
 
 func (t *PantryGet) Name() string  { return "pantry_get" }
 func (t *PantryGet) Title() string { return "Get Pantry (with freshness)" }
 func (t *PantryGet) Description() string {
-	return "Returns pantry quantities plus days_left for perishables at a given current_day."
+	return "Returns pantry quantities plus days_left for perishables as of a date (default today), " +
+		"replaying the pantry's log of purchases and consumption up to that date."
 }
 
 func (t *PantryGet) InputSchema() *jsonschema.Schema {
 	return &jsonschema.Schema{
 		Type: "object",
 		Properties: map[string]*jsonschema.Schema{
+			"date": {
+				Type:        "string",
+				Format:      "date",
+				Description: "ISO date (YYYY-MM-DD) to report the pantry at; defaults to today",
+			},
 			"current_day": {
-				Type: "integer",
+				Type:        "integer",
+				Description: "day number to compute days_left at, for entries tracked in relative days",
 			},
 		},
 	}
@@ -33,7 +46,6 @@ func (t *PantryGet) InputSchema() *jsonschema.Schema {
 
 func (t *PantryGet) OutputSchema() *jsonschema.Schema {
 	minQty := 0.0
-	minDays := 0.0
 	return &jsonschema.Schema{
 		Type: "object",
 		Properties: map[string]*jsonschema.Schema{
@@ -48,7 +60,8 @@ func (t *PantryGet) OutputSchema() *jsonschema.Schema {
 								"name":      {Type: "string"},
 								"qty":       {Type: "number", Minimum: &minQty},
 								"unit":      {Type: "string"},
-								"days_left": {Type: "integer", Minimum: &minDays},
+								"days_left": {Type: "integer", Description: "negative when expired"},
+								"expires":   {Type: "string", Format: "date"},
 							},
 							Required: []string{"name", "qty", "unit", "days_left"},
 						},
@@ -63,20 +76,41 @@ func (t *PantryGet) OutputSchema() *jsonschema.Schema {
 
 func (t *PantryGet) Run(ctx context.Context, input map[string]any) (map[string]any, error) {
 	current := 0
-	if v, ok := input["current_day"].(float64); ok {
+	switch v := input["current_day"].(type) {
+	case float64: // decoded from JSON
 		current = int(v)
+	case int:
+		current = v
+	}
+
+	now := t.now()
+	asOf := DateOf(now)
+	if v, ok := input["date"].(string); ok && v != "" {
+		date, err := ParseDate(v)
+		if err != nil {
+			return nil, &ToolError{
+				Tool:    t.Name(),
+				Code:    ErrCodeInvalidInput,
+				Message: err.Error(),
+				Hint:    "Pass the date as YYYY-MM-DD, or leave it out for today.",
+				Err:     err,
+			}
+		}
+		asOf = date
 	}
 
-	pan, err := t.load(ctx)
+	// The day ends in the time zone it was taken in, so events recorded this evening count today
+	pan, err := t.load(ctx, asOf.EndIn(now.Location()))
 	if err != nil {
 		return nil, err
 	}
 
 	type outIng struct {
-		Name string  `json:"name"`
-		Qty  float64 `json:"qty"`
-		Unit string  `json:"unit"`
-		Days int     `json:"days_left"`
+		Name    string  `json:"name"`
+		Qty     float64 `json:"qty"`
+		Unit    string  `json:"unit"`
+		Days    int     `json:"days_left"`
+		Expires string  `json:"expires,omitempty"`
 	}
 	out := struct {
 		Pantry struct {
@@ -88,9 +122,11 @@ func (t *PantryGet) Run(ctx context.Context, input map[string]any) (map[string]a
 	out.Pantry.Ingredients = make([]outIng, 0)
 
 	for _, it := range pan.Ingredients {
-		out.Pantry.Ingredients = append(out.Pantry.Ingredients, outIng{
-			Name: it.Name, Qty: it.Qty, Unit: it.Unit, Days: getDaysLeft(it, current),
-		})
+		ing := outIng{Name: it.Name, Qty: it.Qty, Unit: it.Unit, Days: getDaysLeft(it, current, asOf)}
+		if !it.Expires.IsZero() {
+			ing.Expires = it.Expires.String()
+		}
+		out.Pantry.Ingredients = append(out.Pantry.Ingredients, ing)
 	}
 
 	// marshal -> map[string]any to keep outputs uniform
@@ -100,23 +136,33 @@ func (t *PantryGet) Run(ctx context.Context, input map[string]any) (map[string]a
 	return m, nil
 }
 
-func (t *PantryGet) load(ctx context.Context) (Pantry, error) {
+// load returns the pantry as of the given time.
+func (t *PantryGet) load(ctx context.Context, asOf time.Time) (*Pantry, error) {
 	b, err := t.state.Load(ctx)
 	if err != nil {
-		return Pantry{}, fmt.Errorf("read pantry: %w", err)
+		return nil, fmt.Errorf("read pantry: %w", err)
 	}
-	var p Pantry
-	return p, json.Unmarshal(b, &p)
+	p, err := ParsePantry(b)
+	if err != nil {
+		return nil, err
+	}
+	return p.AsOf(asOf)
 }
 
 func remainingFreshness(ing Ingredient, currentDay int) int {
 	if ing.PerishableDays == 0 {
-		return 9999
+		return NonPerishableDaysLeft
 	}
 	return ing.PerishableDays - (currentDay - ing.AddedDay)
 }
 
-func getDaysLeft(ing Ingredient, currentDay int) int {
+func getDaysLeft(ing Ingredient, currentDay int, today Date) int {
+	if ing.NonPerishable {
+		return NonPerishableDaysLeft
+	}
+	if !ing.Expires.IsZero() {
+		return today.DaysUntil(ing.Expires)
+	}
 	// If days_left is directly specified in the JSON, use that
 	if ing.DaysLeft > 0 {
 		return ing.DaysLeft
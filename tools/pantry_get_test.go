@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"pantryagent/tools/storage"
+	"pantryagent/tools/storage/format"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -301,3 +302,45 @@ func TestPantryGet_LargeQuantities(t *testing.T) {
 
 	assert.Equal(t, expected, result)
 }
+
+func TestPantryGet_Formats(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []any
+		wantErr error
+	}{
+		{
+			name: "legacy never-expires sentinel",
+			data: `{"ingredients": [{"name": "rice", "qty": 1000, "unit": "g", "days_left": 9999}, {"name": "milk", "qty": 1, "unit": "L", "days_left": 5}]}`,
+			want: []any{
+				map[string]any{"name": "rice", "qty": 1000.0, "unit": "g", "days_left": 9999.0},
+				map[string]any{"name": "milk", "qty": 1.0, "unit": "L", "days_left": 5.0},
+			},
+		},
+		{
+			name: "current envelope",
+			data: `{"kind": "pantry", "version": 2, "data": {"ingredients": [{"name": "rice", "qty": 1000, "unit": "g", "non_perishable": true}]}}`,
+			want: []any{
+				map[string]any{"name": "rice", "qty": 1000.0, "unit": "g", "days_left": 9999.0},
+			},
+		},
+		{
+			name:    "newer version",
+			data:    `{"kind": "pantry", "version": 99, "data": {"ingredients": []}}`,
+			wantErr: format.ErrUnsupportedVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewPantryGet(storage.NewTestPantryState([]byte(tt.data))).Run(context.Background(), map[string]any{})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, result["pantry"].(map[string]any)["ingredients"])
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"pantryagent/tools/storage"
+	"pantryagent/tools/storage/format"
+)
+
+// DefaultUpdateAttempts is the number of load-apply-save rounds UpdatePantry makes by default.
+const DefaultUpdateAttempts = 5
+
+// ErrInsufficientQuantity is returned (wrapped) when a delta consumes more of an ingredient than the pantry has.
+var ErrInsufficientQuantity = errors.New("insufficient quantity")
+
+// IngredientDelta is a change to the pantry: a positive Qty adds to an ingredient (creating it if needed),
+// a negative one consumes it (removing it once used up). Deltas commute, so a delta computed against one
+// version of the pantry can be replayed on a newer one.
+type IngredientDelta struct {
+	Name string  `json:"name"`
+	Unit string  `json:"unit"`
+	Qty  float64 `json:"qty"`
+}
+
+// Apply applies deltas to the pantry's Ingredients in order, ignoring its events. Ingredients are matched
+// by name, ignoring case, and must use the same unit. On error the pantry is left unchanged.
+func (p *Pantry) Apply(deltas ...IngredientDelta) error {
+	applied, err := (&Pantry{Ingredients: p.Ingredients, Events: deltaEvents(deltas, time.Time{}, "")}).Current()
+	if err != nil {
+		return err
+	}
+	p.Ingredients = applied.Ingredients
+	return nil
+}
+
+// deltaEvents returns deltas as events at the given time: positive quantities are purchases, negative
+// ones consumption.
+func deltaEvents(deltas []IngredientDelta, at time.Time, source string) []PantryEvent {
+	var events []PantryEvent
+	for _, d := range deltas {
+		switch {
+		case d.Qty > 0:
+			events = append(events, PantryEvent{Type: Purchased, At: at, Name: d.Name, Qty: d.Qty, Unit: d.Unit, Source: source})
+		case d.Qty < 0:
+			events = append(events, PantryEvent{Type: Consumed, At: at, Name: d.Name, Qty: -d.Qty, Unit: d.Unit, Source: source})
+		}
+	}
+	return events
+}
+
+// UpdatePantry records deltas in the stored pantry's event log, as purchases and consumption at the
+// current time; see RecordPantryEvents.
+func UpdatePantry(ctx context.Context, state storage.VersionedPantryState, deltas []IngredientDelta, attempts int) (*Pantry, error) {
+	return RecordPantryEvents(ctx, state, deltaEvents(deltas, time.Now(), ""), attempts)
+}
+
+// RecordPantryEvents appends events to the stored pantry's log with optimistic concurrency: it loads the
+// pantry, records the events and saves it conditionally. When another writer got there first, it
+// reloads and records the events on their version, up to attempts times (DefaultUpdateAttempts if not
+// positive). It returns the current pantry after the save, or the last *storage.ConflictError if every
+// attempt lost.
+func RecordPantryEvents(ctx context.Context, state storage.VersionedPantryState, events []PantryEvent, attempts int) (*Pantry, error) {
+	if attempts <= 0 {
+		attempts = DefaultUpdateAttempts
+	}
+
+	var err error
+	for range attempts {
+		var pantry *Pantry
+		if pantry, err = tryRecordPantryEvents(ctx, state, events); !errors.Is(err, storage.ErrConflict) {
+			return pantry, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return nil, fmt.Errorf("update pantry: gave up after %d attempts: %w", attempts, err)
+}
+
+func tryRecordPantryEvents(ctx context.Context, state storage.VersionedPantryState, events []PantryEvent) (*Pantry, error) {
+	data, version, err := state.LoadVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load pantry: %w", err)
+	}
+	pantry, err := ParsePantry(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := pantry.Record(events...); err != nil {
+		return nil, err
+	}
+
+	updated, err := format.Encode(format.Pantry, pantry)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := state.Save(ctx, updated, version); err != nil {
+		return nil, err
+	}
+	return pantry.Current()
+}
+
+// ParsePantry parses a stored pantry document of any format version.
+func ParsePantry(data []byte) (*Pantry, error) {
+	doc, _, err := format.Decode(format.Pantry, data)
+	if err != nil {
+		return nil, err
+	}
+	var pantry Pantry
+	if err := json.Unmarshal(doc, &pantry); err != nil {
+		return nil, fmt.Errorf("parse pantry: %w", err)
+	}
+	return &pantry, nil
+}
@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"pantryagent/tools/storage"
+	"pantryagent/tools/storage/format"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPantry_Apply(t *testing.T) {
+	base := func() *Pantry {
+		return &Pantry{Ingredients: []Ingredient{
+			{Name: "egg", Qty: 12, Unit: "count", DaysLeft: 10},
+			{Name: "Milk", Qty: 1, Unit: "L", DaysLeft: 3},
+		}}
+	}
+
+	tests := []struct {
+		name    string
+		deltas  []IngredientDelta
+		want    []Ingredient
+		wantErr error
+		errMsg  string
+	}{
+		{
+			name:   "add and consume",
+			deltas: []IngredientDelta{{Name: "egg", Unit: "count", Qty: -4}, {Name: "milk", Unit: "L", Qty: 0.5}},
+			want:   []Ingredient{{Name: "egg", Qty: 8, Unit: "count", DaysLeft: 10}, {Name: "Milk", Qty: 1.5, Unit: "L", DaysLeft: 3}},
+		},
+		{
+			name:   "new ingredient",
+			deltas: []IngredientDelta{{Name: "rice", Unit: "g", Qty: 500}},
+			want:   []Ingredient{{Name: "egg", Qty: 12, Unit: "count", DaysLeft: 10}, {Name: "Milk", Qty: 1, Unit: "L", DaysLeft: 3}, {Name: "rice", Qty: 500, Unit: "g"}},
+		},
+		{
+			name:   "used up",
+			deltas: []IngredientDelta{{Name: "egg", Unit: "count", Qty: -12}},
+			want:   []Ingredient{{Name: "Milk", Qty: 1, Unit: "L", DaysLeft: 3}},
+		},
+		{name: "too much", deltas: []IngredientDelta{{Name: "egg", Unit: "count", Qty: -2}, {Name: "milk", Unit: "L", Qty: -2}}, wantErr: ErrInsufficientQuantity},
+		{name: "missing", deltas: []IngredientDelta{{Name: "rice", Unit: "g", Qty: -1}}, wantErr: ErrInsufficientQuantity},
+		{name: "unit mismatch", deltas: []IngredientDelta{{Name: "milk", Unit: "ml", Qty: 100}}, errMsg: `unit "ml" does not match pantry unit "L"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pantry := base()
+			err := pantry.Apply(tt.deltas...)
+			if tt.wantErr != nil || tt.errMsg != "" {
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+				}
+				assert.ErrorContains(t, err, tt.errMsg)
+				assert.Equal(t, base(), pantry, "pantry must be unchanged on error")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, pantry.Ingredients)
+		})
+	}
+}
+
+func TestUpdatePantry(t *testing.T) {
+	ctx := context.Background()
+	state := storage.NewTestPantryState([]byte(`{"ingredients": [{"name": "egg", "qty": 12, "unit": "count"}]}`))
+
+	// Another writer adds milk while the first attempt is in flight; the eggs are replayed on their version
+	concurrent := true
+	state.BeforeSave = func() {
+		if concurrent {
+			concurrent = false
+			state.Set([]byte(`{"ingredients": [{"name": "egg", "qty": 10, "unit": "count"}, {"name": "milk", "qty": 1, "unit": "L"}]}`))
+		}
+	}
+
+	pantry, err := UpdatePantry(ctx, state, []IngredientDelta{{Name: "egg", Unit: "count", Qty: -6}}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []Ingredient{{Name: "egg", Qty: 4, Unit: "count"}, {Name: "milk", Qty: 1, Unit: "L"}}, pantry.Ingredients)
+
+	// The pantry is saved at the current format version
+	data, err := state.Load(ctx)
+	require.NoError(t, err)
+	doc, version, err := format.Decode(format.Pantry, data)
+	require.NoError(t, err)
+	assert.Equal(t, format.CurrentVersion(format.Pantry), version)
+	var stored Pantry
+	require.NoError(t, json.Unmarshal(doc, &stored))
+
+	// The change is recorded in the event log rather than rewriting the ingredients
+	require.Len(t, stored.Events, 1)
+	assert.Equal(t, Consumed, stored.Events[0].Type)
+	assert.Equal(t, 6.0, stored.Events[0].Qty)
+	assert.Equal(t, 10.0, stored.Ingredients[0].Qty)
+	current, err := stored.Current()
+	require.NoError(t, err)
+	assert.Equal(t, pantry, current)
+}
+
+func TestUpdatePantry_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("conflicts on every attempt", func(t *testing.T) {
+		state := storage.NewTestPantryState([]byte(`{"ingredients": []}`))
+		attempts := 0
+		state.BeforeSave = func() {
+			attempts++
+			state.Set([]byte(`{"ingredients": []}`))
+		}
+		_, err := UpdatePantry(ctx, state, []IngredientDelta{{Name: "egg", Unit: "count", Qty: 6}}, 3)
+		assert.ErrorIs(t, err, storage.ErrConflict)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("merge fails after a concurrent change", func(t *testing.T) {
+		state := storage.NewTestPantryState([]byte(`{"ingredients": [{"name": "egg", "qty": 12, "unit": "count"}]}`))
+		state.BeforeSave = func() {
+			state.BeforeSave = nil
+			state.Set([]byte(`{"ingredients": [{"name": "egg", "qty": 2, "unit": "count"}]}`))
+		}
+		_, err := UpdatePantry(ctx, state, []IngredientDelta{{Name: "egg", Unit: "count", Qty: -6}}, 0)
+		assert.ErrorIs(t, err, ErrInsufficientQuantity)
+	})
+
+	t.Run("load error", func(t *testing.T) {
+		_, err := UpdatePantry(ctx, storage.NewTestPantryStateWithError(), nil, 0)
+		assert.ErrorContains(t, err, "load pantry: not found")
+	})
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"pantryagent/tools/storage/format"
+)
+
+// Recipe is an entry of the recipe catalog. Tools serve recipes as stored; Recipe types the fields the
+// planner relies on, for checking catalogs and plans.
+type Recipe struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	MealTypes   []string           `json:"meal_types"`
+	Servings    int                `json:"servings"`
+	Ingredients []RecipeIngredient `json:"ingredients"`
+}
+
+// RecipeIngredient is what a recipe needs of an ingredient for its servings.
+type RecipeIngredient struct {
+	Name string  `json:"name"`
+	Qty  float64 `json:"qty"`
+	Unit string  `json:"unit"`
+}
+
+// ParseRecipes parses a stored recipe catalog, upgrading older format versions.
+func ParseRecipes(b []byte) ([]Recipe, error) {
+	data, _, err := format.Decode(format.Recipes, b)
+	if err != nil {
+		return nil, err
+	}
+	var recipes []Recipe
+	if err := json.Unmarshal(data, &recipes); err != nil {
+		return nil, fmt.Errorf("parse recipes: %w", err)
+	}
+	return recipes, nil
+}
+
+// ValidateRecipes returns the problems of a recipe catalog that would trip up planning, e.g.
+// `recipes[2] "soup": ingredients[0].qty must be positive`; none for a valid catalog.
+func ValidateRecipes(recipes []Recipe) []string {
+	var problems []string
+	seen := make(map[string]int, len(recipes))
+	for i, r := range recipes {
+		report := func(format string, args ...any) {
+			problems = append(problems, fmt.Sprintf("recipes[%d] %q: ", i, r.ID)+fmt.Sprintf(format, args...))
+		}
+		if strings.TrimSpace(r.ID) == "" {
+			report("id must not be empty")
+		} else if j, ok := seen[r.ID]; ok {
+			report("duplicate id of recipes[%d]", j)
+		} else {
+			seen[r.ID] = i
+		}
+		if strings.TrimSpace(r.Name) == "" {
+			report("name must not be empty")
+		}
+		if len(r.MealTypes) == 0 {
+			report("meal_types must not be empty")
+		}
+		if r.Servings <= 0 {
+			report("servings must be positive")
+		}
+		if len(r.Ingredients) == 0 {
+			report("ingredients must not be empty")
+		}
+		for j, ing := range r.Ingredients {
+			switch {
+			case strings.TrimSpace(ing.Name) == "":
+				report("ingredients[%d].name must not be empty", j)
+			case ing.Qty <= 0 || math.IsInf(ing.Qty, 0) || math.IsNaN(ing.Qty):
+				report("ingredients[%d].qty must be positive", j)
+			case ing.Unit == "":
+				report("ingredients[%d].unit must not be empty", j)
+			}
+		}
+	}
+	return problems
+}
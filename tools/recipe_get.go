@@ -4,13 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 
 	"github.com/modelcontextprotocol/go-sdk/jsonschema"
 
 	"pantryagent/tools/storage"
+	"pantryagent/tools/storage/format"
 )
 
-type RecipeGet struct{ state storage.RecipeState }
+type RecipeGet struct {
+	state storage.RecipeState
+
+	// The parsed catalog of a storage.GenerationLoader state, e.g. cached S3 recipes in a warm Lambda,
+	// kept until the state's generation changes
+	mu         sync.Mutex
+	parsed     []map[string]any
+	generation uint64
+}
 
 func NewRecipeGet(state storage.RecipeState) *RecipeGet { return &RecipeGet{state: state} }
 
@@ -49,12 +59,16 @@ func (t *RecipeGet) OutputSchema() *jsonschema.Schema {
 }
 
 func (t *RecipeGet) Run(ctx context.Context, input map[string]any) (map[string]any, error) {
+	raw, _ := input["meal_types"].([]any)
+	if finder, ok := t.state.(storage.RecipeFinder); ok && len(raw) > 0 {
+		return t.find(ctx, finder, raw)
+	}
+
 	recipes, err := t.load(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	raw, _ := input["meal_types"].([]any)
 	if len(raw) == 0 {
 		return map[string]any{"recipes": recipes}, nil
 	}
@@ -87,13 +101,61 @@ func (t *RecipeGet) Run(ctx context.Context, input map[string]any) (map[string]a
 	return map[string]any{"recipes": out}, nil
 }
 
+// find uses the state's indexed lookup instead of filtering the whole catalog.
+func (t *RecipeGet) find(ctx context.Context, finder storage.RecipeFinder, raw []any) (map[string]any, error) {
+	mealTypes := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if s, _ := v.(string); s != "" {
+			mealTypes = append(mealTypes, s)
+		}
+	}
+	b, err := finder.FindByMealTypes(ctx, mealTypes)
+	if err != nil {
+		return nil, fmt.Errorf("read recipes: %w", err)
+	}
+	recipes, err := parseRecipes(b)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"recipes": recipes}, nil
+}
+
+// load returns the catalog. The recipes of a storage.GenerationLoader state are parsed once per
+// generation and shared, so they must not be modified.
 func (t *RecipeGet) load(ctx context.Context) ([]map[string]any, error) {
-	b, err := t.state.Load(ctx)
+	gl, ok := t.state.(storage.GenerationLoader)
+	if !ok {
+		b, err := t.state.Load(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("read recipes: %w", err)
+		}
+		return parseRecipes(b)
+	}
+
+	b, generation, err := gl.LoadGeneration(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("read recipes: %w", err)
 	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.parsed != nil && generation == t.generation {
+		return t.parsed, nil
+	}
+	recipes, err := parseRecipes(b)
+	if err != nil {
+		return nil, err
+	}
+	t.parsed, t.generation = recipes, generation
+	return recipes, nil
+}
+
+func parseRecipes(b []byte) ([]map[string]any, error) {
+	data, _, err := format.Decode(format.Recipes, b)
+	if err != nil {
+		return nil, err
+	}
 	var recipes []map[string]any
-	if err := json.Unmarshal(b, &recipes); err != nil {
+	if err := json.Unmarshal(data, &recipes); err != nil {
 		return nil, fmt.Errorf("parse recipes: %w", err)
 	}
 	return recipes, nil
@@ -177,6 +177,27 @@ func TestRecipeGet_Run(t *testing.T) {
 	})
 }
 
+func TestRecipeGet_CachedState(t *testing.T) {
+	ctx := context.Background()
+	source := storage.NewTestPantryState([]byte(`[{"id": "toast", "meal_types": ["breakfast"]}]`))
+	cached := storage.NewCachedPantryState(source, 0)
+	tool := NewRecipeGet(cached)
+
+	first, err := tool.Run(ctx, map[string]any{})
+	require.NoError(t, err)
+	again, err := tool.Run(ctx, map[string]any{})
+	require.NoError(t, err)
+	assert.Same(t, &first["recipes"].([]map[string]any)[0], &again["recipes"].([]map[string]any)[0], "parsed once per generation")
+
+	_, version, err := cached.LoadVersion(ctx)
+	require.NoError(t, err)
+	_, err = cached.Save(ctx, []byte(`[{"id": "pasta", "meal_types": ["dinner"]}]`), version)
+	require.NoError(t, err)
+	out, err := tool.Run(ctx, map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, "pasta", out["recipes"].([]map[string]any)[0]["id"])
+}
+
 func TestRecipeGet_ToolMethods(t *testing.T) {
 	testState := storage.NewTestRecipeState([]byte("[]"))
 	tool := NewRecipeGet(testState)
@@ -0,0 +1,46 @@
+package tools
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRecipes(t *testing.T) {
+	data, err := os.ReadFile("../artifacts/recipes.json")
+	require.NoError(t, err)
+	recipes, err := ParseRecipes(data)
+	require.NoError(t, err)
+	require.NotEmpty(t, recipes)
+	assert.Equal(t, "veggie-tacos", recipes[0].ID)
+	assert.Equal(t, RecipeIngredient{Name: "tortilla", Qty: 4, Unit: "count"}, recipes[0].Ingredients[0])
+	assert.Empty(t, ValidateRecipes(recipes), "the artifacts are valid")
+
+	_, err = ParseRecipes([]byte(`{"id": "soup"}`))
+	assert.ErrorContains(t, err, "parse recipes")
+}
+
+func TestValidateRecipes(t *testing.T) {
+	soup := Recipe{ID: "soup", Name: "Soup", MealTypes: []string{"dinner"}, Servings: 2,
+		Ingredients: []RecipeIngredient{{Name: "carrot", Qty: 2, Unit: "count"}}}
+	assert.Empty(t, ValidateRecipes([]Recipe{soup}))
+
+	problems := ValidateRecipes([]Recipe{
+		soup,
+		soup,
+		{Name: "Nameless", MealTypes: []string{"lunch"}, Servings: 1, Ingredients: []RecipeIngredient{{Name: "egg", Qty: 1, Unit: "count"}}},
+		{ID: "stew", Ingredients: []RecipeIngredient{{Name: "beef", Qty: 0, Unit: "g"}, {Name: "salt", Qty: 1}, {Qty: 1, Unit: "g"}}},
+	})
+	assert.Equal(t, []string{
+		`recipes[1] "soup": duplicate id of recipes[0]`,
+		`recipes[2] "": id must not be empty`,
+		`recipes[3] "stew": name must not be empty`,
+		`recipes[3] "stew": meal_types must not be empty`,
+		`recipes[3] "stew": servings must be positive`,
+		`recipes[3] "stew": ingredients[0].qty must be positive`,
+		`recipes[3] "stew": ingredients[1].unit must not be empty`,
+		`recipes[3] "stew": ingredients[2].name must not be empty`,
+	}, problems)
+}
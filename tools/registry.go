@@ -1,39 +1,222 @@
 package tools
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
 
 	"pantryagent/tools/storage"
 )
 
-// Registry maps tool names to implementations
-type Registry map[string]Tool
+// ErrToolNotFound is returned (wrapped) by GetTool when no tool has the requested name.
+var ErrToolNotFound = errors.New("not found in registry")
 
-// NewRegistry creates a new tool registry with the given pantry and recipe states.
+// ErrDuplicateTool is returned (wrapped) by Register when a tool with the same name is already registered.
+var ErrDuplicateTool = errors.New("already in registry")
+
+// ErrInvalidToolName is returned (wrapped) by Register for names model APIs reject.
+var ErrInvalidToolName = errors.New("invalid tool name")
+
+// MaxToolNameLength is the longest tool name Register accepts.
+const MaxToolNameLength = 64
+
+// validToolName is the name format accepted by the model APIs (Bedrock's is the strictest).
+var validToolName = regexp.MustCompile(fmt.Sprintf(`^[a-zA-Z0-9_-]{1,%d}$`, MaxToolNameLength))
+
+// Metadata describes how a registered tool behaves.
+type Metadata struct {
+	// ReadOnly tools do not change any state and are safe to retry.
+	ReadOnly bool
+	// Timeout bounds each call of the tool; zero means no limit besides the caller's context.
+	Timeout time.Duration
+	// Tags group tools for filtered views, e.g. "pantry" or "mcp".
+	Tags []string
+}
+
+// HasTag reports whether m is tagged with tag.
+func (m Metadata) HasTag(tag string) bool {
+	return slices.Contains(m.Tags, tag)
+}
+
+type registration struct {
+	tool Tool
+	meta Metadata
+}
+
+// Registry holds the tools available to the agent. Tools are listed by name, so prompts built from a
+// registry are identical from run to run. The zero value is an empty registry ready to use, and a
+// registry is safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]registration
+}
+
+// NewRegistry creates a new tool registry with the built-in pantry and recipe tools.
 func NewRegistry(pantry storage.PantryState, recipes storage.RecipeState) (*Registry, error) {
-	tools := map[string]Tool{
-		"pantry_get": NewPantryGet(pantry),
-		"recipe_get": NewRecipeGet(recipes),
+	registry := &Registry{}
+	if err := registry.Register(NewPantryGet(pantry), Metadata{ReadOnly: true, Tags: []string{"pantry"}}); err != nil {
+		return nil, err
+	}
+	if err := registry.Register(NewRecipeGet(recipes), Metadata{ReadOnly: true, Tags: []string{"recipes"}}); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// Register adds tool to the registry. Registered tools validate their inputs and outputs against their
+// schemas (see WithValidation) and are stopped after meta.Timeout.
+func (r *Registry) Register(tool Tool, meta Metadata) error {
+	name := tool.Name()
+	if !validToolName.MatchString(name) {
+		return fmt.Errorf("tool %q: %w: use 1 to %d letters, digits, '_' or '-'", name, ErrInvalidToolName, MaxToolNameLength)
+	}
+
+	validated, err := WithValidation(tool)
+	if err != nil {
+		return err
+	}
+	if meta.Timeout > 0 {
+		validated = &timedTool{Tool: validated, timeout: meta.Timeout}
+	}
+	meta.Tags = slices.Clone(meta.Tags)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tools[name]; exists {
+		return fmt.Errorf("tool %q %w", name, ErrDuplicateTool)
 	}
+	if r.tools == nil {
+		r.tools = map[string]registration{}
+	}
+	r.tools[name] = registration{tool: validated, meta: meta}
+	return nil
+}
 
-	registry := Registry(tools)
-	return &registry, nil
+// Unregister removes the named tool and reports whether it was registered.
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, exists := r.tools[name]
+	delete(r.tools, name)
+	return exists
 }
 
-// GetTools returns all tools in the registry as a slice
+// GetTools returns all tools in the registry, sorted by name.
 func (r *Registry) GetTools() []Tool {
-	tools := make([]Tool, 0, len(*r))
-	for _, tool := range *r {
-		tools = append(tools, tool)
+	return r.filter(nil)
+}
+
+// GetTool retrieves a tool by name from the registry
+func (r *Registry) GetTool(name string) (Tool, error) {
+	tool, _, err := r.lookup(name, nil)
+	return tool, err
+}
+
+// Metadata returns the metadata the named tool was registered with.
+func (r *Registry) Metadata(name string) (Metadata, bool) {
+	_, meta, err := r.lookup(name, nil)
+	return meta, err == nil
+}
+
+// View returns a live view of the tools matching all filters, e.g. the read-only tools for a
+// coordinator that must not change anything. Tools registered later appear in the view if they match.
+func (r *Registry) View(filters ...Filter) *View {
+	return &View{registry: r, filters: filters}
+}
+
+func (r *Registry) lookup(name string, filters []Filter) (Tool, Metadata, error) {
+	r.mu.RLock()
+	reg, exists := r.tools[name]
+	r.mu.RUnlock()
+	if !exists || !matches(reg, filters) {
+		return nil, Metadata{}, fmt.Errorf("tool %q %w", name, ErrToolNotFound)
+	}
+	return reg.tool, reg.meta, nil
+}
+
+func (r *Registry) filter(filters []Filter) []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]Tool, 0, len(r.tools))
+	for _, reg := range r.tools {
+		if matches(reg, filters) {
+			tools = append(tools, reg.tool)
+		}
 	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name() < tools[j].Name() })
 	return tools
 }
 
-// GetTool retrieves a tool by name from the registry
-func (r Registry) GetTool(name string) (Tool, error) {
-	tool, exists := r[name]
-	if !exists {
-		return nil, fmt.Errorf("tool %q not found in registry", name)
+// Filter selects tools for a View.
+type Filter func(tool Tool, meta Metadata) bool
+
+// ReadOnlyTools selects the tools registered as read-only.
+func ReadOnlyTools(_ Tool, meta Metadata) bool { return meta.ReadOnly }
+
+// Tagged selects the tools with at least one of tags.
+func Tagged(tags ...string) Filter {
+	return func(_ Tool, meta Metadata) bool {
+		return slices.ContainsFunc(tags, meta.HasTag)
+	}
+}
+
+// Named selects the tools with one of names.
+func Named(names ...string) Filter {
+	return func(tool Tool, _ Metadata) bool {
+		return slices.Contains(names, tool.Name())
+	}
+}
+
+func matches(reg registration, filters []Filter) bool {
+	for _, keep := range filters {
+		if !keep(reg.tool, reg.meta) {
+			return false
+		}
+	}
+	return true
+}
+
+// View is a filtered view of a Registry; see Registry.View.
+type View struct {
+	registry *Registry
+	filters  []Filter
+}
+
+// GetTools returns the tools in the view, sorted by name.
+func (v *View) GetTools() []Tool {
+	return v.registry.filter(v.filters)
+}
+
+// GetTool retrieves a tool in the view by name; tools filtered out are not found.
+func (v *View) GetTool(name string) (Tool, error) {
+	tool, _, err := v.registry.lookup(name, v.filters)
+	return tool, err
+}
+
+// timedTool bounds each run of a Tool; see Metadata.Timeout.
+type timedTool struct {
+	Tool
+	timeout time.Duration
+}
+
+func (t *timedTool) Run(ctx context.Context, input map[string]any) (map[string]any, error) {
+	runCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	output, err := t.Tool.Run(runCtx, input)
+	if err != nil && ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return nil, &ToolError{
+			Tool:    t.Name(),
+			Code:    ErrCodeToolFailed,
+			Message: fmt.Sprintf("timed out after %s", t.timeout),
+			Hint:    "The tool took too long; try again later or continue without it.",
+			Err:     err,
+		}
 	}
-	return tool, nil
+	return output, err
 }
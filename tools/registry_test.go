@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pantryagent/tools/storage"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// namedTool is a schemaless tool with a configurable name, blocking until ctx is done when slow.
+type namedTool struct {
+	name string
+	slow bool
+}
+
+func (t *namedTool) Name() string                     { return t.name }
+func (t *namedTool) Title() string                    { return t.name }
+func (t *namedTool) Description() string              { return "Tool for registry tests" }
+func (t *namedTool) InputSchema() *jsonschema.Schema  { return nil }
+func (t *namedTool) OutputSchema() *jsonschema.Schema { return nil }
+func (t *namedTool) Run(ctx context.Context, input map[string]any) (map[string]any, error) {
+	if t.slow {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return map[string]any{"tool": t.name}, nil
+}
+
+func toolNames(tools []Tool) []string {
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Name()
+	}
+	return names
+}
+
+func TestRegistry_Register(t *testing.T) {
+	registry, err := NewRegistry(storage.NewTestPantryState(nil), storage.NewTestRecipeState(nil))
+	require.NoError(t, err)
+
+	require.NoError(t, registry.Register(&stubTool{output: map[string]any{"count": 1}}, Metadata{Tags: []string{"test"}}))
+	tool, err := registry.GetTool("stub")
+	require.NoError(t, err)
+	_, err = tool.Run(context.Background(), map[string]any{"current_day": "5"})
+	toolErr, ok := AsToolError(err)
+	require.True(t, ok, "expected a ToolError, got %v", err)
+	assert.Equal(t, ErrCodeInvalidInput, toolErr.Code)
+
+	meta, ok := registry.Metadata("stub")
+	require.True(t, ok)
+	assert.Equal(t, Metadata{Tags: []string{"test"}}, meta)
+	meta, ok = registry.Metadata("pantry_get")
+	require.True(t, ok)
+	assert.True(t, meta.ReadOnly)
+
+	assert.ErrorIs(t, registry.Register(&stubTool{}, Metadata{}), ErrDuplicateTool)
+	assert.ErrorIs(t, registry.Register(&namedTool{name: "price.get"}, Metadata{}), ErrInvalidToolName)
+	assert.ErrorIs(t, registry.Register(&namedTool{name: ""}, Metadata{}), ErrInvalidToolName)
+
+	assert.True(t, registry.Unregister("stub"))
+	assert.False(t, registry.Unregister("stub"))
+	_, err = registry.GetTool("stub")
+	assert.ErrorIs(t, err, ErrToolNotFound)
+	_, ok = registry.Metadata("stub")
+	assert.False(t, ok)
+}
+
+func TestRegistry_GetToolsSorted(t *testing.T) {
+	var registry Registry
+	for _, name := range []string{"zeta", "alpha", "mid_b", "mid_a"} {
+		require.NoError(t, registry.Register(&namedTool{name: name}, Metadata{}))
+	}
+
+	for range 10 {
+		assert.Equal(t, []string{"alpha", "mid_a", "mid_b", "zeta"}, toolNames(registry.GetTools()))
+	}
+}
+
+func TestRegistry_View(t *testing.T) {
+	registry, err := NewRegistry(storage.NewTestPantryState(nil), storage.NewTestRecipeState(nil))
+	require.NoError(t, err)
+	require.NoError(t, registry.Register(&namedTool{name: "pantry_add"}, Metadata{Tags: []string{"pantry"}}))
+
+	readOnly := registry.View(ReadOnlyTools)
+	assert.Equal(t, []string{"pantry_get", "recipe_get"}, toolNames(readOnly.GetTools()))
+	_, err = readOnly.GetTool("pantry_add")
+	assert.ErrorIs(t, err, ErrToolNotFound)
+
+	pantry := registry.View(Tagged("pantry"))
+	assert.Equal(t, []string{"pantry_add", "pantry_get"}, toolNames(pantry.GetTools()))
+	assert.Equal(t, []string{"pantry_get"}, toolNames(registry.View(Tagged("pantry"), ReadOnlyTools).GetTools()))
+	assert.Equal(t, []string{"recipe_get"}, toolNames(registry.View(Named("recipe_get", "missing")).GetTools()))
+
+	// Views are live
+	require.NoError(t, registry.Register(&namedTool{name: "pantry_count"}, Metadata{ReadOnly: true, Tags: []string{"pantry"}}))
+	assert.Equal(t, []string{"pantry_count", "pantry_get", "recipe_get"}, toolNames(readOnly.GetTools()))
+	tool, err := pantry.GetTool("pantry_count")
+	require.NoError(t, err)
+	assert.Equal(t, "pantry_count", tool.Name())
+}
+
+func TestRegistry_Timeout(t *testing.T) {
+	var registry Registry
+	require.NoError(t, registry.Register(&namedTool{name: "slow", slow: true}, Metadata{Timeout: 10 * time.Millisecond}))
+	require.NoError(t, registry.Register(&namedTool{name: "fast"}, Metadata{Timeout: time.Second}))
+
+	slow, err := registry.GetTool("slow")
+	require.NoError(t, err)
+	_, err = slow.Run(context.Background(), nil)
+	toolErr, ok := AsToolError(err)
+	require.True(t, ok, "expected a ToolError, got %v", err)
+	assert.Equal(t, ErrCodeToolFailed, toolErr.Code)
+	assert.Equal(t, "timed out after 10ms", toolErr.Message)
+
+	// A cancelled caller is not a tool failure
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = slow.Run(ctx, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, ok = AsToolError(err)
+	assert.False(t, ok)
+
+	fast, err := registry.GetTool("fast")
+	require.NoError(t, err)
+	out, err := fast.Run(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"tool": "fast"}, out)
+}
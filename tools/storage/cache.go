@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Loader loads a document; PantryState and RecipeState are Loaders.
+type Loader interface {
+	Load(ctx context.Context) ([]byte, error)
+}
+
+// Revalidator is implemented by states that can check a cached copy cheaply, e.g. with S3's
+// If-None-Match instead of downloading the object again.
+type Revalidator interface {
+	// LoadIfChanged returns the document and its version, or changed == false and no data if the stored
+	// document is still at version. The empty version always loads the document.
+	LoadIfChanged(ctx context.Context, version Version) (data []byte, current Version, changed bool, err error)
+}
+
+// GenerationLoader is implemented by caches that count the changes of their document, e.g. CachedState,
+// so callers can keep what they derive from it until the generation moves on.
+type GenerationLoader interface {
+	LoadGeneration(ctx context.Context) ([]byte, uint64, error)
+}
+
+// CachedState caches a Loader's document in memory for ttl. Past the ttl, sources implementing
+// Revalidator are asked whether the document changed; others are loaded again. A CachedState is meant
+// to live across requests, e.g. in a warm Lambda, and is safe for concurrent use.
+type CachedState struct {
+	source Loader
+	ttl    time.Duration
+	now    func() time.Time
+
+	// mu guards the cached document; fetches from the source run without it, one at a time
+	mu         sync.Mutex
+	data       []byte
+	version    Version
+	fetched    time.Time
+	loaded     bool
+	generation uint64
+	refresh    *refresh
+}
+
+// refresh is a fetch from the source in progress; loads needing it wait for done.
+type refresh struct {
+	done chan struct{}
+	err  error
+}
+
+// NewCachedState returns a cache for source. A zero ttl revalidates on every Load.
+func NewCachedState(source Loader, ttl time.Duration) *CachedState {
+	return &CachedState{source: source, ttl: ttl, now: time.Now}
+}
+
+// Load returns the cached document, refreshing it first if it is older than the ttl. The returned
+// bytes are shared and must not be modified.
+func (c *CachedState) Load(ctx context.Context) ([]byte, error) {
+	data, _, err := c.load(ctx)
+	return data, err
+}
+
+// LoadGeneration is Load also returning a counter that changes whenever the document does, so callers
+// can keep what they derive from it (e.g. a parsed catalog) until the generation moves on.
+func (c *CachedState) LoadGeneration(ctx context.Context) ([]byte, uint64, error) {
+	return c.load(ctx)
+}
+
+var _ GenerationLoader = (*CachedState)(nil)
+
+// Invalidate drops the cached document, so the next Load fetches it again.
+func (c *CachedState) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loaded = false
+	c.version = ""
+}
+
+func (c *CachedState) load(ctx context.Context) ([]byte, uint64, error) {
+	c.mu.Lock()
+	if c.loaded && c.now().Sub(c.fetched) < c.ttl {
+		defer c.mu.Unlock()
+		return c.data, c.generation, nil
+	}
+	if r := c.refresh; r != nil {
+		c.mu.Unlock()
+		return c.wait(ctx, r)
+	}
+	r := &refresh{done: make(chan struct{})}
+	c.refresh = r
+	version, generation := c.version, c.generation
+	c.mu.Unlock()
+
+	data, current, changed, err := c.fetch(ctx, version)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh, r.err = nil, err
+	close(r.done)
+	if err != nil {
+		return nil, 0, err
+	}
+	// A save or Invalidate while fetching wins over what was fetched
+	if c.generation == generation && c.version == version {
+		if changed || !c.loaded {
+			c.store(data, current)
+		}
+		c.fetched = c.now()
+	}
+	return c.data, c.generation, nil
+}
+
+// wait waits for the refresh in progress and returns its outcome.
+func (c *CachedState) wait(ctx context.Context, r *refresh) ([]byte, uint64, error) {
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.data, c.generation, nil
+}
+
+// fetch loads the document from the source, or only revalidates version with a Revalidator.
+func (c *CachedState) fetch(ctx context.Context, version Version) ([]byte, Version, bool, error) {
+	if rv, ok := c.source.(Revalidator); ok {
+		return rv.LoadIfChanged(ctx, version)
+	}
+	data, err := c.source.Load(ctx)
+	return data, "", true, err
+}
+
+// store replaces the cached document; callers hold mu.
+func (c *CachedState) store(data []byte, version Version) {
+	if !c.loaded || string(data) != string(c.data) {
+		c.generation++
+	}
+	c.data, c.version, c.loaded = data, version, true
+}
+
+// CachedPantryState is a CachedState for a versioned pantry. Load is served from the cache, while
+// LoadVersion and Save always go to the source, since conditional saves need the current version;
+// successful saves refresh the cache.
+type CachedPantryState struct {
+	*CachedState
+	source VersionedPantryState
+}
+
+var _ VersionedPantryState = (*CachedPantryState)(nil)
+
+// NewCachedPantryState returns a cache for source; see NewCachedState.
+func NewCachedPantryState(source VersionedPantryState, ttl time.Duration) *CachedPantryState {
+	return &CachedPantryState{CachedState: NewCachedState(source, ttl), source: source}
+}
+
+func (c *CachedPantryState) LoadVersion(ctx context.Context) ([]byte, Version, error) {
+	return c.source.LoadVersion(ctx)
+}
+
+func (c *CachedPantryState) Save(ctx context.Context, data []byte, version Version) (Version, error) {
+	saved, err := c.source.Save(ctx, data, version)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(data, saved)
+	c.fetched = c.now()
+	return saved, nil
+}
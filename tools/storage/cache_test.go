@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// revalidatingState is an in-memory Revalidator counting full loads and revalidations.
+type revalidatingState struct {
+	data          []byte
+	version       int
+	loads         int
+	revalidations int
+	err           error
+}
+
+func (s *revalidatingState) Load(ctx context.Context) ([]byte, error) {
+	s.loads++
+	return s.data, s.err
+}
+
+func (s *revalidatingState) LoadIfChanged(ctx context.Context, version Version) ([]byte, Version, bool, error) {
+	if s.err != nil {
+		return nil, "", false, s.err
+	}
+	current := Version(fmt.Sprintf("etag-%d", s.version))
+	if version == current {
+		s.revalidations++
+		return nil, current, false, nil
+	}
+	s.loads++
+	return s.data, current, true, nil
+}
+
+func (s *revalidatingState) set(data string) {
+	s.data = []byte(data)
+	s.version++
+}
+
+// fakeClock returns a clock func and a function advancing it.
+func fakeClock() (func() time.Time, func(time.Duration)) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time { return now }, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCachedState_Revalidation(t *testing.T) {
+	ctx := context.Background()
+	source := &revalidatingState{data: []byte(`v0`)}
+	cache := NewCachedState(source, time.Minute)
+	clock, advance := fakeClock()
+	cache.now = clock
+
+	data, gen, err := cache.LoadGeneration(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "v0", string(data))
+	assert.Equal(t, 1, source.loads)
+
+	// Within the ttl, the source is not asked at all
+	advance(30 * time.Second)
+	data, err = cache.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "v0", string(data))
+	assert.Equal(t, 1, source.loads)
+	assert.Equal(t, 0, source.revalidations)
+
+	// Past the ttl, an unchanged document is revalidated, not downloaded
+	advance(time.Minute)
+	data, gen2, err := cache.LoadGeneration(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "v0", string(data))
+	assert.Equal(t, gen, gen2)
+	assert.Equal(t, 1, source.loads)
+	assert.Equal(t, 1, source.revalidations)
+
+	// A changed document is downloaded and bumps the generation
+	source.set(`v1`)
+	advance(time.Minute)
+	data, gen3, err := cache.LoadGeneration(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(data))
+	assert.NotEqual(t, gen2, gen3)
+	assert.Equal(t, 2, source.loads)
+
+	cache.Invalidate()
+	_, err = cache.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, source.loads)
+
+	source.err = errors.New("unavailable")
+	advance(time.Minute)
+	_, err = cache.Load(ctx)
+	assert.EqualError(t, err, "unavailable")
+}
+
+func TestCachedState_PlainSource(t *testing.T) {
+	ctx := context.Background()
+	source := NewTestRecipeState([]byte(`[]`))
+	cache := NewCachedState(source, time.Minute)
+	clock, advance := fakeClock()
+	cache.now = clock
+
+	_, gen, err := cache.LoadGeneration(ctx)
+	require.NoError(t, err)
+
+	// Reloading the same content keeps the generation
+	advance(2 * time.Minute)
+	_, gen2, err := cache.LoadGeneration(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, gen, gen2)
+
+	source.data = []byte(`[{"id": "soup"}]`)
+	advance(2 * time.Minute)
+	data, gen3, err := cache.LoadGeneration(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"id": "soup"}]`, string(data))
+	assert.NotEqual(t, gen2, gen3)
+}
+
+// blockingState is a source whose loads wait for release.
+type blockingState struct {
+	started chan struct{}
+	release chan struct{}
+	loads   atomic.Int32
+}
+
+func (s *blockingState) Load(ctx context.Context) ([]byte, error) {
+	if s.loads.Add(1) == 1 {
+		close(s.started)
+	}
+	<-s.release
+	return []byte(`[]`), nil
+}
+
+func TestCachedState_ConcurrentLoads(t *testing.T) {
+	ctx := context.Background()
+	source := &blockingState{started: make(chan struct{}), release: make(chan struct{})}
+	cache := NewCachedState(source, time.Minute)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			data, err := cache.Load(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, `[]`, string(data))
+		})
+	}
+	<-source.started
+
+	// The fetch doesn't hold the cache, so a load that gives up returns right away
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := cache.Load(cancelled)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	close(source.release)
+	wg.Wait()
+	assert.Equal(t, int32(1), source.loads.Load(), "concurrent loads share a fetch")
+}
+
+func TestCachedPantryState(t *testing.T) {
+	ctx := context.Background()
+	source := NewTestPantryState([]byte(`{"ingredients": []}`))
+	cache := NewCachedPantryState(source, time.Hour)
+
+	_, err := cache.Load(ctx)
+	require.NoError(t, err)
+
+	// Conditional saves see the source's version, not the cached one
+	source.Set([]byte(`{"ingredients": [{"name": "egg"}]}`))
+	_, version, err := cache.LoadVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Version("v2"), version)
+
+	_, err = cache.Save(ctx, []byte(`{"ingredients": [{"name": "milk"}]}`), "v1")
+	assert.ErrorIs(t, err, ErrConflict)
+	_, err = cache.Save(ctx, []byte(`{"ingredients": [{"name": "milk"}]}`), version)
+	require.NoError(t, err)
+
+	// A successful save refreshes the cache
+	data, err := cache.Load(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "milk")
+}
@@ -2,11 +2,22 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sync"
+
+	"pantryagent/tools/storage/format"
 )
 
 type FilePantryState struct {
 	FilePath string
+
+	// mu serializes conditional saves within the process
+	mu sync.Mutex
 }
 
 func NewFilePantryState(filePath string) *FilePantryState {
@@ -17,6 +28,43 @@ func (p *FilePantryState) Load(ctx context.Context) ([]byte, error) {
 	return os.ReadFile(p.FilePath)
 }
 
+// LoadVersion returns the pantry with the hash of its content as version.
+func (p *FilePantryState) LoadVersion(ctx context.Context) ([]byte, Version, error) {
+	data, err := os.ReadFile(p.FilePath)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentVersion(data), nil
+}
+
+// Save replaces the pantry file if its content still hashes to version. The file is replaced atomically
+// (written to a temporary file, then renamed), so readers never see a partial pantry. Saves are
+// serialized within the process; across processes, a write landing between the check and the rename
+// can still be lost.
+func (p *FilePantryState) Save(ctx context.Context, data []byte, version Version) (Version, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current, err := os.ReadFile(p.FilePath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if version != "" {
+			return "", &ConflictError{Expected: version, Err: err}
+		}
+	case err != nil:
+		return "", err
+	default:
+		if actual := contentVersion(current); actual != version {
+			return "", &ConflictError{Expected: version, Actual: actual}
+		}
+	}
+
+	if err := writeFileAtomic(p.FilePath, "pantry", data); err != nil {
+		return "", err
+	}
+	return contentVersion(data), nil
+}
+
 type FileRecipeState struct {
 	FilePath string
 }
@@ -28,3 +76,40 @@ func NewFileRecipeState(filePath string) *FileRecipeState {
 func (r *FileRecipeState) Load(ctx context.Context) ([]byte, error) {
 	return os.ReadFile(r.FilePath)
 }
+
+// Replace replaces the recipe file with recipes, a recipes document (a JSON array of recipes, optionally
+// in a format envelope). The file is replaced atomically, like the pantry's.
+func (r *FileRecipeState) Replace(ctx context.Context, recipes []byte) error {
+	data, _, err := format.Decode(format.Recipes, recipes)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRecipes, err)
+	}
+	if err := json.Unmarshal(data, new([]json.RawMessage)); err != nil {
+		return fmt.Errorf("%w: parse recipes: %w", ErrInvalidRecipes, err)
+	}
+	return writeFileAtomic(r.FilePath, "recipes", recipes)
+}
+
+// writeFileAtomic writes data to a temporary file next to path, then renames it over path. what names
+// the document in errors.
+func writeFileAtomic(path, what string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temporary %s file: %w", what, err)
+	}
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() // nolint: errcheck
+		return fmt.Errorf("write temporary %s file: %w", what, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write temporary %s file: %w", what, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replace %s file: %w", what, err)
+	}
+	return nil
+}
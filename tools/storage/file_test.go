@@ -67,6 +67,63 @@ func TestFilePantryState(t *testing.T) {
 	})
 }
 
+func TestFilePantryState_Save(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "pantry.json")
+	pantryState := NewFilePantryState(filePath)
+
+	// An existing version cannot be saved over a missing file, the empty one creates it
+	_, err := pantryState.Save(ctx, []byte(`{"ingredients": []}`), "sha256:stale")
+	assert.ErrorIs(t, err, ErrConflict)
+	v1, err := pantryState.Save(ctx, []byte(`{"ingredients": []}`), "")
+	require.NoError(t, err)
+
+	data, version, err := pantryState.LoadVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, v1, version)
+	assert.Equal(t, `{"ingredients": []}`, string(data))
+
+	v2, err := pantryState.Save(ctx, []byte(`{"ingredients": [{"name": "egg", "qty": 12, "unit": "count"}]}`), v1)
+	require.NoError(t, err)
+	assert.NotEqual(t, v1, v2)
+
+	// Saving against the old version conflicts and leaves the file alone
+	_, err = pantryState.Save(ctx, []byte(`{"ingredients": []}`), v1)
+	var conflict *ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, v1, conflict.Expected)
+	assert.Equal(t, v2, conflict.Actual)
+	_, err = pantryState.Save(ctx, []byte(`{"ingredients": []}`), "")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	data, err = pantryState.Load(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "egg")
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(filePath))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestTestPantryState_Save(t *testing.T) {
+	ctx := context.Background()
+	state := NewTestPantryState([]byte(`{}`))
+
+	_, v1, err := state.LoadVersion(ctx)
+	require.NoError(t, err)
+	state.Set([]byte(`{"ingredients": []}`))
+
+	_, err = state.Save(ctx, []byte(`{}`), v1)
+	assert.ErrorIs(t, err, ErrConflict)
+
+	_, v2, err := state.LoadVersion(ctx)
+	require.NoError(t, err)
+	v3, err := state.Save(ctx, []byte(`{}`), v2)
+	require.NoError(t, err)
+	assert.Equal(t, []Version{"v1", "v2", "v3"}, []Version{v1, v2, v3})
+}
+
 func TestFileRecipeState(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "recipes_test")
 	require.NoError(t, err)
@@ -121,3 +178,24 @@ func TestFileRecipeState(t *testing.T) {
 		assert.True(t, os.IsNotExist(err))
 	})
 }
+
+func TestFileRecipeState_Replace(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "recipes.json")
+	require.NoError(t, os.WriteFile(filePath, []byte(`[{"id": "soup"}]`), 0644))
+	state := NewFileRecipeState(filePath)
+
+	recipes := []byte(`{"kind": "recipes", "version": 1, "data": [{"id": "stew"}]}`)
+	require.NoError(t, state.Replace(ctx, recipes))
+	loaded, err := state.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, recipes, loaded)
+
+	err = state.Replace(ctx, []byte(`{"id": "stew"}`))
+	assert.ErrorIs(t, err, ErrInvalidRecipes)
+	assert.ErrorContains(t, err, "parse recipes")
+	assert.ErrorContains(t, state.Replace(ctx, []byte(`{"kind": "pantry", "version": 3, "data": {}}`)), "found a pantry document")
+	loaded, err = state.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, recipes, loaded, "invalid recipes must not be written")
+}
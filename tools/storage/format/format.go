@@ -0,0 +1,160 @@
+// Package format versions the JSON documents the agent stores (the pantry and the recipe catalog).
+//
+// Documents are stored in an envelope naming their kind and format version:
+//
+//	{"kind": "pantry", "version": 3, "data": {"ingredients": [...], "events": [...]}}
+//
+// Documents without an envelope, as written before formats were versioned, are version 1. Readers use
+// Decode, which upgrades older documents in memory through the registered migrations, so changing the
+// data model does not break documents already in files or S3; writers use Encode. Upgrade rewrites a
+// stored document at the current version, see "pantry migrate".
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Kind is the kind of document held by an envelope.
+type Kind string
+
+const (
+	Pantry  Kind = "pantry"
+	Recipes Kind = "recipes"
+)
+
+// LegacyVersion is the version of documents stored without an envelope.
+const LegacyVersion = 1
+
+// ErrUnsupportedVersion is returned (wrapped) for documents newer than this build understands, e.g.
+// written by a newer release; they are rejected rather than misread.
+var ErrUnsupportedVersion = errors.New("unsupported format version")
+
+// Envelope wraps a stored document.
+type Envelope struct {
+	Kind    Kind            `json:"kind"`
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Migration upgrades the data of one kind of document from version From to From+1.
+type Migration struct {
+	Kind        Kind
+	From        int
+	Description string
+	Up          func(data json.RawMessage) (json.RawMessage, error)
+}
+
+// migrations lists every migration, in version order per kind. The current version of a kind is one
+// past its last migration.
+var migrations = []Migration{
+	{
+		Kind:        Pantry,
+		From:        1,
+		Description: "replace the days_left 9999 sentinel with non_perishable",
+		Up:          pantryNonPerishable,
+	},
+	{
+		// Nothing to convert: the version only changes so that releases unaware of the log reject
+		// pantries with events rather than report stale quantities
+		Kind:        Pantry,
+		From:        2,
+		Description: "add the pantry event log",
+		Up:          func(data json.RawMessage) (json.RawMessage, error) { return data, nil },
+	},
+}
+
+// CurrentVersion returns the version Encode writes for kind.
+func CurrentVersion(kind Kind) int {
+	version := LegacyVersion
+	for _, m := range migrations {
+		if m.Kind == kind && m.From >= version {
+			version = m.From + 1
+		}
+	}
+	return version
+}
+
+// Migrations returns the migrations taking a document of kind from version from to the current one.
+func Migrations(kind Kind, from int) []Migration {
+	var steps []Migration
+	for _, m := range migrations {
+		if m.Kind == kind && m.From >= from {
+			steps = append(steps, m)
+		}
+	}
+	return steps
+}
+
+// Parse returns the envelope of a stored document, wrapping documents without one at LegacyVersion. The
+// kind of a legacy document is unknown and left empty.
+func Parse(raw []byte) (Envelope, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '{' {
+		var env Envelope
+		if err := json.Unmarshal(raw, &env); err != nil {
+			return Envelope{}, err
+		}
+		if env.Kind != "" {
+			if env.Version < LegacyVersion {
+				return Envelope{}, fmt.Errorf("%s document: invalid version %d", env.Kind, env.Version)
+			}
+			return env, nil
+		}
+	}
+	if !json.Valid(raw) {
+		return Envelope{}, errors.New("invalid JSON document")
+	}
+	return Envelope{Version: LegacyVersion, Data: raw}, nil
+}
+
+// Decode returns the data of a stored document of kind, upgraded to the current version, along with the
+// version it was stored at.
+func Decode(kind Kind, raw []byte) (json.RawMessage, int, error) {
+	env, err := Parse(raw)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parse %s document: %w", kind, err)
+	}
+	if env.Kind != "" && env.Kind != kind {
+		return nil, 0, fmt.Errorf("parse %s document: found a %s document", kind, env.Kind)
+	}
+	if current := CurrentVersion(kind); env.Version > current {
+		return nil, 0, fmt.Errorf("%s document version %d: %w (latest is %d)", kind, env.Version, ErrUnsupportedVersion, current)
+	}
+
+	data := env.Data
+	for _, m := range Migrations(kind, env.Version) {
+		if data, err = m.Up(data); err != nil {
+			return nil, 0, fmt.Errorf("migrate %s document from version %d: %w", kind, m.From, err)
+		}
+	}
+	return data, env.Version, nil
+}
+
+// Encode returns data in an envelope of kind at the current version.
+func Encode(kind Kind, data any) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s document: %w", kind, err)
+	}
+	return json.MarshalIndent(Envelope{Kind: kind, Version: CurrentVersion(kind), Data: raw}, "", "  ")
+}
+
+// Upgrade returns a stored document of kind rewritten at the current version, with the version it was
+// stored at. Documents already in a current envelope are returned as they are.
+func Upgrade(kind Kind, raw []byte) ([]byte, int, error) {
+	data, from, err := Decode(kind, raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	if env, _ := Parse(raw); env.Kind == kind && from == CurrentVersion(kind) {
+		return raw, from, nil
+	}
+	upgraded, err := Encode(kind, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return upgraded, from, nil
+}
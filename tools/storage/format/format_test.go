@@ -0,0 +1,127 @@
+package format
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrentVersion(t *testing.T) {
+	assert.Equal(t, 3, CurrentVersion(Pantry))
+	assert.Equal(t, 1, CurrentVersion(Recipes))
+	assert.Len(t, Migrations(Pantry, 1), 2)
+	assert.Len(t, Migrations(Pantry, 2), 1)
+	assert.Empty(t, Migrations(Pantry, 3))
+	assert.Empty(t, Migrations(Recipes, 1))
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		kind        Kind
+		raw         string
+		want        string
+		wantVersion int
+		wantErr     string
+	}{
+		{
+			name:        "legacy pantry",
+			kind:        Pantry,
+			raw:         `{"ingredients": [{"name": "rice", "qty": 1000, "unit": "g", "days_left": 9999}, {"name": "milk", "qty": 1, "unit": "L", "days_left": 5, "perishable_days": 7}]}`,
+			want:        `{"ingredients": [{"name": "rice", "qty": 1000, "unit": "g", "non_perishable": true}, {"name": "milk", "qty": 1, "unit": "L", "days_left": 5, "perishable_days": 7}]}`,
+			wantVersion: 1,
+		},
+		{
+			name:        "legacy pantry keeps unknown fields",
+			kind:        Pantry,
+			raw:         `{"owner": "sam", "ingredients": [{"name": "salt", "days_left": 10000, "brand": "x"}]}`,
+			want:        `{"owner": "sam", "ingredients": [{"name": "salt", "non_perishable": true, "brand": "x"}]}`,
+			wantVersion: 1,
+		},
+		{
+			name:        "version 1 envelope is migrated",
+			kind:        Pantry,
+			raw:         `{"kind": "pantry", "version": 1, "data": {"ingredients": [{"name": "rice", "days_left": 9999}]}}`,
+			want:        `{"ingredients": [{"name": "rice", "non_perishable": true}]}`,
+			wantVersion: 1,
+		},
+		{
+			name:        "version 2 pantry",
+			kind:        Pantry,
+			raw:         `{"kind": "pantry", "version": 2, "data": {"ingredients": [{"name": "rice", "days_left": 9999}]}}`,
+			want:        `{"ingredients": [{"name": "rice", "days_left": 9999}]}`,
+			wantVersion: 2,
+		},
+		{
+			name:        "current pantry",
+			kind:        Pantry,
+			raw:         `{"kind": "pantry", "version": 3, "data": {"ingredients": [], "events": [{"type": "purchased", "name": "rice"}]}}`,
+			want:        `{"ingredients": [], "events": [{"type": "purchased", "name": "rice"}]}`,
+			wantVersion: 3,
+		},
+		{
+			name:        "legacy recipes",
+			kind:        Recipes,
+			raw:         `[{"id": "soup"}]`,
+			want:        `[{"id": "soup"}]`,
+			wantVersion: 1,
+		},
+		{
+			name:        "recipes envelope",
+			kind:        Recipes,
+			raw:         `{"kind": "recipes", "version": 1, "data": [{"id": "soup"}]}`,
+			want:        `[{"id": "soup"}]`,
+			wantVersion: 1,
+		},
+		{name: "newer version", kind: Pantry, raw: `{"kind": "pantry", "version": 4, "data": {}}`, wantErr: "unsupported format version"},
+		{name: "wrong kind", kind: Recipes, raw: `{"kind": "pantry", "version": 2, "data": {}}`, wantErr: "found a pantry document"},
+		{name: "invalid version", kind: Pantry, raw: `{"kind": "pantry", "version": 0, "data": {}}`, wantErr: "invalid version 0"},
+		{name: "invalid JSON", kind: Pantry, raw: `{"ingredients": [`, wantErr: "parse pantry document"},
+		{name: "invalid ingredients", kind: Pantry, raw: `{"ingredients": {}}`, wantErr: "migrate pantry document from version 1: ingredients"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, version, err := Decode(tt.kind, []byte(tt.raw))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(data))
+			assert.Equal(t, tt.wantVersion, version)
+		})
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	upgraded, from, err := Upgrade(Pantry, []byte(`{"ingredients": [{"name": "rice", "days_left": 9999}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, from)
+	assert.JSONEq(t, `{"kind": "pantry", "version": 3, "data": {"ingredients": [{"name": "rice", "non_perishable": true}]}}`, string(upgraded))
+
+	// Current documents are left as they are
+	again, from, err := Upgrade(Pantry, upgraded)
+	require.NoError(t, err)
+	assert.Equal(t, 3, from)
+	assert.Equal(t, upgraded, again)
+
+	upgraded, from, err = Upgrade(Recipes, []byte(`[]`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, from)
+	assert.JSONEq(t, `{"kind": "recipes", "version": 1, "data": []}`, string(upgraded))
+}
+
+func TestDecode_Artifacts(t *testing.T) {
+	for file, kind := range map[string]Kind{"pantry.json": Pantry, "recipes.json": Recipes} {
+		raw, err := os.ReadFile("../../../artifacts/" + file)
+		require.NoError(t, err)
+		data, _, err := Decode(kind, raw)
+		require.NoError(t, err, file)
+		assert.True(t, json.Valid(data), file)
+		assert.NotContains(t, string(data), "9999", file)
+	}
+}
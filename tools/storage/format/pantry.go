@@ -0,0 +1,48 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// NeverExpiresDays is the days_left that version 1 pantries used for ingredients that never expire.
+const NeverExpiresDays = 9999
+
+// pantryNonPerishable upgrades a version 1 pantry: ingredients with the days_left sentinel are marked
+// "non_perishable": true instead. Other fields are kept as they are.
+func pantryNonPerishable(data json.RawMessage) (json.RawMessage, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var ingredients []map[string]json.RawMessage
+	if raw, ok := doc["ingredients"]; ok {
+		if err := json.Unmarshal(raw, &ingredients); err != nil {
+			return nil, fmt.Errorf("ingredients: %w", err)
+		}
+	}
+
+	for i, ing := range ingredients {
+		raw, ok := ing["days_left"]
+		if !ok {
+			continue
+		}
+		var daysLeft float64
+		if err := json.Unmarshal(raw, &daysLeft); err != nil {
+			return nil, fmt.Errorf("ingredient %d: days_left: %w", i, err)
+		}
+		if daysLeft >= NeverExpiresDays {
+			delete(ing, "days_left")
+			ing["non_perishable"] = json.RawMessage(`true`)
+		}
+	}
+
+	if ingredients != nil {
+		raw, err := json.Marshal(ingredients)
+		if err != nil {
+			return nil, err
+		}
+		doc["ingredients"] = raw
+	}
+	return json.Marshal(doc)
+}
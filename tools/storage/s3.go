@@ -1,12 +1,16 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
 )
 
 // S3PantryState implements PantryState backed by S3
@@ -37,6 +41,50 @@ func (s *S3PantryState) Load(ctx context.Context) ([]byte, error) {
 	return io.ReadAll(resp.Body)
 }
 
+// LoadVersion returns the pantry with its ETag as version.
+func (s *S3PantryState) LoadVersion(ctx context.Context) ([]byte, Version, error) {
+	data, etag, _, err := getObject(ctx, s.s3, s.bucket, s.key, "")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get pantry object from S3: %w", err)
+	}
+	return data, etag, nil
+}
+
+// LoadIfChanged implements Revalidator with a conditional GetObject.
+func (s *S3PantryState) LoadIfChanged(ctx context.Context, version Version) ([]byte, Version, bool, error) {
+	data, etag, changed, err := getObject(ctx, s.s3, s.bucket, s.key, version)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to get pantry object from S3: %w", err)
+	}
+	return data, etag, changed, nil
+}
+
+// Save writes the pantry with an S3 conditional write: If-Match the version's ETag, or If-None-Match
+// for the empty Version, so S3 itself rejects concurrent changes.
+func (s *S3PantryState) Save(ctx context.Context, data []byte, version Version) (Version, error) {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+	if version == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(string(version))
+	}
+
+	resp, err := s.s3.PutObject(ctx, input)
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict") {
+			return "", &ConflictError{Expected: version, Err: err}
+		}
+		return "", fmt.Errorf("failed to put pantry object to S3: %w", err)
+	}
+	return Version(aws.ToString(resp.ETag)), nil
+}
+
 // S3RecipeState implements RecipeState backed by S3
 
 type S3RecipeState struct {
@@ -64,3 +112,44 @@ func (s *S3RecipeState) Load(ctx context.Context) ([]byte, error) {
 	defer resp.Body.Close()
 	return io.ReadAll(resp.Body)
 }
+
+// LoadIfChanged implements Revalidator with a conditional GetObject.
+func (s *S3RecipeState) LoadIfChanged(ctx context.Context, version Version) ([]byte, Version, bool, error) {
+	data, etag, changed, err := getObject(ctx, s.s3, s.bucket, s.key, version)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to get recipe object from S3: %w", err)
+	}
+	return data, etag, changed, nil
+}
+
+// getObject downloads an object with its ETag. With a non-empty ifNoneMatch, it returns changed == false
+// and no data when the object still has that ETag (S3 answers 304 Not Modified without a body).
+func getObject(ctx context.Context, client *s3.Client, bucket, key string, ifNoneMatch Version) ([]byte, Version, bool, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if ifNoneMatch != "" {
+		input.IfNoneMatch = aws.String(string(ifNoneMatch))
+	}
+
+	resp, err := client.GetObject(ctx, input)
+	if err != nil {
+		var respErr interface{ HTTPStatusCode() int }
+		if ifNoneMatch != "" && errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotModified {
+			return nil, ifNoneMatch, false, nil
+		}
+		return nil, "", false, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", false, err
+	}
+	return data, Version(aws.ToString(resp.ETag)), true, nil
+}
+
+var (
+	_ Revalidator = (*S3PantryState)(nil)
+	_ Revalidator = (*S3RecipeState)(nil)
+)
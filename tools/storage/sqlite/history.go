@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pantryagent/tools/storage"
+)
+
+var _ storage.PlanHistory = (*Store)(nil)
+
+// SavePlan appends a plan to the history and returns its ID.
+func (s *Store) SavePlan(ctx context.Context, task, modelID string, plan json.RawMessage) (int64, error) {
+	if !json.Valid(plan) {
+		return 0, fmt.Errorf("save plan: invalid JSON")
+	}
+	res, err := s.db.ExecContext(ctx, `INSERT INTO plans (created_at, task, model_id, plan) VALUES (?, ?, ?, ?)`,
+		now(), task, modelID, compact(plan))
+	if err != nil {
+		return 0, fmt.Errorf("save plan: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// RecentPlans returns up to limit plans, newest first.
+func (s *Store) RecentPlans(ctx context.Context, limit int) ([]storage.PlanRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, created_at, task, model_id, plan FROM plans ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("read plans: %w", err)
+	}
+	defer rows.Close()
+
+	var plans []storage.PlanRecord
+	for rows.Next() {
+		var (
+			p         storage.PlanRecord
+			createdAt string
+			plan      string
+		)
+		if err := rows.Scan(&p.ID, &createdAt, &p.Task, &p.ModelID, &plan); err != nil {
+			return nil, err
+		}
+		if p.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, err
+		}
+		p.Plan = json.RawMessage(plan)
+		plans = append(plans, p)
+	}
+	return plans, rows.Err()
+}
+
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse timestamp %q: %w", s, err)
+	}
+	return t, nil
+}
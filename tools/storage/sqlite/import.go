@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+// Import replaces the pantry and recipes with the JSON documents of the file backend (artifacts/pantry.json
+// and artifacts/recipes.json, at any format version), in one transaction. The pantry version is bumped, so concurrent conditional
+// saves based on the previous pantry fail.
+func (s *Store) Import(ctx context.Context, pantry, recipes []byte) error {
+	items, events, err := decodePantry(pantry)
+	if err != nil {
+		return err
+	}
+	recipeDocs, err := decodeRecipes(recipes)
+	if err != nil {
+		return err
+	}
+
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := replacePantry(ctx, tx, items, events); err != nil {
+			return err
+		}
+		if _, err := bumpPantryVersion(ctx, tx); err != nil {
+			return err
+		}
+		return replaceRecipes(ctx, tx, recipeDocs)
+	})
+}
+
+// ImportFiles imports the pantry and recipes files at the given paths; see Import.
+func (s *Store) ImportFiles(ctx context.Context, pantryPath, recipesPath string) error {
+	pantry, err := os.ReadFile(pantryPath)
+	if err != nil {
+		return fmt.Errorf("read pantry: %w", err)
+	}
+	recipes, err := os.ReadFile(recipesPath)
+	if err != nil {
+		return fmt.Errorf("read recipes: %w", err)
+	}
+	return s.Import(ctx, pantry, recipes)
+}
@@ -0,0 +1,235 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pantryagent/jobs"
+)
+
+// JobQueue is the plan job queue; it implements jobs.Queue, whose documentation describes its methods.
+// Claims and idempotent enqueues run in transactions, so the queue can be shared by several workers.
+type JobQueue struct {
+	store *Store
+}
+
+// Jobs returns the store's job queue.
+func (s *Store) Jobs() *JobQueue {
+	return &JobQueue{store: s}
+}
+
+var _ jobs.Queue = (*JobQueue)(nil)
+
+const jobColumns = `id, COALESCE(idempotency_key, ''), backend, task, status, attempts, max_attempts, created_at, updated_at, run_after, output, error`
+
+func (q *JobQueue) Enqueue(ctx context.Context, req jobs.Request) (jobs.Job, bool, error) {
+	if req.MaxAttempts <= 0 {
+		req.MaxAttempts = jobs.DefaultMaxAttempts
+	}
+	var (
+		job     jobs.Job
+		created bool
+	)
+	err := q.store.withTx(ctx, func(tx *sql.Tx) error {
+		if req.IdempotencyKey != "" {
+			existing, err := scanJob(tx.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE idempotency_key = ?`, req.IdempotencyKey))
+			if err == nil {
+				job = existing
+				return nil
+			}
+			if !errors.Is(err, jobs.ErrNotFound) {
+				return err
+			}
+		}
+
+		id, ts := jobs.NewID(), now()
+		_, err := tx.ExecContext(ctx, `INSERT INTO jobs (id, idempotency_key, backend, task, status, max_attempts, created_at, updated_at, run_after)
+			VALUES (?, NULLIF(?, ''), ?, ?, ?, ?, ?, ?, ?)`,
+			id, req.IdempotencyKey, req.Backend, req.Task, jobs.Pending, req.MaxAttempts, ts, ts, time.Now().UnixNano())
+		if err != nil {
+			return err
+		}
+		created = true
+		job, err = getJob(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return jobs.Job{}, false, fmt.Errorf("enqueue job: %w", err)
+	}
+	return job, created, nil
+}
+
+func (q *JobQueue) Claim(ctx context.Context, at time.Time, busy []string) (jobs.Job, bool, error) {
+	query := `SELECT id FROM jobs WHERE status = ? AND run_after <= ?`
+	args := []any{jobs.Pending, at.UnixNano()}
+	if len(busy) > 0 {
+		query += ` AND backend NOT IN (` + placeholders(len(busy)) + `)`
+		for _, b := range busy {
+			args = append(args, b)
+		}
+	}
+	query += ` ORDER BY seq LIMIT 1`
+
+	var (
+		job jobs.Job
+		ok  bool
+	)
+	err := q.store.withTx(ctx, func(tx *sql.Tx) error {
+		var id string
+		switch err := tx.QueryRowContext(ctx, query, args...).Scan(&id); {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil
+		case err != nil:
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE jobs SET status = ?, attempts = attempts + 1, updated_at = ? WHERE id = ?`,
+			jobs.Running, now(), id); err != nil {
+			return err
+		}
+		var err error
+		job, err = getJob(ctx, tx, id)
+		ok = err == nil
+		return err
+	})
+	if err != nil {
+		return jobs.Job{}, false, fmt.Errorf("claim job: %w", err)
+	}
+	return job, ok, nil
+}
+
+func (q *JobQueue) Complete(ctx context.Context, id, output string) error {
+	return q.finish(ctx, "complete job", id,
+		`UPDATE jobs SET status = ?, output = ?, error = '', updated_at = ? WHERE id = ? AND status = ?`,
+		jobs.Succeeded, output, now(), id, jobs.Running)
+}
+
+func (q *JobQueue) Fail(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	if retryAt.IsZero() {
+		return q.finish(ctx, "fail job", id,
+			`UPDATE jobs SET status = ?, error = ?, updated_at = ? WHERE id = ? AND status = ?`,
+			jobs.Dead, cause.Error(), now(), id, jobs.Running)
+	}
+	return q.finish(ctx, "fail job", id,
+		`UPDATE jobs SET status = ?, error = ?, run_after = ?, updated_at = ? WHERE id = ? AND status = ?`,
+		jobs.Pending, cause.Error(), retryAt.UnixNano(), now(), id, jobs.Running)
+}
+
+func (q *JobQueue) Release(ctx context.Context, id string) error {
+	return q.finish(ctx, "release job", id,
+		`UPDATE jobs SET status = ?, attempts = attempts - 1, updated_at = ? WHERE id = ? AND status = ?`,
+		jobs.Pending, now(), id, jobs.Running)
+}
+
+// finish runs a status update of a running job.
+func (q *JobQueue) finish(ctx context.Context, op, id, query string, args ...any) error {
+	res, err := q.store.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("%s %q: no running job", op, id)
+	}
+	return nil
+}
+
+func (q *JobQueue) Recover(ctx context.Context) (int, error) {
+	res, err := q.store.db.ExecContext(ctx, `UPDATE jobs SET status = ?, attempts = attempts - 1, updated_at = ? WHERE status = ?`,
+		jobs.Pending, now(), jobs.Running)
+	if err != nil {
+		return 0, fmt.Errorf("recover jobs: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (q *JobQueue) Retry(ctx context.Context, id string) (jobs.Job, error) {
+	var job jobs.Job
+	err := q.store.withTx(ctx, func(tx *sql.Tx) error {
+		current, err := getJob(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if current.Status != jobs.Dead {
+			return fmt.Errorf("%w (%s)", jobs.ErrNotDead, current.Status)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE jobs SET status = ?, attempts = 0, run_after = ?, updated_at = ? WHERE id = ?`,
+			jobs.Pending, time.Now().UnixNano(), now(), id); err != nil {
+			return err
+		}
+		job, err = getJob(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return jobs.Job{}, fmt.Errorf("retry job %q: %w", id, err)
+	}
+	return job, nil
+}
+
+func (q *JobQueue) Get(ctx context.Context, id string) (jobs.Job, error) {
+	job, err := scanJob(q.store.db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if err != nil {
+		return jobs.Job{}, fmt.Errorf("get job %q: %w", id, err)
+	}
+	return job, nil
+}
+
+func (q *JobQueue) List(ctx context.Context, opts jobs.ListOptions) ([]jobs.Job, error) {
+	query, args := `SELECT `+jobColumns+` FROM jobs`, []any{}
+	if opts.Status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, opts.Status)
+	}
+	query += ` ORDER BY seq DESC LIMIT ?`
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	args = append(args, limit)
+
+	rows, err := q.store.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list jobs: %w", err)
+	}
+	defer rows.Close()
+	list := []jobs.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, job)
+	}
+	return list, rows.Err()
+}
+
+func getJob(ctx context.Context, tx *sql.Tx, id string) (jobs.Job, error) {
+	return scanJob(tx.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+}
+
+// scanJob scans a row of jobColumns, returning jobs.ErrNotFound for no row.
+func scanJob(row interface{ Scan(...any) error }) (jobs.Job, error) {
+	var (
+		job                  jobs.Job
+		createdAt, updatedAt string
+		runAfter             int64
+	)
+	err := row.Scan(&job.ID, &job.IdempotencyKey, &job.Backend, &job.Task, &job.Status, &job.Attempts, &job.MaxAttempts,
+		&createdAt, &updatedAt, &runAfter, &job.Output, &job.Error)
+	if errors.Is(err, sql.ErrNoRows) {
+		return jobs.Job{}, jobs.ErrNotFound
+	}
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	if job.CreatedAt, err = parseTime(createdAt); err != nil {
+		return jobs.Job{}, err
+	}
+	if job.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return jobs.Job{}, err
+	}
+	job.RunAfter = time.Unix(0, runAfter).UTC()
+	return job, nil
+}
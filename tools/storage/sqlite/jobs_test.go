@@ -0,0 +1,125 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pantryagent/jobs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobQueue(t *testing.T) {
+	ctx := context.Background()
+	q := openTestStore(t).Jobs()
+
+	first, created, err := q.Enqueue(ctx, jobs.Request{Backend: "bedrock", Task: "one", IdempotencyKey: "req-1"})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, jobs.Pending, first.Status)
+	assert.Equal(t, jobs.DefaultMaxAttempts, first.MaxAttempts)
+
+	// The same idempotency key gives back the first job
+	again, created, err := q.Enqueue(ctx, jobs.Request{Backend: "bedrock", Task: "other", IdempotencyKey: "req-1"})
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, again.ID)
+
+	second, _, err := q.Enqueue(ctx, jobs.Request{Backend: "ollama", Task: "two", MaxAttempts: 1})
+	require.NoError(t, err)
+
+	// Busy backends are skipped; jobs are claimed first in, first out
+	now := time.Now()
+	job, ok, err := q.Claim(ctx, now, []string{"bedrock"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, second.ID, job.ID)
+	assert.Equal(t, jobs.Running, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	_, ok, err = q.Claim(ctx, now, []string{"bedrock"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, q.Fail(ctx, second.ID, errors.New("model unavailable"), time.Time{}))
+	job, err = q.Get(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Dead, job.Status)
+	assert.Equal(t, "model unavailable", job.Error)
+
+	// Retries wait for their time
+	job, ok, err = q.Claim(ctx, now, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, first.ID, job.ID)
+	retryAt := now.Add(time.Minute)
+	require.NoError(t, q.Fail(ctx, first.ID, errors.New("throttled"), retryAt))
+	_, ok, err = q.Claim(ctx, now, nil)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	job, ok, err = q.Claim(ctx, retryAt, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Equal(t, "throttled", job.Error)
+	require.NoError(t, q.Complete(ctx, first.ID, "plan"))
+	assert.ErrorContains(t, q.Complete(ctx, first.ID, "plan"), "no running job")
+
+	job, err = q.Get(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Succeeded, job.Status)
+	assert.Equal(t, "plan", job.Output)
+	assert.Empty(t, job.Error)
+
+	// Dead jobs can be retried by hand
+	_, err = q.Retry(ctx, first.ID)
+	assert.ErrorIs(t, err, jobs.ErrNotDead)
+	job, err = q.Retry(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Pending, job.Status)
+	assert.Equal(t, 0, job.Attempts)
+
+	list, err := q.List(ctx, jobs.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, second.ID, list[0].ID)
+	list, err = q.List(ctx, jobs.ListOptions{Status: jobs.Succeeded, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, first.ID, list[0].ID)
+
+	_, err = q.Get(ctx, "nope")
+	assert.ErrorIs(t, err, jobs.ErrNotFound)
+}
+
+func TestJobQueue_Release(t *testing.T) {
+	ctx := context.Background()
+	q := openTestStore(t).Jobs()
+
+	for range 2 {
+		_, _, err := q.Enqueue(ctx, jobs.Request{Backend: "mock", Task: "plan"})
+		require.NoError(t, err)
+	}
+	first, _, err := q.Claim(ctx, time.Now(), nil)
+	require.NoError(t, err)
+	require.NoError(t, q.Release(ctx, first.ID))
+	job, err := q.Get(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Pending, job.Status)
+	assert.Equal(t, 0, job.Attempts, "released attempts do not count")
+
+	// Jobs left running by a previous process are recovered
+	for range 2 {
+		_, ok, err := q.Claim(ctx, time.Now(), nil)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+	n, err := q.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	pending, err := q.List(ctx, jobs.ListOptions{Status: jobs.Pending})
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+}
@@ -0,0 +1,55 @@
+-- Pantry items, kept in file order. The pantry row holds the version used for conditional saves;
+-- its absence means no pantry has been saved yet.
+CREATE TABLE pantry (
+    id      INTEGER PRIMARY KEY CHECK (id = 1),
+    version INTEGER NOT NULL
+);
+
+CREATE TABLE pantry_items (
+    position INTEGER PRIMARY KEY,
+    name     TEXT NOT NULL COLLATE NOCASE,
+    doc      TEXT NOT NULL
+);
+
+CREATE INDEX pantry_items_name ON pantry_items (name);
+
+-- Recipes as their JSON documents, with lookup tables for meal types and ingredients.
+CREATE TABLE recipes (
+    id       TEXT PRIMARY KEY,
+    position INTEGER NOT NULL,
+    name     TEXT NOT NULL,
+    doc      TEXT NOT NULL
+);
+
+CREATE TABLE recipe_meal_types (
+    recipe_id TEXT NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    meal_type TEXT NOT NULL COLLATE NOCASE,
+    PRIMARY KEY (recipe_id, meal_type)
+);
+
+CREATE INDEX recipe_meal_types_meal_type ON recipe_meal_types (meal_type);
+
+CREATE TABLE recipe_ingredients (
+    recipe_id  TEXT NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    ingredient TEXT NOT NULL COLLATE NOCASE,
+    PRIMARY KEY (recipe_id, ingredient)
+);
+
+CREATE INDEX recipe_ingredients_ingredient ON recipe_ingredients (ingredient);
+
+-- Final plans, one row per run.
+CREATE TABLE plans (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TEXT NOT NULL,
+    task       TEXT NOT NULL,
+    model_id   TEXT NOT NULL,
+    plan       TEXT NOT NULL
+);
+
+-- Changes applied to the pantry, oldest first.
+CREATE TABLE pantry_events (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TEXT NOT NULL,
+    source     TEXT NOT NULL,
+    deltas     TEXT NOT NULL
+);
@@ -0,0 +1,5 @@
+-- The pantry's event log (purchases, consumption, ...), replayed on top of pantry_items, oldest first.
+CREATE TABLE pantry_log (
+    position INTEGER PRIMARY KEY,
+    doc      TEXT NOT NULL
+);
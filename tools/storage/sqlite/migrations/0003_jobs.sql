@@ -0,0 +1,19 @@
+-- The plan job queue, see jobs.Queue. seq orders jobs first in, first out; run_after is in Unix
+-- nanoseconds so that due jobs can be found by comparison.
+CREATE TABLE jobs (
+    seq             INTEGER PRIMARY KEY,
+    id              TEXT NOT NULL UNIQUE,
+    idempotency_key TEXT UNIQUE,
+    backend         TEXT NOT NULL,
+    task            TEXT NOT NULL,
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    max_attempts    INTEGER NOT NULL,
+    created_at      TEXT NOT NULL,
+    updated_at      TEXT NOT NULL,
+    run_after       INTEGER NOT NULL,
+    output          TEXT NOT NULL DEFAULT '',
+    error           TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX jobs_due ON jobs (status, run_after);
@@ -0,0 +1,2 @@
+-- Pantry events live in pantry_log, with the pantry they apply to; this table was never written.
+DROP TABLE pantry_events;
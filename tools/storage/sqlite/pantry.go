@@ -0,0 +1,220 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"pantryagent/tools/storage"
+	"pantryagent/tools/storage/format"
+)
+
+// PantryState is the pantry stored in a Store. It implements storage.VersionedPantryState, with a
+// counter bumped by every save as version.
+type PantryState struct {
+	store *Store
+}
+
+var _ storage.VersionedPantryState = (*PantryState)(nil)
+
+// Pantry returns the store's pantry.
+func (s *Store) Pantry() *PantryState {
+	return &PantryState{store: s}
+}
+
+// Load returns the pantry as a current format document, or an error matching fs.ErrNotExist if none
+// was saved.
+func (p *PantryState) Load(ctx context.Context) ([]byte, error) {
+	data, _, err := p.LoadVersion(ctx)
+	return data, err
+}
+
+// LoadVersion returns the pantry with its version.
+func (p *PantryState) LoadVersion(ctx context.Context) ([]byte, storage.Version, error) {
+	var (
+		data    []byte
+		version storage.Version
+	)
+	err := p.store.withTx(ctx, func(tx *sql.Tx) error {
+		v, err := pantryVersion(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if v == "" {
+			return fmt.Errorf("pantry: %w", fs.ErrNotExist)
+		}
+		version = v
+
+		var doc struct {
+			Ingredients json.RawMessage `json:"ingredients"`
+			Events      json.RawMessage `json:"events,omitempty"`
+		}
+		rows, err := tx.QueryContext(ctx, `SELECT doc FROM pantry_items ORDER BY position`)
+		if err != nil {
+			return err
+		}
+		if doc.Ingredients, err = joinDocs(rows, "[", "]"); err != nil {
+			return err
+		}
+		if rows, err = tx.QueryContext(ctx, `SELECT doc FROM pantry_log ORDER BY position`); err != nil {
+			return err
+		}
+		if doc.Events, err = joinDocs(rows, "[", "]"); err != nil {
+			return err
+		}
+		if string(doc.Events) == "[]" {
+			doc.Events = nil
+		}
+		data, err = format.Encode(format.Pantry, doc)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return data, version, nil
+}
+
+// Save replaces the pantry if it is still at version (or was never saved, for the empty Version). Older
+// format versions are upgraded first.
+func (p *PantryState) Save(ctx context.Context, data []byte, version storage.Version) (storage.Version, error) {
+	items, events, err := decodePantry(data)
+	if err != nil {
+		return "", err
+	}
+
+	var saved storage.Version
+	err = p.store.withTx(ctx, func(tx *sql.Tx) error {
+		actual, err := pantryVersion(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if actual != version {
+			return &storage.ConflictError{Expected: version, Actual: actual}
+		}
+		if err := replacePantry(ctx, tx, items, events); err != nil {
+			return err
+		}
+		saved, err = bumpPantryVersion(ctx, tx)
+		return err
+	})
+	return saved, err
+}
+
+// decodePantry returns the ingredients and events of a pantry document, upgraded to the current format
+// version.
+func decodePantry(data []byte) (ingredients, events []json.RawMessage, err error) {
+	doc, _, err := format.Decode(format.Pantry, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var pantry struct {
+		Ingredients []json.RawMessage `json:"ingredients"`
+		Events      []json.RawMessage `json:"events"`
+	}
+	if err := json.Unmarshal(doc, &pantry); err != nil {
+		return nil, nil, fmt.Errorf("parse pantry: %w", err)
+	}
+	return pantry.Ingredients, pantry.Events, nil
+}
+
+func pantryVersion(ctx context.Context, tx *sql.Tx) (storage.Version, error) {
+	var version int64
+	err := tx.QueryRowContext(ctx, `SELECT version FROM pantry WHERE id = 1`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("read pantry version: %w", err)
+	}
+	return storage.Version(strconv.FormatInt(version, 10)), nil
+}
+
+func bumpPantryVersion(ctx context.Context, tx *sql.Tx) (storage.Version, error) {
+	var version int64
+	err := tx.QueryRowContext(ctx, `INSERT INTO pantry (id, version) VALUES (1, 1)
+		ON CONFLICT (id) DO UPDATE SET version = version + 1
+		RETURNING version`).Scan(&version)
+	if err != nil {
+		return "", fmt.Errorf("update pantry version: %w", err)
+	}
+	return storage.Version(strconv.FormatInt(version, 10)), nil
+}
+
+func replacePantry(ctx context.Context, tx *sql.Tx, items, events []json.RawMessage) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM pantry_items`); err != nil {
+		return err
+	}
+	for i, item := range items {
+		var ing struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(item, &ing); err != nil {
+			return fmt.Errorf("parse pantry ingredient %d: %w", i, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO pantry_items (position, name, doc) VALUES (?, ?, ?)`,
+			i, ing.Name, compact(item)); err != nil {
+			return fmt.Errorf("insert pantry ingredient %q: %w", ing.Name, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM pantry_log`); err != nil {
+		return err
+	}
+	for i, event := range events {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO pantry_log (position, doc) VALUES (?, ?)`, i, compact(event)); err != nil {
+			return fmt.Errorf("insert pantry event %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Ingredient returns the pantry entry for the named ingredient (ignoring case) and whether there is one.
+// Entries are the pantry's starting point, before its event log is replayed.
+func (p *PantryState) Ingredient(ctx context.Context, name string) (json.RawMessage, bool, error) {
+	var doc string
+	err := p.store.db.QueryRowContext(ctx, `SELECT doc FROM pantry_items WHERE name = ? ORDER BY position LIMIT 1`, name).Scan(&doc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("read pantry ingredient %q: %w", name, err)
+	}
+	return json.RawMessage(doc), true, nil
+}
+
+// joinDocs closes rows and returns their single doc column joined into prefix[doc,doc,...]suffix.
+func joinDocs(rows *sql.Rows, prefix, suffix string) ([]byte, error) {
+	defer rows.Close()
+	var b strings.Builder
+	b.WriteString(prefix)
+	for n := 0; rows.Next(); n++ {
+		var doc string
+		if err := rows.Scan(&doc); err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	b.WriteString(suffix)
+	return []byte(b.String()), nil
+}
+
+// compact returns doc without insignificant whitespace, or as is if it is not valid JSON.
+func compact(doc json.RawMessage) string {
+	var b bytes.Buffer
+	if err := json.Compact(&b, doc); err != nil {
+		return string(doc)
+	}
+	return b.String()
+}
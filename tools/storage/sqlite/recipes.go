@@ -0,0 +1,159 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"pantryagent/tools/storage"
+	"pantryagent/tools/storage/format"
+)
+
+// RecipeState is the recipe catalog stored in a Store. It implements storage.RecipeState and
+// storage.RecipeFinder.
+type RecipeState struct {
+	store *Store
+}
+
+var (
+	_ storage.RecipeState  = (*RecipeState)(nil)
+	_ storage.RecipeFinder = (*RecipeState)(nil)
+)
+
+// Recipes returns the store's recipe catalog.
+func (s *Store) Recipes() *RecipeState {
+	return &RecipeState{store: s}
+}
+
+// Load returns all recipes as a JSON array, in catalog order.
+func (r *RecipeState) Load(ctx context.Context) ([]byte, error) {
+	rows, err := r.store.db.QueryContext(ctx, `SELECT doc FROM recipes ORDER BY position`)
+	if err != nil {
+		return nil, fmt.Errorf("read recipes: %w", err)
+	}
+	return joinDocs(rows, "[", "]")
+}
+
+// FindByMealTypes returns the recipes with at least one of mealTypes (ignoring case) as a JSON array.
+func (r *RecipeState) FindByMealTypes(ctx context.Context, mealTypes []string) ([]byte, error) {
+	return r.findBy(ctx, "recipe_meal_types", "meal_type", mealTypes)
+}
+
+// FindByIngredients returns the recipes using at least one of ingredients (ignoring case) as a JSON array.
+func (r *RecipeState) FindByIngredients(ctx context.Context, ingredients []string) ([]byte, error) {
+	return r.findBy(ctx, "recipe_ingredients", "ingredient", ingredients)
+}
+
+func (r *RecipeState) findBy(ctx context.Context, table, column string, values []string) ([]byte, error) {
+	if len(values) == 0 {
+		return []byte("[]"), nil
+	}
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	query := fmt.Sprintf(`SELECT doc FROM recipes WHERE id IN (
+		SELECT recipe_id FROM %s WHERE %s IN (%s)
+	) ORDER BY position`, table, column, placeholders(len(values)))
+
+	rows, err := r.store.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("find recipes by %s: %w", column, err)
+	}
+	return joinDocs(rows, "[", "]")
+}
+
+// Replace replaces the catalog with recipes, a recipes document (a JSON array of recipe objects with
+// unique "id"s, optionally in a format envelope).
+func (r *RecipeState) Replace(ctx context.Context, recipes []byte) error {
+	docs, err := decodeRecipes(recipes)
+	if err != nil {
+		return fmt.Errorf("%w: %w", storage.ErrInvalidRecipes, err)
+	}
+	if err := checkRecipeIDs(docs); err != nil {
+		return fmt.Errorf("%w: %w", storage.ErrInvalidRecipes, err)
+	}
+	return r.store.withTx(ctx, func(tx *sql.Tx) error {
+		return replaceRecipes(ctx, tx, docs)
+	})
+}
+
+// decodeRecipes returns the recipes of a recipes document, upgraded to the current format version.
+func decodeRecipes(data []byte) ([]json.RawMessage, error) {
+	doc, _, err := format.Decode(format.Recipes, data)
+	if err != nil {
+		return nil, err
+	}
+	var recipes []json.RawMessage
+	if err := json.Unmarshal(doc, &recipes); err != nil {
+		return nil, fmt.Errorf("parse recipes: %w", err)
+	}
+	return recipes, nil
+}
+
+// checkRecipeIDs checks that every recipe has an id, used by no other recipe.
+func checkRecipeIDs(docs []json.RawMessage) error {
+	seen := make(map[string]bool, len(docs))
+	for i, doc := range docs {
+		var recipe struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(doc, &recipe); err != nil {
+			return fmt.Errorf("parse recipe %d: %w", i, err)
+		}
+		if recipe.ID == "" {
+			return fmt.Errorf("recipe %d: missing id", i)
+		}
+		if seen[recipe.ID] {
+			return fmt.Errorf("recipe %d: duplicate id %q", i, recipe.ID)
+		}
+		seen[recipe.ID] = true
+	}
+	return nil
+}
+
+func replaceRecipes(ctx context.Context, tx *sql.Tx, docs []json.RawMessage) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recipes`); err != nil {
+		return err
+	}
+	for i, doc := range docs {
+		var recipe struct {
+			ID          string   `json:"id"`
+			Name        string   `json:"name"`
+			MealTypes   []string `json:"meal_types"`
+			Ingredients []struct {
+				Name string `json:"name"`
+			} `json:"ingredients"`
+		}
+		if err := json.Unmarshal(doc, &recipe); err != nil {
+			return fmt.Errorf("parse recipe %d: %w", i, err)
+		}
+		if recipe.ID == "" {
+			return fmt.Errorf("recipe %d: missing id", i)
+		}
+
+		if _, err := tx.ExecContext(ctx, `INSERT INTO recipes (id, position, name, doc) VALUES (?, ?, ?, ?)`,
+			recipe.ID, i, recipe.Name, compact(doc)); err != nil {
+			return fmt.Errorf("insert recipe %q: %w", recipe.ID, err)
+		}
+		for _, mealType := range recipe.MealTypes {
+			if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO recipe_meal_types (recipe_id, meal_type) VALUES (?, ?)`,
+				recipe.ID, mealType); err != nil {
+				return fmt.Errorf("insert meal type of recipe %q: %w", recipe.ID, err)
+			}
+		}
+		for _, ing := range recipe.Ingredients {
+			if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO recipe_ingredients (recipe_id, ingredient) VALUES (?, ?)`,
+				recipe.ID, ing.Name); err != nil {
+				return fmt.Errorf("insert ingredient of recipe %q: %w", recipe.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
@@ -0,0 +1,130 @@
+// Package sqlite is an embedded SQL storage backend (pure Go, no cgo) for the pantry with its event log,
+// recipes and plan history. Store.Pantry and Store.Recipes implement the storage interfaces used by the
+// tools, with recipe lookups by meal type and ingredient served from indexes, and Store is the
+// storage.PlanHistory. Store.Jobs is the plan job queue of the jobs package.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/ncruces/go-sqlite3/driver" // registers the "sqlite3" driver
+	_ "github.com/ncruces/go-sqlite3/embed"  // SQLite compiled to WebAssembly, run with wazero
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Store is a SQLite database holding the agent's data.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens (creating it if needed) the database at path and applies pending migrations.
+// Use ":memory:" for a private in-memory database.
+func Open(ctx context.Context, path string) (*Store, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if path != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database %q: %w", path, err)
+	}
+	// A single connection serializes writers in the process and keeps ":memory:" databases shared
+	db.SetMaxOpenConns(1)
+
+	s := &Store{db: db}
+	if err := s.Migrate(ctx); err != nil {
+		db.Close() // nolint: errcheck
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Migrate applies the migrations in migrations/ that the database has not seen yet, each in its own
+// transaction, in file name order. Files are named "<version>_<description>.sql".
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		version, err := strconv.Atoi(strings.SplitN(strings.TrimPrefix(file, "migrations/"), "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("migration %s: invalid version: %w", file, err)
+		}
+		if version <= current {
+			continue
+		}
+		stmts, err := migrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		err = s.withTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, string(stmts)); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, now())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", file, err)
+		}
+		slog.Info("SQLITE: Applied migration", "version", version, "file", file)
+	}
+	return nil
+}
+
+// SchemaVersion returns the version of the last migration applied, 0 for none.
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// withTx runs fn in a transaction, committed if fn succeeds and rolled back otherwise.
+func (s *Store) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback() // nolint: errcheck
+		return err
+	}
+	return tx.Commit()
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,226 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pantryagent/tools"
+	"pantryagent/tools/storage"
+	"pantryagent/tools/storage/format"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPantry  = `{"ingredients": [{"name": "egg", "qty": 12, "unit": "count", "days_left": 10}, {"name": "Milk", "qty": 1, "unit": "L"}]}`
+	testRecipes = `[
+		{"id": "omelet", "name": "Omelet", "meal_types": ["breakfast"], "ingredients": [{"name": "egg", "qty": 3, "unit": "count"}]},
+		{"id": "pancakes", "name": "Pancakes", "meal_types": ["breakfast", "Dessert"], "ingredients": [{"name": "egg", "qty": 1, "unit": "count"}, {"name": "milk", "qty": 0.3, "unit": "L"}]},
+		{"id": "soup", "name": "Soup", "meal_types": ["dinner"], "ingredients": [{"name": "carrot", "qty": 200, "unit": "g"}]}
+	]`
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(context.Background(), ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func recipeIDs(t *testing.T, data []byte) []string {
+	t.Helper()
+	var recipes []struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(data, &recipes))
+	ids := []string{}
+	for _, r := range recipes {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+// decoded returns the data of a format document, upgraded to the current version.
+func decoded(t *testing.T, kind format.Kind, data []byte) string {
+	t.Helper()
+	doc, _, err := format.Decode(kind, data)
+	require.NoError(t, err)
+	return string(doc)
+}
+
+func TestOpen_Migrations(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "pantry.db")
+
+	store, err := Open(ctx, path)
+	require.NoError(t, err)
+	version, err := store.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, version)
+	require.NoError(t, store.Import(ctx, []byte(testPantry), []byte(testRecipes)))
+	require.NoError(t, store.Close())
+
+	// Reopening applies nothing twice and keeps the data
+	store, err = Open(ctx, path)
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.Migrate(ctx))
+	version, err = store.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, version)
+
+	data, err := store.Recipes().Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"omelet", "pancakes", "soup"}, recipeIDs(t, data))
+}
+
+func TestImportFiles_Artifacts(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	require.NoError(t, store.ImportFiles(ctx, "../../../artifacts/pantry.json", "../../../artifacts/recipes.json"))
+
+	for _, tt := range []struct {
+		file string
+		kind format.Kind
+	}{{"pantry.json", format.Pantry}, {"recipes.json", format.Recipes}} {
+		want, err := os.ReadFile(filepath.Join("../../../artifacts", tt.file))
+		require.NoError(t, err)
+		var got []byte
+		if tt.kind == format.Pantry {
+			got, err = store.Pantry().Load(ctx)
+		} else {
+			got, err = store.Recipes().Load(ctx)
+		}
+		require.NoError(t, err)
+		assert.JSONEq(t, decoded(t, tt.kind, want), decoded(t, tt.kind, got), tt.file)
+	}
+}
+
+func TestRecipeState_Find(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	recipes := store.Recipes()
+	require.NoError(t, recipes.Replace(ctx, []byte(testRecipes)))
+
+	tests := []struct {
+		name string
+		find func(context.Context, []string) ([]byte, error)
+		keys []string
+		want []string
+	}{
+		{name: "meal type", find: recipes.FindByMealTypes, keys: []string{"breakfast"}, want: []string{"omelet", "pancakes"}},
+		{name: "meal types ignore case", find: recipes.FindByMealTypes, keys: []string{"dessert", "DINNER"}, want: []string{"pancakes", "soup"}},
+		{name: "ingredient", find: recipes.FindByIngredients, keys: []string{"Milk"}, want: []string{"pancakes"}},
+		{name: "ingredients", find: recipes.FindByIngredients, keys: []string{"egg", "carrot"}, want: []string{"omelet", "pancakes", "soup"}},
+		{name: "no match", find: recipes.FindByIngredients, keys: []string{"tofu"}, want: []string{}},
+		{name: "no keys", find: recipes.FindByMealTypes, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.find(ctx, tt.keys)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, recipeIDs(t, data))
+		})
+	}
+
+	// Replacing the catalog drops the old lookups
+	require.NoError(t, recipes.Replace(ctx, []byte(`[{"id": "toast", "meal_types": ["breakfast"]}]`)))
+	data, err := recipes.FindByMealTypes(ctx, []string{"breakfast"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"toast"}, recipeIDs(t, data))
+
+	assert.ErrorContains(t, recipes.Replace(ctx, []byte(`[{"name": "no id"}]`)), "missing id")
+	err = recipes.Replace(ctx, []byte(`[{"id": "a"}, {"id": "a"}]`))
+	assert.ErrorIs(t, err, storage.ErrInvalidRecipes)
+	assert.ErrorContains(t, err, `duplicate id "a"`)
+}
+
+func TestRecipeState_RecipeGet(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	require.NoError(t, store.Recipes().Replace(ctx, []byte(testRecipes)))
+
+	out, err := tools.NewRecipeGet(store.Recipes()).Run(ctx, map[string]any{"meal_types": []any{"dinner"}})
+	require.NoError(t, err)
+	recipes := out["recipes"].([]map[string]any)
+	require.Len(t, recipes, 1)
+	assert.Equal(t, "soup", recipes[0]["id"])
+}
+
+func TestPantryState(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	pantry := store.Pantry()
+
+	_, err := pantry.Load(ctx)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	v1, err := pantry.Save(ctx, []byte(testPantry), "")
+	require.NoError(t, err)
+	data, version, err := pantry.LoadVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, v1, version)
+	assert.JSONEq(t, testPantry, decoded(t, format.Pantry, data))
+	env, err := format.Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, format.Pantry, env.Kind)
+
+	milk, ok, err := pantry.Ingredient(ctx, "milk")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.JSONEq(t, `{"name": "Milk", "qty": 1, "unit": "L"}`, string(milk))
+	_, ok, err = pantry.Ingredient(ctx, "tofu")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = pantry.Save(ctx, []byte(`{"ingredients": []}`), "")
+	assert.ErrorIs(t, err, storage.ErrConflict)
+
+	// Deltas are merged with optimistic concurrency
+	updated, err := tools.UpdatePantry(ctx, pantry, []tools.IngredientDelta{{Name: "egg", Unit: "count", Qty: -2}}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, updated.Ingredients[0].Qty)
+	data, err = pantry.Load(ctx)
+	require.NoError(t, err)
+	stored, err := tools.ParsePantry(data)
+	require.NoError(t, err)
+	require.Len(t, stored.Events, 1)
+	assert.Equal(t, tools.Consumed, stored.Events[0].Type)
+	assert.Equal(t, 12.0, stored.Ingredients[0].Qty, "the log is replayed on the stored ingredients")
+	_, err = pantry.Save(ctx, []byte(`{"ingredients": []}`), v1)
+	var conflict *storage.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, storage.Version("2"), conflict.Actual)
+
+	// Importing bumps the version too
+	require.NoError(t, store.Import(ctx, []byte(`{"ingredients": []}`), []byte(`[]`)))
+	_, version, err = pantry.LoadVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, storage.Version("3"), version)
+}
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+
+	for _, task := range []string{"first", "second", "third"} {
+		_, err := store.SavePlan(ctx, task, "model", json.RawMessage(`{"summary": "`+task+`"}`))
+		require.NoError(t, err)
+	}
+	_, err := store.SavePlan(ctx, "bad", "model", json.RawMessage(`{`))
+	assert.Error(t, err)
+
+	plans, err := store.RecentPlans(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, plans, 2)
+	assert.Equal(t, "third", plans[0].Task)
+	assert.Equal(t, "second", plans[1].Task)
+	assert.JSONEq(t, `{"summary": "third"}`, string(plans[0].Plan))
+	assert.False(t, plans[0].CreatedAt.IsZero())
+}
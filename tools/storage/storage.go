@@ -2,7 +2,11 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 )
 
 type PantryState interface {
@@ -13,10 +17,51 @@ type RecipeState interface {
 	Load(ctx context.Context) ([]byte, error)
 }
 
-// TestPantryState is a simple in-memory implementation for testing
+// RecipeFinder is implemented by RecipeStates with indexed lookups, so callers can skip loading the
+// whole catalog.
+type RecipeFinder interface {
+	// FindByMealTypes returns the JSON array of recipes with at least one of mealTypes.
+	FindByMealTypes(ctx context.Context, mealTypes []string) ([]byte, error)
+}
+
+// RecipeReplacer is implemented by RecipeStates whose catalog can be replaced, e.g. through the API
+// server.
+type RecipeReplacer interface {
+	// Replace validates recipes, a recipes document, and replaces the catalog with it. Documents failing
+	// validation are reported with ErrInvalidRecipes; other errors come from the storage.
+	Replace(ctx context.Context, recipes []byte) error
+}
+
+// ErrInvalidRecipes is wrapped by the errors of RecipeReplacer.Replace for documents that are not a valid
+// recipe catalog.
+var ErrInvalidRecipes = errors.New("invalid recipes")
+
+// PlanRecord is a final plan kept in a PlanHistory.
+type PlanRecord struct {
+	ID        int64           `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Task      string          `json:"task"`
+	ModelID   string          `json:"model_id"`
+	Plan      json.RawMessage `json:"plan"`
+}
+
+// PlanHistory is implemented by storages keeping the final plans of runs, e.g. the sqlite storage.
+type PlanHistory interface {
+	// SavePlan appends a plan, a JSON document, to the history and returns its ID.
+	SavePlan(ctx context.Context, task, modelID string, plan json.RawMessage) (int64, error)
+	// RecentPlans returns up to limit plans, newest first.
+	RecentPlans(ctx context.Context, limit int) ([]PlanRecord, error)
+}
+
+// TestPantryState is a simple in-memory implementation for testing. It implements VersionedPantryState
+// with versions "v1", "v2", ..., counting saves.
 type TestPantryState struct {
-	data []byte
-	err  error
+	mu      sync.Mutex
+	data    []byte
+	err     error
+	version int
+	// BeforeSave, if set, runs at the start of each Save, e.g. to simulate a concurrent write.
+	BeforeSave func()
 }
 
 func NewTestPantryState(data []byte) *TestPantryState {
@@ -28,10 +73,49 @@ func NewTestPantryStateWithError() *TestPantryState {
 }
 
 func (t *TestPantryState) Load(ctx context.Context) ([]byte, error) {
+	data, _, err := t.LoadVersion(ctx)
+	return data, err
+}
+
+func (t *TestPantryState) LoadVersion(ctx context.Context) ([]byte, Version, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	if t.err != nil {
-		return nil, t.err
+		return nil, "", t.err
 	}
-	return t.data, nil
+	return t.data, t.currentVersion(), nil
+}
+
+func (t *TestPantryState) Save(ctx context.Context, data []byte, version Version) (Version, error) {
+	if t.BeforeSave != nil {
+		t.BeforeSave()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return "", t.err
+	}
+	if actual := t.currentVersion(); actual != version {
+		return "", &ConflictError{Expected: version, Actual: actual}
+	}
+	t.data = data
+	t.version++
+	return t.currentVersion(), nil
+}
+
+// Set replaces the data unconditionally, as a concurrent writer would.
+func (t *TestPantryState) Set(data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.data = data
+	t.version++
+}
+
+func (t *TestPantryState) currentVersion() Version {
+	if t.data == nil && t.version == 0 {
+		return ""
+	}
+	return Version(fmt.Sprintf("v%d", t.version+1))
 }
 
 // TestRecipeState is a simple in-memory implementation for testing
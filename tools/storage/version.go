@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Version identifies a stored revision of a document: an S3 ETag, or a content hash for files.
+// The empty Version stands for a document that does not exist yet.
+type Version string
+
+// ErrConflict is matched (with errors.Is) by the *ConflictError returned when a conditional Save
+// finds the document changed since it was loaded.
+var ErrConflict = errors.New("storage conflict")
+
+// ConflictError reports a conditional Save that lost against a concurrent write.
+type ConflictError struct {
+	// Expected is the version the caller loaded; Actual is the stored one, if known.
+	Expected Version
+	Actual   Version
+	Err      error
+}
+
+func (e *ConflictError) Error() string {
+	if e.Actual == "" {
+		return fmt.Sprintf("%s: expected version %q", ErrConflict, e.Expected)
+	}
+	return fmt.Sprintf("%s: expected version %q, found %q", ErrConflict, e.Expected, e.Actual)
+}
+
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+func (e *ConflictError) Unwrap() error { return e.Err }
+
+// VersionedPantryState is a PantryState supporting optimistic concurrency: LoadVersion returns the data
+// with its version, and Save only writes if the stored version still matches.
+type VersionedPantryState interface {
+	PantryState
+	LoadVersion(ctx context.Context) ([]byte, Version, error)
+	// Save writes data if the stored document is at version (or does not exist, for the empty Version)
+	// and returns the new version. Otherwise it returns a *ConflictError and writes nothing.
+	Save(ctx context.Context, data []byte, version Version) (Version, error)
+}
+
+// contentVersion returns the version of data for stores without native versions.
+func contentVersion(data []byte) Version {
+	sum := sha256.Sum256(data)
+	return Version("sha256:" + hex.EncodeToString(sum[:]))
+}
+
+var (
+	_ VersionedPantryState = (*FilePantryState)(nil)
+	_ VersionedPantryState = (*S3PantryState)(nil)
+	_ VersionedPantryState = (*TestPantryState)(nil)
+)
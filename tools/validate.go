@@ -0,0 +1,151 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+)
+
+// Tool error codes reported in ToolError.Code.
+const (
+	ErrCodeInvalidInput  = "invalid_input"
+	ErrCodeInvalidOutput = "invalid_output"
+	ErrCodeUnknownTool   = "unknown_tool"
+	ErrCodeToolFailed    = "tool_failed"
+	ErrCodeToolRejected  = "tool_rejected"
+)
+
+// ToolError is a tool failure meant to be reported back to the model, which can usually recover from it
+// (e.g. by fixing its arguments), rather than aborting the run.
+type ToolError struct {
+	Tool    string
+	Code    string
+	Message string
+	Hint    string
+	// ValidTools lists the tools the model may call, for errors about unknown tools.
+	ValidTools []string
+	Err        error
+}
+
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("tool %q: %s: %s", e.Tool, e.Code, e.Message)
+}
+
+func (e *ToolError) Unwrap() error { return e.Err }
+
+// Payload returns the structured error sent to the model as the tool result.
+func (e *ToolError) Payload() map[string]any {
+	p := map[string]any{
+		"error":   e.Code,
+		"tool":    e.Tool,
+		"message": e.Message,
+	}
+	if e.Hint != "" {
+		p["hint"] = e.Hint
+	}
+	if len(e.ValidTools) > 0 {
+		p["valid_tools"] = e.ValidTools
+	}
+	return p
+}
+
+// AsToolError reports whether err is (or wraps) a ToolError and returns it.
+func AsToolError(err error) (*ToolError, bool) {
+	var te *ToolError
+	ok := errors.As(err, &te)
+	return te, ok
+}
+
+// NewUnknownToolError reports a call to a tool that does not exist, listing the tools available instead.
+func NewUnknownToolError(name string, available []Tool, err error) *ToolError {
+	valid := make([]string, len(available))
+	for i, t := range available {
+		valid[i] = t.Name()
+	}
+	sort.Strings(valid)
+	return &ToolError{
+		Tool:       name,
+		Code:       ErrCodeUnknownTool,
+		Message:    fmt.Sprintf("there is no tool named %q", name),
+		Hint:       "Call one of the tools listed in valid_tools, spelled exactly as listed.",
+		ValidTools: valid,
+		Err:        err,
+	}
+}
+
+// AsCallError returns err as a *ToolError to report to the model: ToolErrors are returned as is,
+// other errors are wrapped with the given code.
+func AsCallError(name, code string, err error) *ToolError {
+	if toolErr, ok := AsToolError(err); ok {
+		return toolErr
+	}
+	return &ToolError{Tool: name, Code: code, Message: err.Error(), Err: err}
+}
+
+// validatedTool wraps a Tool and validates its inputs and outputs against the tool's schemas.
+type validatedTool struct {
+	Tool
+	input  *jsonschema.Resolved
+	output *jsonschema.Resolved
+}
+
+// WithValidation wraps t so that Run validates the input against t.InputSchema before running the tool,
+// and the output against t.OutputSchema after it. Violations are returned as *ToolError.
+// A nil schema disables the corresponding check.
+func WithValidation(t Tool) (Tool, error) {
+	if _, ok := t.(*validatedTool); ok {
+		return t, nil
+	}
+
+	v := &validatedTool{Tool: t}
+	var err error
+	if s := t.InputSchema(); s != nil {
+		if v.input, err = s.Resolve(nil); err != nil {
+			return nil, fmt.Errorf("resolve input schema of tool %q: %w", t.Name(), err)
+		}
+	}
+	if s := t.OutputSchema(); s != nil {
+		if v.output, err = s.Resolve(nil); err != nil {
+			return nil, fmt.Errorf("resolve output schema of tool %q: %w", t.Name(), err)
+		}
+	}
+	return v, nil
+}
+
+func (v *validatedTool) Run(ctx context.Context, input map[string]any) (map[string]any, error) {
+	if input == nil {
+		input = map[string]any{}
+	}
+	if v.input != nil {
+		if err := v.input.Validate(input); err != nil {
+			return nil, &ToolError{
+				Tool:    v.Name(),
+				Code:    ErrCodeInvalidInput,
+				Message: err.Error(),
+				Hint:    "Fix the arguments so they match the tool's input schema and call the tool again.",
+				Err:     err,
+			}
+		}
+	}
+
+	output, err := v.Tool.Run(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.output != nil {
+		if err := v.output.Validate(output); err != nil {
+			return nil, &ToolError{
+				Tool:    v.Name(),
+				Code:    ErrCodeInvalidOutput,
+				Message: err.Error(),
+				Hint:    "The tool returned malformed data; do not rely on it.",
+				Err:     err,
+			}
+		}
+	}
+	return output, nil
+}
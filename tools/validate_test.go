@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"pantryagent/tools/storage"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubTool returns a fixed output and counts calls.
+type stubTool struct {
+	output map[string]any
+	err    error
+	calls  int
+}
+
+func (t *stubTool) Name() string        { return "stub" }
+func (t *stubTool) Title() string       { return "Stub" }
+func (t *stubTool) Description() string { return "Stub tool for validation tests" }
+func (t *stubTool) InputSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"current_day": {Type: "integer"},
+		},
+	}
+}
+func (t *stubTool) OutputSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"count": {Type: "integer"},
+		},
+		Required: []string{"count"},
+	}
+}
+func (t *stubTool) Run(ctx context.Context, input map[string]any) (map[string]any, error) {
+	t.calls++
+	return t.output, t.err
+}
+
+func TestWithValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       map[string]any
+		output      map[string]any
+		runErr      error
+		expectCode  string
+		expectCalls int
+	}{
+		{
+			name:        "valid input and output",
+			input:       map[string]any{"current_day": 3.0},
+			output:      map[string]any{"count": 1.0},
+			expectCalls: 1,
+		},
+		{
+			name:        "nil input is an empty object",
+			input:       nil,
+			output:      map[string]any{"count": 1},
+			expectCalls: 1,
+		},
+		{
+			name:        "string where integer expected",
+			input:       map[string]any{"current_day": "3"},
+			expectCode:  ErrCodeInvalidInput,
+			expectCalls: 0,
+		},
+		{
+			name:        "fractional integer",
+			input:       map[string]any{"current_day": 1.5},
+			expectCode:  ErrCodeInvalidInput,
+			expectCalls: 0,
+		},
+		{
+			name:        "output missing required property",
+			input:       map[string]any{},
+			output:      map[string]any{"total": 1.0},
+			expectCode:  ErrCodeInvalidOutput,
+			expectCalls: 1,
+		},
+		{
+			name:        "run error is passed through",
+			input:       map[string]any{},
+			runErr:      errors.New("boom"),
+			expectCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubTool{output: tt.output, err: tt.runErr}
+			tool, err := WithValidation(stub)
+			require.NoError(t, err)
+
+			out, err := tool.Run(context.Background(), tt.input)
+			assert.Equal(t, tt.expectCalls, stub.calls)
+
+			switch {
+			case tt.expectCode != "":
+				toolErr, ok := AsToolError(err)
+				require.True(t, ok, "expected a ToolError, got %v", err)
+				assert.Equal(t, tt.expectCode, toolErr.Code)
+				assert.Equal(t, "stub", toolErr.Tool)
+				assert.Equal(t, tt.expectCode, toolErr.Payload()["error"])
+				assert.Nil(t, out)
+			case tt.runErr != nil:
+				assert.ErrorIs(t, err, tt.runErr)
+				_, ok := AsToolError(err)
+				assert.False(t, ok)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tt.output, out)
+			}
+		})
+	}
+}
+
+func TestWithValidation_Idempotent(t *testing.T) {
+	tool, err := WithValidation(&stubTool{})
+	require.NoError(t, err)
+
+	again, err := WithValidation(tool)
+	require.NoError(t, err)
+	assert.Same(t, tool, again)
+}
+
+func TestRegistry_ValidatesTools(t *testing.T) {
+	pantry := storage.NewTestPantryState([]byte(`{"ingredients":[{"name":"milk","qty":1,"unit":"L","perishable_days":2,"added_day":0}]}`))
+	registry, err := NewRegistry(pantry, storage.NewTestRecipeState([]byte(`[]`)))
+	require.NoError(t, err)
+
+	tool, err := registry.GetTool("pantry_get")
+	require.NoError(t, err)
+
+	_, err = tool.Run(context.Background(), map[string]any{"current_day": "5"})
+	toolErr, ok := AsToolError(err)
+	require.True(t, ok, "expected a ToolError, got %v", err)
+	assert.Equal(t, ErrCodeInvalidInput, toolErr.Code)
+	assert.Contains(t, toolErr.Message, "current_day")
+
+	// Expired items have a negative days_left, which the output schema allows
+	out, err := tool.Run(context.Background(), map[string]any{"current_day": 5.0})
+	require.NoError(t, err)
+	ingredients := out["pantry"].(map[string]any)["ingredients"].([]any)
+	assert.Equal(t, -3.0, ingredients[0].(map[string]any)["days_left"])
+}
+
+func TestNewUnknownToolError(t *testing.T) {
+	err := NewUnknownToolError("get_pantry", []Tool{NewRecipeGet(storage.NewTestRecipeState(nil)), NewPantryGet(storage.NewTestPantryState(nil))}, ErrToolNotFound)
+
+	assert.ErrorIs(t, err, ErrToolNotFound)
+	assert.Equal(t, map[string]any{
+		"error":       ErrCodeUnknownTool,
+		"tool":        "get_pantry",
+		"message":     `there is no tool named "get_pantry"`,
+		"hint":        "Call one of the tools listed in valid_tools, spelled exactly as listed.",
+		"valid_tools": []string{"pantry_get", "recipe_get"},
+	}, err.Payload())
+}
+
+func TestAsCallError(t *testing.T) {
+	invalid := &ToolError{Tool: "pantry_get", Code: ErrCodeInvalidInput, Message: "bad"}
+	assert.Same(t, invalid, AsCallError("pantry_get", ErrCodeToolFailed, invalid))
+
+	failed := AsCallError("pantry_get", ErrCodeToolFailed, errors.New("storage unavailable"))
+	assert.Equal(t, ErrCodeToolFailed, failed.Code)
+	assert.Equal(t, "storage unavailable", failed.Message)
+}
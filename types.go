@@ -2,6 +2,8 @@ package pantryagent
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"pantryagent/tools"
 )
@@ -44,29 +46,40 @@ type Meal struct {
 
 // IsValid checks if the MealPlan meets basic validation requirements
 func (mp *MealPlan) IsValid() bool {
+	return mp.Validate() == nil
+}
+
+// Validate checks the MealPlan against the same requirements as IsValid, reporting the first field
+// that fails them, e.g. "days_planned[1].meals[0].servings must be positive".
+func (mp *MealPlan) Validate() error {
 	// Must have at least one day planned
 	if len(mp.DaysPlanned) == 0 {
-		return false
+		return errors.New("days_planned must not be empty")
 	}
 
 	// Each day must have at least one meal
-	for _, day := range mp.DaysPlanned {
+	for i, day := range mp.DaysPlanned {
 		if len(day.Meals) == 0 {
-			return false
+			return fmt.Errorf("days_planned[%d].meals must not be empty", i)
 		}
 
 		// Each meal must have valid fields
-		for _, meal := range day.Meals {
-			if meal.ID == "" || meal.Name == "" || meal.Servings <= 0 {
-				return false
+		for j, meal := range day.Meals {
+			switch {
+			case meal.ID == "":
+				return fmt.Errorf("days_planned[%d].meals[%d].id must not be empty", i, j)
+			case meal.Name == "":
+				return fmt.Errorf("days_planned[%d].meals[%d].name must not be empty", i, j)
+			case meal.Servings <= 0:
+				return fmt.Errorf("days_planned[%d].meals[%d].servings must be positive", i, j)
 			}
 		}
 	}
 
 	// Summary should not be empty
 	if mp.Summary == "" {
-		return false
+		return errors.New("summary must not be empty")
 	}
 
-	return true
+	return nil
 }